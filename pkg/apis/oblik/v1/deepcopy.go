@@ -68,6 +68,9 @@ func (in *ResourcesConfigList) DeepCopyObject() runtime.Object {
 func (in *ResourcesConfigSpec) DeepCopyInto(out *ResourcesConfigSpec) {
 	*out = *in
 	out.TargetRef = in.TargetRef
+	if in.ClusterSelector != nil {
+		out.ClusterSelector = in.ClusterSelector.DeepCopy()
+	}
 	if in.ContainerConfigs != nil {
 		in, out := &in.ContainerConfigs, &out.ContainerConfigs
 		*out = make(map[string]ContainerConfig, len(*in))
@@ -96,6 +99,9 @@ func (in *ResourcesConfigStatus) DeepCopyInto(out *ResourcesConfigStatus) {
 	if !in.LastSyncTime.IsZero() {
 		out.LastSyncTime = in.LastSyncTime
 	}
+	if !in.LastFailureTime.IsZero() {
+		out.LastFailureTime = in.LastFailureTime
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]metav1.Condition, len(*in))
@@ -23,6 +23,9 @@ type ResourceList struct {
 
 	// Memory resource value
 	Memory string `json:"memory,omitempty"`
+
+	// Ephemeral storage resource value
+	EphemeralStorage string `json:"ephemeral-storage,omitempty"`
 }
 
 // ResourcesConfigSpec defines the desired state of ResourcesConfig
@@ -35,12 +38,22 @@ type ResourcesConfigSpec struct {
 	// "merge": Merge with existing annotations, with ResourcesConfig taking precedence
 	AnnotationMode string `json:"annotationMode,omitempty"`
 
+	// ClusterSelector selects by their labels the clusters whose target is configured, in multi-cluster mode,
+	// the target of the cluster running Oblik only when unset
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+
 	// Cron expression to schedule when the recommendations are applied
 	Cron string `json:"cron,omitempty"`
 
+	// IANA timezone in which the cron expression is evaluated, e.g. "Europe/Paris"
+	CronTimezone string `json:"cronTimezone,omitempty"`
+
 	// Maximum random delay added to the cron schedule
 	CronAddRandomMax string `json:"cronAddRandomMax,omitempty"`
 
+	// Maximum lateness of a run missed during an operator restart to be caught up, "0" disables catch-up
+	CronCatchUpDeadline string `json:"cronCatchUpDeadline,omitempty"`
+
 	// If true, Oblik will simulate the updates without applying them
 	DryRun bool `json:"dryRun,omitempty"`
 
@@ -53,16 +66,16 @@ type ResourcesConfigSpec struct {
 	// Memory request recommendation mode: "enforce" or "off"
 	RequestMemoryApplyMode string `json:"requestMemoryApplyMode,omitempty"`
 
-	// CPU limit apply mode: "enforce" or "off"
+	// CPU limit apply mode: "enforce", "off" or "remove"
 	LimitCpuApplyMode string `json:"limitCpuApplyMode,omitempty"`
 
 	// Memory limit apply mode: "enforce" or "off"
 	LimitMemoryApplyMode string `json:"limitMemoryApplyMode,omitempty"`
 
-	// CPU limit calculator algorithm: "ratio" or "margin"
+	// CPU limit calculator algorithm: "ratio", "margin" or "expr"
 	LimitCpuCalculatorAlgo string `json:"limitCpuCalculatorAlgo,omitempty"`
 
-	// Memory limit calculator algorithm: "ratio" or "margin"
+	// Memory limit calculator algorithm: "ratio", "margin" or "expr"
 	LimitMemoryCalculatorAlgo string `json:"limitMemoryCalculatorAlgo,omitempty"`
 
 	// Value used by the CPU limit calculator algorithm
@@ -71,19 +84,31 @@ type ResourcesConfigSpec struct {
 	// Value used by the memory limit calculator algorithm
 	LimitMemoryCalculatorValue string `json:"limitMemoryCalculatorValue,omitempty"`
 
+	// CPU rounding algorithm: "off", "multiple", "power-of-two" or "ladder"
+	CpuRoundingAlgo string `json:"cpuRoundingAlgo,omitempty"`
+
+	// Value used by the CPU rounding algorithm: the multiple, or the comma separated ladder of sizes
+	CpuRoundingValue string `json:"cpuRoundingValue,omitempty"`
+
+	// Memory rounding algorithm: "off", "multiple", "power-of-two" or "ladder"
+	MemoryRoundingAlgo string `json:"memoryRoundingAlgo,omitempty"`
+
+	// Value used by the memory rounding algorithm: the multiple, or the comma separated ladder of sizes
+	MemoryRoundingValue string `json:"memoryRoundingValue,omitempty"`
+
 	// Default CPU request if not provided by the VPA: "off", "minAllowed", "maxAllowed", or value
 	UnprovidedApplyDefaultRequestCpu string `json:"unprovidedApplyDefaultRequestCpu,omitempty"`
 
 	// Default memory request if not provided by the VPA: "off", "minAllowed", "maxAllowed", or value
 	UnprovidedApplyDefaultRequestMemory string `json:"unprovidedApplyDefaultRequestMemory,omitempty"`
 
-	// Algorithm to increase CPU request: "ratio" or "margin"
+	// Algorithm to increase CPU request: "ratio", "margin" or "expr"
 	IncreaseRequestCpuAlgo string `json:"increaseRequestCpuAlgo,omitempty"`
 
 	// Value used to increase CPU request
 	IncreaseRequestCpuValue string `json:"increaseRequestCpuValue,omitempty"`
 
-	// Algorithm to increase memory request: "ratio" or "margin"
+	// Algorithm to increase memory request: "ratio", "margin" or "expr"
 	IncreaseRequestMemoryAlgo string `json:"increaseRequestMemoryAlgo,omitempty"`
 
 	// Value used to increase memory request
@@ -125,25 +150,25 @@ type ResourcesConfigSpec struct {
 	// Maximum allowed memory recommendation value
 	MaxAllowedRecommendationMemory string `json:"maxAllowedRecommendationMemory,omitempty"`
 
-	// Algorithm to calculate minimum CPU request difference: "ratio" or "margin"
+	// Algorithm to calculate minimum CPU request difference: "ratio", "margin" or "expr"
 	MinDiffCpuRequestAlgo string `json:"minDiffCpuRequestAlgo,omitempty"`
 
 	// Value used for minimum CPU request difference calculation
 	MinDiffCpuRequestValue string `json:"minDiffCpuRequestValue,omitempty"`
 
-	// Algorithm to calculate minimum memory request difference: "ratio" or "margin"
+	// Algorithm to calculate minimum memory request difference: "ratio", "margin" or "expr"
 	MinDiffMemoryRequestAlgo string `json:"minDiffMemoryRequestAlgo,omitempty"`
 
 	// Value used for minimum memory request difference calculation
 	MinDiffMemoryRequestValue string `json:"minDiffMemoryRequestValue,omitempty"`
 
-	// Algorithm to calculate minimum CPU limit difference: "ratio" or "margin"
+	// Algorithm to calculate minimum CPU limit difference: "ratio", "margin" or "expr"
 	MinDiffCpuLimitAlgo string `json:"minDiffCpuLimitAlgo,omitempty"`
 
 	// Value used for minimum CPU limit difference calculation
 	MinDiffCpuLimitValue string `json:"minDiffCpuLimitValue,omitempty"`
 
-	// Algorithm to calculate minimum memory limit difference: "ratio" or "margin"
+	// Algorithm to calculate minimum memory limit difference: "ratio", "margin" or "expr"
 	MinDiffMemoryLimitAlgo string `json:"minDiffMemoryLimitAlgo,omitempty"`
 
 	// Value used for minimum memory limit difference calculation
@@ -155,25 +180,73 @@ type ResourcesConfigSpec struct {
 	// Calculate memory limit from CPU limit instead of recommendation
 	MemoryLimitFromCpuEnabled bool `json:"memoryLimitFromCpuEnabled,omitempty"`
 
-	// Algorithm to calculate memory request based on CPU request: "ratio" or "margin"
+	// Algorithm to calculate memory request based on CPU request: "ratio", "margin" or "expr"
 	MemoryRequestFromCpuAlgo string `json:"memoryRequestFromCpuAlgo,omitempty"`
 
 	// Value used for calculating memory request from CPU request
 	MemoryRequestFromCpuValue string `json:"memoryRequestFromCpuValue,omitempty"`
 
-	// Algorithm to calculate memory limit based on CPU limit: "ratio" or "margin"
+	// Algorithm to calculate memory limit based on CPU limit: "ratio", "margin" or "expr"
 	MemoryLimitFromCpuAlgo string `json:"memoryLimitFromCpuAlgo,omitempty"`
 
 	// Value used for calculating memory limit from CPU limit
 	MemoryLimitFromCpuValue string `json:"memoryLimitFromCpuValue,omitempty"`
 
-	// Select which recommendation to apply by default on request: "frugal", "balanced", "peak"
+	// Memory per CPU core used to calculate memory from CPU, e.g. "4Gi"
+	MemoryFromCpuRatio string `json:"memoryFromCpuRatio,omitempty"`
+
+	// CPU value memory is calculated from: "applied" or "recommendation"
+	MemoryFromCpuSource string `json:"memoryFromCpuSource,omitempty"`
+
+	// Runtime whose heap size follows the memory limit: "off", "jvm", "node" or "go"
+	RuntimeProfile string `json:"runtimeProfile,omitempty"`
+
+	// Fraction of the memory limit the runtime heap is sized to, e.g. "0.75"
+	RuntimeHeapRatio string `json:"runtimeHeapRatio,omitempty"`
+
+	// Fraction of the CPU limit GOMAXPROCS is set to, e.g. "1"
+	RuntimeCpuRatio string `json:"runtimeCpuRatio,omitempty"`
+
+	// Env var the JVM heap option is set in, e.g. "JAVA_TOOL_OPTIONS" or "JDK_JAVA_OPTIONS"
+	JVMOptionsEnv string `json:"jvmOptionsEnv,omitempty"`
+
+	// JVM option the heap size is set with: "max-ram-percentage" or "xmx"
+	JVMHeapFlag string `json:"jvmHeapFlag,omitempty"`
+
+	// Shape enforced on the requests and limits: "off", "guaranteed", "burstable" or "besteffort-cpu-limit-free"
+	QoSClass string `json:"qosClass,omitempty"`
+
+	// Minimum fraction the limits exceed the requests by with the "burstable" QoS class, e.g. "0.1"
+	QoSBurstableMargin string `json:"qosBurstableMargin,omitempty"`
+
+	// Manage the ephemeral-storage request and limit: "enforce" or "off"
+	EphemeralStorageApplyMode string `json:"ephemeralStorageApplyMode,omitempty"`
+
+	// Direct ephemeral-storage request and limit values
+	RequestEphemeralStorage string `json:"requestEphemeralStorage,omitempty"`
+	LimitEphemeralStorage   string `json:"limitEphemeralStorage,omitempty"`
+
+	// Minimum and maximum ephemeral-storage request values
+	MinRequestEphemeralStorage string `json:"minRequestEphemeralStorage,omitempty"`
+	MaxRequestEphemeralStorage string `json:"maxRequestEphemeralStorage,omitempty"`
+
+	// Minimum and maximum ephemeral-storage limit values
+	MinLimitEphemeralStorage string `json:"minLimitEphemeralStorage,omitempty"`
+	MaxLimitEphemeralStorage string `json:"maxLimitEphemeralStorage,omitempty"`
+
+	// Ratio of the ephemeral-storage request the limit is set to, e.g. "2"
+	LimitEphemeralStorageRatio string `json:"limitEphemeralStorageRatio,omitempty"`
+
+	// Fraction added to the ephemeral-storage usage for the request, e.g. "0.25"
+	EphemeralStorageHeadroom string `json:"ephemeralStorageHeadroom,omitempty"`
+
+	// Select which recommendation to apply by default on request: "frugal", "balanced", "peak", "replicas", "uncapped" or "pNN"
 	RequestApplyTarget string `json:"requestApplyTarget,omitempty"`
 
-	// Select which recommendation to apply for CPU request: "frugal", "balanced", "peak"
+	// Select which recommendation to apply for CPU request: "frugal", "balanced", "peak", "replicas", "uncapped" or "pNN"
 	RequestCpuApplyTarget string `json:"requestCpuApplyTarget,omitempty"`
 
-	// Select which recommendation to apply for memory request: "frugal", "balanced", "peak"
+	// Select which recommendation to apply for memory request: "frugal", "balanced", "peak", "replicas", "uncapped" or "pNN"
 	RequestMemoryApplyTarget string `json:"requestMemoryApplyTarget,omitempty"`
 
 	// Select which recommendation to apply by default on limit: "auto", "frugal", "balanced", "peak"
@@ -197,6 +270,66 @@ type ResourcesConfigSpec struct {
 	// Allowed scaling direction for memory limit: "both", "up", "down"
 	LimitMemoryScaleDirection string `json:"limitMemoryScaleDirection,omitempty"`
 
+	// Replica count up to which the "replicas" apply target uses the peak recommendation
+	ReplicasPeakMax string `json:"replicasPeakMax,omitempty"`
+
+	// Replica count from which the "replicas" apply target uses the frugal recommendation
+	ReplicasFrugalMin string `json:"replicasFrugalMin,omitempty"`
+
+	// Maximum total CPU requests of all the containers across all the replicas
+	MaxTotalCpu string `json:"maxTotalCpu,omitempty"`
+
+	// Maximum total memory requests of all the containers across all the replicas
+	MaxTotalMemory string `json:"maxTotalMemory,omitempty"`
+
+	// Handling of requests an HPA scales on: "skip", "band", "replicas" or "off"
+	HPAMode string `json:"hpaMode,omitempty"`
+
+	// Maximum relative change of a request an HPA scales on in "band" mode, e.g. "0.1"
+	HPABand string `json:"hpaBand,omitempty"`
+
+	// Management of the pod-level resources from the sum of the containers resources: "enforce" or "off"
+	PodResourcesMode string `json:"podResourcesMode,omitempty"`
+
+	// Fraction added to the sum of the containers resources for the pod-level resources, e.g. "0.1"
+	PodResourcesHeadroom string `json:"podResourcesHeadroom,omitempty"`
+
+	// CPU and memory resources of the containers when the pod-level resources are managed: "unset" or "floor"
+	PodResourcesContainerMode string `json:"podResourcesContainerMode,omitempty"`
+
+	// Reaction to a change of the containers resources made outside Oblik: "ignore", "reapply" or "accept"
+	DriftPolicy string `json:"driftPolicy,omitempty"`
+
+	// Handling of the resources also set by ArgoCD or Flux: "ignore", "warn", "skip", "webhook" or "annotate"
+	GitOpsPolicy string `json:"gitOpsPolicy,omitempty"`
+
+	// Minimum CPU recommendation of the managed VPA
+	VPAMinAllowedCpu string `json:"vpaMinAllowedCpu,omitempty"`
+
+	// Maximum CPU recommendation of the managed VPA
+	VPAMaxAllowedCpu string `json:"vpaMaxAllowedCpu,omitempty"`
+
+	// Minimum memory recommendation of the managed VPA
+	VPAMinAllowedMemory string `json:"vpaMinAllowedMemory,omitempty"`
+
+	// Maximum memory recommendation of the managed VPA
+	VPAMaxAllowedMemory string `json:"vpaMaxAllowedMemory,omitempty"`
+
+	// Resources recommended by the managed VPA, e.g. "cpu,memory"
+	VPAControlledResources string `json:"vpaControlledResources,omitempty"`
+
+	// Values controlled by the managed VPA: "requests-and-limits" or "requests-only"
+	VPAControlledValues string `json:"vpaControlledValues,omitempty"`
+
+	// Scaling mode of the containers in the managed VPA, "off" excluding them from the recommendations: "auto" or "off"
+	VPAMode string `json:"vpaMode,omitempty"`
+
+	// Names of the recommenders of the managed VPA, comma separated, the default recommender when empty
+	VPARecommenders string `json:"vpaRecommenders,omitempty"`
+
+	// Adoption of the VPAs created by the users targeting the workload instead of the managed VPA: "off", "adopt" or "own"
+	VPAAdoption string `json:"vpaAdoption,omitempty"`
+
 	// Direct resource specifications (flat style)
 	RequestCpu    string `json:"requestCpu,omitempty"`
 	RequestMemory string `json:"requestMemory,omitempty"`
@@ -225,6 +358,15 @@ type TargetRef struct {
 
 // ContainerConfig defines container-specific configurations
 type ContainerConfig struct {
+	// Select which recommendation to apply by default on request: "frugal", "balanced", "peak", "replicas", "uncapped" or "pNN"
+	RequestApplyTarget string `json:"requestApplyTarget,omitempty"`
+
+	// Select which recommendation to apply for CPU request: "frugal", "balanced", "peak", "replicas", "uncapped" or "pNN"
+	RequestCpuApplyTarget string `json:"requestCpuApplyTarget,omitempty"`
+
+	// Select which recommendation to apply for memory request: "frugal", "balanced", "peak", "replicas", "uncapped" or "pNN"
+	RequestMemoryApplyTarget string `json:"requestMemoryApplyTarget,omitempty"`
+
 	// Direct resource specifications (flat style)
 	RequestCpu    string `json:"requestCpu,omitempty"`
 	RequestMemory string `json:"requestMemory,omitempty"`
@@ -234,6 +376,13 @@ type ContainerConfig struct {
 	// Kubernetes-native style resource specifications (nested)
 	Request *ResourceList `json:"request,omitempty"`
 	Limit   *ResourceList `json:"limit,omitempty"`
+
+	// CPU and memory requests and limits apply modes of the container: "enforce" or "off"
+	RequestCpuApplyMode    string `json:"requestCpuApplyMode,omitempty"`
+	RequestMemoryApplyMode string `json:"requestMemoryApplyMode,omitempty"`
+	LimitCpuApplyMode      string `json:"limitCpuApplyMode,omitempty"`
+	LimitMemoryApplyMode   string `json:"limitMemoryApplyMode,omitempty"`
+
 	// Minimum CPU limit value
 	MinLimitCpu string `json:"minLimitCpu,omitempty"`
 
@@ -269,6 +418,75 @@ type ContainerConfig struct {
 
 	// Maximum allowed memory recommendation value
 	MaxAllowedRecommendationMemory string `json:"maxAllowedRecommendationMemory,omitempty"`
+
+	// Memory per CPU core used to calculate memory from CPU, e.g. "4Gi"
+	MemoryFromCpuRatio string `json:"memoryFromCpuRatio,omitempty"`
+
+	// CPU value memory is calculated from: "applied" or "recommendation"
+	MemoryFromCpuSource string `json:"memoryFromCpuSource,omitempty"`
+
+	// Runtime whose heap size follows the memory limit: "off", "jvm", "node" or "go"
+	RuntimeProfile string `json:"runtimeProfile,omitempty"`
+
+	// Fraction of the memory limit the runtime heap is sized to, e.g. "0.75"
+	RuntimeHeapRatio string `json:"runtimeHeapRatio,omitempty"`
+
+	// Fraction of the CPU limit GOMAXPROCS is set to, e.g. "1"
+	RuntimeCpuRatio string `json:"runtimeCpuRatio,omitempty"`
+
+	// Env var the JVM heap option is set in, e.g. "JAVA_TOOL_OPTIONS" or "JDK_JAVA_OPTIONS"
+	JVMOptionsEnv string `json:"jvmOptionsEnv,omitempty"`
+
+	// JVM option the heap size is set with: "max-ram-percentage" or "xmx"
+	JVMHeapFlag string `json:"jvmHeapFlag,omitempty"`
+
+	// Shape enforced on the requests and limits: "off", "guaranteed", "burstable" or "besteffort-cpu-limit-free"
+	QoSClass string `json:"qosClass,omitempty"`
+
+	// Minimum fraction the limits exceed the requests by with the "burstable" QoS class, e.g. "0.1"
+	QoSBurstableMargin string `json:"qosBurstableMargin,omitempty"`
+
+	// Manage the ephemeral-storage request and limit: "enforce" or "off"
+	EphemeralStorageApplyMode string `json:"ephemeralStorageApplyMode,omitempty"`
+
+	// Direct ephemeral-storage request and limit values
+	RequestEphemeralStorage string `json:"requestEphemeralStorage,omitempty"`
+	LimitEphemeralStorage   string `json:"limitEphemeralStorage,omitempty"`
+
+	// Minimum and maximum ephemeral-storage request values
+	MinRequestEphemeralStorage string `json:"minRequestEphemeralStorage,omitempty"`
+	MaxRequestEphemeralStorage string `json:"maxRequestEphemeralStorage,omitempty"`
+
+	// Minimum and maximum ephemeral-storage limit values
+	MinLimitEphemeralStorage string `json:"minLimitEphemeralStorage,omitempty"`
+	MaxLimitEphemeralStorage string `json:"maxLimitEphemeralStorage,omitempty"`
+
+	// Ratio of the ephemeral-storage request the limit is set to, e.g. "2"
+	LimitEphemeralStorageRatio string `json:"limitEphemeralStorageRatio,omitempty"`
+
+	// Fraction added to the ephemeral-storage usage for the request, e.g. "0.25"
+	EphemeralStorageHeadroom string `json:"ephemeralStorageHeadroom,omitempty"`
+
+	// Minimum CPU recommendation of the managed VPA
+	VPAMinAllowedCpu string `json:"vpaMinAllowedCpu,omitempty"`
+
+	// Maximum CPU recommendation of the managed VPA
+	VPAMaxAllowedCpu string `json:"vpaMaxAllowedCpu,omitempty"`
+
+	// Minimum memory recommendation of the managed VPA
+	VPAMinAllowedMemory string `json:"vpaMinAllowedMemory,omitempty"`
+
+	// Maximum memory recommendation of the managed VPA
+	VPAMaxAllowedMemory string `json:"vpaMaxAllowedMemory,omitempty"`
+
+	// Resources recommended by the managed VPA, e.g. "cpu,memory"
+	VPAControlledResources string `json:"vpaControlledResources,omitempty"`
+
+	// Values controlled by the managed VPA: "requests-and-limits" or "requests-only"
+	VPAControlledValues string `json:"vpaControlledValues,omitempty"`
+
+	// Scaling mode of the containers in the managed VPA, "off" excluding them from the recommendations: "auto" or "off"
+	VPAMode string `json:"vpaMode,omitempty"`
 }
 
 // ResourcesConfigStatus defines the observed state of ResourcesConfig
@@ -282,6 +500,12 @@ type ResourcesConfigStatus struct {
 	// LastSyncTime is the last time the object was successfully synced with the target resource
 	LastSyncTime metav1.Time `json:"lastSyncTime,omitempty"`
 
+	// ConsecutiveFailures is the number of applies of the recommendations failed since the last successful one
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// LastFailureTime is the last time applying the recommendations to the target resource failed
+	LastFailureTime metav1.Time `json:"lastFailureTime,omitempty"`
+
 	// Conditions represent the latest available observations of an object's state
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
@@ -12,6 +12,8 @@ type CalculatorAlgo int
 const (
 	CalculatorAlgoRatio CalculatorAlgo = iota
 	CalculatorAlgoMargin
+	// CalculatorAlgoExpr evaluates a CEL expression, see ExprVars
+	CalculatorAlgoExpr
 )
 
 type ResourceType int
@@ -22,6 +24,11 @@ const (
 )
 
 func CalculateResourceValue(currentValue resource.Quantity, algo CalculatorAlgo, valueStr string, resourceType ResourceType) resource.Quantity {
+	return CalculateResourceValueWithVars(currentValue, algo, valueStr, resourceType, nil)
+}
+
+// CalculateResourceValueWithVars is CalculateResourceValue exposing the vars to the expr algorithm
+func CalculateResourceValueWithVars(currentValue resource.Quantity, algo CalculatorAlgo, valueStr string, resourceType ResourceType, vars *ExprVars) resource.Quantity {
 	// klog.Infof("CalculateResourceValue: Start - currentValue: %v, algo: %v, valueStr: %s, resourceType: %v", currentValue, algo, valueStr, resourceType)
 
 	if valueStr == "" {
@@ -31,6 +38,8 @@ func CalculateResourceValue(currentValue resource.Quantity, algo CalculatorAlgo,
 
 	newValue := currentValue.DeepCopy()
 	switch algo {
+	case CalculatorAlgoExpr:
+		newValue = calculateExprValue(currentValue, valueStr, resourceType, vars)
 	case CalculatorAlgoRatio:
 		// klog.Infof("CalculateResourceValue: Using CalculatorAlgoRatio")
 		value, err := strconv.ParseFloat(valueStr, 64)
@@ -71,15 +80,19 @@ func CalculateResourceValue(currentValue resource.Quantity, algo CalculatorAlgo,
 	return newValue
 }
 
-func CalculateCpuToMemory(cpu resource.Quantity) resource.Quantity {
+// DefaultMemoryPerCpu is the memory per CPU core used by CalculateCpuToMemory when none is configured (1 milliCPU = 1 MB)
+const DefaultMemoryPerCpu = "1G"
+
+// CalculateCpuToMemory converts CPU to memory with the given memory per CPU core, e.g. 500m with 4Gi gives 2Gi
+func CalculateCpuToMemory(cpu resource.Quantity, memoryPerCpu resource.Quantity) resource.Quantity {
 	// klog.Infof("CalculateCpuToMemory: Start - cpu: %v", cpu)
 
 	// Convert CPU to milli-units to ensure proper calculation
 	cpuMilliValue := cpu.MilliValue()
 	// klog.Infof("CalculateCpuToMemory: CPU in milli-units: %d", cpuMilliValue)
 
-	// Convert CPU to bytes (1 CPU = 1 GB)
-	cpuToMemoryBytes := cpuMilliValue * 1_000_000 // 1 milliCPU = 1 MB = 1_000_000 bytes
+	// Convert CPU to bytes (1 CPU = memoryPerCpu)
+	cpuToMemoryBytes := cpuMilliValue * memoryPerCpu.Value() / 1000
 	// klog.Infof("CalculateCpuToMemory: Converted CPU to memory bytes: %d", cpuToMemoryBytes)
 
 	// Create a new resource.Quantity representing the memory
@@ -0,0 +1,51 @@
+package calculator
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestCalculateResourceValue(t *testing.T) {
+	tests := []struct {
+		name         string
+		current      string
+		algo         CalculatorAlgo
+		value        string
+		resourceType ResourceType
+		want         string
+	}{
+		{name: "cpu ratio", current: "500m", algo: CalculatorAlgoRatio, value: "1.5", resourceType: ResourceTypeCPU, want: "750m"},
+		{name: "memory ratio", current: "1Gi", algo: CalculatorAlgoRatio, value: "2", resourceType: ResourceTypeMemory, want: "2Gi"},
+		{name: "cpu margin", current: "500m", algo: CalculatorAlgoMargin, value: "250m", resourceType: ResourceTypeCPU, want: "750m"},
+		{name: "memory margin", current: "1Gi", algo: CalculatorAlgoMargin, value: "512Mi", resourceType: ResourceTypeMemory, want: "1536Mi"},
+		{name: "no value", current: "500m", algo: CalculatorAlgoRatio, value: "", resourceType: ResourceTypeCPU, want: "500m"},
+		{name: "invalid ratio", current: "500m", algo: CalculatorAlgoRatio, value: "abc", resourceType: ResourceTypeCPU, want: "500m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateResourceValue(resource.MustParse(tt.current), tt.algo, tt.value, tt.resourceType)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("expected %s, got %s", want.String(), got.String())
+			}
+		})
+	}
+}
+
+func TestCalculateCpuToMemory(t *testing.T) {
+	tests := []struct {
+		cpu          string
+		memoryPerCpu string
+		want         string
+	}{
+		{cpu: "500m", memoryPerCpu: "4Gi", want: "2Gi"},
+		{cpu: "2", memoryPerCpu: "1Gi", want: "2Gi"},
+		{cpu: "250m", memoryPerCpu: DefaultMemoryPerCpu, want: "250M"},
+	}
+	for _, tt := range tests {
+		got := CalculateCpuToMemory(resource.MustParse(tt.cpu), resource.MustParse(tt.memoryPerCpu))
+		if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+			t.Errorf("expected %s for %s with %s per CPU, got %s", want.String(), tt.cpu, tt.memoryPerCpu, got.String())
+		}
+	}
+}
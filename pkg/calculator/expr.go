@@ -0,0 +1,162 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+// ExprVars are the values exposed to the expr calculator algorithm, for the resource being calculated
+type ExprVars struct {
+	// Lower, Target and Upper are the VPA recommendation bounds, nil when not available
+	Lower  *resource.Quantity
+	Target *resource.Quantity
+	Upper  *resource.Quantity
+	// Request and Limit are the current values
+	Request resource.Quantity
+	Limit   resource.Quantity
+	// Cpu and Memory are the requests of the container
+	Cpu    resource.Quantity
+	Memory resource.Quantity
+	// Replicas is the replica count of the workload, 0 if unknown
+	Replicas int32
+}
+
+var exprVariableNames = []string{"value", "lower", "target", "upper", "request", "limit", "cpu", "memory", "replicas"}
+
+// quantityLiteral matches numbers with an optional quantity suffix, e.g. 2, 1.5, 500m or 256Mi
+var quantityLiteral = regexp.MustCompile(`\b\d+(\.\d+)?(Ki|Mi|Gi|Ti|Pi|Ei|k|M|G|T|P|E|m)?\b`)
+
+var (
+	exprEnvOnce sync.Once
+	exprEnv     *cel.Env
+	exprEnvErr  error
+
+	exprPrograms sync.Map
+)
+
+func getExprEnv() (*cel.Env, error) {
+	exprEnvOnce.Do(func() {
+		options := []cel.EnvOption{}
+		for _, name := range exprVariableNames {
+			options = append(options, cel.Variable(name, cel.DoubleType))
+		}
+		binary := func(name string, fn func(a, b float64) float64) cel.EnvOption {
+			return cel.Function(name, cel.Overload(name+"_double_double", []*cel.Type{cel.DoubleType, cel.DoubleType}, cel.DoubleType,
+				cel.BinaryBinding(func(a, b ref.Val) ref.Val {
+					return types.Double(fn(float64(a.(types.Double)), float64(b.(types.Double))))
+				})))
+		}
+		options = append(options, binary("max", math.Max), binary("min", math.Min))
+		exprEnv, exprEnvErr = cel.NewEnv(options...)
+	})
+	return exprEnv, exprEnvErr
+}
+
+// normalizeExpr converts the numbers and quantities of the expression to doubles in base units,
+// cores for CPU and bytes for memory, e.g. 256Mi to 268435456.0 and 500m to 0.5
+func normalizeExpr(expr string) (string, error) {
+	var err error
+	normalized := quantityLiteral.ReplaceAllStringFunc(expr, func(literal string) string {
+		quantity, parseErr := resource.ParseQuantity(literal)
+		if parseErr != nil {
+			err = fmt.Errorf("invalid quantity %s: %s", literal, parseErr.Error())
+			return literal
+		}
+		value := strconv.FormatFloat(quantity.AsApproximateFloat64(), 'f', -1, 64)
+		if !strings.Contains(value, ".") {
+			value += ".0"
+		}
+		return value
+	})
+	return normalized, err
+}
+
+func getExprProgram(expr string) (cel.Program, error) {
+	if program, ok := exprPrograms.Load(expr); ok {
+		return program.(cel.Program), nil
+	}
+	env, err := getExprEnv()
+	if err != nil {
+		return nil, err
+	}
+	normalized, err := normalizeExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(normalized)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.DoubleType {
+		return nil, fmt.Errorf("expression must return a number, got %s", ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	exprPrograms.Store(expr, program)
+	return program, nil
+}
+
+// ValidateExpr checks that the expression compiles to a number
+func ValidateExpr(expr string) error {
+	_, err := getExprProgram(expr)
+	return err
+}
+
+func toExprValue(quantity *resource.Quantity) float64 {
+	if quantity == nil {
+		return 0
+	}
+	return quantity.AsApproximateFloat64()
+}
+
+func calculateExprValue(currentValue resource.Quantity, expr string, resourceType ResourceType, vars *ExprVars) resource.Quantity {
+	program, err := getExprProgram(expr)
+	if err != nil {
+		klog.Warningf("Error compiling calculator expression %q: %s", expr, err.Error())
+		return currentValue
+	}
+	if vars == nil {
+		vars = &ExprVars{}
+	}
+	result, _, err := program.Eval(map[string]interface{}{
+		"value":    toExprValue(&currentValue),
+		"lower":    toExprValue(vars.Lower),
+		"target":   toExprValue(vars.Target),
+		"upper":    toExprValue(vars.Upper),
+		"request":  toExprValue(&vars.Request),
+		"limit":    toExprValue(&vars.Limit),
+		"cpu":      toExprValue(&vars.Cpu),
+		"memory":   toExprValue(&vars.Memory),
+		"replicas": float64(vars.Replicas),
+	})
+	if err != nil {
+		klog.Warningf("Error evaluating calculator expression %q: %s", expr, err.Error())
+		return currentValue
+	}
+	value, ok := result.Value().(float64)
+	if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+		klog.Warningf("Invalid result of calculator expression %q: %v", expr, result.Value())
+		return currentValue
+	}
+	value = math.Max(value, 0)
+
+	// round up to millicores or bytes, ignoring floating point noise such as 0.65000000001
+	switch resourceType {
+	case ResourceTypeCPU:
+		return *resource.NewMilliQuantity(int64(math.Ceil(math.Round(value*1e6)/1e3)), resource.DecimalSI)
+	default:
+		return *resource.NewQuantity(int64(math.Ceil(math.Round(value*1e3)/1e3)), resource.BinarySI)
+	}
+}
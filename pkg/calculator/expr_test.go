@@ -0,0 +1,69 @@
+package calculator
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestNormalizeExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "value * 1.5", want: "value * 1.5"},
+		{expr: "max(value, 500m)", want: "max(value, 0.5)"},
+		{expr: "target + 256Mi", want: "target + 268435456.0"},
+		{expr: "value * 2", want: "value * 2.0"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeExpr(tt.expr)
+		if err != nil {
+			t.Fatalf("Error normalizing %q: %s", tt.expr, err.Error())
+		}
+		if got != tt.want {
+			t.Errorf("expected %q for %q, got %q", tt.want, tt.expr, got)
+		}
+	}
+}
+
+func TestValidateExpr(t *testing.T) {
+	if err := ValidateExpr("max(value * 1.2, lower) + replicas"); err != nil {
+		t.Errorf("expected a valid expression, got %s", err.Error())
+	}
+	for _, expr := range []string{"value *", "unknown * 2", "value > 1"} {
+		if err := ValidateExpr(expr); err == nil {
+			t.Errorf("expected %q to be invalid", expr)
+		}
+	}
+}
+
+func TestCalculateExprValue(t *testing.T) {
+	target := resource.MustParse("300m")
+	memoryTarget := resource.MustParse("1Gi")
+	tests := []struct {
+		name         string
+		current      string
+		expr         string
+		resourceType ResourceType
+		vars         *ExprVars
+		want         string
+	}{
+		{name: "cpu ratio", current: "500m", expr: "value * 1.3", resourceType: ResourceTypeCPU, want: "650m"},
+		{name: "cpu floor", current: "50m", expr: "max(value, 100m)", resourceType: ResourceTypeCPU, want: "100m"},
+		{name: "cpu from the target", current: "500m", expr: "target * 2", resourceType: ResourceTypeCPU, vars: &ExprVars{Target: &target}, want: "600m"},
+		{name: "cpu per replica", current: "1", expr: "value / replicas", resourceType: ResourceTypeCPU, vars: &ExprVars{Replicas: 4}, want: "250m"},
+		{name: "memory margin", current: "512Mi", expr: "target + 256Mi", resourceType: ResourceTypeMemory, vars: &ExprVars{Target: &memoryTarget}, want: "1280Mi"},
+		{name: "negative result", current: "500m", expr: "value - 1", resourceType: ResourceTypeCPU, want: "0"},
+		{name: "invalid expression", current: "500m", expr: "value *", resourceType: ResourceTypeCPU, want: "500m"},
+		{name: "division by zero", current: "500m", expr: "value / replicas", resourceType: ResourceTypeCPU, want: "500m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateResourceValueWithVars(resource.MustParse(tt.current), CalculatorAlgoExpr, tt.expr, tt.resourceType, tt.vars)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("expected %s, got %s", want.String(), got.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+type RoundingAlgo int
+
+const (
+	RoundingAlgoOff RoundingAlgo = iota
+	RoundingAlgoMultiple
+	RoundingAlgoPowerOfTwo
+	RoundingAlgoLadder
+)
+
+func ParseRoundingAlgo(value string) (RoundingAlgo, bool) {
+	switch value {
+	case "off":
+		return RoundingAlgoOff, true
+	case "multiple":
+		return RoundingAlgoMultiple, true
+	case "power-of-two":
+		return RoundingAlgoPowerOfTwo, true
+	case "ladder":
+		return RoundingAlgoLadder, true
+	default:
+		klog.Warningf("Unknown rounding algorithm: %s", value)
+		return RoundingAlgoOff, false
+	}
+}
+
+// RoundResourceValue rounds the value up to a multiple of valueStr ("multiple"), to a power of two ("power-of-two"),
+// or to the next size of the comma separated ladder valueStr ("ladder"), values above the ladder being left as is
+func RoundResourceValue(currentValue resource.Quantity, algo RoundingAlgo, valueStr string, resourceType ResourceType) resource.Quantity {
+	return roundResourceValue(currentValue, algo, valueStr, resourceType, false)
+}
+
+// RoundResourceValueDown rounds the value down, for caps which must not be exceeded once rounded. Values below the
+// first step are left as is
+func RoundResourceValueDown(currentValue resource.Quantity, algo RoundingAlgo, valueStr string, resourceType ResourceType) resource.Quantity {
+	return roundResourceValue(currentValue, algo, valueStr, resourceType, true)
+}
+
+func roundResourceValue(currentValue resource.Quantity, algo RoundingAlgo, valueStr string, resourceType ResourceType, down bool) resource.Quantity {
+	value := toRoundingUnits(currentValue, resourceType)
+	if value <= 0 {
+		return currentValue
+	}
+
+	var rounded int64
+	switch algo {
+	case RoundingAlgoMultiple:
+		step, err := resource.ParseQuantity(valueStr)
+		if err != nil {
+			klog.Warningf("Error parsing rounding multiple value: %s", err.Error())
+			return currentValue
+		}
+		stepValue := toRoundingUnits(step, resourceType)
+		if stepValue <= 0 {
+			return currentValue
+		}
+		if down {
+			rounded = value / stepValue * stepValue
+		} else {
+			rounded = (value + stepValue - 1) / stepValue * stepValue
+		}
+	case RoundingAlgoPowerOfTwo:
+		rounded = roundToPowerOfTwo(value, resourceType)
+		if down && rounded > value {
+			rounded = roundToPowerOfTwo(value/2+1, resourceType)
+			if rounded > value {
+				rounded = value
+			}
+		}
+	case RoundingAlgoLadder:
+		ladder := []int64{}
+		for _, size := range strings.Split(valueStr, ",") {
+			quantity, err := resource.ParseQuantity(strings.TrimSpace(size))
+			if err != nil {
+				klog.Warningf("Error parsing rounding ladder value: %s", err.Error())
+				return currentValue
+			}
+			ladder = append(ladder, toRoundingUnits(quantity, resourceType))
+		}
+		sort.Slice(ladder, func(i, j int) bool { return ladder[i] < ladder[j] })
+		rounded = value
+		if down {
+			for _, size := range ladder {
+				if size <= value {
+					rounded = size
+				}
+			}
+			break
+		}
+		for _, size := range ladder {
+			if size >= value {
+				rounded = size
+				break
+			}
+		}
+	default:
+		return currentValue
+	}
+	if rounded <= 0 {
+		return currentValue
+	}
+
+	switch resourceType {
+	case ResourceTypeCPU:
+		return *resource.NewMilliQuantity(rounded, resource.DecimalSI)
+	default:
+		return *resource.NewQuantity(rounded, resource.BinarySI)
+	}
+}
+
+// toRoundingUnits returns millicores for CPU and bytes for memory
+func toRoundingUnits(quantity resource.Quantity, resourceType ResourceType) int64 {
+	if resourceType == ResourceTypeCPU {
+		return quantity.MilliValue()
+	}
+	return quantity.Value()
+}
+
+// roundToPowerOfTwo rounds bytes up to a power of two, and millicores up to a power of two of cores,
+// fractions included (125m, 250m, 500m, 1, 2, 4...)
+func roundToPowerOfTwo(value int64, resourceType ResourceType) int64 {
+	if resourceType == ResourceTypeCPU {
+		cores := float64(value) / 1000
+		return int64(math.Ceil(math.Pow(2, math.Ceil(math.Log2(cores))) * 1000))
+	}
+	power := int64(1)
+	for power < value {
+		power <<= 1
+	}
+	return power
+}
@@ -0,0 +1,41 @@
+package calculator
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestRoundResourceValue(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		algo         RoundingAlgo
+		algoValue    string
+		resourceType ResourceType
+		up           string
+		down         string
+	}{
+		{name: "multiple", value: "250m", algo: RoundingAlgoMultiple, algoValue: "100m", resourceType: ResourceTypeCPU, up: "300m", down: "200m"},
+		{name: "multiple exact", value: "200m", algo: RoundingAlgoMultiple, algoValue: "100m", resourceType: ResourceTypeCPU, up: "200m", down: "200m"},
+		{name: "multiple below the step", value: "50m", algo: RoundingAlgoMultiple, algoValue: "100m", resourceType: ResourceTypeCPU, up: "100m", down: "50m"},
+		{name: "power of two cpu", value: "300m", algo: RoundingAlgoPowerOfTwo, resourceType: ResourceTypeCPU, up: "500m", down: "250m"},
+		{name: "power of two memory", value: "100Mi", algo: RoundingAlgoPowerOfTwo, resourceType: ResourceTypeMemory, up: "128Mi", down: "64Mi"},
+		{name: "power of two exact", value: "128Mi", algo: RoundingAlgoPowerOfTwo, resourceType: ResourceTypeMemory, up: "128Mi", down: "128Mi"},
+		{name: "ladder", value: "300Mi", algo: RoundingAlgoLadder, algoValue: "128Mi,256Mi,512Mi", resourceType: ResourceTypeMemory, up: "512Mi", down: "256Mi"},
+		{name: "ladder below", value: "100Mi", algo: RoundingAlgoLadder, algoValue: "128Mi,256Mi", resourceType: ResourceTypeMemory, up: "128Mi", down: "100Mi"},
+		{name: "ladder above", value: "1Gi", algo: RoundingAlgoLadder, algoValue: "128Mi,256Mi", resourceType: ResourceTypeMemory, up: "1Gi", down: "256Mi"},
+		{name: "off", value: "123m", algo: RoundingAlgoOff, resourceType: ResourceTypeCPU, up: "123m", down: "123m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := resource.MustParse(tt.value)
+			if up := RoundResourceValue(value, tt.algo, tt.algoValue, tt.resourceType); up.Cmp(resource.MustParse(tt.up)) != 0 {
+				t.Errorf("expected %s rounded up to %s, got %s", tt.value, tt.up, up.String())
+			}
+			if down := RoundResourceValueDown(value, tt.algo, tt.algoValue, tt.resourceType); down.Cmp(resource.MustParse(tt.down)) != 0 {
+				t.Errorf("expected %s rounded down to %s, got %s", tt.value, tt.down, down.String())
+			}
+		})
+	}
+}
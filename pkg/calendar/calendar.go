@@ -0,0 +1,211 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/utils"
+	cron "github.com/robfig/cron/v3"
+	"k8s.io/klog/v2"
+)
+
+type FreezeAction int
+
+const (
+	FreezeActionSkip FreezeAction = iota
+	FreezeActionDefer
+)
+
+// Window is an allowed change window, opening on each cron activation and lasting Duration
+type Window struct {
+	Expr     string
+	Schedule cron.Schedule
+	Duration time.Duration
+}
+
+// Blackout is an absolute date range during which no change is allowed
+type Blackout struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Calendar holds the freeze settings resolved for a namespace
+type Calendar struct {
+	Freeze         bool
+	AllowedWindows []Window
+	Blackouts      []Blackout
+	Action         FreezeAction
+	WebhookEnabled bool
+}
+
+// maxNextAllowedIterations bounds the search of the next allowed time
+const maxNextAllowedIterations = 100
+
+// ParseWindows parses allowed windows, separated by ";", each being a 5 fields cron expression followed by a duration,
+// e.g. "0 20 * * 1-5 10h;0 0 * * 0,6 24h". The expressions without CRON_TZ are evaluated in the location
+func ParseWindows(value string, location *time.Location) ([]Window, error) {
+	windows := []Window{}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid allowed window %q: expected a cron expression followed by a duration", entry)
+		}
+		durationStr := fields[len(fields)-1]
+		duration, err := time.ParseDuration(durationStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed window duration %q: %s", durationStr, err.Error())
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("invalid allowed window duration %q: must be positive", durationStr)
+		}
+		expr := strings.Join(fields[:len(fields)-1], " ")
+		spec := expr
+		if location != nil && location != time.Local && !strings.HasPrefix(expr, "CRON_TZ=") && !strings.HasPrefix(expr, "TZ=") {
+			spec = fmt.Sprintf("CRON_TZ=%s %s", location.String(), expr)
+		}
+		schedule, err := utils.ParseCronSchedule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed window cron %q: %s", expr, err.Error())
+		}
+		windows = append(windows, Window{
+			Expr:     expr,
+			Schedule: schedule,
+			Duration: duration,
+		})
+	}
+	return windows, nil
+}
+
+// ParseBlackouts parses blackout date ranges, separated by ",", each being "start/end" or a single day.
+// Bounds are RFC3339 timestamps or "2006-01-02" dates, an end date is inclusive,
+// e.g. "2024-12-15/2025-01-05,2025-05-01"
+func ParseBlackouts(value string, location *time.Location) ([]Blackout, error) {
+	blackouts := []Blackout{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		startStr, endStr, isRange := strings.Cut(entry, "/")
+		if !isRange {
+			endStr = startStr
+		}
+		start, _, err := parseBound(strings.TrimSpace(startStr), location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout start %q: %s", startStr, err.Error())
+		}
+		end, isDate, err := parseBound(strings.TrimSpace(endStr), location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout end %q: %s", endStr, err.Error())
+		}
+		if isDate {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("invalid blackout %q: end must be after start", entry)
+		}
+		blackouts = append(blackouts, Blackout{
+			Start: start,
+			End:   end,
+		})
+	}
+	return blackouts, nil
+}
+
+func parseBound(value string, location *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, location)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+func ParseFreezeAction(value string) FreezeAction {
+	switch value {
+	case "", "skip":
+		return FreezeActionSkip
+	case "defer":
+		return FreezeActionDefer
+	default:
+		klog.Warningf("Unknown freeze-action: %s", value)
+		return FreezeActionSkip
+	}
+}
+
+func (w *Window) contains(t time.Time) bool {
+	// cron Next is strictly after the given time, at second granularity
+	start := w.Schedule.Next(t.Add(-w.Duration).Add(-time.Second))
+	return !start.After(t) && t.Before(start.Add(w.Duration))
+}
+
+func (b *Blackout) contains(t time.Time) bool {
+	return !t.Before(b.Start) && t.Before(b.End)
+}
+
+// Check tells if changes are allowed at the given time, and the reason when they are not
+func (c *Calendar) Check(t time.Time) (bool, string) {
+	if c.Freeze {
+		return false, "changes are frozen"
+	}
+	for _, blackout := range c.Blackouts {
+		if blackout.contains(t) {
+			return false, fmt.Sprintf("blackout from %s to %s", blackout.Start.Format(time.RFC3339), blackout.End.Format(time.RFC3339))
+		}
+	}
+	if len(c.AllowedWindows) == 0 {
+		return true, ""
+	}
+	for _, window := range c.AllowedWindows {
+		if window.contains(t) {
+			return true, ""
+		}
+	}
+	exprs := []string{}
+	for _, window := range c.AllowedWindows {
+		exprs = append(exprs, fmt.Sprintf("%s (%s)", window.Expr, window.Duration))
+	}
+	return false, fmt.Sprintf("outside of allowed windows: %s", strings.Join(exprs, ", "))
+}
+
+// NextAllowed returns the next time from t when changes are allowed, false if there is none
+func (c *Calendar) NextAllowed(t time.Time) (time.Time, bool) {
+	if c.Freeze {
+		return time.Time{}, false
+	}
+	candidate := t
+	for i := 0; i < maxNextAllowedIterations; i++ {
+		if allowed, _ := c.Check(candidate); allowed {
+			return candidate, true
+		}
+		next := time.Time{}
+		for _, blackout := range c.Blackouts {
+			if blackout.contains(candidate) && (next.IsZero() || blackout.End.Before(next)) {
+				next = blackout.End
+			}
+		}
+		if next.IsZero() {
+			for _, window := range c.AllowedWindows {
+				start := window.Schedule.Next(candidate)
+				if start.IsZero() {
+					continue
+				}
+				if next.IsZero() || start.Before(next) {
+					next = start
+				}
+			}
+		}
+		if next.IsZero() || !next.After(candidate) {
+			return time.Time{}, false
+		}
+		candidate = next
+	}
+	return time.Time{}, false
+}
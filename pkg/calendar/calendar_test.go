@@ -0,0 +1,286 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("invalid time %s: %s", value, err.Error())
+	}
+	return parsed
+}
+
+func mustCalendar(t *testing.T, windows, blackouts string) *Calendar {
+	t.Helper()
+	parsedWindows, err := ParseWindows(windows, time.UTC)
+	if err != nil {
+		t.Fatalf("invalid windows %q: %s", windows, err.Error())
+	}
+	parsedBlackouts, err := ParseBlackouts(blackouts, time.UTC)
+	if err != nil {
+		t.Fatalf("invalid blackouts %q: %s", blackouts, err.Error())
+	}
+	return &Calendar{AllowedWindows: parsedWindows, Blackouts: parsedBlackouts}
+}
+
+func TestParseWindows(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		durations []time.Duration
+		wantErr   bool
+	}{
+		{name: "empty", value: "", durations: []time.Duration{}},
+		{name: "single", value: "0 20 * * 1-5 10h", durations: []time.Duration{10 * time.Hour}},
+		{name: "several", value: "0 20 * * 1-5 10h; 0 0 * * 0,6 24h;", durations: []time.Duration{10 * time.Hour, 24 * time.Hour}},
+		{name: "with zone", value: "CRON_TZ=Europe/Paris 0 20 * * * 1h30m", durations: []time.Duration{90 * time.Minute}},
+		{name: "missing duration", value: "10h", wantErr: true},
+		{name: "invalid duration", value: "0 20 * * * 10x", wantErr: true},
+		{name: "negative duration", value: "0 20 * * * -1h", wantErr: true},
+		{name: "invalid cron", value: "0 25 * * * 1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows, err := ParseWindows(tt.value, time.UTC)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", windows)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if len(windows) != len(tt.durations) {
+				t.Fatalf("expected %d windows, got %d", len(tt.durations), len(windows))
+			}
+			for i, window := range windows {
+				if window.Duration != tt.durations[i] {
+					t.Errorf("window %d: expected duration %s, got %s", i, tt.durations[i], window.Duration)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBlackouts(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		blackouts []Blackout
+		wantErr   bool
+	}{
+		{name: "empty", value: "", blackouts: []Blackout{}},
+		{
+			name:  "single day",
+			value: "2025-05-01",
+			blackouts: []Blackout{
+				{Start: mustTime(t, "2025-05-01T00:00:00Z"), End: mustTime(t, "2025-05-02T00:00:00Z")},
+			},
+		},
+		{
+			name:  "inclusive date range",
+			value: "2024-12-15/2025-01-05",
+			blackouts: []Blackout{
+				{Start: mustTime(t, "2024-12-15T00:00:00Z"), End: mustTime(t, "2025-01-06T00:00:00Z")},
+			},
+		},
+		{
+			name:  "timestamp range and day",
+			value: "2025-03-01T10:00:00Z/2025-03-01T12:00:00Z, 2025-05-01",
+			blackouts: []Blackout{
+				{Start: mustTime(t, "2025-03-01T10:00:00Z"), End: mustTime(t, "2025-03-01T12:00:00Z")},
+				{Start: mustTime(t, "2025-05-01T00:00:00Z"), End: mustTime(t, "2025-05-02T00:00:00Z")},
+			},
+		},
+		{name: "invalid start", value: "2025-13-01", wantErr: true},
+		{name: "invalid end", value: "2025-01-01/tomorrow", wantErr: true},
+		{name: "end before start", value: "2025-01-05/2025-01-01", wantErr: true},
+		{name: "empty timestamp range", value: "2025-03-01T10:00:00Z/2025-03-01T10:00:00Z", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blackouts, err := ParseBlackouts(tt.value, time.UTC)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", blackouts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if len(blackouts) != len(tt.blackouts) {
+				t.Fatalf("expected %d blackouts, got %d", len(tt.blackouts), len(blackouts))
+			}
+			for i, blackout := range blackouts {
+				if !blackout.Start.Equal(tt.blackouts[i].Start) || !blackout.End.Equal(tt.blackouts[i].End) {
+					t.Errorf("blackout %d: expected %v, got %v", i, tt.blackouts[i], blackout)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFreezeAction(t *testing.T) {
+	tests := map[string]FreezeAction{
+		"":        FreezeActionSkip,
+		"skip":    FreezeActionSkip,
+		"defer":   FreezeActionDefer,
+		"unknown": FreezeActionSkip,
+	}
+	for value, expected := range tests {
+		if action := ParseFreezeAction(value); action != expected {
+			t.Errorf("ParseFreezeAction(%q): expected %d, got %d", value, expected, action)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// weeknights from 20:00 for 10 hours, and the whole weekend days
+	windows := "CRON_TZ=UTC 0 20 * * 1-5 10h;CRON_TZ=UTC 0 0 * * 0,6 24h"
+	tests := []struct {
+		name     string
+		calendar *Calendar
+		at       string
+		allowed  bool
+	}{
+		{name: "no constraint", calendar: mustCalendar(t, "", ""), at: "2025-03-04T12:00:00Z", allowed: true},
+		{name: "frozen", calendar: &Calendar{Freeze: true}, at: "2025-03-04T12:00:00Z", allowed: false},
+		{name: "weekday in window", calendar: mustCalendar(t, windows, ""), at: "2025-03-04T21:00:00Z", allowed: true},
+		{name: "window spanning midnight", calendar: mustCalendar(t, windows, ""), at: "2025-03-05T05:59:59Z", allowed: true},
+		{name: "window end is excluded", calendar: mustCalendar(t, windows, ""), at: "2025-03-05T06:00:00Z", allowed: false},
+		{name: "window start is included", calendar: mustCalendar(t, windows, ""), at: "2025-03-04T20:00:00Z", allowed: true},
+		{name: "weekday outside window", calendar: mustCalendar(t, windows, ""), at: "2025-03-04T12:00:00Z", allowed: false},
+		{name: "weekend", calendar: mustCalendar(t, windows, ""), at: "2025-03-08T12:00:00Z", allowed: true},
+		{name: "blackout", calendar: mustCalendar(t, "", "2025-03-04"), at: "2025-03-04T12:00:00Z", allowed: false},
+		{name: "after blackout", calendar: mustCalendar(t, "", "2025-03-04"), at: "2025-03-05T00:00:00Z", allowed: true},
+		{name: "blackout in window", calendar: mustCalendar(t, windows, "2025-03-08"), at: "2025-03-08T12:00:00Z", allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := tt.calendar.Check(mustTime(t, tt.at))
+			if allowed != tt.allowed {
+				t.Fatalf("expected allowed %v, got %v (reason: %s)", tt.allowed, allowed, reason)
+			}
+			if allowed != (reason == "") {
+				t.Errorf("expected a reason only when not allowed, got %q", reason)
+			}
+		})
+	}
+}
+
+func TestNextAllowed(t *testing.T) {
+	windows := "CRON_TZ=UTC 0 20 * * 1-5 10h;CRON_TZ=UTC 0 0 * * 0,6 24h"
+	tests := []struct {
+		name     string
+		calendar *Calendar
+		at       string
+		next     string
+		found    bool
+	}{
+		{name: "already allowed", calendar: mustCalendar(t, windows, ""), at: "2025-03-04T21:00:00Z", next: "2025-03-04T21:00:00Z", found: true},
+		{name: "next window", calendar: mustCalendar(t, windows, ""), at: "2025-03-04T12:00:00Z", next: "2025-03-04T20:00:00Z", found: true},
+		{name: "friday to weekend", calendar: mustCalendar(t, "CRON_TZ=UTC 0 0 * * 0,6 24h", ""), at: "2025-03-07T12:00:00Z", next: "2025-03-08T00:00:00Z", found: true},
+		{name: "end of blackout", calendar: mustCalendar(t, "", "2025-03-04/2025-03-05"), at: "2025-03-04T12:00:00Z", next: "2025-03-06T00:00:00Z", found: true},
+		{name: "window after blackout", calendar: mustCalendar(t, windows, "2025-03-04/2025-03-05"), at: "2025-03-04T12:00:00Z", next: "2025-03-06T00:00:00Z", found: true},
+		{name: "blackout ending outside windows", calendar: mustCalendar(t, windows, "2025-03-04T12:00:00Z/2025-03-04T22:00:00Z"), at: "2025-03-04T13:00:00Z", next: "2025-03-04T22:00:00Z", found: true},
+		{name: "blackout ending before window", calendar: mustCalendar(t, windows, "2025-03-04T08:00:00Z/2025-03-04T14:00:00Z"), at: "2025-03-04T12:00:00Z", next: "2025-03-04T20:00:00Z", found: true},
+		{name: "frozen", calendar: &Calendar{Freeze: true}, at: "2025-03-04T12:00:00Z", found: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, found := tt.calendar.NextAllowed(mustTime(t, tt.at))
+			if found != tt.found {
+				t.Fatalf("expected found %v, got %v (%s)", tt.found, found, next)
+			}
+			if found && !next.Equal(mustTime(t, tt.next)) {
+				t.Errorf("expected %s, got %s", tt.next, next.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestLoadCalendar(t *testing.T) {
+	t.Setenv("OBLIK_FREEZE_ALLOWED_WINDOWS", "0 20 * * * 1h")
+	t.Setenv("OBLIK_FREEZE_BLACKOUT_DATES", "2025-05-01")
+	t.Setenv("OBLIK_FREEZE_ACTION", "defer")
+
+	cal := LoadCalendar(nil, time.UTC)
+	if cal.Freeze || len(cal.AllowedWindows) != 1 || len(cal.Blackouts) != 1 || cal.Action != FreezeActionDefer {
+		t.Fatalf("unexpected cluster calendar: %+v", cal)
+	}
+
+	// namespace windows and action override the cluster ones, freeze and blackouts are cumulated
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				constants.PREFIX + "freeze":                 "true",
+				constants.PREFIX + "freeze-allowed-windows": "0 20 * * * 1h;0 8 * * * 1h",
+				constants.PREFIX + "freeze-blackout-dates":  "2025-12-25",
+				constants.PREFIX + "freeze-action":          "skip",
+			},
+		},
+	}
+	cal = LoadCalendar(namespace, time.UTC)
+	if !cal.Freeze || len(cal.AllowedWindows) != 2 || len(cal.Blackouts) != 2 || cal.Action != FreezeActionSkip {
+		t.Fatalf("unexpected namespace calendar: %+v", cal)
+	}
+}
+
+func TestLocation(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatalf("unknown location: %s", err.Error())
+	}
+	t.Setenv("OBLIK_FREEZE_ALLOWED_WINDOWS", "0 9 * * 1-5 8h")
+	windowsCalendar := LoadCalendar(nil, paris)
+	t.Setenv("OBLIK_FREEZE_ALLOWED_WINDOWS", "")
+	t.Setenv("OBLIK_FREEZE_BLACKOUT_DATES", "2025-05-01")
+	blackoutsCalendar := LoadCalendar(nil, paris)
+
+	tests := []struct {
+		name     string
+		calendar *Calendar
+		at       string
+		allowed  bool
+	}{
+		// 09:00 in Paris is 08:00 UTC in winter, and 07:00 UTC in summer
+		{name: "window start in winter", calendar: windowsCalendar, at: "2025-03-04T08:00:00Z", allowed: true},
+		{name: "before window in winter", calendar: windowsCalendar, at: "2025-03-04T07:59:59Z", allowed: false},
+		{name: "window end in winter", calendar: windowsCalendar, at: "2025-03-04T16:00:00Z", allowed: false},
+		{name: "window start in summer", calendar: windowsCalendar, at: "2025-06-03T07:00:00Z", allowed: true},
+		{name: "before window in summer", calendar: windowsCalendar, at: "2025-06-03T06:59:59Z", allowed: false},
+		// the blackout day is from midnight to midnight in Paris, 22:00 UTC the day before in summer
+		{name: "before blackout", calendar: blackoutsCalendar, at: "2025-04-30T21:59:59Z", allowed: true},
+		{name: "blackout start", calendar: blackoutsCalendar, at: "2025-04-30T22:00:00Z", allowed: false},
+		{name: "blackout end", calendar: blackoutsCalendar, at: "2025-05-01T21:59:59Z", allowed: false},
+		{name: "after blackout", calendar: blackoutsCalendar, at: "2025-05-01T22:00:00Z", allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := tt.calendar.Check(mustTime(t, tt.at))
+			if allowed != tt.allowed {
+				t.Errorf("expected allowed %v, got %v (reason: %s)", tt.allowed, allowed, reason)
+			}
+		})
+	}
+
+	// the zone of a window is kept
+	windows, err := ParseWindows("CRON_TZ=UTC 0 9 * * * 1h", paris)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if !windows[0].contains(mustTime(t, "2025-06-03T09:30:00Z")) {
+		t.Errorf("expected the window to be evaluated in UTC")
+	}
+}
@@ -0,0 +1,78 @@
+package calendar
+
+import (
+	"context"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/constants"
+	"github.com/SocialGouv/oblik/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LoadCalendar resolves the freeze calendar from the cluster-level environment and the namespace annotations.
+// Freeze and blackouts are cumulated, namespace allowed windows, action and webhook settings override cluster ones.
+// The windows and the blackout dates are evaluated in the location, the cron timezone of the workload
+func LoadCalendar(namespace *corev1.Namespace, location *time.Location) *Calendar {
+	annotations := map[string]string{}
+	if namespace != nil && namespace.Annotations != nil {
+		annotations = namespace.Annotations
+	}
+	getAnnotation := func(key string) string {
+		return annotations[constants.PREFIX+key]
+	}
+
+	cal := &Calendar{}
+
+	if utils.GetEnv("OBLIK_FREEZE", "false") == "true" || getAnnotation("freeze") == "true" {
+		cal.Freeze = true
+	}
+
+	windowsStr := getAnnotation("freeze-allowed-windows")
+	if windowsStr == "" {
+		windowsStr = utils.GetEnv("OBLIK_FREEZE_ALLOWED_WINDOWS", "")
+	}
+	windows, err := ParseWindows(windowsStr, location)
+	if err != nil {
+		klog.Warningf("Error parsing freeze-allowed-windows: %s, error: %s", windowsStr, err.Error())
+	} else {
+		cal.AllowedWindows = windows
+	}
+
+	for _, blackoutsStr := range []string{utils.GetEnv("OBLIK_FREEZE_BLACKOUT_DATES", ""), getAnnotation("freeze-blackout-dates")} {
+		blackouts, err := ParseBlackouts(blackoutsStr, location)
+		if err != nil {
+			klog.Warningf("Error parsing freeze-blackout-dates: %s, error: %s", blackoutsStr, err.Error())
+			continue
+		}
+		cal.Blackouts = append(cal.Blackouts, blackouts...)
+	}
+
+	action := getAnnotation("freeze-action")
+	if action == "" {
+		action = utils.GetEnv("OBLIK_FREEZE_ACTION", "skip")
+	}
+	cal.Action = ParseFreezeAction(action)
+
+	webhookEnabled := getAnnotation("freeze-webhook-enabled")
+	if webhookEnabled == "" {
+		webhookEnabled = utils.GetEnv("OBLIK_FREEZE_WEBHOOK_ENABLED", "false")
+	}
+	cal.WebhookEnabled = webhookEnabled == "true"
+
+	return cal
+}
+
+// GetNamespaceCalendar fetches the namespace and resolves its freeze calendar in the location,
+// falling back to the cluster-level calendar when the namespace can't be read
+func GetNamespaceCalendar(ctx context.Context, reader ctrlclient.Reader, namespaceName string, location *time.Location) *Calendar {
+	namespace := &corev1.Namespace{}
+	err := reader.Get(ctx, types.NamespacedName{Name: namespaceName}, namespace)
+	if err != nil {
+		klog.Warningf("Error fetching namespace %s for freeze calendar: %s", namespaceName, err.Error())
+		return LoadCalendar(nil, location)
+	}
+	return LoadCalendar(namespace, location)
+}
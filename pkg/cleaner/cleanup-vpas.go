@@ -7,6 +7,7 @@ import (
 
 	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/target"
 	ovpa "github.com/SocialGouv/oblik/pkg/vpa"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -28,7 +29,7 @@ func CleanUpVPAs(ctx context.Context, kubeClients *client.KubeClients) {
 	}
 
 	for _, vpa := range vpaList.Items {
-		if strings.HasPrefix(vpa.Name, config.VpaPrefix) {
+		if strings.HasPrefix(vpa.Name, config.VpaPrefix) || vpa.Labels[ovpa.AdoptedLabel] != "" {
 			if err := processVPA(ctx, kubeClients, &vpa); err != nil {
 				klog.Errorf("Error processing VPA %s: %s\n", vpa.Name, err.Error())
 			}
@@ -40,8 +41,7 @@ func processVPA(ctx context.Context, kubeClients *client.KubeClients, vpa *vpav1
 	targetRef := vpa.Spec.TargetRef
 	if targetRef == nil {
 		// Delete the VPA if TargetRef is nil
-		ovpa.DeleteVPA(kubeClients.VpaClientset, vpa)
-		return nil
+		return ovpa.DeleteVPA(kubeClients, vpa)
 	}
 
 	// Create a discovery client
@@ -83,15 +83,19 @@ func processVPA(ctx context.Context, kubeClients *client.KubeClients, vpa *vpav1
 	}
 
 	// Attempt to get the target resource
-	_, err = resourceInterface.Get(ctx, targetRef.Name, metav1.GetOptions{})
+	targetResource, err := resourceInterface.Get(ctx, targetRef.Name, metav1.GetOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Target does not exist; delete the VPA
-			ovpa.DeleteVPA(kubeClients.VpaClientset, vpa)
-			return nil
+			return ovpa.DeleteVPA(kubeClients, vpa)
 		} else {
 			return fmt.Errorf("error fetching target: %w", err)
 		}
 	}
+
+	// Target is no longer enabled, e.g. its namespace was excluded or unlabelled while no operator was running
+	if !target.IsWorkloadEnabled(ctx, kubeClients, targetResource) {
+		return ovpa.DeleteVPA(kubeClients, vpa)
+	}
 	return nil
 }
@@ -5,7 +5,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/SocialGouv/oblik/pkg/calendar"
 	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
 	"github.com/SocialGouv/oblik/pkg/target"
@@ -26,6 +28,7 @@ func NewCommand() *cobra.Command {
 	var namespace string
 	var all bool
 	var force bool
+	var ignoreFreeze bool
 	var showVersion bool
 
 	var Command = &cobra.Command{
@@ -41,7 +44,7 @@ func NewCommand() *cobra.Command {
 				fmt.Printf("Oblik version: %s\n", Version)
 				return
 			}
-			if err := Run(namespace, name, selector, all, force); err != nil {
+			if err := Run(namespace, name, selector, all, force, ignoreFreeze); err != nil {
 				os.Exit(1)
 			}
 		},
@@ -60,11 +63,12 @@ func NewCommand() *cobra.Command {
 	flags.StringVarP(&namespace, "namespace", "n", "", "Namespace containing VPAs")
 	flags.BoolVarP(&all, "all", "a", false, "Process all namespaces")
 	flags.BoolVarP(&force, "force", "f", false, "Force to run on not enabled")
+	flags.BoolVar(&ignoreFreeze, "ignore-freeze", false, "Apply changes regardless of freeze calendars")
 	flags.BoolVar(&showVersion, "version", false, "Show version")
 	return Command
 }
 
-func Run(namespace string, resourceName string, selector string, all bool, force bool) error {
+func Run(namespace string, resourceName string, selector string, all bool, force bool, ignoreFreeze bool) error {
 	// Validate input parameters
 	if resourceName != "" && namespace == "" {
 		klog.Fatalf("Namespace must be specified when name is provided")
@@ -76,12 +80,14 @@ func Run(namespace string, resourceName string, selector string, all bool, force
 		return nil
 	}
 
+	config.ValidateDefaultExprs()
+
 	kubeClients := client.NewKubeClients()
 
 	if resourceName != "" {
 		vpaResource := getVPA(kubeClients.VpaClientset, namespace, resourceName)
 		if vpaResource != nil {
-			if err := processVPA(kubeClients, vpaResource, force); err != nil {
+			if err := processVPA(kubeClients, vpaResource, force, ignoreFreeze); err != nil {
 				return err
 			}
 		}
@@ -93,7 +99,7 @@ func Run(namespace string, resourceName string, selector string, all bool, force
 			vpaResources = listVPAs(kubeClients.VpaClientset, namespace, selector)
 		}
 		for _, vpaResource := range vpaResources {
-			if err := processVPA(kubeClients, &vpaResource, force); err != nil {
+			if err := processVPA(kubeClients, &vpaResource, force, ignoreFreeze); err != nil {
 				return err
 			}
 		}
@@ -143,13 +149,19 @@ func listAllVPAs(vpaClient *vpaclientset.Clientset, selector string) []vpa.Verti
 	return vpaList.Items
 }
 
-func processVPA(kubeClients *client.KubeClients, vpaResource *vpa.VerticalPodAutoscaler, force bool) error {
-	configurable := config.CreateConfigurable(vpaResource)
-	scfg := config.CreateStrategyConfig(configurable)
+func processVPA(kubeClients *client.KubeClients, vpaResource *vpa.VerticalPodAutoscaler, force bool, ignoreFreeze bool) error {
+	scfg := kubeClients.CreateStrategyConfig(vpaResource)
 	if !scfg.Enabled && !force {
 		klog.Infof("Skipping VPA: %s/%s\n", vpaResource.Namespace, vpaResource.Name)
 		return nil
 	}
+	if !ignoreFreeze {
+		cal := calendar.GetNamespaceCalendar(context.Background(), kubeClients.Reader, vpaResource.Namespace, scfg.GetLocation())
+		if allowed, reason := cal.Check(time.Now()); !allowed {
+			klog.Infof("Skipping VPA: %s/%s, %s\n", vpaResource.Namespace, vpaResource.Name, reason)
+			return nil
+		}
+	}
 	klog.Infof("Processing VPA: %s/%s\n", vpaResource.Namespace, vpaResource.Name)
 	return target.ApplyVPARecommendations(kubeClients, vpaResource, scfg)
 }
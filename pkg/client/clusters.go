@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/constants"
+	"github.com/SocialGouv/oblik/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+// ClusterNameAnnotation overrides the name of a remote cluster, the name of its Secret by default
+const ClusterNameAnnotation = constants.PREFIX + "cluster-name"
+
+// kubeconfigKey is the key of the kubeconfig in the Secrets of the remote clusters
+const kubeconfigKey = "kubeconfig"
+
+// LoadRemoteClusters creates the clients of the remote clusters from the kubeconfig Secrets of the operator namespace,
+// the labels of a Secret being the labels of its cluster. A cluster whose Secret is invalid is left out
+func LoadRemoteClusters(ctx context.Context, local *KubeClients) []*KubeClients {
+	namespace := utils.GetEnv("NAMESPACE", "default")
+	clusters := []*KubeClients{}
+	names := map[string]bool{local.Cluster: true}
+	for _, secretName := range config.GetClusterSecrets() {
+		cluster, err := loadRemoteCluster(ctx, local, namespace, secretName)
+		if err != nil {
+			klog.Errorf("Error loading cluster from secret %s/%s: %s", namespace, secretName, err.Error())
+			continue
+		}
+		if names[cluster.Cluster] {
+			klog.Errorf("Error loading cluster from secret %s/%s: cluster %s is already managed", namespace, secretName, cluster.Cluster)
+			continue
+		}
+		names[cluster.Cluster] = true
+		klog.Infof("Managing cluster %s", cluster.Cluster)
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+func loadRemoteCluster(ctx context.Context, local *KubeClients, namespace, secretName string) (*KubeClients, error) {
+	secret, err := local.Clientset.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	kubeconfig, ok := secret.Data[kubeconfigKey]
+	if !ok {
+		return nil, fmt.Errorf("no %s key", kubeconfigKey)
+	}
+	conf, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	name := secret.Name
+	if annotation := secret.Annotations[ClusterNameAnnotation]; annotation != "" {
+		name = annotation
+	}
+	// the name qualifies the keys of the workloads, as cluster:namespace/name
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid cluster name %s: %v", name, errs)
+	}
+
+	kubeClients := NewKubeClientsForConfig(conf)
+	kubeClients.Cluster = name
+	kubeClients.ClusterLabels = secret.Labels
+	kubeClients.Local = local
+	return kubeClients, nil
+}
+
+// IsLocal returns whether the clients are the ones of the cluster running Oblik
+func (k *KubeClients) IsLocal() bool {
+	return k.Local == nil || k.Local == k
+}
+
+// GetKey qualifies the namespace/name key of a workload with the cluster
+func (k *KubeClients) GetKey(key string) string {
+	return config.GetClusterKey(k.Cluster, key)
+}
+
+// CreateStrategyConfig creates the strategy config of a workload or VPA of the cluster, its key being qualified
+// with the cluster
+func (k *KubeClients) CreateStrategyConfig(obj interface{}) *config.StrategyConfig {
+	scfg := config.CreateStrategyConfig(config.CreateConfigurable(obj))
+	scfg.Key = k.GetKey(scfg.Key)
+	return scfg
+}
@@ -7,6 +7,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type KubeClients struct {
@@ -15,6 +16,15 @@ type KubeClients struct {
 	VpaClientset             *vpaclientset.Clientset
 	ResourcesConfigClientset *ResourcesConfigClientset
 	RestConfig               *rest.Config
+	// Reader reads the workloads, VPAs, HPAs, LimitRanges, namespaces and ResourcesConfigs: from the shared informer cache
+	// of the manager in the operator, from the API server otherwise
+	Reader ctrlclient.Reader
+	// Cluster is the name of the cluster in multi-cluster mode, qualifying the workload keys
+	Cluster string
+	// ClusterLabels are the labels of the cluster, matched by the cluster selectors of the ResourcesConfigs
+	ClusterLabels map[string]string
+	// Local are the clients of the cluster running Oblik, holding the ResourcesConfigs and the scheduler state
+	Local *KubeClients
 }
 
 func NewKubeClients() *KubeClients {
@@ -24,6 +34,15 @@ func NewKubeClients() *KubeClients {
 		panic(err.Error())
 	}
 
+	kubeClients := NewKubeClientsForConfig(conf)
+	kubeClients.Cluster = config.GetClusterName()
+	kubeClients.ClusterLabels = config.GetClusterLabels()
+	kubeClients.Local = kubeClients
+	return kubeClients
+}
+
+// NewKubeClientsForConfig creates the clients of a cluster from its REST config
+func NewKubeClientsForConfig(conf *rest.Config) *KubeClients {
 	clientset, err := kubernetes.NewForConfig(conf)
 	if err != nil {
 		klog.Fatalf("Error creating Kubernetes client: %s", err.Error())
@@ -46,12 +65,18 @@ func NewKubeClients() *KubeClients {
 		klog.Fatalf("Error creating ResourcesConfig client: %s", err.Error())
 	}
 
+	reader, err := ctrlclient.New(conf, ctrlclient.Options{Scheme: Scheme})
+	if err != nil {
+		klog.Fatalf("Error creating controller-runtime client: %s", err.Error())
+	}
+
 	kubeClients := &KubeClients{
 		Clientset:                clientset,
 		DynamicClient:            dynamicClient,
 		VpaClientset:             vpaClientset,
 		ResourcesConfigClientset: resourcesConfigClientset,
 		RestConfig:               conf,
+		Reader:                   reader,
 	}
 
 	return kubeClients
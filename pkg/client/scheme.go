@@ -0,0 +1,24 @@
+package client
+
+import (
+	oblikv1 "github.com/SocialGouv/oblik/pkg/apis/oblik/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// Scheme registers the types read and watched through controller-runtime: the Kubernetes, VPA and ResourcesConfig types.
+// Unlike the client-go scheme, ResourcesConfig is only registered in its external version, so that its kind is not ambiguous
+var Scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(Scheme))
+	utilruntime.Must(vpa.AddToScheme(Scheme))
+	Scheme.AddKnownTypes(oblikv1.SchemeGroupVersion,
+		&oblikv1.ResourcesConfig{},
+		&oblikv1.ResourcesConfigList{},
+	)
+	metav1.AddToGroupVersion(Scheme, oblikv1.SchemeGroupVersion)
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/SocialGouv/oblik/pkg/utils"
+	"k8s.io/klog/v2"
+)
+
+// GetClusterName returns the name of the cluster running Oblik, empty unless set for multi-cluster mode, which
+// keeps the keys of its workloads unqualified
+func GetClusterName() string {
+	return utils.GetEnv("OBLIK_CLUSTER_NAME", "")
+}
+
+// GetClusterLabels returns the labels of the cluster running Oblik, matched by the cluster selectors of the
+// ResourcesConfigs, from a comma-separated list of key=value pairs
+func GetClusterLabels() map[string]string {
+	labels := map[string]string{}
+	for _, pair := range strings.Split(utils.GetEnv("OBLIK_CLUSTER_LABELS", ""), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			klog.Warningf("Invalid cluster label %s, expected key=value", pair)
+			continue
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return labels
+}
+
+// GetClusterSecrets returns the names of the Secrets of the operator namespace holding the kubeconfigs of the
+// remote clusters managed by Oblik
+func GetClusterSecrets() []string {
+	secrets := []string{}
+	for _, name := range strings.Split(utils.GetEnv("OBLIK_CLUSTER_SECRETS", ""), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			secrets = append(secrets, name)
+		}
+	}
+	return secrets
+}
+
+// GetClusterKey qualifies the namespace/name key of a workload with its cluster, as cluster:namespace/name
+func GetClusterKey(cluster, key string) string {
+	if cluster == "" {
+		return key
+	}
+	return cluster + ":" + key
+}
+
+// SplitClusterKey returns the cluster and the namespace/name key of a workload key
+func SplitClusterKey(key string) (string, string) {
+	if cluster, workloadKey, found := strings.Cut(key, ":"); found {
+		return cluster, workloadKey
+	}
+	return "", key
+}
+
+// IsWebhookOnly returns whether Oblik only serves the webhook, in a cluster whose workloads are reconciled and
+// updated by the Oblik instance of another cluster
+func IsWebhookOnly() bool {
+	return utils.GetEnv("OBLIK_WEBHOOK_ONLY", "false") == "true"
+}
@@ -2,6 +2,9 @@ package config
 
 const defaultCron = "0 2 * * *"
 const defaultCronAddRandomMax = "120m"
+const defaultCronCatchUpDeadline = "6h"
+const defaultReplicasPeakMax = 2
+const defaultReplicasFrugalMin = 10
 
 const VpaPrefix = "oblik-"
 
@@ -10,6 +13,8 @@ type ApplyMode int
 const (
 	ApplyModeEnforce ApplyMode = iota
 	ApplyModeOff
+	// ApplyModeRemove deletes the existing value, only supported for the CPU limit
+	ApplyModeRemove
 )
 
 type UnprovidedApplyDefaultMode int
@@ -27,6 +32,12 @@ const (
 	RequestApplyTargetFrugal RequestApplyTarget = iota
 	RequestApplyTargetBalanced
 	RequestApplyTargetPeak
+	// RequestApplyTargetReplicas chooses between frugal, balanced and peak from the replica count and the total usage of the workload
+	RequestApplyTargetReplicas
+	// RequestApplyTargetPercentile interpolates between the lower bound (p0), the target (p50) and the upper bound (p100)
+	RequestApplyTargetPercentile
+	// RequestApplyTargetUncapped uses the target before the VPA resource policy bounds are applied
+	RequestApplyTargetUncapped
 )
 
 type LimitApplyTarget int
@@ -45,3 +56,103 @@ const (
 	ScaleDirectionUp
 	ScaleDirectionDown
 )
+
+// MemoryFromCpuSource is the CPU value memory is calculated from when memory-from-cpu is enabled
+type MemoryFromCpuSource int
+
+const (
+	// MemoryFromCpuSourceApplied uses the CPU request or limit applied to the container
+	MemoryFromCpuSourceApplied MemoryFromCpuSource = iota
+	// MemoryFromCpuSourceRecommendation uses the VPA CPU bound selected by the request or limit apply target
+	MemoryFromCpuSourceRecommendation
+)
+
+// RuntimeProfile is the runtime of a container whose heap settings follow its memory limit
+type RuntimeProfile int
+
+const (
+	RuntimeProfileOff RuntimeProfile = iota
+	RuntimeProfileJVM
+	RuntimeProfileNode
+	RuntimeProfileGo
+)
+
+// JVMHeapFlag is the JVM option the heap size is set with
+type JVMHeapFlag int
+
+const (
+	JVMHeapFlagMaxRAMPercentage JVMHeapFlag = iota
+	JVMHeapFlagXmx
+)
+
+type HPAMode int
+
+const (
+	HPAModeSkip HPAMode = iota
+	HPAModeBand
+	HPAModeReplicas
+	HPAModeOff
+)
+
+// PodResourcesContainerMode is how the containers resources are set when the pod-level resources are managed
+type PodResourcesContainerMode int
+
+const (
+	// PodResourcesContainerModeUnset removes the CPU and memory requests and limits of the containers
+	PodResourcesContainerModeUnset PodResourcesContainerMode = iota
+	// PodResourcesContainerModeFloor sets the CPU and memory requests of the containers to their minimum and removes their limits
+	PodResourcesContainerModeFloor
+)
+
+// QoSClass is the shape enforced on the requests and limits of a container, which determines the QoS class of its pod
+type QoSClass int
+
+const (
+	QoSClassOff QoSClass = iota
+	// QoSClassGuaranteed sets the CPU and memory limits to the requests
+	QoSClassGuaranteed
+	// QoSClassBurstable keeps the CPU and memory limits above the requests by a minimum margin
+	QoSClassBurstable
+	// QoSClassBestEffortCpuLimitFree removes the CPU limit and keeps the memory limit
+	QoSClassBestEffortCpuLimitFree
+)
+
+// DriftPolicy is the reaction to a change of the containers resources made outside Oblik
+type DriftPolicy int
+
+const (
+	// DriftPolicyIgnore only reports the drift
+	DriftPolicyIgnore DriftPolicy = iota
+	// DriftPolicyReapply applies the recommendations again right away
+	DriftPolicyReapply
+	// DriftPolicyAccept keeps the new values as a manual override of the recommendations
+	DriftPolicyAccept
+)
+
+// GitOpsPolicy is the handling of a workload whose resources are also set by a GitOps tool, ArgoCD or Flux
+type GitOpsPolicy int
+
+const (
+	// GitOpsPolicyIgnore doesn't detect the conflicts
+	GitOpsPolicyIgnore GitOpsPolicy = iota
+	// GitOpsPolicyWarn applies the recommendations and reports the conflict
+	GitOpsPolicyWarn
+	// GitOpsPolicySkip leaves the workload to the GitOps tool, neither applying the recommendations nor mutating it
+	GitOpsPolicySkip
+	// GitOpsPolicyWebhook only sets the resources from the mutating webhook, when the GitOps tool syncs the workload
+	GitOpsPolicyWebhook
+	// GitOpsPolicyAnnotate applies the recommendations and records the ignoreDifferences entry to add to the ArgoCD Application
+	GitOpsPolicyAnnotate
+)
+
+// VPAAdoptionMode is the handling of the VPAs created by the users targeting a workload
+type VPAAdoptionMode int
+
+const (
+	// VPAAdoptionOff ignores them, Oblik creating its own VPA
+	VPAAdoptionOff VPAAdoptionMode = iota
+	// VPAAdoptionAdopt uses the recommendations of the VPA, only adding the Oblik labels and annotations to it
+	VPAAdoptionAdopt
+	// VPAAdoptionOwn also manages the spec of the VPA, and deletes it with the workload
+	VPAAdoptionOwn
+)
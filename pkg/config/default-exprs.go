@@ -0,0 +1,76 @@
+package config
+
+import (
+	"sync"
+
+	"github.com/SocialGouv/oblik/pkg/calculator"
+	"github.com/SocialGouv/oblik/pkg/utils"
+	"k8s.io/klog/v2"
+)
+
+// defaultExpr is a default calculator algorithm env var, with the env var of its value read by the same getters,
+// and the value used instead of an invalid expression
+type defaultExpr struct {
+	algoEnv       string
+	valueEnv      string
+	fallbackValue string
+}
+
+var defaultExprs = []defaultExpr{
+	{"OBLIK_DEFAULT_LIMIT_CPU_CALCULATOR_ALGO", "OBLIK_DEFAULT_LIMIT_CPU_CALCULATOR_VALUE", "1"},
+	{"OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_ALGO", "OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_VALUE", "1"},
+	{"OBLIK_DEFAULT_INCREASE_REQUEST_CPU_ALGO", "OBLIK_DEFAULT_INCREASE_REQUEST_CPU_VALUE", "1"},
+	{"OBLIK_DEFAULT_INCREASE_REQUEST_MEMORY_ALGO", "OBLIK_DEFAULT_INCREASE_REQUEST_MEMORY_VALUE", "1"},
+	{"OBLIK_DEFAULT_MIN_DIFF_CPU_REQUEST_ALGO", "OBLIK_DEFAULT_MIN_DIFF_CPU_REQUEST_VALUE", "0"},
+	{"OBLIK_DEFAULT_MIN_DIFF_MEMORY_REQUEST_ALGO", "OBLIK_DEFAULT_MIN_DIFF_MEMORY_REQUEST_VALUE", "0"},
+	{"OBLIK_DEFAULT_MIN_DIFF_CPU_LIMIT_ALGO", "OBLIK_DEFAULT_MIN_DIFF_CPU_LIMIT_VALUE", "0"},
+	{"OBLIK_DEFAULT_MIN_DIFF_MEMORY_LIMIT_ALGO", "OBLIK_DEFAULT_MIN_DIFF_MEMORY_LIMIT_VALUE", "0"},
+	{"OBLIK_DEFAULT_MEMORY_REQUEST_FROM_CPU_ALGO", "OBLIK_DEFAULT_MEMORY_REQUEST_FROM_CPU_VALUE", "2"},
+	{"OBLIK_DEFAULT_MEMORY_LIMIT_FROM_CPU_ALGO", "OBLIK_DEFAULT_MEMORY_LIMIT_FROM_CPU_VALUE", "2"},
+}
+
+var (
+	defaultExprsOnce    sync.Once
+	invalidDefaultExprs map[string]bool
+)
+
+// ValidateDefaultExprs compiles the default calculator expressions of the env once, on startup. An invalid or missing
+// expression is reported with a warning and replaced by the ratio algorithm with its default value
+func ValidateDefaultExprs() {
+	defaultExprsOnce.Do(func() {
+		invalidDefaultExprs = map[string]bool{}
+		for _, d := range defaultExprs {
+			if utils.GetEnv(d.algoEnv, "") != "expr" {
+				continue
+			}
+			value := utils.GetEnv(d.valueEnv, "")
+			if value == "" {
+				klog.Warningf("Missing calculator expression %s for %s=expr, falling back to the ratio algorithm with %s", d.valueEnv, d.algoEnv, d.fallbackValue)
+				invalidDefaultExprs[d.valueEnv] = true
+				continue
+			}
+			if err := calculator.ValidateExpr(value); err != nil {
+				klog.Warningf("Invalid calculator expression %s=%q: %s, falling back to the ratio algorithm with %s", d.valueEnv, value, err.Error(), d.fallbackValue)
+				invalidDefaultExprs[d.valueEnv] = true
+			}
+		}
+	})
+}
+
+// getDefaultAlgo returns the default calculator algorithm of the env, empty when the expression of its value is invalid
+func getDefaultAlgo(algoEnv string, valueEnv string) string {
+	ValidateDefaultExprs()
+	if invalidDefaultExprs[valueEnv] {
+		return ""
+	}
+	return utils.GetEnv(algoEnv, "")
+}
+
+// getDefaultAlgoValue returns the default calculator value of the env, the fallback when it is an invalid expression
+func getDefaultAlgoValue(valueEnv string, fallbackValue string) string {
+	ValidateDefaultExprs()
+	if invalidDefaultExprs[valueEnv] {
+		return fallbackValue
+	}
+	return utils.GetEnv(valueEnv, fallbackValue)
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/calculator"
+)
+
+func resetDefaultExprs() {
+	defaultExprsOnce = sync.Once{}
+	invalidDefaultExprs = nil
+}
+
+func TestValidateDefaultExprs(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		algo  calculator.CalculatorAlgo
+		want  string
+	}{
+		{name: "valid expression", value: "max(value * 1.5, value + 256Mi)", algo: calculator.CalculatorAlgoExpr, want: "max(value * 1.5, value + 256Mi)"},
+		{name: "invalid expression", value: "max(value *", algo: calculator.CalculatorAlgoRatio, want: "1"},
+		{name: "unknown variable", value: "value * factor", algo: calculator.CalculatorAlgoRatio, want: "1"},
+		{name: "missing expression", value: "", algo: calculator.CalculatorAlgoRatio, want: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OBLIK_DEFAULT_INCREASE_REQUEST_MEMORY_ALGO", "expr")
+			t.Setenv("OBLIK_DEFAULT_INCREASE_REQUEST_MEMORY_VALUE", tt.value)
+			resetDefaultExprs()
+			defer resetDefaultExprs()
+
+			ValidateDefaultExprs()
+			scfg := &StrategyConfig{LoadCfg: &LoadCfg{}}
+			if algo := scfg.GetIncreaseRequestMemoryAlgo("app"); algo != tt.algo {
+				t.Errorf("expected algo %d, got %d", tt.algo, algo)
+			}
+			if value := scfg.GetIncreaseRequestMemoryValue("app"); value != tt.want {
+				t.Errorf("expected value %q, got %q", tt.want, value)
+			}
+		})
+	}
+}
+
+func TestDefaultLimitCalculatorAlgo(t *testing.T) {
+	t.Setenv("OBLIK_DEFAULT_LIMIT_CPU_CALCULATOR_ALGO", "margin")
+	t.Setenv("OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_ALGO", "expr")
+	t.Setenv("OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_VALUE", "value * 2")
+	resetDefaultExprs()
+	defer resetDefaultExprs()
+
+	scfg := &StrategyConfig{LoadCfg: &LoadCfg{}}
+	if algo := scfg.GetLimitCPUCalculatorAlgo("app"); algo != calculator.CalculatorAlgoMargin {
+		t.Errorf("expected the cpu limit algo from OBLIK_DEFAULT_LIMIT_CPU_CALCULATOR_ALGO, got %d", algo)
+	}
+	if algo := scfg.GetLimitMemoryCalculatorAlgo("app"); algo != calculator.CalculatorAlgoExpr {
+		t.Errorf("expected the memory limit algo from OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_ALGO, got %d", algo)
+	}
+}
+
+func TestValidateExprAlgo(t *testing.T) {
+	expr := calculator.CalculatorAlgoExpr
+	ratio := calculator.CalculatorAlgoRatio
+	valid, invalid, empty := "value * 2", "value *", ""
+	tests := []struct {
+		name      string
+		algo      *calculator.CalculatorAlgo
+		value     *string
+		wantAlgo  bool
+		wantValue bool
+	}{
+		{name: "valid expression", algo: &expr, value: &valid, wantAlgo: true, wantValue: true},
+		{name: "invalid expression", algo: &expr, value: &invalid},
+		{name: "missing expression", algo: &expr},
+		{name: "empty expression", algo: &expr, value: &empty},
+		{name: "other algorithm", algo: &ratio, wantAlgo: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algo, value := tt.algo, tt.value
+			validateExprAlgo(&algo, &value)
+			if (algo != nil) != tt.wantAlgo || (value != nil) != tt.wantValue {
+				t.Errorf("expected algo kept %t and value kept %t, got %v and %v", tt.wantAlgo, tt.wantValue, algo, value)
+			}
+		})
+	}
+}
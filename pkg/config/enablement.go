@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/SocialGouv/oblik/pkg/constants"
+	"github.com/SocialGouv/oblik/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// IsNamespaceExcluded returns whether the namespace is excluded cluster-wide, its workloads being never touched,
+// whatever their labels. The operator namespace is always excluded
+func IsNamespaceExcluded(namespace string) bool {
+	if operatorNamespace := os.Getenv("NAMESPACE"); operatorNamespace != "" && namespace == operatorNamespace {
+		return true
+	}
+	for _, pattern := range GetExcludedNamespaces() {
+		matched, err := path.Match(pattern, namespace)
+		if err != nil {
+			klog.Warningf("Invalid excluded namespace pattern %s: %s", pattern, err.Error())
+			continue
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// GetExcludedNamespaces returns the names or glob patterns of the namespaces excluded cluster-wide
+func GetExcludedNamespaces() []string {
+	excluded := []string{}
+	for _, pattern := range strings.Split(utils.GetEnv("OBLIK_EXCLUDED_NAMESPACES", "kube-system,kube-public,kube-node-lease"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			excluded = append(excluded, pattern)
+		}
+	}
+	return excluded
+}
+
+// IsNamespaceEnablementEnabled returns whether the enabled label of a namespace enables its workloads, the workloads
+// without label being then watched too
+func IsNamespaceEnablementEnabled() bool {
+	return utils.GetEnv("OBLIK_NAMESPACE_ENABLEMENT", "false") == "true"
+}
+
+// IsEnabled returns whether Oblik manages a workload: the enabled label of the workload, "true" or "false", overrides
+// the enabled label of its namespace, which enables all the supported workloads of the namespace when the namespace
+// enablement is on. The namespace is nil when it can't be read, the workload label deciding alone
+func IsEnabled(workload metav1.Object, namespace *corev1.Namespace) bool {
+	if IsNamespaceExcluded(workload.GetNamespace()) {
+		return false
+	}
+	switch workload.GetLabels()[constants.PREFIX+"enabled"] {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return IsNamespaceEnablementEnabled() && namespace != nil && namespace.Labels[constants.PREFIX+"enabled"] == "true"
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsEnabled(t *testing.T) {
+	enabledNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps", Labels: map[string]string{constants.PREFIX + "enabled": "true"}}}
+	workload := func(namespace, enabled string) metav1.Object {
+		labels := map[string]string{}
+		if enabled != "" {
+			labels[constants.PREFIX+"enabled"] = enabled
+		}
+		return &metav1.ObjectMeta{Namespace: namespace, Name: "app", Labels: labels}
+	}
+	tests := []struct {
+		name                string
+		namespaceEnablement string
+		workload            metav1.Object
+		namespace           *corev1.Namespace
+		enabled             bool
+	}{
+		{name: "labelled workload", namespaceEnablement: "false", workload: workload("apps", "true"), enabled: true},
+		{name: "namespace label ignored", namespaceEnablement: "false", workload: workload("apps", ""), namespace: enabledNamespace, enabled: false},
+		{name: "enabled by namespace", namespaceEnablement: "true", workload: workload("apps", ""), namespace: enabledNamespace, enabled: true},
+		{name: "opted out", namespaceEnablement: "true", workload: workload("apps", "false"), namespace: enabledNamespace, enabled: false},
+		{name: "unreadable namespace", namespaceEnablement: "true", workload: workload("apps", ""), enabled: false},
+		{name: "excluded namespace", namespaceEnablement: "true", workload: workload("kube-system", "true"), enabled: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OBLIK_NAMESPACE_ENABLEMENT", tt.namespaceEnablement)
+			if enabled := IsEnabled(tt.workload, tt.namespace); enabled != tt.enabled {
+				t.Errorf("expected enabled %v, got %v", tt.enabled, enabled)
+			}
+		})
+	}
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/SocialGouv/oblik/pkg/calculator"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 	"k8s.io/klog/v2"
 )
 
@@ -65,10 +70,16 @@ type LoadCfg struct {
 	MemoryLimitFromCpuEnabled   *bool
 	MemoryLimitFromCpuAlgo      *calculator.CalculatorAlgo
 	MemoryLimitFromCpuValue     *string
+	MemoryFromCpuRatio          *resource.Quantity
+	MemoryFromCpuSource         *MemoryFromCpuSource
 
 	RequestApplyTarget       *RequestApplyTarget
 	RequestCpuApplyTarget    *RequestApplyTarget
 	RequestMemoryApplyTarget *RequestApplyTarget
+	// percentiles of the "pNN" request apply targets
+	RequestApplyTargetPercentile       *int
+	RequestCpuApplyTargetPercentile    *int
+	RequestMemoryApplyTargetPercentile *int
 
 	LimitApplyTarget       *LimitApplyTarget
 	LimitCpuApplyTarget    *LimitApplyTarget
@@ -78,6 +89,42 @@ type LoadCfg struct {
 	RequestMemoryScaleDirection *ScaleDirection
 	LimitCpuScaleDirection      *ScaleDirection
 	LimitMemoryScaleDirection   *ScaleDirection
+
+	HPAMode *HPAMode
+	HPABand *string
+
+	CpuRoundingAlgo     *calculator.RoundingAlgo
+	CpuRoundingValue    *string
+	MemoryRoundingAlgo  *calculator.RoundingAlgo
+	MemoryRoundingValue *string
+
+	RuntimeProfile   *RuntimeProfile
+	RuntimeHeapRatio *string
+	RuntimeCpuRatio  *string
+	JVMOptionsEnv    *string
+	JVMHeapFlag      *JVMHeapFlag
+
+	QoSClass           *QoSClass
+	QoSBurstableMargin *string
+
+	EphemeralStorageApplyMode    *ApplyMode
+	RequestEphemeralStorageValue *string
+	LimitEphemeralStorageValue   *string
+	MinRequestEphemeralStorage   *resource.Quantity
+	MaxRequestEphemeralStorage   *resource.Quantity
+	MinLimitEphemeralStorage     *resource.Quantity
+	MaxLimitEphemeralStorage     *resource.Quantity
+	LimitEphemeralStorageRatio   *string
+	EphemeralStorageHeadroom     *string
+
+	// resource policy of the managed VPA
+	VPAMinAllowedCpu       *resource.Quantity
+	VPAMaxAllowedCpu       *resource.Quantity
+	VPAMinAllowedMemory    *resource.Quantity
+	VPAMaxAllowedMemory    *resource.Quantity
+	VPAControlledResources []corev1.ResourceName
+	VPAControlledValues    *vpa.ContainerControlledValues
+	VPAMode                *vpa.ContainerScalingMode
 }
 
 func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix string) {
@@ -106,6 +153,11 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		cfg.LimitCPUApplyMode = &applyMode
 	}
 
+	if getAnnotation("limit-cpu-apply-mode") == "remove" {
+		applyMode := ApplyModeRemove
+		cfg.LimitCPUApplyMode = &applyMode
+	}
+
 	if getAnnotation("limit-memory-apply-mode") == "off" {
 		applyMode := ApplyModeOff
 		cfg.LimitMemoryApplyMode = &applyMode
@@ -120,6 +172,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.LimitCPUCalculatorAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.LimitCPUCalculatorAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", limitCPUCalculatorAlgo)
 		}
@@ -134,6 +189,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.LimitMemoryCalculatorAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.LimitMemoryCalculatorAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", limitMemoryCalculatorAlgo)
 		}
@@ -195,6 +253,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.IncreaseRequestCpuAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.IncreaseRequestCpuAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", increaseRequestCpuAlgo)
 		}
@@ -209,6 +270,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.IncreaseRequestMemoryAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.IncreaseRequestMemoryAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", increaseRequestMemoryAlgo)
 		}
@@ -312,6 +376,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.MinDiffCpuRequestAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.MinDiffCpuRequestAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", minDiffCpuRequestAlgo)
 		}
@@ -326,6 +393,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.MinDiffMemoryRequestAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.MinDiffMemoryRequestAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", minDiffMemoryRequestAlgo)
 		}
@@ -349,6 +419,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.MinDiffCpuLimitAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.MinDiffCpuLimitAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", minDiffCpuLimitAlgo)
 		}
@@ -363,6 +436,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.MinDiffMemoryLimitAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.MinDiffMemoryLimitAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", minDiffMemoryLimitAlgo)
 		}
@@ -391,6 +467,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.MemoryRequestFromCpuAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.MemoryRequestFromCpuAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", memoryRequestFromCpuAlgo)
 		}
@@ -414,6 +493,9 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		case "margin":
 			algo := calculator.CalculatorAlgoMargin
 			cfg.MemoryLimitFromCpuAlgo = &algo
+		case "expr":
+			algo := calculator.CalculatorAlgoExpr
+			cfg.MemoryLimitFromCpuAlgo = &algo
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", memoryLimitFromCpuAlgo)
 		}
@@ -423,72 +505,43 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		cfg.MemoryLimitFromCpuValue = &memoryLimitFromCpuValue
 	}
 
+	memoryFromCpuRatioStr := getAnnotation("memory-from-cpu-ratio")
+	if memoryFromCpuRatioStr != "" {
+		memoryFromCpuRatio, err := resource.ParseQuantity(memoryFromCpuRatioStr)
+		if err != nil {
+			klog.Warningf("Error parsing memory-from-cpu-ratio: %s, error: %s", memoryFromCpuRatioStr, err.Error())
+		} else {
+			cfg.MemoryFromCpuRatio = &memoryFromCpuRatio
+		}
+	}
+	memoryFromCpuSourceStr := getAnnotation("memory-from-cpu-source")
+	if memoryFromCpuSourceStr != "" {
+		if memoryFromCpuSource, ok := parseMemoryFromCpuSource(memoryFromCpuSourceStr); ok {
+			cfg.MemoryFromCpuSource = &memoryFromCpuSource
+		}
+	}
+
 	requestApplyTarget := getAnnotation("request-apply-target")
 	if requestApplyTarget != "" {
-		switch requestApplyTarget {
-		case "lowerBound":
-			fallthrough
-		case "frugal":
-			applyTarget := RequestApplyTargetFrugal
+		if applyTarget, percentile, ok := parseRequestApplyTarget(requestApplyTarget); ok {
 			cfg.RequestApplyTarget = &applyTarget
-		case "target":
-			fallthrough
-		case "balanced":
-			applyTarget := RequestApplyTargetBalanced
-			cfg.RequestApplyTarget = &applyTarget
-		case "upperBound":
-			fallthrough
-		case "peak":
-			applyTarget := RequestApplyTargetPeak
-			cfg.RequestApplyTarget = &applyTarget
-		default:
-			klog.Warningf("Unknown request-apply-target: %s", requestApplyTarget)
+			cfg.RequestApplyTargetPercentile = &percentile
 		}
 	}
 
 	requestCpuApplyTarget := getAnnotation("request-cpu-apply-target")
 	if requestCpuApplyTarget != "" {
-		switch requestCpuApplyTarget {
-		case "lowerBound":
-			fallthrough
-		case "frugal":
-			applyTarget := RequestApplyTargetFrugal
-			cfg.RequestCpuApplyTarget = &applyTarget
-		case "target":
-			fallthrough
-		case "balanced":
-			applyTarget := RequestApplyTargetBalanced
-			cfg.RequestCpuApplyTarget = &applyTarget
-		case "upperBound":
-			fallthrough
-		case "peak":
-			applyTarget := RequestApplyTargetPeak
+		if applyTarget, percentile, ok := parseRequestApplyTarget(requestCpuApplyTarget); ok {
 			cfg.RequestCpuApplyTarget = &applyTarget
-		default:
-			klog.Warningf("Unknown request-apply-target: %s", requestCpuApplyTarget)
+			cfg.RequestCpuApplyTargetPercentile = &percentile
 		}
 	}
 
 	requestMemoryApplyTarget := getAnnotation("request-memory-apply-target")
 	if requestMemoryApplyTarget != "" {
-		switch requestMemoryApplyTarget {
-		case "lowerBound":
-			fallthrough
-		case "frugal":
-			applyTarget := RequestApplyTargetFrugal
-			cfg.RequestMemoryApplyTarget = &applyTarget
-		case "target":
-			fallthrough
-		case "balanced":
-			applyTarget := RequestApplyTargetBalanced
+		if applyTarget, percentile, ok := parseRequestApplyTarget(requestMemoryApplyTarget); ok {
 			cfg.RequestMemoryApplyTarget = &applyTarget
-		case "upperBound":
-			fallthrough
-		case "peak":
-			applyTarget := RequestApplyTargetPeak
-			cfg.RequestMemoryApplyTarget = &applyTarget
-		default:
-			klog.Warningf("Unknown request-apply-target: %s", requestMemoryApplyTarget)
+			cfg.RequestMemoryApplyTargetPercentile = &percentile
 		}
 	}
 
@@ -629,6 +682,104 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 		}
 	}
 
+	hpaModeStr := getAnnotation("hpa-mode")
+	if hpaModeStr != "" {
+		hpaMode, ok := parseHPAMode(hpaModeStr)
+		if ok {
+			cfg.HPAMode = &hpaMode
+		}
+	}
+
+	hpaBand := getAnnotation("hpa-band")
+	if hpaBand != "" {
+		cfg.HPABand = &hpaBand
+	}
+
+	cpuRoundingAlgo := getAnnotation("cpu-rounding-algo")
+	if cpuRoundingAlgo != "" {
+		if algo, ok := calculator.ParseRoundingAlgo(cpuRoundingAlgo); ok {
+			cfg.CpuRoundingAlgo = &algo
+		}
+	}
+	cpuRoundingValue := getAnnotation("cpu-rounding-value")
+	if cpuRoundingValue != "" {
+		cfg.CpuRoundingValue = &cpuRoundingValue
+	}
+
+	memoryRoundingAlgo := getAnnotation("memory-rounding-algo")
+	if memoryRoundingAlgo != "" {
+		if algo, ok := calculator.ParseRoundingAlgo(memoryRoundingAlgo); ok {
+			cfg.MemoryRoundingAlgo = &algo
+		}
+	}
+	memoryRoundingValue := getAnnotation("memory-rounding-value")
+	if memoryRoundingValue != "" {
+		cfg.MemoryRoundingValue = &memoryRoundingValue
+	}
+
+	runtimeProfileStr := getAnnotation("runtime-profile")
+	if runtimeProfileStr != "" {
+		if runtimeProfile, ok := parseRuntimeProfile(runtimeProfileStr); ok {
+			cfg.RuntimeProfile = &runtimeProfile
+		}
+	}
+	runtimeHeapRatio := getAnnotation("runtime-heap-ratio")
+	if runtimeHeapRatio != "" {
+		cfg.RuntimeHeapRatio = &runtimeHeapRatio
+	}
+	runtimeCpuRatio := getAnnotation("runtime-cpu-ratio")
+	if runtimeCpuRatio != "" {
+		cfg.RuntimeCpuRatio = &runtimeCpuRatio
+	}
+	jvmOptionsEnv := getAnnotation("jvm-options-env")
+	if jvmOptionsEnv != "" {
+		cfg.JVMOptionsEnv = &jvmOptionsEnv
+	}
+	jvmHeapFlagStr := getAnnotation("jvm-heap-flag")
+	if jvmHeapFlagStr != "" {
+		if jvmHeapFlag, ok := parseJVMHeapFlag(jvmHeapFlagStr); ok {
+			cfg.JVMHeapFlag = &jvmHeapFlag
+		}
+	}
+
+	qosClassStr := getAnnotation("qos-class")
+	if qosClassStr != "" {
+		if qosClass, ok := parseQoSClass(qosClassStr); ok {
+			cfg.QoSClass = &qosClass
+		}
+	}
+	qosBurstableMargin := getAnnotation("qos-burstable-margin")
+	if qosBurstableMargin != "" {
+		cfg.QoSBurstableMargin = &qosBurstableMargin
+	}
+
+	ephemeralStorageApplyMode := getAnnotation("ephemeral-storage-apply-mode")
+	if ephemeralStorageApplyMode != "" {
+		if applyMode, ok := parseApplyMode(ephemeralStorageApplyMode); ok {
+			cfg.EphemeralStorageApplyMode = &applyMode
+		}
+	}
+	requestEphemeralStorageValue := getAnnotation("request-ephemeral-storage")
+	if requestEphemeralStorageValue != "" {
+		cfg.RequestEphemeralStorageValue = &requestEphemeralStorageValue
+	}
+	limitEphemeralStorageValue := getAnnotation("limit-ephemeral-storage")
+	if limitEphemeralStorageValue != "" {
+		cfg.LimitEphemeralStorageValue = &limitEphemeralStorageValue
+	}
+	cfg.MinRequestEphemeralStorage = parseQuantityAnnotation(getAnnotation, "min-request-ephemeral-storage")
+	cfg.MaxRequestEphemeralStorage = parseQuantityAnnotation(getAnnotation, "max-request-ephemeral-storage")
+	cfg.MinLimitEphemeralStorage = parseQuantityAnnotation(getAnnotation, "min-limit-ephemeral-storage")
+	cfg.MaxLimitEphemeralStorage = parseQuantityAnnotation(getAnnotation, "max-limit-ephemeral-storage")
+	limitEphemeralStorageRatio := getAnnotation("limit-ephemeral-storage-ratio")
+	if limitEphemeralStorageRatio != "" {
+		cfg.LimitEphemeralStorageRatio = &limitEphemeralStorageRatio
+	}
+	ephemeralStorageHeadroom := getAnnotation("ephemeral-storage-headroom")
+	if ephemeralStorageHeadroom != "" {
+		cfg.EphemeralStorageHeadroom = &ephemeralStorageHeadroom
+	}
+
 	// Process direct resource specifications
 	requestCpuValue := getAnnotation("request-cpu")
 	if requestCpuValue != "" {
@@ -649,4 +800,276 @@ func loadAnnotableCommonCfg(cfg *LoadCfg, annotable Annotable, annotationSuffix
 	if limitMemoryValue != "" {
 		cfg.LimitMemoryValue = &limitMemoryValue
 	}
+
+	validateExprAlgo(&cfg.LimitCPUCalculatorAlgo, &cfg.LimitCPUCalculatorValue)
+	validateExprAlgo(&cfg.LimitMemoryCalculatorAlgo, &cfg.LimitMemoryCalculatorValue)
+	validateExprAlgo(&cfg.IncreaseRequestCpuAlgo, &cfg.IncreaseRequestCpuValue)
+	validateExprAlgo(&cfg.IncreaseRequestMemoryAlgo, &cfg.IncreaseRequestMemoryValue)
+	validateExprAlgo(&cfg.MinDiffCpuRequestAlgo, &cfg.MinDiffCpuRequestValue)
+	validateExprAlgo(&cfg.MinDiffMemoryRequestAlgo, &cfg.MinDiffMemoryRequestValue)
+	validateExprAlgo(&cfg.MinDiffCpuLimitAlgo, &cfg.MinDiffCpuLimitValue)
+	validateExprAlgo(&cfg.MinDiffMemoryLimitAlgo, &cfg.MinDiffMemoryLimitValue)
+	validateExprAlgo(&cfg.MemoryRequestFromCpuAlgo, &cfg.MemoryRequestFromCpuValue)
+	validateExprAlgo(&cfg.MemoryLimitFromCpuAlgo, &cfg.MemoryLimitFromCpuValue)
+
+	cfg.VPAMinAllowedCpu = parseQuantityAnnotation(getAnnotation, "vpa-min-allowed-cpu")
+	cfg.VPAMaxAllowedCpu = parseQuantityAnnotation(getAnnotation, "vpa-max-allowed-cpu")
+	cfg.VPAMinAllowedMemory = parseQuantityAnnotation(getAnnotation, "vpa-min-allowed-memory")
+	cfg.VPAMaxAllowedMemory = parseQuantityAnnotation(getAnnotation, "vpa-max-allowed-memory")
+	vpaControlledResources := getAnnotation("vpa-controlled-resources")
+	if vpaControlledResources != "" {
+		if resourceNames, ok := parseVPAControlledResources(vpaControlledResources); ok {
+			cfg.VPAControlledResources = resourceNames
+		}
+	}
+	vpaControlledValuesStr := getAnnotation("vpa-controlled-values")
+	if vpaControlledValuesStr != "" {
+		if vpaControlledValues, ok := parseVPAControlledValues(vpaControlledValuesStr); ok {
+			cfg.VPAControlledValues = &vpaControlledValues
+		}
+	}
+	vpaModeStr := getAnnotation("vpa-mode")
+	if vpaModeStr != "" {
+		if vpaMode, ok := parseVPAMode(vpaModeStr); ok {
+			cfg.VPAMode = &vpaMode
+		}
+	}
+}
+
+// validateExprAlgo compiles the expression of an "expr" calculator, discarding both the algorithm and the value
+// when it is invalid or missing so that the defaults apply
+func validateExprAlgo(algo **calculator.CalculatorAlgo, value **string) {
+	if *algo == nil || **algo != calculator.CalculatorAlgoExpr {
+		return
+	}
+	if *value == nil || **value == "" {
+		klog.Warningf("Missing calculator expression for the expr algorithm")
+		*algo = nil
+		*value = nil
+		return
+	}
+	if err := calculator.ValidateExpr(**value); err != nil {
+		klog.Warningf("Invalid calculator expression %q: %s", **value, err.Error())
+		*algo = nil
+		*value = nil
+	}
+}
+
+// parseRequestApplyTarget parses a request apply target, returning the percentile of "pNN" targets
+func parseRequestApplyTarget(value string) (RequestApplyTarget, int, bool) {
+	switch value {
+	case "lowerBound", "frugal":
+		return RequestApplyTargetFrugal, 0, true
+	case "target", "balanced":
+		return RequestApplyTargetBalanced, 50, true
+	case "upperBound", "peak":
+		return RequestApplyTargetPeak, 100, true
+	case "replicas":
+		return RequestApplyTargetReplicas, 0, true
+	case "uncappedTarget", "uncapped":
+		return RequestApplyTargetUncapped, 0, true
+	}
+	if strings.HasPrefix(value, "p") {
+		percentile, err := strconv.Atoi(strings.TrimPrefix(value, "p"))
+		if err == nil && percentile >= 0 && percentile <= 100 {
+			return RequestApplyTargetPercentile, percentile, true
+		}
+	}
+	klog.Warningf("Unknown request-apply-target: %s", value)
+	return RequestApplyTargetBalanced, 0, false
+}
+
+func parseMemoryFromCpuSource(value string) (MemoryFromCpuSource, bool) {
+	switch value {
+	case "applied":
+		return MemoryFromCpuSourceApplied, true
+	case "recommendation":
+		return MemoryFromCpuSourceRecommendation, true
+	default:
+		klog.Warningf("Unknown memory-from-cpu-source: %s", value)
+		return MemoryFromCpuSourceApplied, false
+	}
+}
+
+func parseApplyMode(value string) (ApplyMode, bool) {
+	switch value {
+	case "enforce":
+		return ApplyModeEnforce, true
+	case "off":
+		return ApplyModeOff, true
+	default:
+		klog.Warningf("Unknown apply mode: %s", value)
+		return ApplyModeOff, false
+	}
+}
+
+func parseDriftPolicy(value string) (DriftPolicy, bool) {
+	switch value {
+	case "ignore":
+		return DriftPolicyIgnore, true
+	case "reapply":
+		return DriftPolicyReapply, true
+	case "accept":
+		return DriftPolicyAccept, true
+	default:
+		klog.Warningf("Unknown drift-policy: %s", value)
+		return DriftPolicyIgnore, false
+	}
+}
+
+func parseGitOpsPolicy(value string) (GitOpsPolicy, bool) {
+	switch value {
+	case "ignore":
+		return GitOpsPolicyIgnore, true
+	case "warn":
+		return GitOpsPolicyWarn, true
+	case "skip":
+		return GitOpsPolicySkip, true
+	case "webhook":
+		return GitOpsPolicyWebhook, true
+	case "annotate":
+		return GitOpsPolicyAnnotate, true
+	default:
+		klog.Warningf("Unknown gitops-policy: %s", value)
+		return GitOpsPolicyWarn, false
+	}
+}
+
+func parseVPAAdoptionMode(value string) (VPAAdoptionMode, bool) {
+	switch value {
+	case "off":
+		return VPAAdoptionOff, true
+	case "adopt":
+		return VPAAdoptionAdopt, true
+	case "own":
+		return VPAAdoptionOwn, true
+	default:
+		klog.Warningf("Unknown vpa-adoption: %s", value)
+		return VPAAdoptionOff, false
+	}
+}
+
+func parsePodResourcesContainerMode(value string) (PodResourcesContainerMode, bool) {
+	switch value {
+	case "unset":
+		return PodResourcesContainerModeUnset, true
+	case "floor":
+		return PodResourcesContainerModeFloor, true
+	default:
+		klog.Warningf("Unknown pod-resources-container-mode: %s", value)
+		return PodResourcesContainerModeUnset, false
+	}
+}
+
+// parseVPAControlledResources parses a comma separated list of "cpu" and "memory"
+func parseVPAControlledResources(value string) ([]corev1.ResourceName, bool) {
+	resourceNames := []corev1.ResourceName{}
+	for _, item := range strings.Split(value, ",") {
+		switch resourceName := corev1.ResourceName(strings.TrimSpace(item)); resourceName {
+		case corev1.ResourceCPU, corev1.ResourceMemory:
+			resourceNames = append(resourceNames, resourceName)
+		default:
+			klog.Warningf("Unknown vpa-controlled-resources: %s", value)
+			return nil, false
+		}
+	}
+	return resourceNames, true
+}
+
+func parseVPAControlledValues(value string) (vpa.ContainerControlledValues, bool) {
+	switch value {
+	case "requests-and-limits":
+		return vpa.ContainerControlledValuesRequestsAndLimits, true
+	case "requests-only":
+		return vpa.ContainerControlledValuesRequestsOnly, true
+	default:
+		klog.Warningf("Unknown vpa-controlled-values: %s", value)
+		return vpa.ContainerControlledValuesRequestsAndLimits, false
+	}
+}
+
+func parseVPAMode(value string) (vpa.ContainerScalingMode, bool) {
+	switch value {
+	case "auto":
+		return vpa.ContainerScalingModeAuto, true
+	case "off":
+		return vpa.ContainerScalingModeOff, true
+	default:
+		klog.Warningf("Unknown vpa-mode: %s", value)
+		return vpa.ContainerScalingModeAuto, false
+	}
+}
+
+// parseQuantityAnnotation returns the quantity of the annotation, nil when it is not set or invalid
+func parseQuantityAnnotation(getAnnotation func(key string) string, key string) *resource.Quantity {
+	value := getAnnotation(key)
+	if value == "" {
+		return nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		klog.Warningf("Error parsing %s: %s, error: %s", key, value, err.Error())
+		return nil
+	}
+	return &quantity
+}
+
+func parseRuntimeProfile(value string) (RuntimeProfile, bool) {
+	switch value {
+	case "off":
+		return RuntimeProfileOff, true
+	case "jvm":
+		return RuntimeProfileJVM, true
+	case "node":
+		return RuntimeProfileNode, true
+	case "go":
+		return RuntimeProfileGo, true
+	default:
+		klog.Warningf("Unknown runtime-profile: %s", value)
+		return RuntimeProfileOff, false
+	}
+}
+
+func parseJVMHeapFlag(value string) (JVMHeapFlag, bool) {
+	switch value {
+	case "max-ram-percentage":
+		return JVMHeapFlagMaxRAMPercentage, true
+	case "xmx":
+		return JVMHeapFlagXmx, true
+	default:
+		klog.Warningf("Unknown jvm-heap-flag: %s", value)
+		return JVMHeapFlagMaxRAMPercentage, false
+	}
+}
+
+func parseQoSClass(value string) (QoSClass, bool) {
+	switch value {
+	case "off":
+		return QoSClassOff, true
+	case "guaranteed":
+		return QoSClassGuaranteed, true
+	case "burstable":
+		return QoSClassBurstable, true
+	case "besteffort-cpu-limit-free":
+		return QoSClassBestEffortCpuLimitFree, true
+	default:
+		klog.Warningf("Unknown qos-class: %s", value)
+		return QoSClassOff, false
+	}
+}
+
+func parseHPAMode(value string) (HPAMode, bool) {
+	switch value {
+	case "skip":
+		return HPAModeSkip, true
+	case "band":
+		return HPAModeBand, true
+	case "replicas":
+		return HPAModeReplicas, true
+	case "off":
+		return HPAModeOff, true
+	default:
+		klog.Warningf("Unknown hpa-mode: %s", value)
+		return HPAModeOff, false
+	}
 }
@@ -0,0 +1,31 @@
+package config
+
+import "testing"
+
+func TestParseRequestApplyTarget(t *testing.T) {
+	tests := []struct {
+		value      string
+		target     RequestApplyTarget
+		percentile int
+		ok         bool
+	}{
+		{value: "frugal", target: RequestApplyTargetFrugal, percentile: 0, ok: true},
+		{value: "target", target: RequestApplyTargetBalanced, percentile: 50, ok: true},
+		{value: "peak", target: RequestApplyTargetPeak, percentile: 100, ok: true},
+		{value: "uncapped", target: RequestApplyTargetUncapped, ok: true},
+		{value: "p0", target: RequestApplyTargetPercentile, percentile: 0, ok: true},
+		{value: "p75", target: RequestApplyTargetPercentile, percentile: 75, ok: true},
+		{value: "p100", target: RequestApplyTargetPercentile, percentile: 100, ok: true},
+		{value: "p101", target: RequestApplyTargetBalanced, ok: false},
+		{value: "p-1", target: RequestApplyTargetBalanced, ok: false},
+		{value: "p", target: RequestApplyTargetBalanced, ok: false},
+		{value: "p7.5", target: RequestApplyTargetBalanced, ok: false},
+		{value: "unknown", target: RequestApplyTargetBalanced, ok: false},
+	}
+	for _, tt := range tests {
+		target, percentile, ok := parseRequestApplyTarget(tt.value)
+		if ok != tt.ok || target != tt.target || (ok && percentile != tt.percentile) {
+			t.Errorf("%s: expected (%d, %d, %t), got (%d, %d, %t)", tt.value, tt.target, tt.percentile, tt.ok, target, percentile, ok)
+		}
+	}
+}
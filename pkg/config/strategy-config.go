@@ -2,19 +2,25 @@ package config
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/SocialGouv/oblik/pkg/calculator"
 	"github.com/SocialGouv/oblik/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 	"k8s.io/klog/v2"
 )
 
 func GetKey(configurable *Configurable) string {
 	workloadName := configurable.GetName()
 
-	if strings.HasPrefix(workloadName, VpaPrefix) {
+	// the VPAs adopted by Oblik have any name
+	if vpaResource, ok := configurable.Object.(*vpa.VerticalPodAutoscaler); ok && vpaResource.Spec.TargetRef != nil {
+		workloadName = vpaResource.Spec.TargetRef.Name
+	} else if strings.HasPrefix(workloadName, VpaPrefix) {
 		workloadName = strings.TrimPrefix(workloadName, VpaPrefix)
 		workloadKind := strings.Split(workloadName, "-")[0]
 		workloadName = strings.TrimPrefix(workloadName, workloadKind+"-")
@@ -53,12 +59,30 @@ func CreateStrategyConfig(configurable *Configurable) *StrategyConfig {
 	}
 	cfg.CronExpr = cronExpr
 
+	cronTimezone := getAnnotation("cron-timezone")
+	if cronTimezone == "" {
+		cronTimezone = utils.GetEnv("OBLIK_DEFAULT_CRON_TIMEZONE", "")
+	}
+	if cronTimezone != "" {
+		if _, err := time.LoadLocation(cronTimezone); err != nil {
+			klog.Warningf("Unknown cron-timezone: %s, error: %s", cronTimezone, err.Error())
+			cronTimezone = ""
+		}
+	}
+	cfg.CronTimezone = cronTimezone
+
 	cronAddRandomMax := getAnnotation("cron-add-random-max")
 	if cronAddRandomMax == "" {
 		cronAddRandomMax = utils.GetEnv("OBLIK_DEFAULT_CRON_ADD_RANDOM_MAX", defaultCronAddRandomMax)
 	}
 	cfg.CronMaxRandomDelay = utils.ParseDuration(cronAddRandomMax, 120*time.Minute)
 
+	cronCatchUpDeadline := getAnnotation("cron-catch-up-deadline")
+	if cronCatchUpDeadline == "" {
+		cronCatchUpDeadline = utils.GetEnv("OBLIK_DEFAULT_CRON_CATCH_UP_DEADLINE", defaultCronCatchUpDeadline)
+	}
+	cfg.CronCatchUpDeadline = utils.ParseDuration(cronCatchUpDeadline, 6*time.Hour)
+
 	dryRunStr := getAnnotation("dry-run")
 	if dryRunStr == "" {
 		dryRunStr = utils.GetEnv("OBLIK_DEFAULT_DRY_RUN", "false")
@@ -75,6 +99,73 @@ func CreateStrategyConfig(configurable *Configurable) *StrategyConfig {
 		cfg.WebhookEnabled = true
 	}
 
+	cfg.ReplicasPeakMax = getIntAnnotationOrEnv(getAnnotation, "replicas-peak-max", "OBLIK_DEFAULT_REPLICAS_PEAK_MAX", defaultReplicasPeakMax)
+	cfg.ReplicasFrugalMin = getIntAnnotationOrEnv(getAnnotation, "replicas-frugal-min", "OBLIK_DEFAULT_REPLICAS_FRUGAL_MIN", defaultReplicasFrugalMin)
+	cfg.MaxTotalCpu = getQuantityAnnotationOrEnv(getAnnotation, "max-total-cpu", "OBLIK_DEFAULT_MAX_TOTAL_CPU")
+	cfg.MaxTotalMemory = getQuantityAnnotationOrEnv(getAnnotation, "max-total-memory", "OBLIK_DEFAULT_MAX_TOTAL_MEMORY")
+
+	podResourcesMode := getAnnotation("pod-resources-mode")
+	if podResourcesMode == "" {
+		podResourcesMode = utils.GetEnv("OBLIK_DEFAULT_POD_RESOURCES_MODE", "off")
+	}
+	if mode, ok := parseApplyMode(podResourcesMode); ok {
+		cfg.PodResourcesMode = mode
+	} else {
+		cfg.PodResourcesMode = ApplyModeOff
+	}
+
+	podResourcesHeadroom := getAnnotation("pod-resources-headroom")
+	if podResourcesHeadroom == "" {
+		podResourcesHeadroom = utils.GetEnv("OBLIK_DEFAULT_POD_RESOURCES_HEADROOM", "0")
+	}
+	headroom, err := strconv.ParseFloat(podResourcesHeadroom, 64)
+	if err != nil || headroom < 0 {
+		klog.Warningf("Error parsing pod-resources-headroom: %s, using 0", podResourcesHeadroom)
+		headroom = 0
+	}
+	cfg.PodResourcesHeadroom = headroom
+
+	podResourcesContainerMode := getAnnotation("pod-resources-container-mode")
+	if podResourcesContainerMode == "" {
+		podResourcesContainerMode = utils.GetEnv("OBLIK_DEFAULT_POD_RESOURCES_CONTAINER_MODE", "unset")
+	}
+	if mode, ok := parsePodResourcesContainerMode(podResourcesContainerMode); ok {
+		cfg.PodResourcesContainerMode = mode
+	}
+
+	driftPolicy := getAnnotation("drift-policy")
+	if driftPolicy == "" {
+		driftPolicy = utils.GetEnv("OBLIK_DEFAULT_DRIFT_POLICY", "ignore")
+	}
+	if policy, ok := parseDriftPolicy(driftPolicy); ok {
+		cfg.DriftPolicy = policy
+	}
+
+	gitOpsPolicy := getAnnotation("gitops-policy")
+	if gitOpsPolicy == "" {
+		gitOpsPolicy = utils.GetEnv("OBLIK_DEFAULT_GITOPS_POLICY", "warn")
+	}
+	// an unknown policy falls back to the default
+	cfg.GitOpsPolicy, _ = parseGitOpsPolicy(gitOpsPolicy)
+
+	vpaAdoption := getAnnotation("vpa-adoption")
+	if vpaAdoption == "" {
+		vpaAdoption = utils.GetEnv("OBLIK_DEFAULT_VPA_ADOPTION", "off")
+	}
+	if mode, ok := parseVPAAdoptionMode(vpaAdoption); ok {
+		cfg.VPAAdoption = mode
+	}
+
+	vpaRecommenders := getAnnotation("vpa-recommenders")
+	if vpaRecommenders == "" {
+		vpaRecommenders = utils.GetEnv("OBLIK_DEFAULT_VPA_RECOMMENDERS", "")
+	}
+	for _, recommender := range strings.Split(vpaRecommenders, ",") {
+		if recommender = strings.TrimSpace(recommender); recommender != "" {
+			cfg.VPARecommenders = append(cfg.VPARecommenders, recommender)
+		}
+	}
+
 	enabled := getLabel("enabled")
 	if enabled == "true" {
 		cfg.Enabled = true
@@ -94,14 +185,105 @@ func CreateStrategyConfig(configurable *Configurable) *StrategyConfig {
 type StrategyConfig struct {
 	Key                string
 	CronExpr           string
+	CronTimezone       string
 	CronMaxRandomDelay time.Duration
-	DryRun             bool
-	Enabled            bool
-	WebhookEnabled     bool
-	Containers         map[string]*ContainerConfig
+	// CronCatchUpDeadline is the maximum lateness of a missed run to be caught up on startup, 0 disables catch-up
+	CronCatchUpDeadline time.Duration
+	DryRun              bool
+	Enabled             bool
+	WebhookEnabled      bool
+	// ReplicasPeakMax is the replica count up to which the replicas apply target uses the peak recommendation
+	ReplicasPeakMax int
+	// ReplicasFrugalMin is the replica count from which the replicas apply target uses the frugal recommendation
+	ReplicasFrugalMin int
+	// MaxTotalCpu caps the CPU requests of all the containers across all the replicas, nil for no cap
+	MaxTotalCpu *resource.Quantity
+	// MaxTotalMemory caps the memory requests of all the containers across all the replicas, nil for no cap
+	MaxTotalMemory *resource.Quantity
+	// PodResourcesMode enables the management of the pod-level resources from the sum of the containers resources
+	PodResourcesMode ApplyMode
+	// PodResourcesHeadroom is the fraction added to the sum of the containers resources for the pod-level resources
+	PodResourcesHeadroom float64
+	// PodResourcesContainerMode is how the containers resources are set when the pod-level resources are managed
+	PodResourcesContainerMode PodResourcesContainerMode
+	// DriftPolicy is the reaction to a change of the containers resources made outside Oblik
+	DriftPolicy DriftPolicy
+	// GitOpsPolicy is the handling of the workloads whose resources are also set by ArgoCD or Flux
+	GitOpsPolicy GitOpsPolicy
+	// VPARecommenders are the names of the recommenders of the managed VPA, the default recommender when empty
+	VPARecommenders []string
+	// VPAAdoption is the handling of the VPAs created by the users targeting the workload
+	VPAAdoption VPAAdoptionMode
+	Containers  map[string]*ContainerConfig
 	*LoadCfg
 }
 
+func getIntAnnotationOrEnv(getAnnotation func(key string) string, key string, envKey string, defaultValue int) int {
+	value := getAnnotation(key)
+	if value == "" {
+		value = utils.GetEnv(envKey, "")
+	}
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		klog.Warningf("Error parsing %s: %s, error: %s", key, value, err.Error())
+		return defaultValue
+	}
+	return parsed
+}
+
+// getQuantityEnv returns the quantity of the env var, nil when it is not set or invalid
+func getQuantityEnv(envKey string, key string) *resource.Quantity {
+	value := utils.GetEnv(envKey, "")
+	if value == "" {
+		return nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		klog.Warningf("Error parsing %s: %s, error: %s", key, value, err.Error())
+		return nil
+	}
+	return &quantity
+}
+
+func getQuantityAnnotationOrEnv(getAnnotation func(key string) string, key string, envKey string) *resource.Quantity {
+	value := getAnnotation(key)
+	if value == "" {
+		value = utils.GetEnv(envKey, "")
+	}
+	if value == "" {
+		return nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		klog.Warningf("Error parsing %s: %s, error: %s", key, value, err.Error())
+		return nil
+	}
+	return &quantity
+}
+
+// GetCronSpec returns the cron expression evaluated in the configured IANA timezone, or in the operator local time when none is set
+func (v *StrategyConfig) GetCronSpec() string {
+	if v.CronTimezone == "" || strings.HasPrefix(v.CronExpr, "CRON_TZ=") || strings.HasPrefix(v.CronExpr, "TZ=") {
+		return v.CronExpr
+	}
+	return fmt.Sprintf("CRON_TZ=%s %s", v.CronTimezone, v.CronExpr)
+}
+
+// GetLocation returns the configured IANA timezone, or the operator local time when none is set
+func (v *StrategyConfig) GetLocation() *time.Location {
+	if v.CronTimezone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(v.CronTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
 func (v *StrategyConfig) GetDryRun() bool {
 	return v.DryRun
 }
@@ -153,13 +335,15 @@ func (v *StrategyConfig) GetLimitCPUCalculatorAlgo(containerName string) calcula
 	if v.LimitCPUCalculatorAlgo != nil {
 		return *v.LimitCPUCalculatorAlgo
 	}
-	defaultLimitCPUCalculatorAlgoParam := utils.GetEnv("OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_ALGO", "")
+	defaultLimitCPUCalculatorAlgoParam := getDefaultAlgo("OBLIK_DEFAULT_LIMIT_CPU_CALCULATOR_ALGO", "OBLIK_DEFAULT_LIMIT_CPU_CALCULATOR_VALUE")
 	if defaultLimitCPUCalculatorAlgoParam != "" {
 		switch defaultLimitCPUCalculatorAlgoParam {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", defaultLimitCPUCalculatorAlgoParam)
 		}
@@ -174,13 +358,15 @@ func (v *StrategyConfig) GetLimitMemoryCalculatorAlgo(containerName string) calc
 	if v.LimitMemoryCalculatorAlgo != nil {
 		return *v.LimitMemoryCalculatorAlgo
 	}
-	defaultLimitMemoryCalculatorAlgoParam := utils.GetEnv("OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_ALGO", "")
+	defaultLimitMemoryCalculatorAlgoParam := getDefaultAlgo("OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_ALGO", "OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_VALUE")
 	if defaultLimitMemoryCalculatorAlgoParam != "" {
 		switch defaultLimitMemoryCalculatorAlgoParam {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", defaultLimitMemoryCalculatorAlgoParam)
 		}
@@ -195,7 +381,7 @@ func (v *StrategyConfig) GetLimitMemoryCalculatorValue(containerName string) str
 	if v.LimitMemoryCalculatorValue != nil {
 		return *v.LimitMemoryCalculatorValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_VALUE", "1")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_LIMIT_MEMORY_CALCULATOR_VALUE", "1")
 }
 
 func (v *StrategyConfig) GetLimitCPUCalculatorValue(containerName string) string {
@@ -205,7 +391,7 @@ func (v *StrategyConfig) GetLimitCPUCalculatorValue(containerName string) string
 	if v.LimitCPUCalculatorValue != nil {
 		return *v.LimitCPUCalculatorValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_LIMIT_CPU_CALCULATOR_VALUE", "1")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_LIMIT_CPU_CALCULATOR_VALUE", "1")
 }
 
 func (v *StrategyConfig) GetUnprovidedApplyDefaultRequestCPUSource(containerName string) UnprovidedApplyDefaultMode {
@@ -309,13 +495,15 @@ func (v *StrategyConfig) GetIncreaseRequestCpuAlgo(containerName string) calcula
 	if v.IncreaseRequestCpuAlgo != nil {
 		return *v.IncreaseRequestCpuAlgo
 	}
-	increaseRequestCpuAlgo := utils.GetEnv("OBLIK_DEFAULT_INCREASE_REQUEST_CPU_ALGO", "")
+	increaseRequestCpuAlgo := getDefaultAlgo("OBLIK_DEFAULT_INCREASE_REQUEST_CPU_ALGO", "OBLIK_DEFAULT_INCREASE_REQUEST_CPU_VALUE")
 	if increaseRequestCpuAlgo != "" {
 		switch increaseRequestCpuAlgo {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", increaseRequestCpuAlgo)
 		}
@@ -330,13 +518,15 @@ func (v *StrategyConfig) GetIncreaseRequestMemoryAlgo(containerName string) calc
 	if v.IncreaseRequestMemoryAlgo != nil {
 		return *v.IncreaseRequestMemoryAlgo
 	}
-	increaseRequestMemoryAlgo := utils.GetEnv("OBLIK_DEFAULT_INCREASE_REQUEST_MEMORY_ALGO", "")
+	increaseRequestMemoryAlgo := getDefaultAlgo("OBLIK_DEFAULT_INCREASE_REQUEST_MEMORY_ALGO", "OBLIK_DEFAULT_INCREASE_REQUEST_MEMORY_VALUE")
 	if increaseRequestMemoryAlgo != "" {
 		switch increaseRequestMemoryAlgo {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", increaseRequestMemoryAlgo)
 		}
@@ -351,7 +541,7 @@ func (v *StrategyConfig) GetIncreaseRequestCpuValue(containerName string) string
 	if v.IncreaseRequestCpuValue != nil {
 		return *v.IncreaseRequestCpuValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_INCREASE_REQUEST_CPU_VALUE", "1")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_INCREASE_REQUEST_CPU_VALUE", "1")
 }
 
 func (v *StrategyConfig) GetIncreaseRequestMemoryValue(containerName string) string {
@@ -361,7 +551,7 @@ func (v *StrategyConfig) GetIncreaseRequestMemoryValue(containerName string) str
 	if v.IncreaseRequestMemoryValue != nil {
 		return *v.IncreaseRequestMemoryValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_INCREASE_REQUEST_MEMORY_VALUE", "1")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_INCREASE_REQUEST_MEMORY_VALUE", "1")
 }
 
 func (v *StrategyConfig) GetMinLimitCpu(containerName string) *resource.Quantity {
@@ -599,13 +789,15 @@ func (v *StrategyConfig) GetMinDiffCpuRequestAlgo(containerName string) calculat
 	if v.MinDiffCpuRequestAlgo != nil {
 		return *v.MinDiffCpuRequestAlgo
 	}
-	minDiffCpuRequestAlgo := utils.GetEnv("OBLIK_DEFAULT_MIN_DIFF_CPU_REQUEST_ALGO", "")
+	minDiffCpuRequestAlgo := getDefaultAlgo("OBLIK_DEFAULT_MIN_DIFF_CPU_REQUEST_ALGO", "OBLIK_DEFAULT_MIN_DIFF_CPU_REQUEST_VALUE")
 	if minDiffCpuRequestAlgo != "" {
 		switch minDiffCpuRequestAlgo {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", minDiffCpuRequestAlgo)
 		}
@@ -620,7 +812,7 @@ func (v *StrategyConfig) GetMinDiffCpuRequestValue(containerName string) string
 	if v.MinDiffCpuRequestValue != nil {
 		return *v.MinDiffCpuRequestValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_MIN_DIFF_CPU_REQUEST_VALUE", "0")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_MIN_DIFF_CPU_REQUEST_VALUE", "0")
 }
 
 func (v *StrategyConfig) GetMinDiffMemoryRequestAlgo(containerName string) calculator.CalculatorAlgo {
@@ -630,13 +822,15 @@ func (v *StrategyConfig) GetMinDiffMemoryRequestAlgo(containerName string) calcu
 	if v.MinDiffMemoryRequestAlgo != nil {
 		return *v.MinDiffMemoryRequestAlgo
 	}
-	minDiffMemoryRequestAlgo := utils.GetEnv("OBLIK_DEFAULT_MIN_DIFF_MEMORY_REQUEST_ALGO", "")
+	minDiffMemoryRequestAlgo := getDefaultAlgo("OBLIK_DEFAULT_MIN_DIFF_MEMORY_REQUEST_ALGO", "OBLIK_DEFAULT_MIN_DIFF_MEMORY_REQUEST_VALUE")
 	if minDiffMemoryRequestAlgo != "" {
 		switch minDiffMemoryRequestAlgo {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", minDiffMemoryRequestAlgo)
 		}
@@ -651,7 +845,7 @@ func (v *StrategyConfig) GetMinDiffMemoryRequestValue(containerName string) stri
 	if v.MinDiffMemoryRequestValue != nil {
 		return *v.MinDiffMemoryRequestValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_MIN_DIFF_MEMORY_REQUEST_VALUE", "0")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_MIN_DIFF_MEMORY_REQUEST_VALUE", "0")
 }
 
 func (v *StrategyConfig) GetMinDiffCpuLimitAlgo(containerName string) calculator.CalculatorAlgo {
@@ -661,13 +855,15 @@ func (v *StrategyConfig) GetMinDiffCpuLimitAlgo(containerName string) calculator
 	if v.MinDiffCpuLimitAlgo != nil {
 		return *v.MinDiffCpuLimitAlgo
 	}
-	minDiffCpuLimitAlgo := utils.GetEnv("OBLIK_DEFAULT_MIN_DIFF_CPU_LIMIT_ALGO", "")
+	minDiffCpuLimitAlgo := getDefaultAlgo("OBLIK_DEFAULT_MIN_DIFF_CPU_LIMIT_ALGO", "OBLIK_DEFAULT_MIN_DIFF_CPU_LIMIT_VALUE")
 	if minDiffCpuLimitAlgo != "" {
 		switch minDiffCpuLimitAlgo {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", minDiffCpuLimitAlgo)
 		}
@@ -682,7 +878,7 @@ func (v *StrategyConfig) GetMinDiffCpuLimitValue(containerName string) string {
 	if v.MinDiffCpuLimitValue != nil {
 		return *v.MinDiffCpuLimitValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_MIN_DIFF_CPU_LIMIT_VALUE", "0")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_MIN_DIFF_CPU_LIMIT_VALUE", "0")
 }
 
 func (v *StrategyConfig) GetMinDiffMemoryLimitAlgo(containerName string) calculator.CalculatorAlgo {
@@ -692,13 +888,15 @@ func (v *StrategyConfig) GetMinDiffMemoryLimitAlgo(containerName string) calcula
 	if v.MinDiffMemoryLimitAlgo != nil {
 		return *v.MinDiffMemoryLimitAlgo
 	}
-	minDiffMemoryLimitAlgo := utils.GetEnv("OBLIK_DEFAULT_MIN_DIFF_MEMORY_LIMIT_ALGO", "")
+	minDiffMemoryLimitAlgo := getDefaultAlgo("OBLIK_DEFAULT_MIN_DIFF_MEMORY_LIMIT_ALGO", "OBLIK_DEFAULT_MIN_DIFF_MEMORY_LIMIT_VALUE")
 	if minDiffMemoryLimitAlgo != "" {
 		switch minDiffMemoryLimitAlgo {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", minDiffMemoryLimitAlgo)
 		}
@@ -713,7 +911,7 @@ func (v *StrategyConfig) GetMinDiffMemoryLimitValue(containerName string) string
 	if v.MinDiffMemoryLimitValue != nil {
 		return *v.MinDiffMemoryLimitValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_MIN_DIFF_MEMORY_LIMIT_VALUE", "0")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_MIN_DIFF_MEMORY_LIMIT_VALUE", "0")
 }
 
 func (v *StrategyConfig) GetMemoryRequestFromCpuEnabled(containerName string) bool {
@@ -734,13 +932,15 @@ func (v *StrategyConfig) GetMemoryRequestFromCpuAlgo(containerName string) calcu
 	if v.MemoryRequestFromCpuAlgo != nil {
 		return *v.MemoryRequestFromCpuAlgo
 	}
-	memoryRequestFromCpuAlgo := utils.GetEnv("OBLIK_DEFAULT_MEMORY_REQUEST_FROM_CPU_ALGO", "")
+	memoryRequestFromCpuAlgo := getDefaultAlgo("OBLIK_DEFAULT_MEMORY_REQUEST_FROM_CPU_ALGO", "OBLIK_DEFAULT_MEMORY_REQUEST_FROM_CPU_VALUE")
 	if memoryRequestFromCpuAlgo != "" {
 		switch memoryRequestFromCpuAlgo {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", memoryRequestFromCpuAlgo)
 		}
@@ -755,7 +955,7 @@ func (v *StrategyConfig) GetMemoryRequestFromCpuValue(containerName string) stri
 	if v.MemoryRequestFromCpuValue != nil {
 		return *v.MemoryRequestFromCpuValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_MEMORY_REQUEST_FROM_CPU_VALUE", "2")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_MEMORY_REQUEST_FROM_CPU_VALUE", "2")
 }
 
 func (v *StrategyConfig) GetMemoryLimitFromCpuEnabled(containerName string) bool {
@@ -776,13 +976,15 @@ func (v *StrategyConfig) GetMemoryLimitFromCpuAlgo(containerName string) calcula
 	if v.MemoryLimitFromCpuAlgo != nil {
 		return *v.MemoryLimitFromCpuAlgo
 	}
-	memoryLimitFromCpuAlgo := utils.GetEnv("OBLIK_DEFAULT_MEMORY_LIMIT_FROM_CPU_ALGO", "")
+	memoryLimitFromCpuAlgo := getDefaultAlgo("OBLIK_DEFAULT_MEMORY_LIMIT_FROM_CPU_ALGO", "OBLIK_DEFAULT_MEMORY_LIMIT_FROM_CPU_VALUE")
 	if memoryLimitFromCpuAlgo != "" {
 		switch memoryLimitFromCpuAlgo {
 		case "ratio":
 			return calculator.CalculatorAlgoRatio
 		case "margin":
 			return calculator.CalculatorAlgoMargin
+		case "expr":
+			return calculator.CalculatorAlgoExpr
 		default:
 			klog.Warningf("Unknown calculator algorithm: %s", memoryLimitFromCpuAlgo)
 		}
@@ -797,7 +999,43 @@ func (v *StrategyConfig) GetMemoryLimitFromCpuValue(containerName string) string
 	if v.MemoryLimitFromCpuValue != nil {
 		return *v.MemoryLimitFromCpuValue
 	}
-	return utils.GetEnv("OBLIK_DEFAULT_MEMORY_LIMIT_FROM_CPU_VALUE", "2")
+	return getDefaultAlgoValue("OBLIK_DEFAULT_MEMORY_LIMIT_FROM_CPU_VALUE", "2")
+}
+
+// GetMemoryFromCpuRatio returns the memory per CPU core memory is calculated with when memory-from-cpu is enabled
+func (v *StrategyConfig) GetMemoryFromCpuRatio(containerName string) resource.Quantity {
+	if v.Containers[containerName] != nil && v.Containers[containerName].MemoryFromCpuRatio != nil {
+		return *v.Containers[containerName].MemoryFromCpuRatio
+	}
+	if v.MemoryFromCpuRatio != nil {
+		return *v.MemoryFromCpuRatio
+	}
+	memoryFromCpuRatioStr := utils.GetEnv("OBLIK_DEFAULT_MEMORY_FROM_CPU_RATIO", "")
+	if memoryFromCpuRatioStr != "" {
+		memoryFromCpuRatio, err := resource.ParseQuantity(memoryFromCpuRatioStr)
+		if err != nil {
+			klog.Warningf("Error parsing memory-from-cpu-ratio: %s, error: %s", memoryFromCpuRatioStr, err.Error())
+		} else {
+			return memoryFromCpuRatio
+		}
+	}
+	return resource.MustParse(calculator.DefaultMemoryPerCpu)
+}
+
+func (v *StrategyConfig) GetMemoryFromCpuSource(containerName string) MemoryFromCpuSource {
+	if v.Containers[containerName] != nil && v.Containers[containerName].MemoryFromCpuSource != nil {
+		return *v.Containers[containerName].MemoryFromCpuSource
+	}
+	if v.MemoryFromCpuSource != nil {
+		return *v.MemoryFromCpuSource
+	}
+	memoryFromCpuSourceStr := utils.GetEnv("OBLIK_DEFAULT_MEMORY_FROM_CPU_SOURCE", "")
+	if memoryFromCpuSourceStr != "" {
+		if memoryFromCpuSource, ok := parseMemoryFromCpuSource(memoryFromCpuSourceStr); ok {
+			return memoryFromCpuSource
+		}
+	}
+	return MemoryFromCpuSourceApplied
 }
 
 func (v *StrategyConfig) GetRequestApplyTarget(containerName string) RequestApplyTarget {
@@ -809,21 +1047,8 @@ func (v *StrategyConfig) GetRequestApplyTarget(containerName string) RequestAppl
 	}
 	requestApplyTarget := utils.GetEnv("OBLIK_DEFAULT_REQUEST_APPLY_TARGET", "")
 	if requestApplyTarget != "" {
-		switch requestApplyTarget {
-		case "lowerBound":
-			fallthrough
-		case "frugal":
-			return RequestApplyTargetFrugal
-		case "target":
-			fallthrough
-		case "balanced":
-			return RequestApplyTargetBalanced
-		case "upperBound":
-			fallthrough
-		case "peak":
-			return RequestApplyTargetPeak
-		default:
-			klog.Warningf("Unknown request-apply-target: %s", requestApplyTarget)
+		if applyTarget, _, ok := parseRequestApplyTarget(requestApplyTarget); ok {
+			return applyTarget
 		}
 	}
 	return RequestApplyTargetBalanced
@@ -838,21 +1063,8 @@ func (v *StrategyConfig) GetRequestCpuApplyTarget(containerName string) RequestA
 	}
 	requestCpuApplyTarget := utils.GetEnv("OBLIK_DEFAULT_REQUEST_CPU_APPLY_TARGET", "")
 	if requestCpuApplyTarget != "" {
-		switch requestCpuApplyTarget {
-		case "lowerBound":
-			fallthrough
-		case "frugal":
-			return RequestApplyTargetFrugal
-		case "target":
-			fallthrough
-		case "balanced":
-			return RequestApplyTargetBalanced
-		case "upperBound":
-			fallthrough
-		case "peak":
-			return RequestApplyTargetPeak
-		default:
-			klog.Warningf("Unknown request-apply-target: %s", requestCpuApplyTarget)
+		if applyTarget, _, ok := parseRequestApplyTarget(requestCpuApplyTarget); ok {
+			return applyTarget
 		}
 	}
 	return v.GetRequestApplyTarget(containerName)
@@ -867,26 +1079,64 @@ func (v *StrategyConfig) GetRequestMemoryApplyTarget(containerName string) Reque
 	}
 	requestMemoryApplyTarget := utils.GetEnv("OBLIK_DEFAULT_REQUEST_MEMORY_APPLY_TARGET", "")
 	if requestMemoryApplyTarget != "" {
-		switch requestMemoryApplyTarget {
-		case "lowerBound":
-			fallthrough
-		case "frugal":
-			return RequestApplyTargetFrugal
-		case "target":
-			fallthrough
-		case "balanced":
-			return RequestApplyTargetBalanced
-		case "upperBound":
-			fallthrough
-		case "peak":
-			return RequestApplyTargetPeak
-		default:
-			klog.Warningf("Unknown request-apply-target: %s", requestMemoryApplyTarget)
+		if applyTarget, _, ok := parseRequestApplyTarget(requestMemoryApplyTarget); ok {
+			return applyTarget
 		}
 	}
 	return v.GetRequestApplyTarget(containerName)
 }
 
+// GetRequestApplyTargetPercentile returns the percentile of the "pNN" apply target, resolved like the apply target
+func (v *StrategyConfig) GetRequestApplyTargetPercentile(containerName string) int {
+	if v.Containers[containerName] != nil && v.Containers[containerName].RequestApplyTarget != nil {
+		return *v.Containers[containerName].RequestApplyTargetPercentile
+	}
+	if v.RequestApplyTarget != nil {
+		return *v.RequestApplyTargetPercentile
+	}
+	requestApplyTarget := utils.GetEnv("OBLIK_DEFAULT_REQUEST_APPLY_TARGET", "")
+	if requestApplyTarget != "" {
+		if _, percentile, ok := parseRequestApplyTarget(requestApplyTarget); ok {
+			return percentile
+		}
+	}
+	return 50
+}
+
+// GetRequestCpuApplyTargetPercentile returns the percentile of the "pNN" apply target, resolved like the apply target
+func (v *StrategyConfig) GetRequestCpuApplyTargetPercentile(containerName string) int {
+	if v.Containers[containerName] != nil && v.Containers[containerName].RequestCpuApplyTarget != nil {
+		return *v.Containers[containerName].RequestCpuApplyTargetPercentile
+	}
+	if v.RequestCpuApplyTarget != nil {
+		return *v.RequestCpuApplyTargetPercentile
+	}
+	requestCpuApplyTarget := utils.GetEnv("OBLIK_DEFAULT_REQUEST_CPU_APPLY_TARGET", "")
+	if requestCpuApplyTarget != "" {
+		if _, percentile, ok := parseRequestApplyTarget(requestCpuApplyTarget); ok {
+			return percentile
+		}
+	}
+	return v.GetRequestApplyTargetPercentile(containerName)
+}
+
+// GetRequestMemoryApplyTargetPercentile returns the percentile of the "pNN" apply target, resolved like the apply target
+func (v *StrategyConfig) GetRequestMemoryApplyTargetPercentile(containerName string) int {
+	if v.Containers[containerName] != nil && v.Containers[containerName].RequestMemoryApplyTarget != nil {
+		return *v.Containers[containerName].RequestMemoryApplyTargetPercentile
+	}
+	if v.RequestMemoryApplyTarget != nil {
+		return *v.RequestMemoryApplyTargetPercentile
+	}
+	requestMemoryApplyTarget := utils.GetEnv("OBLIK_DEFAULT_REQUEST_MEMORY_APPLY_TARGET", "")
+	if requestMemoryApplyTarget != "" {
+		if _, percentile, ok := parseRequestApplyTarget(requestMemoryApplyTarget); ok {
+			return percentile
+		}
+	}
+	return v.GetRequestApplyTargetPercentile(containerName)
+}
+
 func (v *StrategyConfig) GetLimitApplyTarget(containerName string) LimitApplyTarget {
 	if v.Containers[containerName] != nil && v.Containers[containerName].LimitApplyTarget != nil {
 		return *v.Containers[containerName].LimitApplyTarget
@@ -1115,3 +1365,392 @@ func (v *StrategyConfig) GetLimitMemoryValue(containerName string) *string {
 	}
 	return nil
 }
+
+func (v *StrategyConfig) GetHPAMode(containerName string) HPAMode {
+	if v.Containers[containerName] != nil && v.Containers[containerName].HPAMode != nil {
+		return *v.Containers[containerName].HPAMode
+	}
+	if v.HPAMode != nil {
+		return *v.HPAMode
+	}
+	hpaModeStr := utils.GetEnv("OBLIK_DEFAULT_HPA_MODE", "")
+	if hpaModeStr != "" {
+		if hpaMode, ok := parseHPAMode(hpaModeStr); ok {
+			return hpaMode
+		}
+	}
+	return HPAModeOff
+}
+
+// GetHPABand returns the maximum ratio a resource the HPA scales on can be changed by in band mode
+func (v *StrategyConfig) GetHPABand(containerName string) float64 {
+	hpaBand := utils.GetEnv("OBLIK_DEFAULT_HPA_BAND", "0.1")
+	if v.Containers[containerName] != nil && v.Containers[containerName].HPABand != nil {
+		hpaBand = *v.Containers[containerName].HPABand
+	} else if v.HPABand != nil {
+		hpaBand = *v.HPABand
+	}
+	band, err := strconv.ParseFloat(hpaBand, 64)
+	if err != nil || band < 0 {
+		klog.Warningf("Error parsing hpa-band: %s, using 0.1", hpaBand)
+		return 0.1
+	}
+	return band
+}
+
+func (v *StrategyConfig) GetCpuRoundingAlgo(containerName string) calculator.RoundingAlgo {
+	if v.Containers[containerName] != nil && v.Containers[containerName].CpuRoundingAlgo != nil {
+		return *v.Containers[containerName].CpuRoundingAlgo
+	}
+	if v.CpuRoundingAlgo != nil {
+		return *v.CpuRoundingAlgo
+	}
+	cpuRoundingAlgo := utils.GetEnv("OBLIK_DEFAULT_CPU_ROUNDING_ALGO", "")
+	if cpuRoundingAlgo != "" {
+		if algo, ok := calculator.ParseRoundingAlgo(cpuRoundingAlgo); ok {
+			return algo
+		}
+	}
+	return calculator.RoundingAlgoOff
+}
+
+func (v *StrategyConfig) GetCpuRoundingValue(containerName string) string {
+	if v.Containers[containerName] != nil && v.Containers[containerName].CpuRoundingValue != nil {
+		return *v.Containers[containerName].CpuRoundingValue
+	}
+	if v.CpuRoundingValue != nil {
+		return *v.CpuRoundingValue
+	}
+	return utils.GetEnv("OBLIK_DEFAULT_CPU_ROUNDING_VALUE", "")
+}
+
+func (v *StrategyConfig) GetMemoryRoundingAlgo(containerName string) calculator.RoundingAlgo {
+	if v.Containers[containerName] != nil && v.Containers[containerName].MemoryRoundingAlgo != nil {
+		return *v.Containers[containerName].MemoryRoundingAlgo
+	}
+	if v.MemoryRoundingAlgo != nil {
+		return *v.MemoryRoundingAlgo
+	}
+	memoryRoundingAlgo := utils.GetEnv("OBLIK_DEFAULT_MEMORY_ROUNDING_ALGO", "")
+	if memoryRoundingAlgo != "" {
+		if algo, ok := calculator.ParseRoundingAlgo(memoryRoundingAlgo); ok {
+			return algo
+		}
+	}
+	return calculator.RoundingAlgoOff
+}
+
+func (v *StrategyConfig) GetMemoryRoundingValue(containerName string) string {
+	if v.Containers[containerName] != nil && v.Containers[containerName].MemoryRoundingValue != nil {
+		return *v.Containers[containerName].MemoryRoundingValue
+	}
+	if v.MemoryRoundingValue != nil {
+		return *v.MemoryRoundingValue
+	}
+	return utils.GetEnv("OBLIK_DEFAULT_MEMORY_ROUNDING_VALUE", "")
+}
+
+func (v *StrategyConfig) GetRuntimeProfile(containerName string) RuntimeProfile {
+	if v.Containers[containerName] != nil && v.Containers[containerName].RuntimeProfile != nil {
+		return *v.Containers[containerName].RuntimeProfile
+	}
+	if v.RuntimeProfile != nil {
+		return *v.RuntimeProfile
+	}
+	runtimeProfileStr := utils.GetEnv("OBLIK_DEFAULT_RUNTIME_PROFILE", "")
+	if runtimeProfileStr != "" {
+		if runtimeProfile, ok := parseRuntimeProfile(runtimeProfileStr); ok {
+			return runtimeProfile
+		}
+	}
+	return RuntimeProfileOff
+}
+
+// GetRuntimeHeapRatio returns the fraction of the memory limit the runtime heap is sized to
+func (v *StrategyConfig) GetRuntimeHeapRatio(containerName string) float64 {
+	runtimeHeapRatio := utils.GetEnv("OBLIK_DEFAULT_RUNTIME_HEAP_RATIO", "0.75")
+	if v.Containers[containerName] != nil && v.Containers[containerName].RuntimeHeapRatio != nil {
+		runtimeHeapRatio = *v.Containers[containerName].RuntimeHeapRatio
+	} else if v.RuntimeHeapRatio != nil {
+		runtimeHeapRatio = *v.RuntimeHeapRatio
+	}
+	ratio, err := strconv.ParseFloat(runtimeHeapRatio, 64)
+	if err != nil || ratio <= 0 || ratio > 1 {
+		klog.Warningf("Invalid runtime-heap-ratio: %s, using 0.75", runtimeHeapRatio)
+		return 0.75
+	}
+	return ratio
+}
+
+// GetRuntimeCpuRatio returns the fraction of the CPU limit GOMAXPROCS is set to
+func (v *StrategyConfig) GetRuntimeCpuRatio(containerName string) float64 {
+	runtimeCpuRatio := utils.GetEnv("OBLIK_DEFAULT_RUNTIME_CPU_RATIO", "1")
+	if v.Containers[containerName] != nil && v.Containers[containerName].RuntimeCpuRatio != nil {
+		runtimeCpuRatio = *v.Containers[containerName].RuntimeCpuRatio
+	} else if v.RuntimeCpuRatio != nil {
+		runtimeCpuRatio = *v.RuntimeCpuRatio
+	}
+	ratio, err := strconv.ParseFloat(runtimeCpuRatio, 64)
+	if err != nil || ratio <= 0 {
+		klog.Warningf("Invalid runtime-cpu-ratio: %s, using 1", runtimeCpuRatio)
+		return 1
+	}
+	return ratio
+}
+
+func (v *StrategyConfig) GetJVMOptionsEnv(containerName string) string {
+	if v.Containers[containerName] != nil && v.Containers[containerName].JVMOptionsEnv != nil {
+		return *v.Containers[containerName].JVMOptionsEnv
+	}
+	if v.JVMOptionsEnv != nil {
+		return *v.JVMOptionsEnv
+	}
+	return utils.GetEnv("OBLIK_DEFAULT_JVM_OPTIONS_ENV", "JAVA_TOOL_OPTIONS")
+}
+
+func (v *StrategyConfig) GetJVMHeapFlag(containerName string) JVMHeapFlag {
+	if v.Containers[containerName] != nil && v.Containers[containerName].JVMHeapFlag != nil {
+		return *v.Containers[containerName].JVMHeapFlag
+	}
+	if v.JVMHeapFlag != nil {
+		return *v.JVMHeapFlag
+	}
+	jvmHeapFlagStr := utils.GetEnv("OBLIK_DEFAULT_JVM_HEAP_FLAG", "")
+	if jvmHeapFlagStr != "" {
+		if jvmHeapFlag, ok := parseJVMHeapFlag(jvmHeapFlagStr); ok {
+			return jvmHeapFlag
+		}
+	}
+	return JVMHeapFlagMaxRAMPercentage
+}
+
+// IsEphemeralStorageEnabled returns whether the ephemeral-storage of the workload or one of its containers is managed
+func (v *StrategyConfig) IsEphemeralStorageEnabled() bool {
+	if v.GetEphemeralStorageApplyMode("") == ApplyModeEnforce {
+		return true
+	}
+	for containerName := range v.Containers {
+		if v.GetEphemeralStorageApplyMode(containerName) == ApplyModeEnforce {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *StrategyConfig) GetEphemeralStorageApplyMode(containerName string) ApplyMode {
+	if v.Containers[containerName] != nil && v.Containers[containerName].EphemeralStorageApplyMode != nil {
+		return *v.Containers[containerName].EphemeralStorageApplyMode
+	}
+	if v.EphemeralStorageApplyMode != nil {
+		return *v.EphemeralStorageApplyMode
+	}
+	ephemeralStorageApplyMode := utils.GetEnv("OBLIK_DEFAULT_EPHEMERAL_STORAGE_APPLY_MODE", "")
+	if ephemeralStorageApplyMode != "" {
+		if applyMode, ok := parseApplyMode(ephemeralStorageApplyMode); ok {
+			return applyMode
+		}
+	}
+	return ApplyModeOff
+}
+
+func (v *StrategyConfig) GetRequestEphemeralStorageValue(containerName string) *string {
+	if v.Containers[containerName] != nil && v.Containers[containerName].RequestEphemeralStorageValue != nil {
+		return v.Containers[containerName].RequestEphemeralStorageValue
+	}
+	return v.RequestEphemeralStorageValue
+}
+
+func (v *StrategyConfig) GetLimitEphemeralStorageValue(containerName string) *string {
+	if v.Containers[containerName] != nil && v.Containers[containerName].LimitEphemeralStorageValue != nil {
+		return v.Containers[containerName].LimitEphemeralStorageValue
+	}
+	return v.LimitEphemeralStorageValue
+}
+
+func (v *StrategyConfig) GetMinRequestEphemeralStorage(containerName string) *resource.Quantity {
+	if v.Containers[containerName] != nil && v.Containers[containerName].MinRequestEphemeralStorage != nil {
+		return v.Containers[containerName].MinRequestEphemeralStorage
+	}
+	if v.MinRequestEphemeralStorage != nil {
+		return v.MinRequestEphemeralStorage
+	}
+	return getQuantityEnv("OBLIK_DEFAULT_MIN_REQUEST_EPHEMERAL_STORAGE", "min-request-ephemeral-storage")
+}
+
+func (v *StrategyConfig) GetMaxRequestEphemeralStorage(containerName string) *resource.Quantity {
+	if v.Containers[containerName] != nil && v.Containers[containerName].MaxRequestEphemeralStorage != nil {
+		return v.Containers[containerName].MaxRequestEphemeralStorage
+	}
+	if v.MaxRequestEphemeralStorage != nil {
+		return v.MaxRequestEphemeralStorage
+	}
+	return getQuantityEnv("OBLIK_DEFAULT_MAX_REQUEST_EPHEMERAL_STORAGE", "max-request-ephemeral-storage")
+}
+
+func (v *StrategyConfig) GetMinLimitEphemeralStorage(containerName string) *resource.Quantity {
+	if v.Containers[containerName] != nil && v.Containers[containerName].MinLimitEphemeralStorage != nil {
+		return v.Containers[containerName].MinLimitEphemeralStorage
+	}
+	if v.MinLimitEphemeralStorage != nil {
+		return v.MinLimitEphemeralStorage
+	}
+	return getQuantityEnv("OBLIK_DEFAULT_MIN_LIMIT_EPHEMERAL_STORAGE", "min-limit-ephemeral-storage")
+}
+
+func (v *StrategyConfig) GetMaxLimitEphemeralStorage(containerName string) *resource.Quantity {
+	if v.Containers[containerName] != nil && v.Containers[containerName].MaxLimitEphemeralStorage != nil {
+		return v.Containers[containerName].MaxLimitEphemeralStorage
+	}
+	if v.MaxLimitEphemeralStorage != nil {
+		return v.MaxLimitEphemeralStorage
+	}
+	return getQuantityEnv("OBLIK_DEFAULT_MAX_LIMIT_EPHEMERAL_STORAGE", "max-limit-ephemeral-storage")
+}
+
+// GetLimitEphemeralStorageRatio returns the ratio of the request the ephemeral-storage limit is set to, 0 to leave the limit to the bounds
+func (v *StrategyConfig) GetLimitEphemeralStorageRatio(containerName string) float64 {
+	limitEphemeralStorageRatio := utils.GetEnv("OBLIK_DEFAULT_LIMIT_EPHEMERAL_STORAGE_RATIO", "")
+	if v.Containers[containerName] != nil && v.Containers[containerName].LimitEphemeralStorageRatio != nil {
+		limitEphemeralStorageRatio = *v.Containers[containerName].LimitEphemeralStorageRatio
+	} else if v.LimitEphemeralStorageRatio != nil {
+		limitEphemeralStorageRatio = *v.LimitEphemeralStorageRatio
+	}
+	if limitEphemeralStorageRatio == "" {
+		return 0
+	}
+	ratio, err := strconv.ParseFloat(limitEphemeralStorageRatio, 64)
+	if err != nil || ratio < 1 {
+		klog.Warningf("Invalid limit-ephemeral-storage-ratio: %s, must be at least 1", limitEphemeralStorageRatio)
+		return 0
+	}
+	return ratio
+}
+
+// GetEphemeralStorageHeadroom returns the fraction added to the ephemeral-storage usage for the request
+func (v *StrategyConfig) GetEphemeralStorageHeadroom(containerName string) float64 {
+	ephemeralStorageHeadroom := utils.GetEnv("OBLIK_DEFAULT_EPHEMERAL_STORAGE_HEADROOM", "0.25")
+	if v.Containers[containerName] != nil && v.Containers[containerName].EphemeralStorageHeadroom != nil {
+		ephemeralStorageHeadroom = *v.Containers[containerName].EphemeralStorageHeadroom
+	} else if v.EphemeralStorageHeadroom != nil {
+		ephemeralStorageHeadroom = *v.EphemeralStorageHeadroom
+	}
+	headroom, err := strconv.ParseFloat(ephemeralStorageHeadroom, 64)
+	if err != nil || headroom < 0 {
+		klog.Warningf("Invalid ephemeral-storage-headroom: %s, must be a positive number", ephemeralStorageHeadroom)
+		return 0.25
+	}
+	return headroom
+}
+
+// IsPodResourcesEnabled returns whether the pod-level resources are managed instead of the containers resources
+func (v *StrategyConfig) IsPodResourcesEnabled() bool {
+	return v.PodResourcesMode == ApplyModeEnforce
+}
+
+// GetQoSClass returns the shape enforced on the requests and limits of the container
+func (v *StrategyConfig) GetQoSClass(containerName string) QoSClass {
+	if v.Containers[containerName] != nil && v.Containers[containerName].QoSClass != nil {
+		return *v.Containers[containerName].QoSClass
+	}
+	if v.QoSClass != nil {
+		return *v.QoSClass
+	}
+	qosClassStr := utils.GetEnv("OBLIK_DEFAULT_QOS_CLASS", "")
+	if qosClassStr != "" {
+		if qosClass, ok := parseQoSClass(qosClassStr); ok {
+			return qosClass
+		}
+	}
+	return QoSClassOff
+}
+
+// GetQoSBurstableMargin returns the minimum fraction the limits exceed the requests by with the burstable QoS class
+func (v *StrategyConfig) GetQoSBurstableMargin(containerName string) float64 {
+	qosBurstableMargin := utils.GetEnv("OBLIK_DEFAULT_QOS_BURSTABLE_MARGIN", "0.1")
+	if v.Containers[containerName] != nil && v.Containers[containerName].QoSBurstableMargin != nil {
+		qosBurstableMargin = *v.Containers[containerName].QoSBurstableMargin
+	} else if v.QoSBurstableMargin != nil {
+		qosBurstableMargin = *v.QoSBurstableMargin
+	}
+	margin, err := strconv.ParseFloat(qosBurstableMargin, 64)
+	if err != nil || margin <= 0 {
+		klog.Warningf("Invalid qos-burstable-margin: %s, using 0.1", qosBurstableMargin)
+		return 0.1
+	}
+	return margin
+}
+
+// IsLimitCPURemoveEnabled returns whether the CPU limit of the workload or of one of its containers is removed
+func (v *StrategyConfig) IsLimitCPURemoveEnabled() bool {
+	if v.LimitCPUApplyMode != nil && *v.LimitCPUApplyMode == ApplyModeRemove {
+		return true
+	}
+	for _, containerConfig := range v.Containers {
+		if containerConfig.LimitCPUApplyMode != nil && *containerConfig.LimitCPUApplyMode == ApplyModeRemove {
+			return true
+		}
+	}
+	return false
+}
+
+// GetVPAMinAllowedCpu returns the minimum CPU recommendation of the managed VPA for the container
+func (v *StrategyConfig) GetVPAMinAllowedCpu(containerName string) *resource.Quantity {
+	if v.Containers[containerName] != nil && v.Containers[containerName].VPAMinAllowedCpu != nil {
+		return v.Containers[containerName].VPAMinAllowedCpu
+	}
+	return v.VPAMinAllowedCpu
+}
+
+// GetVPAMaxAllowedCpu returns the maximum CPU recommendation of the managed VPA for the container
+func (v *StrategyConfig) GetVPAMaxAllowedCpu(containerName string) *resource.Quantity {
+	if v.Containers[containerName] != nil && v.Containers[containerName].VPAMaxAllowedCpu != nil {
+		return v.Containers[containerName].VPAMaxAllowedCpu
+	}
+	return v.VPAMaxAllowedCpu
+}
+
+// GetVPAMinAllowedMemory returns the minimum memory recommendation of the managed VPA for the container
+func (v *StrategyConfig) GetVPAMinAllowedMemory(containerName string) *resource.Quantity {
+	if v.Containers[containerName] != nil && v.Containers[containerName].VPAMinAllowedMemory != nil {
+		return v.Containers[containerName].VPAMinAllowedMemory
+	}
+	return v.VPAMinAllowedMemory
+}
+
+// GetVPAMaxAllowedMemory returns the maximum memory recommendation of the managed VPA for the container
+func (v *StrategyConfig) GetVPAMaxAllowedMemory(containerName string) *resource.Quantity {
+	if v.Containers[containerName] != nil && v.Containers[containerName].VPAMaxAllowedMemory != nil {
+		return v.Containers[containerName].VPAMaxAllowedMemory
+	}
+	return v.VPAMaxAllowedMemory
+}
+
+// GetVPAControlledResources returns the resources the managed VPA recommends for the container, nil for the VPA default
+func (v *StrategyConfig) GetVPAControlledResources(containerName string) []corev1.ResourceName {
+	if v.Containers[containerName] != nil && v.Containers[containerName].VPAControlledResources != nil {
+		return v.Containers[containerName].VPAControlledResources
+	}
+	return v.VPAControlledResources
+}
+
+// GetVPAControlledValues returns the values the managed VPA controls for the container, nil for the VPA default
+func (v *StrategyConfig) GetVPAControlledValues(containerName string) *vpa.ContainerControlledValues {
+	if v.Containers[containerName] != nil && v.Containers[containerName].VPAControlledValues != nil {
+		return v.Containers[containerName].VPAControlledValues
+	}
+	return v.VPAControlledValues
+}
+
+// GetVPAMode returns the scaling mode of the container in the managed VPA, "Off" excluding it from the recommendations
+func (v *StrategyConfig) GetVPAMode(containerName string) *vpa.ContainerScalingMode {
+	if v.Containers[containerName] != nil && v.Containers[containerName].VPAMode != nil {
+		return v.Containers[containerName].VPAMode
+	}
+	return v.VPAMode
+}
+
+// HasVPAResourcePolicy returns whether the LoadCfg sets a field of the resource policy of the managed VPA
+func (cfg *LoadCfg) HasVPAResourcePolicy() bool {
+	return cfg.VPAMinAllowedCpu != nil || cfg.VPAMaxAllowedCpu != nil || cfg.VPAMinAllowedMemory != nil || cfg.VPAMaxAllowedMemory != nil ||
+		cfg.VPAControlledResources != nil || cfg.VPAControlledValues != nil || cfg.VPAMode != nil
+}
@@ -1,3 +1,11 @@
 package constants
 
 const PREFIX = "oblik.socialgouv.io/"
+
+// AppliedResourcesAnnotation records the containers resources last applied by Oblik on a workload, to detect their drift.
+// It is out of PREFIX so that it is neither replaced by the ResourcesConfig sync nor copied to the VPA
+const AppliedResourcesAnnotation = "applied.oblik.socialgouv.io/resources"
+
+// GitOpsIgnoreDifferencesAnnotation records the ignoreDifferences entry to add to the ArgoCD Application of a workload
+// whose resources are also set by ArgoCD, for the gitops-policy "annotate"
+const GitOpsIgnoreDifferencesAnnotation = "applied.oblik.socialgouv.io/ignore-differences"
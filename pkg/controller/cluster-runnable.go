@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	"github.com/SocialGouv/oblik/pkg/watcher"
+	corev1 "k8s.io/api/core/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+const (
+	// clusterSyncTimeout is the time given to the cache of a remote cluster to sync, before reporting the cluster
+	// unhealthy and waiting again
+	clusterSyncTimeout = 2 * time.Minute
+	// clusterRetryInterval is the interval to retry reaching a remote cluster whose API can't be discovered
+	clusterRetryInterval = 30 * time.Second
+)
+
+var errCacheSyncTimeout = errors.New("cache sync timed out")
+
+// clusterRunnable runs the cache of a remote cluster, and registers its reconcilers once the cache synced: an
+// unreachable cluster is reported unhealthy until it can be reached, instead of failing the manager on the cache sync
+// timeout of its controllers
+type clusterRunnable struct {
+	Manager       ctrl.Manager
+	Cluster       cluster.Cluster
+	KubeClients   *client.KubeClients
+	WorkloadTypes map[string]watcher.WorkloadType
+}
+
+func (c *clusterRunnable) Start(ctx context.Context) error {
+	go func() {
+		if err := c.Cluster.Start(ctx); err != nil {
+			klog.Errorf("Error running cache of cluster %s: %s", c.KubeClients.Cluster, err.Error())
+		}
+	}()
+
+	for {
+		err := c.waitForCacheSync(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			break
+		}
+		klog.Warningf("Cluster %s is unhealthy: %s", c.KubeClients.Cluster, err.Error())
+		reporting.SetClusterHealthy(c.KubeClients.Cluster, false)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(clusterRetryInterval):
+		}
+	}
+	reporting.SetClusterHealthy(c.KubeClients.Cluster, true)
+	klog.Infof("Cache of cluster %s synced", c.KubeClients.Cluster)
+
+	// the informers are synced, the controllers don't wait for them and can't time out
+	if err := setupCluster(c.Manager, c.Cluster, c.KubeClients, c.WorkloadTypes); err != nil {
+		klog.Errorf("Unable to set up reconcilers of cluster %s: %s", c.KubeClients.Cluster, err.Error())
+		reporting.SetClusterHealthy(c.KubeClients.Cluster, false)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// waitForCacheSync creates the informers watched by the reconcilers of the cluster, which needs the discovery of its
+// API, and waits for their sync
+func (c *clusterRunnable) waitForCacheSync(ctx context.Context) error {
+	objects := []ctrlclient.Object{&vpa.VerticalPodAutoscaler{}, &corev1.Namespace{}}
+	for _, workloadType := range c.WorkloadTypes {
+		objects = append(objects, workloadType.NewObject())
+	}
+	for _, obj := range objects {
+		if _, err := c.Cluster.GetCache().GetInformer(ctx, obj, cache.BlockUntilSynced(false)); err != nil {
+			return err
+		}
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, clusterSyncTimeout)
+	defer cancel()
+	if !c.Cluster.GetCache().WaitForCacheSync(syncCtx) {
+		return errCacheSyncTimeout
+	}
+	return nil
+}
+
+// NeedLeaderElection runs the cache of the cluster on all the replicas, as the cache of the cluster running Oblik,
+// its reconcilers being elected on their own
+func (c *clusterRunnable) NeedLeaderElection() bool {
+	return false
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"os"
 
-	oblikv1 "github.com/SocialGouv/oblik/pkg/apis/oblik/v1"
 	"github.com/SocialGouv/oblik/pkg/client"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/sharding"
+	"github.com/SocialGouv/oblik/pkg/watcher"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 	"k8s.io/klog/v2"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
@@ -20,36 +25,40 @@ func Run(leaderElect bool, ctx context.Context) {
 	// Set up controller-runtime logger
 	log.SetLogger(zap.New())
 
+	// Register ResourcesConfig types with the client-go scheme
+	client.AddToScheme()
+
+	config.ValidateDefaultExprs()
+
+	kubeClients := client.NewKubeClients()
+	webhookOnly := config.IsWebhookOnly()
+	if sharding.IsEnabled() && !webhookOnly {
+		watcher.Sharder = sharding.New(kubeClients.Clientset)
+		klog.Infof("Sharding the workloads, replica %s", watcher.Sharder.Identity)
+	}
+	workloadTypes := watcher.GetWorkloadTypes(ctx, kubeClients)
+
 	// Create the manager
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	mgr, err := ctrl.NewManager(kubeClients.RestConfig, ctrl.Options{
+		Scheme:           client.Scheme,
 		LeaderElection:   leaderElect,
 		LeaderElectionID: "oblik-operator-leader-election",
+		Cache: cache.Options{
+			ByObject: watcher.GetCacheByObject(workloadTypes),
+		},
 	})
 	if err != nil {
 		klog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	// Register ResourcesConfig types with the controller-runtime scheme
-	schemeBuilder := runtime.NewSchemeBuilder(func(scheme *runtime.Scheme) error {
-		scheme.AddKnownTypes(
-			schema.GroupVersion{Group: oblikv1.GroupName, Version: oblikv1.Version},
-			&oblikv1.ResourcesConfig{},
-			&oblikv1.ResourcesConfigList{},
-		)
-		metav1.AddToGroupVersion(scheme, schema.GroupVersion{Group: oblikv1.GroupName, Version: oblikv1.Version})
-		return nil
-	})
-	if err := schemeBuilder.AddToScheme(mgr.GetScheme()); err != nil {
-		klog.Error(err, "unable to add ResourcesConfig to scheme")
+	// The webhook, the reconcilers and the updaters read from the shared cache of the manager
+	kubeClients.Reader = mgr.GetCache()
+	if err := registerInformers(ctx, mgr.GetCache()); err != nil {
+		klog.Error(err, "unable to register informers")
 		os.Exit(1)
 	}
 
-	// Register ResourcesConfig types with the client-go scheme
-	client.AddToScheme()
-
-	kubeClients := client.NewKubeClients()
-
 	if err := mgr.Add(&serverRunnable{
 		KubeClients: kubeClients,
 	}); err != nil {
@@ -57,6 +66,22 @@ func Run(leaderElect bool, ctx context.Context) {
 		os.Exit(1)
 	}
 
+	if webhookOnly {
+		klog.Info("Serving the webhook only, the workloads are managed by the Oblik instance of another cluster")
+	} else {
+		setupControllers(ctx, mgr, kubeClients, workloadTypes)
+	}
+
+	klog.Info("Starting Oblik Operator...")
+	if err := mgr.Start(ctx); err != nil {
+		klog.Error(err, "problem running manager")
+		os.Exit(1)
+	}
+}
+
+// setupControllers registers the reconcilers, the scheduled updates and the cleaner of the cluster running Oblik and
+// of the remote clusters, whose reconcilers are registered once their cache synced
+func setupControllers(ctx context.Context, mgr ctrl.Manager, kubeClients *client.KubeClients, workloadTypes map[string]watcher.WorkloadType) {
 	if err := mgr.Add(&watcherRunnable{
 		KubeClients: kubeClients,
 	}); err != nil {
@@ -64,9 +89,74 @@ func Run(leaderElect bool, ctx context.Context) {
 		os.Exit(1)
 	}
 
-	klog.Info("Starting Oblik Operator...")
-	if err := mgr.Start(ctx); err != nil {
-		klog.Error(err, "problem running manager")
+	if watcher.Sharder != nil {
+		if err := mgr.Add(watcher.Sharder); err != nil {
+			klog.Error(err, "unable to add sharder runnable")
+			os.Exit(1)
+		}
+	}
+
+	if err := setupCluster(mgr, mgr, kubeClients, workloadTypes); err != nil {
+		klog.Error(err, "unable to set up reconcilers")
+		os.Exit(1)
+	}
+
+	clusters := []*client.KubeClients{kubeClients}
+	for _, remote := range client.LoadRemoteClusters(ctx, kubeClients) {
+		remoteWorkloadTypes := watcher.GetWorkloadTypes(ctx, remote)
+		cl, err := cluster.New(remote.RestConfig, func(o *cluster.Options) {
+			o.Scheme = client.Scheme
+			o.Cache.ByObject = watcher.GetCacheByObject(remoteWorkloadTypes)
+		})
+		if err != nil {
+			klog.Errorf("Unable to create cache of cluster %s: %s", remote.Cluster, err.Error())
+			continue
+		}
+		remote.Reader = cl.GetCache()
+		if err := mgr.Add(&clusterRunnable{
+			Manager:       mgr,
+			Cluster:       cl,
+			KubeClients:   remote,
+			WorkloadTypes: remoteWorkloadTypes,
+		}); err != nil {
+			klog.Errorf("Unable to add cache of cluster %s: %s", remote.Cluster, err.Error())
+			continue
+		}
+		clusters = append(clusters, remote)
+	}
+
+	if err := watcher.SetupResourcesConfigReconciler(mgr, kubeClients, clusters); err != nil {
+		klog.Error(err, "unable to set up ResourcesConfig reconciler")
 		os.Exit(1)
 	}
 }
+
+// setupCluster registers the reconcilers of the workloads and VPAs and the cleaner of a cluster
+func setupCluster(mgr ctrl.Manager, cl cluster.Cluster, kubeClients *client.KubeClients, workloadTypes map[string]watcher.WorkloadType) error {
+	if err := watcher.SetupWorkloadReconcilers(mgr, cl, kubeClients, workloadTypes); err != nil {
+		return err
+	}
+	if err := watcher.SetupVPAReconciler(mgr, cl, kubeClients); err != nil {
+		return err
+	}
+	return mgr.Add(&cleanerRunnable{
+		KubeClients: kubeClients,
+	})
+}
+
+// registerInformers starts with the cache the informers read by the webhook, which also runs on the replicas not
+// leading, where no reconciler starts them, so that the first admissions don't wait for their sync
+func registerInformers(ctx context.Context, informers cache.Informers) error {
+	objects := []ctrlclient.Object{
+		&vpa.VerticalPodAutoscaler{},
+		&autoscalingv2.HorizontalPodAutoscaler{},
+		&corev1.LimitRange{},
+		&corev1.Namespace{},
+	}
+	for _, obj := range objects {
+		if _, err := informers.GetInformer(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
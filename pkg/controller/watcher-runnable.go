@@ -16,25 +16,28 @@ func (w *watcherRunnable) Start(ctx context.Context) error {
 	watcher.CronScheduler.Start()
 
 	go func() {
-		cleaner.CleanUpVPAs(ctx, w.KubeClients)
+		watcher.ApplyQueue.Run(ctx)
 	}()
 
-	go func() {
-		watcher.WatchWorkloads(ctx, w.KubeClients)
-	}()
+	<-ctx.Done()
+	return nil
+}
 
-	go func() {
-		watcher.WatchVPAs(ctx, w.KubeClients)
-	}()
+// NeedLeaderElection runs the scheduled updates on all the replicas with sharding, each one for its own workloads
+func (w *watcherRunnable) NeedLeaderElection() bool {
+	return watcher.Sharder == nil
+}
 
-	go func() {
-		watcher.WatchResourcesConfigs(ctx, w.KubeClients)
-	}()
+// cleanerRunnable deletes the orphaned VPAs of the whole cluster, on the leader only
+type cleanerRunnable struct {
+	KubeClients *client.KubeClients
+}
 
-	<-ctx.Done()
+func (c *cleanerRunnable) Start(ctx context.Context) error {
+	cleaner.CleanUpVPAs(ctx, c.KubeClients)
 	return nil
 }
 
-func (w *watcherRunnable) NeedLeaderElection() bool {
+func (c *cleanerRunnable) NeedLeaderElection() bool {
 	return true
 }
@@ -6,12 +6,21 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-func ApplyRecommendationsToContainers(containers []corev1.Container, requestRecommendations []TargetRecommendation, limitRecommendations []TargetRecommendation, scfg *config.StrategyConfig) *reporting.UpdateResult {
+func ApplyRecommendationsToContainers(containers []corev1.Container, requestRecommendations []TargetRecommendation, limitRecommendations []TargetRecommendation, scfg *config.StrategyConfig, workload *Workload) *reporting.UpdateResult {
 	changes := []reporting.Change{}
 	update := reporting.UpdateResult{
 		Key: scfg.Key,
 	}
 
+	originals := make([]corev1.ResourceRequirements, len(containers))
+	for index := range containers {
+		originals[index] = *containers[index].Resources.DeepCopy()
+	}
+	var originalPodResources *corev1.ResourceRequirements
+	if workload != nil && workload.PodResources != nil {
+		originalPodResources = workload.PodResources.DeepCopy()
+	}
+
 	for index, container := range containers {
 		var containerRequestRecommendation *TargetRecommendation
 		var containerLimitRecommendation *TargetRecommendation
@@ -42,17 +51,45 @@ func ApplyRecommendationsToContainers(containers []corev1.Container, requestReco
 		containerRef := &container
 
 		if containerRequestRecommendation.Cpu != nil {
-			changes = setContainerCpuRequest(containerRef, containerRequestRecommendation, changes, scfg)
-			changes = setContainerCpuLimit(containerRef, containerRequestRecommendation, containerLimitRecommendation, changes, scfg)
+			changes = setContainerCpuRequest(containerRef, containerRequestRecommendation, changes, scfg, workload)
+			changes = setContainerCpuLimit(containerRef, containerRequestRecommendation, containerLimitRecommendation, changes, scfg, workload)
 		}
 
 		if containerRequestRecommendation.Memory != nil {
-			changes = setContainerMemoryRequest(containerRef, containerRequestRecommendation, changes, scfg)
-			changes = setContainerMemoryLimit(containerRef, containerRequestRecommendation, containerLimitRecommendation, changes, scfg)
+			changes = setContainerMemoryRequest(containerRef, containerRequestRecommendation, changes, scfg, workload)
+			changes = setContainerMemoryLimit(containerRef, containerRequestRecommendation, containerLimitRecommendation, changes, scfg, workload)
 
 		}
+
+		changes = setContainerEphemeralStorage(containerRef, containerRequestRecommendation, changes, scfg)
 		containers[index] = *containerRef
 	}
+	changes = capTotalRequests(containers, changes, scfg, workload, corev1.ResourceCPU)
+	changes = capTotalRequests(containers, changes, scfg, workload, corev1.ResourceMemory)
+	// the CPU limit removal, the QoS class shape and the runtime settings follow the final resources, after the caps
+	for index := range containers {
+		if scfg.GetLimitCPUApplyMode(containers[index].Name) == config.ApplyModeRemove {
+			changes = removeContainerCpuLimit(&containers[index], changes, workload)
+		}
+		changes = applyQoSClass(&containers[index], changes, scfg)
+		changes = applyRuntimeProfile(&containers[index], changes, scfg)
+	}
+	changes = applyPodResources(containers, originals, changes, scfg, workload)
+
+	finals := make([]corev1.ResourceRequirements, len(containers))
+	for index := range containers {
+		finals[index] = containers[index].Resources
+	}
+	var podResources *corev1.ResourceRequirements
+	if workload != nil {
+		podResources = workload.PodResources
+	}
+	oldQoSClass := getQoSClass(originals, originalPodResources)
+	newQoSClass := getQoSClass(finals, podResources)
+	if oldQoSClass != newQoSClass {
+		update.OldQoSClass = oldQoSClass
+		update.NewQoSClass = newQoSClass
+	}
 	update.Changes = changes
 	return &update
 }
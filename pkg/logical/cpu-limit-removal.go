@@ -0,0 +1,92 @@
+package logical
+
+import (
+	"fmt"
+
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+// removeContainerCpuLimit deletes the CPU limit of the container. As a LimitRange of the namespace defining a default
+// CPU limit or a maximum limit to request ratio would add a limit back to the pods, or reject them, the limit is then
+// set to the highest value the LimitRange allows when it has a maximum or a ratio
+func removeContainerCpuLimit(container *corev1.Container, changes []reporting.Change, workload *Workload) []reporting.Change {
+	containerName := container.Name
+	cpuLimit, hasLimit := container.Resources.Limits[corev1.ResourceCPU]
+
+	reason := ""
+	if limitRange, item := workload.getContainerCpuLimitRange(); item != nil {
+		defaultLimit, hasDefault := item.Default[corev1.ResourceCPU]
+		maxLimit, hasMax := item.Max[corev1.ResourceCPU]
+		ratio, hasRatio := item.MaxLimitRequestRatio[corev1.ResourceCPU]
+		request, hasRequest := container.Resources.Requests[corev1.ResourceCPU]
+
+		var allowed *resource.Quantity
+		if hasRatio && hasRequest {
+			ratioLimit := *resource.NewMilliQuantity(int64(float64(request.MilliValue())*ratio.AsApproximateFloat64()), resource.DecimalSI)
+			allowed = &ratioLimit
+		}
+		if hasMax && (allowed == nil || maxLimit.Cmp(*allowed) == -1) {
+			allowed = &maxLimit
+		}
+
+		switch {
+		case allowed != nil:
+			newCPULimit := *allowed
+			if hasLimit && newCPULimit.Cmp(cpuLimit) == 0 {
+				return changes
+			}
+			if container.Resources.Limits == nil {
+				container.Resources.Limits = corev1.ResourceList{}
+			}
+			container.Resources.Limits[corev1.ResourceCPU] = newCPULimit
+			return append(changes, reporting.Change{
+				Old:           cpuLimit,
+				New:           newCPULimit,
+				Type:          reporting.UpdateTypeCpuLimit,
+				ContainerName: containerName,
+				Reason:        fmt.Sprintf("highest CPU limit allowed by LimitRange %s", limitRange.Name),
+			})
+		case hasDefault:
+			klog.Warningf("LimitRange %s/%s adds back a default CPU limit of %s to container %s", limitRange.Namespace, limitRange.Name, defaultLimit.String(), containerName)
+			reason = fmt.Sprintf("LimitRange %s adds back its default %s", limitRange.Name, defaultLimit.String())
+		}
+	}
+
+	if !hasLimit {
+		return changes
+	}
+	delete(container.Resources.Limits, corev1.ResourceCPU)
+	return append(changes, reporting.Change{
+		Old:           cpuLimit,
+		Type:          reporting.UpdateTypeCpuLimit,
+		ContainerName: containerName,
+		Reason:        reason,
+		Removed:       true,
+	})
+}
+
+// getContainerCpuLimitRange returns the first container LimitRange item of the namespace constraining the CPU limit
+func (w *Workload) getContainerCpuLimitRange() (*corev1.LimitRange, *corev1.LimitRangeItem) {
+	if w == nil {
+		return nil, nil
+	}
+	for i := range w.LimitRanges {
+		limitRange := &w.LimitRanges[i]
+		for j := range limitRange.Spec.Limits {
+			item := &limitRange.Spec.Limits[j]
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			_, hasDefault := item.Default[corev1.ResourceCPU]
+			_, hasMax := item.Max[corev1.ResourceCPU]
+			_, hasRatio := item.MaxLimitRequestRatio[corev1.ResourceCPU]
+			if hasDefault || hasMax || hasRatio {
+				return limitRange, item
+			}
+		}
+	}
+	return nil, nil
+}
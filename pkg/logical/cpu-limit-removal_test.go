@@ -0,0 +1,102 @@
+package logical
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newCpuLimitRange(item corev1.LimitRangeItem) corev1.LimitRange {
+	item.Type = corev1.LimitTypeContainer
+	return corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "limits", Namespace: "test"},
+		Spec:       corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{item}},
+	}
+}
+
+func TestRemoveContainerCpuLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		limits      corev1.ResourceList
+		limitRanges []corev1.LimitRange
+		wantLimit   string
+		wantChange  *reporting.Change
+	}{
+		{
+			name:       "limit removed",
+			limits:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			wantChange: &reporting.Change{Type: reporting.UpdateTypeCpuLimit, ContainerName: "app", Old: resource.MustParse("1"), Removed: true},
+		},
+		{
+			name: "no limit",
+		},
+		{
+			name:        "limit removed with a default of the LimitRange",
+			limits:      corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			limitRanges: []corev1.LimitRange{newCpuLimitRange(corev1.LimitRangeItem{Default: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}})},
+			wantChange:  &reporting.Change{Type: reporting.UpdateTypeCpuLimit, ContainerName: "app", Old: resource.MustParse("1"), Removed: true, Reason: "LimitRange limits adds back its default 2"},
+		},
+		{
+			name:        "limit set to the max of the LimitRange",
+			limits:      corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			limitRanges: []corev1.LimitRange{newCpuLimitRange(corev1.LimitRangeItem{Max: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")}})},
+			wantLimit:   "4",
+			wantChange:  &reporting.Change{Type: reporting.UpdateTypeCpuLimit, ContainerName: "app", Old: resource.MustParse("1"), New: resource.MustParse("4"), Reason: "highest CPU limit allowed by LimitRange limits"},
+		},
+		{
+			name:   "limit set to the ratio of the LimitRange below its max",
+			limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			limitRanges: []corev1.LimitRange{newCpuLimitRange(corev1.LimitRangeItem{
+				Max:                  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+				MaxLimitRequestRatio: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3")},
+			})},
+			wantLimit:  "1500m",
+			wantChange: &reporting.Change{Type: reporting.UpdateTypeCpuLimit, ContainerName: "app", Old: resource.MustParse("1"), New: resource.MustParse("1500m"), Reason: "highest CPU limit allowed by LimitRange limits"},
+		},
+		{
+			name:        "limit already at the max of the LimitRange",
+			limits:      corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			limitRanges: []corev1.LimitRange{newCpuLimitRange(corev1.LimitRangeItem{Max: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")}})},
+			wantLimit:   "4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := &corev1.Container{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+					Limits:   tt.limits,
+				},
+			}
+			changes := removeContainerCpuLimit(container, nil, &Workload{LimitRanges: tt.limitRanges})
+
+			limit, hasLimit := container.Resources.Limits[corev1.ResourceCPU]
+			if tt.wantLimit == "" && hasLimit {
+				t.Errorf("expected no cpu limit, got %s", limit.String())
+			}
+			if tt.wantLimit != "" && (!hasLimit || limit.Cmp(resource.MustParse(tt.wantLimit)) != 0) {
+				t.Errorf("expected cpu limit %s, got %s", tt.wantLimit, limit.String())
+			}
+
+			if tt.wantChange == nil {
+				if len(changes) != 0 {
+					t.Fatalf("expected no change, got %+v", changes)
+				}
+				return
+			}
+			if len(changes) != 1 {
+				t.Fatalf("expected 1 change, got %+v", changes)
+			}
+			got := changes[0]
+			if got.Type != tt.wantChange.Type || got.ContainerName != tt.wantChange.ContainerName || got.Removed != tt.wantChange.Removed || got.Reason != tt.wantChange.Reason ||
+				got.Old.Cmp(tt.wantChange.Old) != 0 || got.New.Cmp(tt.wantChange.New) != 0 {
+				t.Errorf("expected change %+v, got %+v", *tt.wantChange, got)
+			}
+		})
+	}
+}
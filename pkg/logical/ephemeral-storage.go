@@ -0,0 +1,96 @@
+package logical
+
+import (
+	"math"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+// ephemeralStorageUsageFloor is the lowest ephemeral-storage request derived from the usage, when no minimum is set,
+// a sample of a freshly started container being close to zero
+var ephemeralStorageUsageFloor = resource.MustParse("256Mi")
+
+// setContainerEphemeralStorage sets the ephemeral-storage request from the direct value or the usage of the container,
+// and the limit from the direct value or the ratio of the request, both within their bounds.
+// The usage is a single sample of the kubelet stats: the request derived from it gets a headroom and a floor, and the
+// limit derived from it is only raised, a quiet sample never lowering the limit the container may need
+func setContainerEphemeralStorage(container *corev1.Container, containerRequestRecommendation *TargetRecommendation, changes []reporting.Change, scfg *config.StrategyConfig) []reporting.Change {
+	containerName := container.Name
+	if scfg.GetEphemeralStorageApplyMode(containerName) != config.ApplyModeEnforce {
+		return changes
+	}
+
+	request := container.Resources.Requests[corev1.ResourceEphemeralStorage]
+	newRequest := request
+	fromUsage := false
+	if value := scfg.GetRequestEphemeralStorageValue(containerName); value != nil {
+		directRequest, err := resource.ParseQuantity(*value)
+		if err != nil {
+			klog.Warningf("Error parsing direct ephemeral-storage request value: %s, error: %s", *value, err.Error())
+		} else {
+			newRequest = directRequest
+		}
+	} else if containerRequestRecommendation != nil && containerRequestRecommendation.EphemeralStorage != nil {
+		usage := containerRequestRecommendation.EphemeralStorage
+		newRequest = *resource.NewQuantity(int64(math.Ceil(float64(usage.Value())*(1+scfg.GetEphemeralStorageHeadroom(containerName)))), resource.BinarySI)
+		fromUsage = true
+	}
+	if minRequest := scfg.GetMinRequestEphemeralStorage(containerName); minRequest != nil {
+		if newRequest.Cmp(*minRequest) == -1 {
+			newRequest = *minRequest
+		}
+	} else if fromUsage && newRequest.Cmp(ephemeralStorageUsageFloor) == -1 {
+		newRequest = ephemeralStorageUsageFloor
+	}
+	if maxRequest := scfg.GetMaxRequestEphemeralStorage(containerName); maxRequest != nil && !newRequest.IsZero() && newRequest.Cmp(*maxRequest) == 1 {
+		newRequest = *maxRequest
+	}
+	if !newRequest.IsZero() && newRequest.Cmp(request) != 0 {
+		changes = append(changes, reporting.Change{
+			Old:           request,
+			New:           newRequest,
+			Type:          reporting.UpdateTypeEphemeralStorageRequest,
+			ContainerName: containerName,
+		})
+		container.Resources.Requests[corev1.ResourceEphemeralStorage] = newRequest
+	}
+
+	limit := container.Resources.Limits[corev1.ResourceEphemeralStorage]
+	newLimit := limit
+	if value := scfg.GetLimitEphemeralStorageValue(containerName); value != nil {
+		directLimit, err := resource.ParseQuantity(*value)
+		if err != nil {
+			klog.Warningf("Error parsing direct ephemeral-storage limit value: %s, error: %s", *value, err.Error())
+		} else {
+			newLimit = directLimit
+		}
+	} else if ratio := scfg.GetLimitEphemeralStorageRatio(containerName); ratio > 0 && !newRequest.IsZero() {
+		ratioLimit := *resource.NewQuantity(int64(math.Ceil(float64(newRequest.Value())*ratio)), resource.BinarySI)
+		if !fromUsage || limit.IsZero() || ratioLimit.Cmp(limit) == 1 {
+			newLimit = ratioLimit
+		}
+	}
+	if minLimit := scfg.GetMinLimitEphemeralStorage(containerName); minLimit != nil && newLimit.Cmp(*minLimit) == -1 {
+		newLimit = *minLimit
+	}
+	if maxLimit := scfg.GetMaxLimitEphemeralStorage(containerName); maxLimit != nil && !newLimit.IsZero() && newLimit.Cmp(*maxLimit) == 1 {
+		newLimit = *maxLimit
+	}
+	if !newLimit.IsZero() && newLimit.Cmp(newRequest) == -1 {
+		newLimit = newRequest
+	}
+	if !newLimit.IsZero() && newLimit.Cmp(limit) != 0 {
+		changes = append(changes, reporting.Change{
+			Old:           limit,
+			New:           newLimit,
+			Type:          reporting.UpdateTypeEphemeralStorageLimit,
+			ContainerName: containerName,
+		})
+		container.Resources.Limits[corev1.ResourceEphemeralStorage] = newLimit
+	}
+	return changes
+}
@@ -0,0 +1,77 @@
+package logical
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestSetContainerEphemeralStorage(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      string
+		limit        string
+		usage        string
+		directValue  string
+		limitRatio   string
+		wantRequest  string
+		wantLimit    string
+		wantChanges  int
+		applyModeOff bool
+	}{
+		{name: "request from the usage with headroom", usage: "1Gi", wantRequest: "1280Mi", wantChanges: 1},
+		{name: "request from the usage raised to the floor", usage: "10Mi", wantRequest: "256Mi", wantChanges: 1},
+		{name: "direct request", usage: "1Gi", directValue: "2Gi", wantRequest: "2Gi", wantChanges: 1},
+		{name: "limit from the ratio of the request", usage: "1Gi", limitRatio: "2", wantRequest: "1280Mi", wantLimit: "2560Mi", wantChanges: 2},
+		{name: "limit from the usage only raised", request: "1Gi", limit: "4Gi", usage: "1Gi", limitRatio: "2", wantRequest: "1280Mi", wantLimit: "4Gi", wantChanges: 1},
+		{name: "unchanged", request: "1280Mi", usage: "1Gi", wantRequest: "1280Mi"},
+		{name: "apply mode off", usage: "1Gi", applyModeOff: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyMode := config.ApplyModeEnforce
+			if tt.applyModeOff {
+				applyMode = config.ApplyModeOff
+			}
+			scfg := &config.StrategyConfig{LoadCfg: &config.LoadCfg{EphemeralStorageApplyMode: &applyMode}}
+			if tt.directValue != "" {
+				scfg.RequestEphemeralStorageValue = &tt.directValue
+			}
+			if tt.limitRatio != "" {
+				scfg.LimitEphemeralStorageRatio = &tt.limitRatio
+			}
+			container := &corev1.Container{Name: "app", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{}, Limits: corev1.ResourceList{}}}
+			if tt.request != "" {
+				container.Resources.Requests[corev1.ResourceEphemeralStorage] = resource.MustParse(tt.request)
+			}
+			if tt.limit != "" {
+				container.Resources.Limits[corev1.ResourceEphemeralStorage] = resource.MustParse(tt.limit)
+			}
+			usage := resource.MustParse(tt.usage)
+			changes := setContainerEphemeralStorage(container, &TargetRecommendation{ContainerName: "app", EphemeralStorage: &usage}, []reporting.Change{}, scfg)
+
+			if len(changes) != tt.wantChanges {
+				t.Errorf("expected %d change(s), got %v", tt.wantChanges, changes)
+			}
+			checkQuantity(t, "request", container.Resources.Requests, tt.wantRequest)
+			checkQuantity(t, "limit", container.Resources.Limits, tt.wantLimit)
+		})
+	}
+}
+
+func checkQuantity(t *testing.T, name string, list corev1.ResourceList, want string) {
+	t.Helper()
+	got, ok := list[corev1.ResourceEphemeralStorage]
+	if want == "" {
+		if ok {
+			t.Errorf("expected no %s, got %s", name, got.String())
+		}
+		return
+	}
+	if wantQuantity := resource.MustParse(want); !ok || got.Cmp(wantQuantity) != 0 {
+		t.Errorf("expected %s %s, got %s", name, want, got.String())
+	}
+}
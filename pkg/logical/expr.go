@@ -0,0 +1,25 @@
+package logical
+
+import (
+	"github.com/SocialGouv/oblik/pkg/calculator"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// getExprVars returns the values exposed to the expr calculator algorithm for a resource of the container
+func getExprVars(container *corev1.Container, recommendation *TargetRecommendation, resourceName corev1.ResourceName, workload *Workload) *calculator.ExprVars {
+	vars := &calculator.ExprVars{
+		Request: container.Resources.Requests[resourceName],
+		Limit:   container.Resources.Limits[resourceName],
+		Cpu:     container.Resources.Requests[corev1.ResourceCPU],
+		Memory:  container.Resources.Requests[corev1.ResourceMemory],
+	}
+	if recommendation != nil && recommendation.Bounds != nil {
+		vars.Lower = getRecommendationQuantity(recommendation.Bounds.LowerBound, resourceName)
+		vars.Target = getRecommendationQuantity(recommendation.Bounds.Target, resourceName)
+		vars.Upper = getRecommendationQuantity(recommendation.Bounds.UpperBound, resourceName)
+	}
+	if workload != nil && workload.Replicas != nil {
+		vars.Replicas = *workload.Replicas
+	}
+	return vars
+}
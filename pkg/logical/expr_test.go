@@ -0,0 +1,58 @@
+package logical
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+)
+
+func TestGetExprVars(t *testing.T) {
+	container := &corev1.Container{
+		Name: "app",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+		},
+	}
+	recommendation := &TargetRecommendation{
+		ContainerName: "app",
+		Bounds: &vpa.RecommendedContainerResources{
+			LowerBound: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+			Target:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+			UpperBound: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("400m")},
+		},
+	}
+	replicas := int32(3)
+
+	vars := getExprVars(container, recommendation, corev1.ResourceCPU, &Workload{Replicas: &replicas})
+	expected := map[string]struct {
+		got  resource.Quantity
+		want string
+	}{
+		"request": {vars.Request, "500m"},
+		"limit":   {vars.Limit, "1"},
+		"cpu":     {vars.Cpu, "500m"},
+		"memory":  {vars.Memory, "512Mi"},
+		"lower":   {*vars.Lower, "100m"},
+		"target":  {*vars.Target, "200m"},
+		"upper":   {*vars.Upper, "400m"},
+	}
+	for name, value := range expected {
+		if want := resource.MustParse(value.want); value.got.Cmp(want) != 0 {
+			t.Errorf("expected %s %s, got %s", name, want.String(), value.got.String())
+		}
+	}
+	if vars.Replicas != 3 {
+		t.Errorf("expected 3 replicas, got %d", vars.Replicas)
+	}
+
+	vars = getExprVars(container, nil, corev1.ResourceMemory, nil)
+	if vars.Lower != nil || vars.Target != nil || vars.Upper != nil || vars.Replicas != 0 {
+		t.Errorf("expected no bounds nor replicas without recommendation and workload, got %+v", vars)
+	}
+	if want := resource.MustParse("512Mi"); vars.Request.Cmp(want) != 0 {
+		t.Errorf("expected memory request %s, got %s", want.String(), vars.Request.String())
+	}
+}
@@ -0,0 +1,95 @@
+package logical
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// hpaUtilization is an HPA scaling on the average utilization of a resource request
+type hpaUtilization struct {
+	hpa         *autoscalingv2.HorizontalPodAutoscaler
+	utilization int32
+}
+
+func (h *hpaUtilization) String() string {
+	return fmt.Sprintf("HPA %s targeting %d%% utilization", h.hpa.Name, h.utilization)
+}
+
+// getHPAUtilization returns the HPA scaling on the utilization of the resource for the container, nil if none
+func (w *Workload) getHPAUtilization(resourceName corev1.ResourceName, containerName string) *hpaUtilization {
+	if w == nil {
+		return nil
+	}
+	for i := range w.HPAs {
+		hpa := &w.HPAs[i]
+		for _, metric := range hpa.Spec.Metrics {
+			var target *autoscalingv2.MetricTarget
+			switch metric.Type {
+			case autoscalingv2.ResourceMetricSourceType:
+				if metric.Resource != nil && metric.Resource.Name == resourceName {
+					target = &metric.Resource.Target
+				}
+			case autoscalingv2.ContainerResourceMetricSourceType:
+				if metric.ContainerResource != nil && metric.ContainerResource.Name == resourceName && metric.ContainerResource.Container == containerName {
+					target = &metric.ContainerResource.Target
+				}
+			}
+			if target != nil && target.Type == autoscalingv2.UtilizationMetricType && target.AverageUtilization != nil && *target.AverageUtilization > 0 {
+				return &hpaUtilization{
+					hpa:         hpa,
+					utilization: *target.AverageUtilization,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// scaleQuantity multiplies the quantity by the factor, rounding up
+func scaleQuantity(quantity resource.Quantity, factor float64, resourceType corev1.ResourceName) resource.Quantity {
+	if resourceType == corev1.ResourceCPU {
+		return *resource.NewMilliQuantity(int64(math.Ceil(float64(quantity.MilliValue())*factor)), quantity.Format)
+	}
+	return *resource.NewQuantity(int64(math.Ceil(float64(quantity.Value())*factor)), quantity.Format)
+}
+
+// applyHPAReplicas derives the request from the per-replica usage at the HPA desired replica count,
+// so that the utilization at this replica count matches the HPA target
+func applyHPAReplicas(recommendation resource.Quantity, hpa *hpaUtilization, resourceType corev1.ResourceName) (resource.Quantity, string) {
+	currentReplicas := hpa.hpa.Status.CurrentReplicas
+	desiredReplicas := hpa.hpa.Status.DesiredReplicas
+	if currentReplicas <= 0 || desiredReplicas <= 0 {
+		return recommendation, ""
+	}
+	factor := float64(currentReplicas) / float64(desiredReplicas) / (float64(hpa.utilization) / 100)
+	reason := fmt.Sprintf("derived from usage per replica at %d replicas for %s", desiredReplicas, hpa)
+	return scaleQuantity(recommendation, factor, resourceType), reason
+}
+
+// applyHPAMode holds or limits the change of a request the HPA scales on
+func applyHPAMode(current resource.Quantity, newValue resource.Quantity, hpa *hpaUtilization, mode config.HPAMode, band float64, resourceType corev1.ResourceName) (resource.Quantity, string) {
+	if current.IsZero() {
+		return newValue, ""
+	}
+	switch mode {
+	case config.HPAModeSkip:
+		if newValue.Cmp(current) != 0 {
+			return current, fmt.Sprintf("held by %s", hpa)
+		}
+	case config.HPAModeBand:
+		lower := scaleQuantity(current, 1-band, resourceType)
+		upper := scaleQuantity(current, 1+band, resourceType)
+		if newValue.Cmp(lower) == -1 {
+			return lower, fmt.Sprintf("limited to -%g%% by %s", band*100, hpa)
+		}
+		if newValue.Cmp(upper) == 1 {
+			return upper, fmt.Sprintf("limited to +%g%% by %s", band*100, hpa)
+		}
+	}
+	return newValue, ""
+}
@@ -0,0 +1,209 @@
+package logical
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/calculator"
+	"github.com/SocialGouv/oblik/pkg/config"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newUtilizationHPA(metric autoscalingv2.MetricSpec, currentReplicas int32, desiredReplicas int32) autoscalingv2.HorizontalPodAutoscaler {
+	return autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec:       autoscalingv2.HorizontalPodAutoscalerSpec{Metrics: []autoscalingv2.MetricSpec{metric}},
+		Status:     autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: currentReplicas, DesiredReplicas: desiredReplicas},
+	}
+}
+
+func newUtilizationTarget(utilization int32) autoscalingv2.MetricTarget {
+	return autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &utilization}
+}
+
+func newResourceMetric(resourceName corev1.ResourceName, utilization int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type:     autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{Name: resourceName, Target: newUtilizationTarget(utilization)},
+	}
+}
+
+func TestGetHPAUtilization(t *testing.T) {
+	averageValue := resource.MustParse("500m")
+	tests := []struct {
+		name      string
+		metric    autoscalingv2.MetricSpec
+		container string
+		want      int32
+	}{
+		{
+			name:      "resource utilization",
+			metric:    newResourceMetric(corev1.ResourceCPU, 70),
+			container: "app",
+			want:      70,
+		},
+		{
+			name:      "other resource",
+			metric:    newResourceMetric(corev1.ResourceMemory, 70),
+			container: "app",
+		},
+		{
+			name: "container resource utilization",
+			metric: autoscalingv2.MetricSpec{
+				Type:              autoscalingv2.ContainerResourceMetricSourceType,
+				ContainerResource: &autoscalingv2.ContainerResourceMetricSource{Name: corev1.ResourceCPU, Container: "app", Target: newUtilizationTarget(60)},
+			},
+			container: "app",
+			want:      60,
+		},
+		{
+			name: "container resource of another container",
+			metric: autoscalingv2.MetricSpec{
+				Type:              autoscalingv2.ContainerResourceMetricSourceType,
+				ContainerResource: &autoscalingv2.ContainerResourceMetricSource{Name: corev1.ResourceCPU, Container: "sidecar", Target: newUtilizationTarget(60)},
+			},
+			container: "app",
+		},
+		{
+			name: "average value target",
+			metric: autoscalingv2.MetricSpec{
+				Type:     autoscalingv2.ResourceMetricSourceType,
+				Resource: &autoscalingv2.ResourceMetricSource{Name: corev1.ResourceCPU, Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: &averageValue}},
+			},
+			container: "app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := &Workload{HPAs: []autoscalingv2.HorizontalPodAutoscaler{newUtilizationHPA(tt.metric, 2, 2)}}
+			hpa := workload.getHPAUtilization(corev1.ResourceCPU, tt.container)
+			if tt.want == 0 {
+				if hpa != nil {
+					t.Errorf("expected no HPA, got %s", hpa)
+				}
+				return
+			}
+			if hpa == nil || hpa.utilization != tt.want {
+				t.Errorf("expected HPA targeting %d%%, got %v", tt.want, hpa)
+			}
+		})
+	}
+
+	var workload *Workload
+	if hpa := workload.getHPAUtilization(corev1.ResourceCPU, "app"); hpa != nil {
+		t.Errorf("expected no HPA without workload, got %s", hpa)
+	}
+}
+
+func TestApplyHPAReplicas(t *testing.T) {
+	tests := []struct {
+		name            string
+		currentReplicas int32
+		desiredReplicas int32
+		want            string
+	}{
+		{
+			name:            "scaled to the desired replicas and the target utilization",
+			currentReplicas: 4,
+			desiredReplicas: 2,
+			want:            "400m",
+		},
+		{
+			name:            "at the desired replicas",
+			currentReplicas: 2,
+			desiredReplicas: 2,
+			want:            "200m",
+		},
+		{
+			name:            "no replica status",
+			currentReplicas: 0,
+			desiredReplicas: 2,
+			want:            "100m",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hpa := newUtilizationHPA(newResourceMetric(corev1.ResourceCPU, 50), tt.currentReplicas, tt.desiredReplicas)
+			got, _ := applyHPAReplicas(resource.MustParse("100m"), &hpaUtilization{hpa: &hpa, utilization: 50}, corev1.ResourceCPU)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("expected %s, got %s", want.String(), got.String())
+			}
+		})
+	}
+}
+
+func TestApplyHPAMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		current    string
+		newValue   string
+		mode       config.HPAMode
+		want       string
+		wantReason bool
+	}{
+		{name: "skip holds the request", current: "500m", newValue: "800m", mode: config.HPAModeSkip, want: "500m", wantReason: true},
+		{name: "skip without change", current: "500m", newValue: "500m", mode: config.HPAModeSkip, want: "500m"},
+		{name: "band limits an increase", current: "500m", newValue: "800m", mode: config.HPAModeBand, want: "550m", wantReason: true},
+		{name: "band limits a decrease", current: "500m", newValue: "200m", mode: config.HPAModeBand, want: "450m", wantReason: true},
+		{name: "band keeps a change within", current: "500m", newValue: "520m", mode: config.HPAModeBand, want: "520m"},
+		{name: "no current request", current: "0", newValue: "800m", mode: config.HPAModeSkip, want: "800m"},
+	}
+	hpa := newUtilizationHPA(newResourceMetric(corev1.ResourceCPU, 50), 2, 2)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := applyHPAMode(resource.MustParse(tt.current), resource.MustParse(tt.newValue), &hpaUtilization{hpa: &hpa, utilization: 50}, tt.mode, 0.1, corev1.ResourceCPU)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("expected %s, got %s", want.String(), got.String())
+			}
+			if (reason != "") != tt.wantReason {
+				t.Errorf("expected reason %t, got %q", tt.wantReason, reason)
+			}
+		})
+	}
+}
+
+// TestSetContainerCpuRequestHPA checks the HPA modes on the rounded request: the replicas mode scales the
+// recommendation before the rounding, the skip and band modes apply to the rounded request
+func TestSetContainerCpuRequestHPA(t *testing.T) {
+	multiple := calculator.RoundingAlgoMultiple
+	step := "100m"
+	tests := []struct {
+		name           string
+		mode           config.HPAMode
+		recommendation string
+		want           string
+		wantChange     bool
+	}{
+		{name: "replicas scaled then rounded", mode: config.HPAModeReplicas, recommendation: "130m", want: "600m", wantChange: true},
+		{name: "skip holds a change made by the rounding only", mode: config.HPAModeSkip, recommendation: "520m", want: "500m"},
+		{name: "band limits the rounded request", mode: config.HPAModeBand, recommendation: "620m", want: "550m", wantChange: true},
+		{name: "band keeps the rounded request within", mode: config.HPAModeBand, recommendation: "420m", want: "500m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := tt.mode
+			scfg := &config.StrategyConfig{LoadCfg: &config.LoadCfg{HPAMode: &mode, CpuRoundingAlgo: &multiple, CpuRoundingValue: &step}}
+			// 4 replicas for 2 desired at 50% utilization: the per-replica usage is scaled 4 times
+			workload := &Workload{HPAs: []autoscalingv2.HorizontalPodAutoscaler{newUtilizationHPA(newResourceMetric(corev1.ResourceCPU, 50), 4, 2)}}
+			container := &corev1.Container{
+				Name:      "app",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}},
+			}
+			recommendationCpu := resource.MustParse(tt.recommendation)
+			changes := setContainerCpuRequest(container, &TargetRecommendation{ContainerName: "app", Cpu: &recommendationCpu}, nil, scfg, workload)
+
+			request := container.Resources.Requests[corev1.ResourceCPU]
+			if want := resource.MustParse(tt.want); request.Cmp(want) != 0 {
+				t.Errorf("expected request %s, got %s", want.String(), request.String())
+			}
+			if (len(changes) == 1) != tt.wantChange {
+				t.Errorf("expected change %t, got %v", tt.wantChange, changes)
+			}
+			if tt.wantChange && len(changes) == 1 && changes[0].Reason == "" {
+				t.Errorf("expected the change to be explained by the HPA")
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package logical
+
+import (
+	"github.com/SocialGouv/oblik/pkg/calculator"
+	"github.com/SocialGouv/oblik/pkg/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// getMemoryFromCpu converts the CPU applied to the container, or the CPU recommendation when memory-from-cpu-source
+// is "recommendation", to memory with the configured memory-from-cpu-ratio
+func getMemoryFromCpu(appliedCpu resource.Quantity, recommendation *TargetRecommendation, scfg *config.StrategyConfig, containerName string) resource.Quantity {
+	cpu := appliedCpu
+	if scfg.GetMemoryFromCpuSource(containerName) == config.MemoryFromCpuSourceRecommendation && recommendation != nil && recommendation.Cpu != nil {
+		cpu = *recommendation.Cpu
+	}
+	return calculator.CalculateCpuToMemory(cpu, scfg.GetMemoryFromCpuRatio(containerName))
+}
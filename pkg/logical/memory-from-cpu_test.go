@@ -0,0 +1,34 @@
+package logical
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGetMemoryFromCpu(t *testing.T) {
+	recommendationCpu := resource.MustParse("1")
+	recommendation := &TargetRecommendation{ContainerName: "app", Cpu: &recommendationCpu}
+	tests := []struct {
+		name           string
+		source         config.MemoryFromCpuSource
+		recommendation *TargetRecommendation
+		want           string
+	}{
+		{name: "applied cpu", source: config.MemoryFromCpuSourceApplied, recommendation: recommendation, want: "2Gi"},
+		{name: "recommended cpu", source: config.MemoryFromCpuSourceRecommendation, recommendation: recommendation, want: "4Gi"},
+		{name: "recommended cpu without recommendation", source: config.MemoryFromCpuSourceRecommendation, want: "2Gi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ratio := resource.MustParse("4Gi")
+			source := tt.source
+			scfg := &config.StrategyConfig{LoadCfg: &config.LoadCfg{MemoryFromCpuRatio: &ratio, MemoryFromCpuSource: &source}}
+			got := getMemoryFromCpu(resource.MustParse("500m"), tt.recommendation, scfg, "app")
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("expected %s, got %s", want.String(), got.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package logical
+
+import (
+	"math"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const podResourcesReason = "managed by pod-level resources"
+
+var cpuMemoryResourceNames = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+
+// applyPodResources sets the pod-level resources of the workload to the sum of the containers resources plus the headroom,
+// then unsets the CPU and memory resources of the containers or sets them to their floors
+func applyPodResources(containers []corev1.Container, originals []corev1.ResourceRequirements, changes []reporting.Change, scfg *config.StrategyConfig, workload *Workload) []reporting.Change {
+	if !scfg.IsPodResourcesEnabled() || workload == nil || workload.PodResources == nil {
+		return changes
+	}
+
+	podResources := corev1.ResourceRequirements{}
+	for _, resourceName := range cpuMemoryResourceNames {
+		var requests, limits resource.Quantity
+		hasRequest := false
+		allLimited := len(containers) > 0
+		for _, container := range containers {
+			if request, ok := container.Resources.Requests[resourceName]; ok {
+				requests.Add(request)
+				hasRequest = true
+			}
+			if limit, ok := container.Resources.Limits[resourceName]; ok {
+				limits.Add(limit)
+			} else {
+				allLimited = false
+			}
+		}
+		if hasRequest {
+			if podResources.Requests == nil {
+				podResources.Requests = corev1.ResourceList{}
+			}
+			podResources.Requests[resourceName] = addHeadroom(requests, resourceName, scfg.PodResourcesHeadroom)
+		}
+		// a pod-level limit would also constrain the containers without limit, so it's only set when all have one
+		if allLimited {
+			if podResources.Limits == nil {
+				podResources.Limits = corev1.ResourceList{}
+			}
+			podResources.Limits[resourceName] = addHeadroom(limits, resourceName, scfg.PodResourcesHeadroom)
+		}
+	}
+	changes = appendPodResourcesChange(changes, workload.PodResources.Requests, podResources.Requests, corev1.ResourceCPU, reporting.UpdateTypePodCpuRequest)
+	changes = appendPodResourcesChange(changes, workload.PodResources.Requests, podResources.Requests, corev1.ResourceMemory, reporting.UpdateTypePodMemoryRequest)
+	changes = appendPodResourcesChange(changes, workload.PodResources.Limits, podResources.Limits, corev1.ResourceCPU, reporting.UpdateTypePodCpuLimit)
+	changes = appendPodResourcesChange(changes, workload.PodResources.Limits, podResources.Limits, corev1.ResourceMemory, reporting.UpdateTypePodMemoryLimit)
+	workload.PodResources = &podResources
+
+	for index := range containers {
+		setContainerPodResourcesFloors(&containers[index], scfg)
+	}
+
+	// the containers changes are reported from their values before the update to their floors
+	filtered := []reporting.Change{}
+	for _, change := range changes {
+		switch change.Type {
+		case reporting.UpdateTypeCpuRequest, reporting.UpdateTypeMemoryRequest, reporting.UpdateTypeCpuLimit, reporting.UpdateTypeMemoryLimit:
+			continue
+		}
+		filtered = append(filtered, change)
+	}
+	for index, container := range containers {
+		original := originals[index]
+		filtered = appendContainerFloorChange(filtered, container, original.Requests, container.Resources.Requests, corev1.ResourceCPU, reporting.UpdateTypeCpuRequest)
+		filtered = appendContainerFloorChange(filtered, container, original.Requests, container.Resources.Requests, corev1.ResourceMemory, reporting.UpdateTypeMemoryRequest)
+		filtered = appendContainerFloorChange(filtered, container, original.Limits, container.Resources.Limits, corev1.ResourceCPU, reporting.UpdateTypeCpuLimit)
+		filtered = appendContainerFloorChange(filtered, container, original.Limits, container.Resources.Limits, corev1.ResourceMemory, reporting.UpdateTypeMemoryLimit)
+	}
+	return filtered
+}
+
+func addHeadroom(quantity resource.Quantity, resourceName corev1.ResourceName, headroom float64) resource.Quantity {
+	if resourceName == corev1.ResourceCPU {
+		return *resource.NewMilliQuantity(int64(math.Ceil(float64(quantity.MilliValue())*(1+headroom))), resource.DecimalSI)
+	}
+	return *resource.NewQuantity(int64(math.Ceil(float64(quantity.Value())*(1+headroom))), resource.BinarySI)
+}
+
+// setContainerPodResourcesFloors removes the CPU and memory limits of the container and unsets its requests,
+// or sets them to the minimum requests in floor mode
+func setContainerPodResourcesFloors(container *corev1.Container, scfg *config.StrategyConfig) {
+	for _, resourceName := range cpuMemoryResourceNames {
+		delete(container.Resources.Limits, resourceName)
+		delete(container.Resources.Requests, resourceName)
+		if scfg.PodResourcesContainerMode != config.PodResourcesContainerModeFloor {
+			continue
+		}
+		var floor *resource.Quantity
+		if resourceName == corev1.ResourceCPU {
+			floor = scfg.GetMinRequestCpu(container.Name)
+		} else {
+			floor = scfg.GetMinRequestMemory(container.Name)
+		}
+		if floor != nil {
+			if container.Resources.Requests == nil {
+				container.Resources.Requests = corev1.ResourceList{}
+			}
+			container.Resources.Requests[resourceName] = *floor
+		}
+	}
+	if len(container.Resources.Requests) == 0 {
+		container.Resources.Requests = nil
+	}
+	if len(container.Resources.Limits) == 0 {
+		container.Resources.Limits = nil
+	}
+}
+
+func appendPodResourcesChange(changes []reporting.Change, oldList corev1.ResourceList, newList corev1.ResourceList, resourceName corev1.ResourceName, updateType reporting.UpdateType) []reporting.Change {
+	oldValue, hasOld := oldList[resourceName]
+	newValue, hasNew := newList[resourceName]
+	if !hasOld && !hasNew {
+		return changes
+	}
+	return append(changes, reporting.Change{
+		Old:     oldValue,
+		New:     newValue,
+		Type:    updateType,
+		Removed: hasOld && !hasNew,
+	})
+}
+
+func appendContainerFloorChange(changes []reporting.Change, container corev1.Container, oldList corev1.ResourceList, newList corev1.ResourceList, resourceName corev1.ResourceName, updateType reporting.UpdateType) []reporting.Change {
+	oldValue, hasOld := oldList[resourceName]
+	newValue, hasNew := newList[resourceName]
+	if hasOld == hasNew && oldValue.Cmp(newValue) == 0 {
+		return changes
+	}
+	return append(changes, reporting.Change{
+		Old:           oldValue,
+		New:           newValue,
+		Type:          updateType,
+		ContainerName: container.Name,
+		Reason:        podResourcesReason,
+		Removed:       hasOld && !hasNew,
+	})
+}
@@ -0,0 +1,120 @@
+package logical
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func newPodResourcesContainers() []corev1.Container {
+	return []corev1.Container{
+		{
+			Name: "app",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+		},
+		{
+			Name: "sidecar",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+			},
+		},
+	}
+}
+
+func TestApplyPodResources(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		containers := newPodResourcesContainers()
+		scfg := &config.StrategyConfig{LoadCfg: &config.LoadCfg{}, PodResourcesMode: config.ApplyModeOff}
+		workload := &Workload{PodResources: &corev1.ResourceRequirements{}}
+		if changes := applyPodResources(containers, nil, nil, scfg, workload); len(changes) != 0 {
+			t.Errorf("expected no change, got %v", changes)
+		}
+		if len(workload.PodResources.Requests) != 0 {
+			t.Errorf("expected the pod-level resources to be left unset, got %v", workload.PodResources)
+		}
+	})
+
+	t.Run("containers unset", func(t *testing.T) {
+		containers := newPodResourcesContainers()
+		originals := []corev1.ResourceRequirements{*containers[0].Resources.DeepCopy(), *containers[1].Resources.DeepCopy()}
+		scfg := &config.StrategyConfig{LoadCfg: &config.LoadCfg{}, PodResourcesMode: config.ApplyModeEnforce, PodResourcesHeadroom: 0.1}
+		workload := &Workload{PodResources: &corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		}}
+		changes := applyPodResources(containers, originals, []reporting.Change{}, scfg, workload)
+
+		podResources := workload.PodResources
+		wantRequests := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("660m"), corev1.ResourceMemory: resource.MustParse("704Mi")}
+		for resourceName, want := range wantRequests {
+			if got := podResources.Requests[resourceName]; got.Cmp(want) != 0 {
+				t.Errorf("expected pod %s request %s, got %s", resourceName, want.String(), got.String())
+			}
+		}
+		// the sidecar has no CPU limit, a pod-level CPU limit would constrain it
+		if limit, ok := podResources.Limits[corev1.ResourceCPU]; ok {
+			t.Errorf("expected no pod cpu limit, got %s", limit.String())
+		}
+		if want, got := resource.MustParse("1408Mi"), podResources.Limits[corev1.ResourceMemory]; got.Cmp(want) != 0 {
+			t.Errorf("expected pod memory limit %s, got %s", want.String(), got.String())
+		}
+		for _, container := range containers {
+			if container.Resources.Requests != nil || container.Resources.Limits != nil {
+				t.Errorf("expected the resources of container %s to be unset, got %v", container.Name, container.Resources)
+			}
+		}
+
+		removedPodCpuLimit := false
+		containerChanges := 0
+		for _, change := range changes {
+			switch change.Type {
+			case reporting.UpdateTypePodCpuLimit:
+				removedPodCpuLimit = change.Removed
+			case reporting.UpdateTypeCpuRequest, reporting.UpdateTypeMemoryRequest, reporting.UpdateTypeCpuLimit, reporting.UpdateTypeMemoryLimit:
+				containerChanges++
+				if !change.Removed || change.Reason != podResourcesReason {
+					t.Errorf("expected the container change to be a removal for the pod-level resources, got %+v", change)
+				}
+			}
+		}
+		if !removedPodCpuLimit {
+			t.Errorf("expected the removal of the pod cpu limit, got %v", changes)
+		}
+		if containerChanges != 7 {
+			t.Errorf("expected 7 container changes, got %d", containerChanges)
+		}
+	})
+
+	t.Run("containers floors", func(t *testing.T) {
+		containers := newPodResourcesContainers()
+		originals := []corev1.ResourceRequirements{*containers[0].Resources.DeepCopy(), *containers[1].Resources.DeepCopy()}
+		minCpu := resource.MustParse("50m")
+		scfg := &config.StrategyConfig{
+			LoadCfg:                   &config.LoadCfg{MinRequestCpu: &minCpu},
+			PodResourcesMode:          config.ApplyModeEnforce,
+			PodResourcesContainerMode: config.PodResourcesContainerModeFloor,
+		}
+		workload := &Workload{PodResources: &corev1.ResourceRequirements{}}
+		changes := applyPodResources(containers, originals, []reporting.Change{}, scfg, workload)
+
+		for _, container := range containers {
+			if got := container.Resources.Requests[corev1.ResourceCPU]; got.Cmp(minCpu) != 0 {
+				t.Errorf("expected container %s cpu request at the floor, got %s", container.Name, got.String())
+			}
+			if _, ok := container.Resources.Requests[corev1.ResourceMemory]; ok {
+				t.Errorf("expected container %s memory request to be unset", container.Name)
+			}
+		}
+		for _, change := range changes {
+			if change.Type == reporting.UpdateTypeCpuRequest && change.Removed {
+				t.Errorf("expected the cpu request set to the floor not to be a removal, got %+v", change)
+			}
+		}
+	})
+}
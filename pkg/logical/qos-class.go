@@ -0,0 +1,119 @@
+package logical
+
+import (
+	"fmt"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// applyQoSClass enforces the QoS class shape of the container on its final requests and limits
+func applyQoSClass(container *corev1.Container, changes []reporting.Change, scfg *config.StrategyConfig) []reporting.Change {
+	qosClass := scfg.GetQoSClass(container.Name)
+	switch qosClass {
+	case config.QoSClassGuaranteed:
+		for _, resourceName := range cpuMemoryResourceNames {
+			request, ok := container.Resources.Requests[resourceName]
+			if !ok {
+				// the request defaults to the limit
+				continue
+			}
+			limit, hasLimit := container.Resources.Limits[resourceName]
+			if hasLimit && limit.Cmp(request) == 0 {
+				continue
+			}
+			changes = setContainerQoSLimit(container, resourceName, &request, changes, "qos-class guaranteed")
+		}
+	case config.QoSClassBurstable:
+		margin := scfg.GetQoSBurstableMargin(container.Name)
+		for _, resourceName := range cpuMemoryResourceNames {
+			request, ok := container.Resources.Requests[resourceName]
+			limit, hasLimit := container.Resources.Limits[resourceName]
+			// without limit, the container can already burst
+			if !ok || !hasLimit {
+				continue
+			}
+			minLimit := addHeadroom(request, resourceName, margin)
+			if limit.Cmp(minLimit) >= 0 {
+				continue
+			}
+			changes = setContainerQoSLimit(container, resourceName, &minLimit, changes, fmt.Sprintf("qos-class burstable margin %g", margin))
+		}
+	case config.QoSClassBestEffortCpuLimitFree:
+		if _, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+			changes = setContainerQoSLimit(container, corev1.ResourceCPU, nil, changes, "qos-class besteffort-cpu-limit-free")
+		}
+	}
+	return changes
+}
+
+// setContainerQoSLimit sets the limit of the container, or removes it when nil, and updates its reported change
+func setContainerQoSLimit(container *corev1.Container, resourceName corev1.ResourceName, limit *resource.Quantity, changes []reporting.Change, reason string) []reporting.Change {
+	updateType := reporting.UpdateTypeCpuLimit
+	if resourceName == corev1.ResourceMemory {
+		updateType = reporting.UpdateTypeMemoryLimit
+	}
+	current := container.Resources.Limits[resourceName]
+	var newLimit resource.Quantity
+	if limit == nil {
+		delete(container.Resources.Limits, resourceName)
+	} else {
+		if container.Resources.Limits == nil {
+			container.Resources.Limits = corev1.ResourceList{}
+		}
+		newLimit = *limit
+		container.Resources.Limits[resourceName] = newLimit
+	}
+
+	for i := range changes {
+		if changes[i].ContainerName == container.Name && changes[i].Type == updateType {
+			// a limit added by the update then removed is no change
+			if limit == nil && changes[i].Old.IsZero() {
+				return append(changes[:i], changes[i+1:]...)
+			}
+			changes[i].New = newLimit
+			changes[i].Reason = reason
+			changes[i].Removed = limit == nil
+			return changes
+		}
+	}
+	return append(changes, reporting.Change{
+		Old:           current,
+		New:           newLimit,
+		Type:          updateType,
+		ContainerName: container.Name,
+		Reason:        reason,
+		Removed:       limit == nil,
+	})
+}
+
+// getQoSClass returns the QoS class of the pods with the given containers resources, from the pod-level resources when set
+func getQoSClass(containersResources []corev1.ResourceRequirements, podResources *corev1.ResourceRequirements) corev1.PodQOSClass {
+	if podResources != nil && (len(podResources.Requests) > 0 || len(podResources.Limits) > 0) {
+		containersResources = []corev1.ResourceRequirements{*podResources}
+	}
+	isBestEffort := true
+	isGuaranteed := true
+	for _, resources := range containersResources {
+		for _, resourceName := range cpuMemoryResourceNames {
+			request, hasRequest := resources.Requests[resourceName]
+			limit, hasLimit := resources.Limits[resourceName]
+			if (hasRequest && !request.IsZero()) || (hasLimit && !limit.IsZero()) {
+				isBestEffort = false
+			}
+			if !hasLimit || limit.IsZero() || (hasRequest && request.Cmp(limit) != 0) {
+				isGuaranteed = false
+			}
+		}
+	}
+	switch {
+	case isBestEffort:
+		return corev1.PodQOSBestEffort
+	case isGuaranteed:
+		return corev1.PodQOSGuaranteed
+	default:
+		return corev1.PodQOSBurstable
+	}
+}
@@ -0,0 +1,82 @@
+package logical
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestApplyQoSClass(t *testing.T) {
+	tests := []struct {
+		name        string
+		qosClass    string
+		limits      corev1.ResourceList
+		changes     []reporting.Change
+		wantLimits  map[corev1.ResourceName]string
+		wantChanges []reporting.Change
+	}{
+		{
+			name:        "guaranteed limits set to the requests",
+			qosClass:    "guaranteed",
+			limits:      corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			wantLimits:  map[corev1.ResourceName]string{corev1.ResourceCPU: "500m", corev1.ResourceMemory: "256Mi"},
+			wantChanges: []reporting.Change{{Type: reporting.UpdateTypeCpuLimit, Old: resource.MustParse("1"), New: resource.MustParse("500m")}, {Type: reporting.UpdateTypeMemoryLimit, New: resource.MustParse("256Mi")}},
+		},
+		{
+			name:        "besteffort-cpu-limit-free cpu limit removed",
+			qosClass:    "besteffort-cpu-limit-free",
+			limits:      corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+			wantLimits:  map[corev1.ResourceName]string{corev1.ResourceMemory: "512Mi"},
+			wantChanges: []reporting.Change{{Type: reporting.UpdateTypeCpuLimit, Old: resource.MustParse("1"), Removed: true}},
+		},
+		{
+			name:        "besteffort-cpu-limit-free change of the cpu limit turned into a removal",
+			qosClass:    "besteffort-cpu-limit-free",
+			limits:      corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			changes:     []reporting.Change{{Type: reporting.UpdateTypeCpuLimit, ContainerName: "app", Old: resource.MustParse("1"), New: resource.MustParse("2")}},
+			wantLimits:  map[corev1.ResourceName]string{},
+			wantChanges: []reporting.Change{{Type: reporting.UpdateTypeCpuLimit, Old: resource.MustParse("1"), Removed: true}},
+		},
+		{
+			name:       "besteffort-cpu-limit-free cpu limit added then removed",
+			qosClass:   "besteffort-cpu-limit-free",
+			limits:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			changes:    []reporting.Change{{Type: reporting.UpdateTypeCpuLimit, ContainerName: "app", New: resource.MustParse("2")}},
+			wantLimits: map[corev1.ResourceName]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OBLIK_DEFAULT_QOS_CLASS", tt.qosClass)
+			container := &corev1.Container{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("256Mi")},
+					Limits:   tt.limits,
+				},
+			}
+			changes := applyQoSClass(container, tt.changes, &config.StrategyConfig{LoadCfg: &config.LoadCfg{}})
+
+			if len(container.Resources.Limits) != len(tt.wantLimits) {
+				t.Errorf("expected limits %v, got %v", tt.wantLimits, container.Resources.Limits)
+			}
+			for resourceName, want := range tt.wantLimits {
+				if limit := container.Resources.Limits[resourceName]; limit.Cmp(resource.MustParse(want)) != 0 {
+					t.Errorf("%s: expected limit %s, got %s", resourceName, want, limit.String())
+				}
+			}
+			if len(changes) != len(tt.wantChanges) {
+				t.Fatalf("expected %d change(s), got %v", len(tt.wantChanges), changes)
+			}
+			for i, want := range tt.wantChanges {
+				change := changes[i]
+				if change.Type != want.Type || change.Old.Cmp(want.Old) != 0 || change.New.Cmp(want.New) != 0 || change.Removed != want.Removed {
+					t.Errorf("expected change %+v, got %+v", want, change)
+				}
+			}
+		})
+	}
+}
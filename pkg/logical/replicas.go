@@ -0,0 +1,166 @@
+package logical
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/SocialGouv/oblik/pkg/calculator"
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/klog/v2"
+)
+
+// getReplicasRecommendation chooses between the frugal, balanced and peak recommendations from the replica count:
+// per pod peaks don't happen at the same time on many replicas, while few replicas have to absorb them.
+// The choice is raised when it doesn't cover the current usage per replica of the workload.
+func (w *Workload) getReplicasRecommendation(containerRecommendation vpa.RecommendedContainerResources, resourceName corev1.ResourceName, scfg *config.StrategyConfig) *resource.Quantity {
+	candidates := []corev1.ResourceList{
+		containerRecommendation.LowerBound,
+		containerRecommendation.Target,
+		containerRecommendation.UpperBound,
+	}
+	if w == nil || w.Replicas == nil || *w.Replicas <= 0 {
+		return getRecommendationQuantity(candidates[1], resourceName)
+	}
+	replicas := int(*w.Replicas)
+
+	index := 1
+	if replicas <= scfg.ReplicasPeakMax {
+		index = 2
+	} else if replicas >= scfg.ReplicasFrugalMin {
+		index = 0
+	}
+
+	if usage, ok := w.Usage[containerRecommendation.ContainerName][resourceName]; ok {
+		usagePerReplica := scaleQuantity(usage, 1/float64(replicas), resourceName)
+		for index < len(candidates)-1 && getRecommendationQuantity(candidates[index], resourceName).Cmp(usagePerReplica) == -1 {
+			index++
+		}
+	}
+	return getRecommendationQuantity(candidates[index], resourceName)
+}
+
+func getRecommendationQuantity(resources corev1.ResourceList, resourceName corev1.ResourceName) *resource.Quantity {
+	if resourceName == corev1.ResourceCPU {
+		return resources.Cpu()
+	}
+	return resources.Memory()
+}
+
+// capTotalRequests scales down the requests applied to the containers so that their total across the current replicas
+// doesn't exceed the configured maximum. The total is divided by the current replica count of the workload, as scaled
+// by an HPA, so that the cap matches the actual footprint rather than the HPA max replicas. The capped requests are
+// rounded down, not to exceed the cap once rounded, and bounded by the max and min requests, the min requests winning
+// over the cap
+func capTotalRequests(containers []corev1.Container, changes []reporting.Change, scfg *config.StrategyConfig, workload *Workload, resourceName corev1.ResourceName) []reporting.Change {
+	var maxTotal *resource.Quantity
+	var updateType reporting.UpdateType
+	var annotation string
+	if resourceName == corev1.ResourceCPU {
+		maxTotal, updateType, annotation = scfg.MaxTotalCpu, reporting.UpdateTypeCpuRequest, "max-total-cpu"
+	} else {
+		maxTotal, updateType, annotation = scfg.MaxTotalMemory, reporting.UpdateTypeMemoryRequest, "max-total-memory"
+	}
+	replicas := workload.getCurrentReplicas()
+	if maxTotal == nil || replicas <= 0 {
+		return changes
+	}
+
+	isManaged := func(containerName string) bool {
+		if resourceName == corev1.ResourceCPU {
+			return scfg.GetRequestCPUApplyMode(containerName) == config.ApplyModeEnforce
+		}
+		return scfg.GetRequestMemoryApplyMode(containerName) == config.ApplyModeEnforce
+	}
+	getTotals := func() (float64, float64) {
+		var managed, unmanaged float64
+		for _, container := range containers {
+			request, ok := container.Resources.Requests[resourceName]
+			if !ok {
+				continue
+			}
+			if isManaged(container.Name) {
+				managed += request.AsApproximateFloat64()
+			} else {
+				unmanaged += request.AsApproximateFloat64()
+			}
+		}
+		return managed, unmanaged
+	}
+
+	managed, unmanaged := getTotals()
+	available := maxTotal.AsApproximateFloat64()/float64(replicas) - unmanaged
+	if managed <= 0 || managed <= available {
+		return changes
+	}
+	factor := math.Max(available, 0) / managed
+	reason := fmt.Sprintf("capped by %s %s at %d replicas", annotation, maxTotal.String(), replicas)
+
+	for index := range containers {
+		container := &containers[index]
+		request, ok := container.Resources.Requests[resourceName]
+		if !ok || !isManaged(container.Name) {
+			continue
+		}
+		capped := capRequest(request, factor, scfg, container.Name, resourceName)
+		if capped.Cmp(request) == 0 {
+			continue
+		}
+		container.Resources.Requests[resourceName] = capped
+		changes = setCappedChange(changes, request, capped, updateType, container.Name, reason)
+	}
+
+	if managed, _ = getTotals(); managed > available {
+		klog.Warningf("%s: requests above %s %s at %d replicas, bounded by the min requests", scfg.Key, annotation, maxTotal.String(), replicas)
+	}
+	return changes
+}
+
+// capRequest scales the request down by the factor, rounded down and bounded by the max and min requests of the
+// container
+func capRequest(request resource.Quantity, factor float64, scfg *config.StrategyConfig, containerName string, resourceName corev1.ResourceName) resource.Quantity {
+	var minRequest, maxRequest *resource.Quantity
+	var capped resource.Quantity
+	resourceType := calculator.ResourceTypeMemory
+	if resourceName == corev1.ResourceCPU {
+		minRequest, maxRequest, resourceType = scfg.GetMinRequestCpu(containerName), scfg.GetMaxRequestCpu(containerName), calculator.ResourceTypeCPU
+		capped = *resource.NewMilliQuantity(int64(math.Floor(float64(request.MilliValue())*factor)), request.Format)
+	} else {
+		minRequest, maxRequest = scfg.GetMinRequestMemory(containerName), scfg.GetMaxRequestMemory(containerName)
+		capped = *resource.NewQuantity(int64(math.Floor(float64(request.Value())*factor)), request.Format)
+	}
+	capped = roundResourceValueDown(capped, scfg, containerName, resourceType)
+	if maxRequest != nil && capped.Cmp(*maxRequest) == 1 {
+		capped = *maxRequest
+	}
+	if minRequest != nil && capped.Cmp(*minRequest) == -1 {
+		capped = *minRequest
+	}
+	return capped
+}
+
+// setCappedChange records the capped request in the change of the container, the change being dropped when the cap
+// brings the request back to its original value
+func setCappedChange(changes []reporting.Change, request resource.Quantity, capped resource.Quantity, updateType reporting.UpdateType, containerName string, reason string) []reporting.Change {
+	for i := range changes {
+		if changes[i].ContainerName != containerName || changes[i].Type != updateType {
+			continue
+		}
+		if changes[i].Old.Cmp(capped) == 0 {
+			return append(changes[:i], changes[i+1:]...)
+		}
+		changes[i].New = capped
+		changes[i].Reason = reason
+		return changes
+	}
+	return append(changes, reporting.Change{
+		Old:           request,
+		New:           capped,
+		Type:          updateType,
+		ContainerName: containerName,
+		Reason:        reason,
+	})
+}
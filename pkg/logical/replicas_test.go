@@ -0,0 +1,117 @@
+package logical
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/calculator"
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func newContainer(name string, cpu string) corev1.Container {
+	return corev1.Container{
+		Name: name,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+		},
+	}
+}
+
+func TestCapTotalRequests(t *testing.T) {
+	multiple := calculator.RoundingAlgoMultiple
+	step := "100m"
+	tests := []struct {
+		name       string
+		containers []corev1.Container
+		changes    []reporting.Change
+		maxTotal   string
+		replicas   int32
+		rounding   bool
+		minRequest string
+		want       map[string]string
+		wantNews   map[string]string
+	}{
+		{
+			name:       "under the cap",
+			containers: []corev1.Container{newContainer("app", "500m")},
+			maxTotal:   "2",
+			replicas:   2,
+			want:       map[string]string{"app": "500m"},
+		},
+		{
+			name:       "scaled down by the current replicas",
+			containers: []corev1.Container{newContainer("app", "600m"), newContainer("sidecar", "200m")},
+			maxTotal:   "2",
+			replicas:   4,
+			want:       map[string]string{"app": "375m", "sidecar": "125m"},
+			wantNews:   map[string]string{"app": "375m", "sidecar": "125m"},
+		},
+		{
+			name:       "rounded down not to exceed the cap",
+			containers: []corev1.Container{newContainer("app", "650m"), newContainer("sidecar", "350m")},
+			maxTotal:   "1450m",
+			replicas:   2,
+			rounding:   true,
+			want:       map[string]string{"app": "400m", "sidecar": "200m"},
+			wantNews:   map[string]string{"app": "400m", "sidecar": "200m"},
+		},
+		{
+			name:       "min request winning over the cap",
+			containers: []corev1.Container{newContainer("app", "1")},
+			maxTotal:   "1",
+			replicas:   4,
+			minRequest: "500m",
+			want:       map[string]string{"app": "500m"},
+			wantNews:   map[string]string{"app": "500m"},
+		},
+		{
+			name:       "change dropped when capped back to the original request",
+			containers: []corev1.Container{newContainer("app", "800m")},
+			changes: []reporting.Change{
+				{Old: resource.MustParse("500m"), New: resource.MustParse("800m"), Type: reporting.UpdateTypeCpuRequest, ContainerName: "app"},
+			},
+			maxTotal: "1",
+			replicas: 2,
+			want:     map[string]string{"app": "500m"},
+			wantNews: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxTotal := resource.MustParse(tt.maxTotal)
+			scfg := &config.StrategyConfig{LoadCfg: &config.LoadCfg{}, MaxTotalCpu: &maxTotal}
+			if tt.rounding {
+				scfg.CpuRoundingAlgo = &multiple
+				scfg.CpuRoundingValue = &step
+			}
+			if tt.minRequest != "" {
+				minRequest := resource.MustParse(tt.minRequest)
+				scfg.MinRequestCpu = &minRequest
+			}
+			changes := capTotalRequests(tt.containers, tt.changes, scfg, &Workload{Replicas: &tt.replicas}, corev1.ResourceCPU)
+
+			for _, container := range tt.containers {
+				request := container.Resources.Requests[corev1.ResourceCPU]
+				if want := resource.MustParse(tt.want[container.Name]); request.Cmp(want) != 0 {
+					t.Errorf("%s: expected request %s, got %s", container.Name, want.String(), request.String())
+				}
+			}
+			if tt.wantNews == nil {
+				tt.wantNews = map[string]string{}
+			}
+			if len(changes) != len(tt.wantNews) {
+				t.Fatalf("expected %d change(s), got %v", len(tt.wantNews), changes)
+			}
+			for _, change := range changes {
+				if change.Old.Cmp(change.New) == 0 {
+					t.Errorf("%s: unexpected no-op change", change.ContainerName)
+				}
+				if want := resource.MustParse(tt.wantNews[change.ContainerName]); change.New.Cmp(want) != 0 {
+					t.Errorf("%s: expected change to %s, got %s", change.ContainerName, want.String(), change.New.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package logical
+
+import (
+	"github.com/SocialGouv/oblik/pkg/calculator"
+	"github.com/SocialGouv/oblik/pkg/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// roundResourceValue rounds the value with the rounding policy of its resource type, without exceeding the maximum
+func roundResourceValue(value resource.Quantity, maxValue *resource.Quantity, scfg *config.StrategyConfig, containerName string, resourceType calculator.ResourceType) resource.Quantity {
+	var rounded resource.Quantity
+	if resourceType == calculator.ResourceTypeCPU {
+		rounded = calculator.RoundResourceValue(value, scfg.GetCpuRoundingAlgo(containerName), scfg.GetCpuRoundingValue(containerName), resourceType)
+	} else {
+		rounded = calculator.RoundResourceValue(value, scfg.GetMemoryRoundingAlgo(containerName), scfg.GetMemoryRoundingValue(containerName), resourceType)
+	}
+	if maxValue != nil && rounded.Cmp(*maxValue) == 1 {
+		return *maxValue
+	}
+	return rounded
+}
+
+// roundResourceValueDown rounds the value down with the rounding policy of its resource type, for caps
+func roundResourceValueDown(value resource.Quantity, scfg *config.StrategyConfig, containerName string, resourceType calculator.ResourceType) resource.Quantity {
+	if resourceType == calculator.ResourceTypeCPU {
+		return calculator.RoundResourceValueDown(value, scfg.GetCpuRoundingAlgo(containerName), scfg.GetCpuRoundingValue(containerName), resourceType)
+	}
+	return calculator.RoundResourceValueDown(value, scfg.GetMemoryRoundingAlgo(containerName), scfg.GetMemoryRoundingValue(containerName), resourceType)
+}
@@ -0,0 +1,137 @@
+package logical
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+var (
+	jvmHeapOption  = regexp.MustCompile(`(^|\s)(-XX:MaxRAMPercentage=|-Xmx)\S*`)
+	nodeHeapOption = regexp.MustCompile(`(^|\s)--max-old-space-size=\S*`)
+)
+
+// applyRuntimeProfile sets the heap size of the runtime of the container to the configured fraction of its memory limit,
+// or of its memory request when it has no limit, and GOMAXPROCS of Go containers to the configured fraction of its CPU limit.
+// The env is only updated when the resources it follows are changed, not to restart containers for env changes only
+func applyRuntimeProfile(container *corev1.Container, changes []reporting.Change, scfg *config.StrategyConfig) []reporting.Change {
+	containerName := container.Name
+	profile := scfg.GetRuntimeProfile(containerName)
+	if profile == config.RuntimeProfileOff {
+		return changes
+	}
+
+	memoryLimit := container.Resources.Limits[corev1.ResourceMemory]
+	memory := memoryLimit
+	memoryChanged := hasResourceChange(changes, containerName, reporting.UpdateTypeMemoryLimit)
+	if memory.IsZero() {
+		memory = container.Resources.Requests[corev1.ResourceMemory]
+		memoryChanged = memoryChanged || hasResourceChange(changes, containerName, reporting.UpdateTypeMemoryRequest)
+	}
+	ratio := scfg.GetRuntimeHeapRatio(containerName)
+	heapMiB := int64(float64(memory.Value())*ratio) / (1024 * 1024)
+	if memoryChanged && heapMiB >= 1 {
+		switch profile {
+		case config.RuntimeProfileJVM:
+			// MaxRAMPercentage is relative to the memory limit, without one the heap is sized explicitly
+			option := fmt.Sprintf("-Xmx%dm", heapMiB)
+			if scfg.GetJVMHeapFlag(containerName) == config.JVMHeapFlagMaxRAMPercentage && !memoryLimit.IsZero() {
+				option = "-XX:MaxRAMPercentage=" + strconv.FormatFloat(ratio*100, 'f', 1, 64)
+			}
+			changes = setContainerEnvOption(container, scfg.GetJVMOptionsEnv(containerName), jvmHeapOption, option, changes)
+		case config.RuntimeProfileNode:
+			changes = setContainerEnvOption(container, "NODE_OPTIONS", nodeHeapOption, fmt.Sprintf("--max-old-space-size=%d", heapMiB), changes)
+		case config.RuntimeProfileGo:
+			changes = setContainerEnv(container, "GOMEMLIMIT", fmt.Sprintf("%dMiB", heapMiB), changes)
+		}
+	}
+
+	if profile == config.RuntimeProfileGo {
+		cpu := container.Resources.Limits[corev1.ResourceCPU]
+		cpuChanged := hasResourceChange(changes, containerName, reporting.UpdateTypeCpuLimit)
+		if cpu.IsZero() {
+			cpu = container.Resources.Requests[corev1.ResourceCPU]
+			cpuChanged = cpuChanged || hasResourceChange(changes, containerName, reporting.UpdateTypeCpuRequest)
+		}
+		if cpuChanged && !cpu.IsZero() {
+			procs := int64(math.Max(math.Floor(cpu.AsApproximateFloat64()*scfg.GetRuntimeCpuRatio(containerName)), 1))
+			changes = setContainerEnv(container, "GOMAXPROCS", strconv.FormatInt(procs, 10), changes)
+		}
+	}
+	return changes
+}
+
+// hasResourceChange returns whether the changes update a resource of one of the update types for the container
+func hasResourceChange(changes []reporting.Change, containerName string, updateTypes ...reporting.UpdateType) bool {
+	for _, change := range changes {
+		if change.ContainerName != containerName || change.Old.Cmp(change.New) == 0 {
+			continue
+		}
+		for _, updateType := range updateTypes {
+			if change.Type == updateType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setContainerEnvOption replaces the options matching the pattern in the env var of the container with the option
+func setContainerEnvOption(container *corev1.Container, name string, pattern *regexp.Regexp, option string, changes []reporting.Change) []reporting.Change {
+	value, ok := getContainerEnv(container, name)
+	if !ok {
+		return changes
+	}
+	value = strings.TrimSpace(pattern.ReplaceAllString(value, ""))
+	if value != "" {
+		value += " "
+	}
+	return setContainerEnv(container, name, value+option, changes)
+}
+
+// getContainerEnv returns the value of the env var of the container, false when it is set from a source Oblik can't update
+func getContainerEnv(container *corev1.Container, name string) (string, bool) {
+	for _, env := range container.Env {
+		if env.Name != name {
+			continue
+		}
+		if env.ValueFrom != nil {
+			klog.Warningf("Not updating %s of container %s: set from a reference", name, container.Name)
+			return "", false
+		}
+		return env.Value, true
+	}
+	return "", true
+}
+
+func setContainerEnv(container *corev1.Container, name string, value string, changes []reporting.Change) []reporting.Change {
+	oldValue, ok := getContainerEnv(container, name)
+	if !ok || oldValue == value {
+		return changes
+	}
+	found := false
+	for index := range container.Env {
+		if container.Env[index].Name == name {
+			container.Env[index].Value = value
+			found = true
+			break
+		}
+	}
+	if !found {
+		container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: value})
+	}
+	return append(changes, reporting.Change{
+		Type:          reporting.UpdateTypeEnv,
+		ContainerName: container.Name,
+		Env:           name,
+		OldEnv:        oldValue,
+		NewEnv:        value,
+	})
+}
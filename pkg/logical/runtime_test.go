@@ -0,0 +1,151 @@
+package logical
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestApplyRuntimeProfile(t *testing.T) {
+	memoryLimitChange := reporting.Change{Type: reporting.UpdateTypeMemoryLimit, ContainerName: "app", Old: resource.MustParse("512Mi"), New: resource.MustParse("1Gi")}
+	memoryRequestChange := reporting.Change{Type: reporting.UpdateTypeMemoryRequest, ContainerName: "app", Old: resource.MustParse("512Mi"), New: resource.MustParse("1Gi")}
+	cpuLimitChange := reporting.Change{Type: reporting.UpdateTypeCpuLimit, ContainerName: "app", Old: resource.MustParse("1"), New: resource.MustParse("2500m")}
+	cpuRequestChange := reporting.Change{Type: reporting.UpdateTypeCpuRequest, ContainerName: "app", Old: resource.MustParse("1"), New: resource.MustParse("1500m")}
+	tests := []struct {
+		name     string
+		env      map[string]string
+		limits   corev1.ResourceList
+		requests corev1.ResourceList
+		envVars  []corev1.EnvVar
+		changes  []reporting.Change
+		want     map[string]string
+		// wantChanges is the number of env changes
+		wantChanges int
+	}{
+		{
+			name:        "jvm max ram percentage added",
+			env:         map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "jvm"},
+			limits:      corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			changes:     []reporting.Change{memoryLimitChange},
+			want:        map[string]string{"JAVA_TOOL_OPTIONS": "-XX:MaxRAMPercentage=75.0"},
+			wantChanges: 1,
+		},
+		{
+			name:        "jvm existing max ram percentage replaced, other options kept",
+			env:         map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "jvm", "OBLIK_DEFAULT_RUNTIME_HEAP_RATIO": "0.5"},
+			limits:      corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			envVars:     []corev1.EnvVar{{Name: "JAVA_TOOL_OPTIONS", Value: "-XX:+UseG1GC -XX:MaxRAMPercentage=80.0 -Dfoo=bar"}},
+			changes:     []reporting.Change{memoryLimitChange},
+			want:        map[string]string{"JAVA_TOOL_OPTIONS": "-XX:+UseG1GC -Dfoo=bar -XX:MaxRAMPercentage=50.0"},
+			wantChanges: 1,
+		},
+		{
+			name:        "jvm existing xmx replaced",
+			env:         map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "jvm", "OBLIK_DEFAULT_JVM_HEAP_FLAG": "xmx"},
+			limits:      corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			envVars:     []corev1.EnvVar{{Name: "JAVA_TOOL_OPTIONS", Value: "-Xmx256m -Xss1m"}},
+			changes:     []reporting.Change{memoryLimitChange},
+			want:        map[string]string{"JAVA_TOOL_OPTIONS": "-Xss1m -Xmx768m"},
+			wantChanges: 1,
+		},
+		{
+			name:        "jvm xmx without memory limit",
+			env:         map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "jvm"},
+			requests:    corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			changes:     []reporting.Change{memoryRequestChange},
+			want:        map[string]string{"JAVA_TOOL_OPTIONS": "-Xmx768m"},
+			wantChanges: 1,
+		},
+		{
+			name:    "jvm options set from a reference left untouched",
+			env:     map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "jvm"},
+			limits:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			envVars: []corev1.EnvVar{{Name: "JAVA_TOOL_OPTIONS", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{Key: "options"}}}},
+			changes: []reporting.Change{memoryLimitChange},
+			want:    map[string]string{"JAVA_TOOL_OPTIONS": ""},
+		},
+		{
+			name:        "node max old space size replaced",
+			env:         map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "node"},
+			limits:      corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			envVars:     []corev1.EnvVar{{Name: "NODE_OPTIONS", Value: "--max-old-space-size=256 --enable-source-maps"}},
+			changes:     []reporting.Change{memoryLimitChange},
+			want:        map[string]string{"NODE_OPTIONS": "--enable-source-maps --max-old-space-size=768"},
+			wantChanges: 1,
+		},
+		{
+			name:        "go memory limit and max procs rounded down",
+			env:         map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "go"},
+			limits:      corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi"), corev1.ResourceCPU: resource.MustParse("2500m")},
+			changes:     []reporting.Change{memoryLimitChange, cpuLimitChange},
+			want:        map[string]string{"GOMEMLIMIT": "768MiB", "GOMAXPROCS": "2"},
+			wantChanges: 2,
+		},
+		{
+			name:        "go max procs at least 1",
+			env:         map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "go", "OBLIK_DEFAULT_RUNTIME_CPU_RATIO": "0.5"},
+			requests:    corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1500m")},
+			changes:     []reporting.Change{cpuRequestChange},
+			want:        map[string]string{"GOMAXPROCS": "1"},
+			wantChanges: 1,
+		},
+		{
+			name:    "go max procs set from a reference left untouched",
+			env:     map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "go"},
+			limits:  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2500m")},
+			envVars: []corev1.EnvVar{{Name: "GOMAXPROCS", ValueFrom: &corev1.EnvVarSource{ResourceFieldRef: &corev1.ResourceFieldSelector{Resource: "limits.cpu"}}}},
+			changes: []reporting.Change{cpuLimitChange},
+			want:    map[string]string{"GOMAXPROCS": ""},
+		},
+		{
+			name:    "env untouched without limit change",
+			env:     map[string]string{"OBLIK_DEFAULT_RUNTIME_PROFILE": "go"},
+			limits:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi"), corev1.ResourceCPU: resource.MustParse("2500m")},
+			changes: []reporting.Change{memoryRequestChange, cpuRequestChange},
+			want:    map[string]string{"GOMEMLIMIT": "", "GOMAXPROCS": ""},
+		},
+		{
+			name:    "profile off",
+			limits:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			changes: []reporting.Change{memoryLimitChange},
+			want:    map[string]string{"JAVA_TOOL_OPTIONS": "", "NODE_OPTIONS": "", "GOMEMLIMIT": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			container := &corev1.Container{
+				Name:      "app",
+				Env:       tt.envVars,
+				Resources: corev1.ResourceRequirements{Limits: tt.limits, Requests: tt.requests},
+			}
+			changes := applyRuntimeProfile(container, tt.changes, &config.StrategyConfig{LoadCfg: &config.LoadCfg{}})
+
+			for name, want := range tt.want {
+				value := ""
+				for _, env := range container.Env {
+					if env.Name == name {
+						value = env.Value
+					}
+				}
+				if value != want {
+					t.Errorf("%s: expected %q, got %q", name, want, value)
+				}
+			}
+			envChanges := 0
+			for _, change := range changes {
+				if change.Type == reporting.UpdateTypeEnv {
+					envChanges++
+				}
+			}
+			if envChanges != tt.wantChanges {
+				t.Errorf("expected %d env change(s), got %d", tt.wantChanges, envChanges)
+			}
+		})
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"k8s.io/klog/v2"
 )
 
-func setContainerCpuRequest(container *corev1.Container, containerRequestRecommendation *TargetRecommendation, changes []reporting.Change, scfg *config.StrategyConfig) []reporting.Change {
+func setContainerCpuRequest(container *corev1.Container, containerRequestRecommendation *TargetRecommendation, changes []reporting.Change, scfg *config.StrategyConfig, workload *Workload) []reporting.Change {
 	containerName := container.Name
 	cpuRequest := *container.Resources.Requests.Cpu()
 
@@ -42,7 +42,14 @@ func setContainerCpuRequest(container *corev1.Container, containerRequestRecomme
 		newCPURequest = *scfg.GetMaxAllowedRecommendationCpu(containerName)
 	}
 
-	newCPURequest = calculator.CalculateResourceValue(newCPURequest, scfg.GetIncreaseRequestCpuAlgo(containerName), scfg.GetIncreaseRequestCpuValue(containerName), calculator.ResourceTypeCPU)
+	var reason string
+	hpa := workload.getHPAUtilization(corev1.ResourceCPU, containerName)
+	hpaMode := scfg.GetHPAMode(containerName)
+	if hpa != nil && hpaMode == config.HPAModeReplicas {
+		newCPURequest, reason = applyHPAReplicas(newCPURequest, hpa, corev1.ResourceCPU)
+	}
+
+	newCPURequest = calculator.CalculateResourceValueWithVars(newCPURequest, scfg.GetIncreaseRequestCpuAlgo(containerName), scfg.GetIncreaseRequestCpuValue(containerName), calculator.ResourceTypeCPU, getExprVars(container, containerRequestRecommendation, corev1.ResourceCPU, workload))
 
 	if scfg.GetMinRequestCpu(containerName) != nil && newCPURequest.Cmp(*scfg.GetMinRequestCpu(containerName)) == -1 {
 		newCPURequest = *scfg.GetMinRequestCpu(containerName)
@@ -50,8 +57,13 @@ func setContainerCpuRequest(container *corev1.Container, containerRequestRecomme
 	if scfg.GetMaxRequestCpu(containerName) != nil && newCPURequest.Cmp(*scfg.GetMaxRequestCpu(containerName)) == 1 {
 		newCPURequest = *scfg.GetMaxRequestCpu(containerName)
 	}
+	newCPURequest = roundResourceValue(newCPURequest, scfg.GetMaxRequestCpu(containerName), scfg, containerName, calculator.ResourceTypeCPU)
 
-	minDiffCpuRequest := calculator.CalculateResourceValue(container.Resources.Requests[corev1.ResourceCPU], scfg.GetMinDiffCpuRequestAlgo(containerName), scfg.GetMinDiffCpuRequestValue(containerName), calculator.ResourceTypeCPU)
+	if hpa != nil && (hpaMode == config.HPAModeSkip || hpaMode == config.HPAModeBand) {
+		newCPURequest, reason = applyHPAMode(cpuRequest, newCPURequest, hpa, hpaMode, scfg.GetHPABand(containerName), corev1.ResourceCPU)
+	}
+
+	minDiffCpuRequest := calculator.CalculateResourceValueWithVars(container.Resources.Requests[corev1.ResourceCPU], scfg.GetMinDiffCpuRequestAlgo(containerName), scfg.GetMinDiffCpuRequestValue(containerName), calculator.ResourceTypeCPU, getExprVars(container, containerRequestRecommendation, corev1.ResourceCPU, workload))
 	if newCPURequest.Cmp(minDiffCpuRequest) == -1 {
 		newCPURequest = cpuRequest
 	}
@@ -61,22 +73,32 @@ func setContainerCpuRequest(container *corev1.Container, containerRequestRecomme
 	if scfg.GetRequestCpuScaleDirection(containerName) == config.ScaleDirectionUp && newCPURequest.Cmp(cpuRequest) == -1 {
 		newCPURequest = cpuRequest
 	}
+	if reason != "" && newCPURequest.Cmp(cpuRequest) == 0 {
+		// a request held by an HPA is not a change, only logged
+		klog.Infof("Keeping CPU request to %s for %s container: %s (%s)", cpuRequest.String(), scfg.Key, containerName, reason)
+	}
 	if scfg.GetRequestCPUApplyMode(containerName) == config.ApplyModeEnforce && newCPURequest.Cmp(cpuRequest) != 0 {
 		changes = append(changes, reporting.Change{
 			Old:           cpuRequest,
 			New:           newCPURequest,
 			Type:          reporting.UpdateTypeCpuRequest,
 			ContainerName: containerName,
+			Reason:        reason,
 		})
 		container.Resources.Requests[corev1.ResourceCPU] = newCPURequest
 	}
 	return changes
 }
 
-func setContainerCpuLimit(container *corev1.Container, containerRequestRecommendation *TargetRecommendation, containerLimitRecommendation *TargetRecommendation, changes []reporting.Change, scfg *config.StrategyConfig) []reporting.Change {
+func setContainerCpuLimit(container *corev1.Container, containerRequestRecommendation *TargetRecommendation, containerLimitRecommendation *TargetRecommendation, changes []reporting.Change, scfg *config.StrategyConfig, workload *Workload) []reporting.Change {
 	containerName := container.Name
 	cpuLimit := *container.Resources.Limits.Cpu()
 
+	// the limit is removed from the final resources, see removeContainerCpuLimit
+	if scfg.GetLimitCPUApplyMode(containerName) == config.ApplyModeRemove {
+		return changes
+	}
+
 	// Check if a direct CPU limit value is specified
 	if scfg.GetLimitCpuValue(containerName) != nil {
 		directCpuLimit, err := resource.ParseQuantity(*scfg.GetLimitCpuValue(containerName))
@@ -100,7 +122,7 @@ func setContainerCpuLimit(container *corev1.Container, containerRequestRecommend
 	// If no direct value is specified, use the VPA recommendation or calculator
 	var newCPULimit resource.Quantity
 	if scfg.GetLimitCpuApplyTarget(containerName) == config.LimitApplyTargetAuto {
-		newCPULimit = calculator.CalculateResourceValue(container.Resources.Requests[corev1.ResourceCPU], scfg.GetLimitCPUCalculatorAlgo(containerName), scfg.GetLimitCPUCalculatorValue(containerName), calculator.ResourceTypeCPU)
+		newCPULimit = calculator.CalculateResourceValueWithVars(container.Resources.Requests[corev1.ResourceCPU], scfg.GetLimitCPUCalculatorAlgo(containerName), scfg.GetLimitCPUCalculatorValue(containerName), calculator.ResourceTypeCPU, getExprVars(container, containerLimitRecommendation, corev1.ResourceCPU, workload))
 	} else {
 		newCPULimit = *containerLimitRecommendation.Cpu
 	}
@@ -111,12 +133,13 @@ func setContainerCpuLimit(container *corev1.Container, containerRequestRecommend
 	if scfg.GetMaxLimitCpu(containerName) != nil && newCPULimit.Cmp(*scfg.GetMaxLimitCpu(containerName)) == 1 {
 		newCPULimit = *scfg.GetMaxLimitCpu(containerName)
 	}
+	newCPULimit = roundResourceValue(newCPULimit, scfg.GetMaxLimitCpu(containerName), scfg, containerName, calculator.ResourceTypeCPU)
 
 	if newCPULimit.Cmp(container.Resources.Requests[corev1.ResourceCPU]) == -1 {
 		newCPULimit = container.Resources.Requests[corev1.ResourceCPU]
 	}
 
-	minDiffCpuLimit := calculator.CalculateResourceValue(container.Resources.Limits[corev1.ResourceCPU], scfg.GetMinDiffCpuLimitAlgo(containerName), scfg.GetMinDiffCpuLimitValue(containerName), calculator.ResourceTypeCPU)
+	minDiffCpuLimit := calculator.CalculateResourceValueWithVars(container.Resources.Limits[corev1.ResourceCPU], scfg.GetMinDiffCpuLimitAlgo(containerName), scfg.GetMinDiffCpuLimitValue(containerName), calculator.ResourceTypeCPU, getExprVars(container, containerLimitRecommendation, corev1.ResourceCPU, workload))
 	if newCPULimit.Cmp(minDiffCpuLimit) == -1 {
 		newCPULimit = cpuLimit
 	}
@@ -138,7 +161,7 @@ func setContainerCpuLimit(container *corev1.Container, containerRequestRecommend
 	return changes
 }
 
-func setContainerMemoryRequest(container *corev1.Container, containerRequestRecommendation *TargetRecommendation, changes []reporting.Change, scfg *config.StrategyConfig) []reporting.Change {
+func setContainerMemoryRequest(container *corev1.Container, containerRequestRecommendation *TargetRecommendation, changes []reporting.Change, scfg *config.StrategyConfig, workload *Workload) []reporting.Change {
 	containerName := container.Name
 	memoryRequest := *container.Resources.Requests.Memory()
 
@@ -163,10 +186,13 @@ func setContainerMemoryRequest(container *corev1.Container, containerRequestReco
 	}
 
 	// If no direct value is specified, use the VPA recommendation or calculator
+	var reason string
+	hpa := workload.getHPAUtilization(corev1.ResourceMemory, containerName)
+	hpaMode := scfg.GetHPAMode(containerName)
 	var newMemoryRequest resource.Quantity
 	if scfg.GetMemoryRequestFromCpuEnabled(containerName) {
-		memoryFromCpu := calculator.CalculateCpuToMemory(container.Resources.Requests[corev1.ResourceCPU])
-		newMemoryRequest = calculator.CalculateResourceValue(memoryFromCpu, scfg.GetMemoryRequestFromCpuAlgo(containerName), scfg.GetMemoryRequestFromCpuValue(containerName), calculator.ResourceTypeMemory)
+		memoryFromCpu := getMemoryFromCpu(container.Resources.Requests[corev1.ResourceCPU], containerRequestRecommendation, scfg, containerName)
+		newMemoryRequest = calculator.CalculateResourceValueWithVars(memoryFromCpu, scfg.GetMemoryRequestFromCpuAlgo(containerName), scfg.GetMemoryRequestFromCpuValue(containerName), calculator.ResourceTypeMemory, getExprVars(container, containerRequestRecommendation, corev1.ResourceMemory, workload))
 	} else {
 		newMemoryRequest = *containerRequestRecommendation.Memory
 		if scfg.GetMinAllowedRecommendationMemory(containerName) != nil && newMemoryRequest.Cmp(*scfg.GetMinAllowedRecommendationMemory(containerName)) == -1 {
@@ -175,7 +201,10 @@ func setContainerMemoryRequest(container *corev1.Container, containerRequestReco
 		if scfg.GetMaxAllowedRecommendationMemory(containerName) != nil && newMemoryRequest.Cmp(*scfg.GetMaxAllowedRecommendationMemory(containerName)) == 1 {
 			newMemoryRequest = *scfg.GetMaxAllowedRecommendationMemory(containerName)
 		}
-		newMemoryRequest = calculator.CalculateResourceValue(newMemoryRequest, scfg.GetIncreaseRequestMemoryAlgo(containerName), scfg.GetIncreaseRequestMemoryValue(containerName), calculator.ResourceTypeMemory)
+		if hpa != nil && hpaMode == config.HPAModeReplicas {
+			newMemoryRequest, reason = applyHPAReplicas(newMemoryRequest, hpa, corev1.ResourceMemory)
+		}
+		newMemoryRequest = calculator.CalculateResourceValueWithVars(newMemoryRequest, scfg.GetIncreaseRequestMemoryAlgo(containerName), scfg.GetIncreaseRequestMemoryValue(containerName), calculator.ResourceTypeMemory, getExprVars(container, containerRequestRecommendation, corev1.ResourceMemory, workload))
 	}
 	if scfg.GetMinRequestMemory(containerName) != nil && newMemoryRequest.Cmp(*scfg.GetMinRequestMemory(containerName)) == -1 {
 		newMemoryRequest = *scfg.GetMinRequestMemory(containerName)
@@ -183,7 +212,11 @@ func setContainerMemoryRequest(container *corev1.Container, containerRequestReco
 	if scfg.GetMaxRequestMemory(containerName) != nil && newMemoryRequest.Cmp(*scfg.GetMaxRequestMemory(containerName)) == 1 {
 		newMemoryRequest = *scfg.GetMaxRequestMemory(containerName)
 	}
-	minDiffMemoryRequest := calculator.CalculateResourceValue(container.Resources.Requests[corev1.ResourceMemory], scfg.GetMinDiffMemoryRequestAlgo(containerName), scfg.GetMinDiffMemoryRequestValue(containerName), calculator.ResourceTypeMemory)
+	newMemoryRequest = roundResourceValue(newMemoryRequest, scfg.GetMaxRequestMemory(containerName), scfg, containerName, calculator.ResourceTypeMemory)
+	if hpa != nil && (hpaMode == config.HPAModeSkip || hpaMode == config.HPAModeBand) {
+		newMemoryRequest, reason = applyHPAMode(memoryRequest, newMemoryRequest, hpa, hpaMode, scfg.GetHPABand(containerName), corev1.ResourceMemory)
+	}
+	minDiffMemoryRequest := calculator.CalculateResourceValueWithVars(container.Resources.Requests[corev1.ResourceMemory], scfg.GetMinDiffMemoryRequestAlgo(containerName), scfg.GetMinDiffMemoryRequestValue(containerName), calculator.ResourceTypeMemory, getExprVars(container, containerRequestRecommendation, corev1.ResourceMemory, workload))
 	if newMemoryRequest.Cmp(minDiffMemoryRequest) == -1 {
 		newMemoryRequest = memoryRequest
 	}
@@ -193,19 +226,24 @@ func setContainerMemoryRequest(container *corev1.Container, containerRequestReco
 	if scfg.GetRequestMemoryScaleDirection(containerName) == config.ScaleDirectionUp && newMemoryRequest.Cmp(memoryRequest) == -1 {
 		newMemoryRequest = memoryRequest
 	}
+	if reason != "" && newMemoryRequest.Cmp(memoryRequest) == 0 {
+		// a request held by an HPA is not a change, only logged
+		klog.Infof("Keeping memory request to %s for %s container: %s (%s)", memoryRequest.String(), scfg.Key, containerName, reason)
+	}
 	if scfg.GetRequestMemoryApplyMode(containerName) == config.ApplyModeEnforce && newMemoryRequest.Cmp(memoryRequest) != 0 {
 		changes = append(changes, reporting.Change{
 			Old:           memoryRequest,
 			New:           newMemoryRequest,
 			Type:          reporting.UpdateTypeMemoryRequest,
 			ContainerName: containerName,
+			Reason:        reason,
 		})
 		container.Resources.Requests[corev1.ResourceMemory] = newMemoryRequest
 	}
 	return changes
 }
 
-func setContainerMemoryLimit(container *corev1.Container, containerRequestRecommendation *TargetRecommendation, containerLimitRecommendation *TargetRecommendation, changes []reporting.Change, scfg *config.StrategyConfig) []reporting.Change {
+func setContainerMemoryLimit(container *corev1.Container, containerRequestRecommendation *TargetRecommendation, containerLimitRecommendation *TargetRecommendation, changes []reporting.Change, scfg *config.StrategyConfig, workload *Workload) []reporting.Change {
 	containerName := container.Name
 	memoryLimit := *container.Resources.Limits.Memory()
 
@@ -232,11 +270,11 @@ func setContainerMemoryLimit(container *corev1.Container, containerRequestRecomm
 	// If no direct value is specified, use the VPA recommendation or calculator
 	var newMemoryLimit resource.Quantity
 	if scfg.GetMemoryLimitFromCpuEnabled(containerName) {
-		memoryFromCpu := calculator.CalculateCpuToMemory(container.Resources.Limits[corev1.ResourceCPU])
-		newMemoryLimit = calculator.CalculateResourceValue(memoryFromCpu, scfg.GetMemoryLimitFromCpuAlgo(containerName), scfg.GetMemoryLimitFromCpuValue(containerName), calculator.ResourceTypeMemory)
+		memoryFromCpu := getMemoryFromCpu(container.Resources.Limits[corev1.ResourceCPU], containerLimitRecommendation, scfg, containerName)
+		newMemoryLimit = calculator.CalculateResourceValueWithVars(memoryFromCpu, scfg.GetMemoryLimitFromCpuAlgo(containerName), scfg.GetMemoryLimitFromCpuValue(containerName), calculator.ResourceTypeMemory, getExprVars(container, containerLimitRecommendation, corev1.ResourceMemory, workload))
 	} else {
 		if scfg.GetLimitMemoryApplyTarget(containerName) == config.LimitApplyTargetAuto {
-			newMemoryLimit = calculator.CalculateResourceValue(container.Resources.Requests[corev1.ResourceMemory], scfg.GetLimitMemoryCalculatorAlgo(containerName), scfg.GetLimitMemoryCalculatorValue(containerName), calculator.ResourceTypeMemory)
+			newMemoryLimit = calculator.CalculateResourceValueWithVars(container.Resources.Requests[corev1.ResourceMemory], scfg.GetLimitMemoryCalculatorAlgo(containerName), scfg.GetLimitMemoryCalculatorValue(containerName), calculator.ResourceTypeMemory, getExprVars(container, containerLimitRecommendation, corev1.ResourceMemory, workload))
 		} else {
 			newMemoryLimit = *containerLimitRecommendation.Memory
 		}
@@ -247,12 +285,13 @@ func setContainerMemoryLimit(container *corev1.Container, containerRequestRecomm
 	if scfg.GetMaxLimitMemory(containerName) != nil && newMemoryLimit.Cmp(*scfg.GetMaxLimitMemory(containerName)) == 1 {
 		newMemoryLimit = *scfg.GetMaxLimitMemory(containerName)
 	}
+	newMemoryLimit = roundResourceValue(newMemoryLimit, scfg.GetMaxLimitMemory(containerName), scfg, containerName, calculator.ResourceTypeMemory)
 
 	if newMemoryLimit.Cmp(container.Resources.Requests[corev1.ResourceMemory]) == -1 {
 		newMemoryLimit = container.Resources.Requests[corev1.ResourceMemory]
 	}
 
-	minDiffMemoryLimit := calculator.CalculateResourceValue(container.Resources.Limits[corev1.ResourceMemory], scfg.GetMinDiffMemoryLimitAlgo(containerName), scfg.GetMinDiffMemoryLimitValue(containerName), calculator.ResourceTypeMemory)
+	minDiffMemoryLimit := calculator.CalculateResourceValueWithVars(container.Resources.Limits[corev1.ResourceMemory], scfg.GetMinDiffMemoryLimitAlgo(containerName), scfg.GetMinDiffMemoryLimitValue(containerName), calculator.ResourceTypeMemory, getExprVars(container, containerLimitRecommendation, corev1.ResourceMemory, workload))
 	if newMemoryLimit.Cmp(minDiffMemoryLimit) == -1 {
 		newMemoryLimit = memoryLimit
 	}
@@ -74,14 +74,23 @@ func SetUnprovidedDefaultRecommendations(containers []corev1.Container, recommen
 	return recommendations
 }
 
+// findContainerPolicy returns the resource policy of the container, else the default one, else an empty one
 func findContainerPolicy(vpaResource *vpa.VerticalPodAutoscaler, containerName string) *vpa.ContainerResourcePolicy {
-	if vpaResource == nil {
-		return nil
+	if vpaResource == nil || vpaResource.Spec.ResourcePolicy == nil {
+		return &vpa.ContainerResourcePolicy{}
 	}
-	for _, containerPolicy := range vpaResource.Spec.ResourcePolicy.ContainerPolicies {
-		if containerPolicy.ContainerName == containerName || containerPolicy.ContainerName == "*" {
-			return &containerPolicy
+	var defaultPolicy *vpa.ContainerResourcePolicy
+	for i := range vpaResource.Spec.ResourcePolicy.ContainerPolicies {
+		containerPolicy := &vpaResource.Spec.ResourcePolicy.ContainerPolicies[i]
+		if containerPolicy.ContainerName == containerName {
+			return containerPolicy
 		}
+		if containerPolicy.ContainerName == vpa.DefaultContainerResourcePolicy {
+			defaultPolicy = containerPolicy
+		}
+	}
+	if defaultPolicy != nil {
+		return defaultPolicy
 	}
-	return nil
+	return &vpa.ContainerResourcePolicy{}
 }
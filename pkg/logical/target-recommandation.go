@@ -1,24 +1,31 @@
 package logical
 
 import (
+	"math"
+
 	"github.com/SocialGouv/oblik/pkg/config"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 )
 
 type TargetRecommendation struct {
-	Cpu           *resource.Quantity
-	Memory        *resource.Quantity
-	ContainerName string
+	Cpu              *resource.Quantity
+	Memory           *resource.Quantity
+	EphemeralStorage *resource.Quantity
+	ContainerName    string
+	// Bounds is the VPA recommendation the values come from, nil for default recommendations
+	Bounds *vpa.RecommendedContainerResources
 }
 
-func GetRequestTargetRecommendations(vpaResource *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) []TargetRecommendation {
+func GetRequestTargetRecommendations(vpaResource *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig, workload *Workload) []TargetRecommendation {
 	recommendations := []TargetRecommendation{}
 	if vpaResource.Status.Recommendation != nil {
 		for _, containerRecommendation := range vpaResource.Status.Recommendation.ContainerRecommendations {
 			containerName := containerRecommendation.ContainerName
 			recommendation := TargetRecommendation{
 				ContainerName: containerName,
+				Bounds:        containerRecommendation.DeepCopy(),
 			}
 			switch scfg.GetRequestCpuApplyTarget(containerName) {
 			case config.RequestApplyTargetFrugal:
@@ -27,6 +34,12 @@ func GetRequestTargetRecommendations(vpaResource *vpa.VerticalPodAutoscaler, scf
 				recommendation.Cpu = containerRecommendation.Target.Cpu()
 			case config.RequestApplyTargetPeak:
 				recommendation.Cpu = containerRecommendation.UpperBound.Cpu()
+			case config.RequestApplyTargetReplicas:
+				recommendation.Cpu = workload.getReplicasRecommendation(containerRecommendation, corev1.ResourceCPU, scfg)
+			case config.RequestApplyTargetPercentile:
+				recommendation.Cpu = getPercentileRecommendation(containerRecommendation, corev1.ResourceCPU, scfg.GetRequestCpuApplyTargetPercentile(containerName))
+			case config.RequestApplyTargetUncapped:
+				recommendation.Cpu = getUncappedRecommendation(containerRecommendation, corev1.ResourceCPU)
 			}
 			switch scfg.GetRequestMemoryApplyTarget(containerName) {
 			case config.RequestApplyTargetFrugal:
@@ -35,13 +48,47 @@ func GetRequestTargetRecommendations(vpaResource *vpa.VerticalPodAutoscaler, scf
 				recommendation.Memory = containerRecommendation.Target.Memory()
 			case config.RequestApplyTargetPeak:
 				recommendation.Memory = containerRecommendation.UpperBound.Memory()
+			case config.RequestApplyTargetReplicas:
+				recommendation.Memory = workload.getReplicasRecommendation(containerRecommendation, corev1.ResourceMemory, scfg)
+			case config.RequestApplyTargetPercentile:
+				recommendation.Memory = getPercentileRecommendation(containerRecommendation, corev1.ResourceMemory, scfg.GetRequestMemoryApplyTargetPercentile(containerName))
+			case config.RequestApplyTargetUncapped:
+				recommendation.Memory = getUncappedRecommendation(containerRecommendation, corev1.ResourceMemory)
 			}
+			recommendation.EphemeralStorage = workload.getEphemeralStorageRecommendation(containerName)
 			recommendations = append(recommendations, recommendation)
 		}
 	}
 	return recommendations
 }
 
+// getPercentileRecommendation interpolates linearly between the lower bound (p0), the target (p50) and the upper bound (p100),
+// e.g. p75 is the mean of the target and the upper bound
+func getPercentileRecommendation(containerRecommendation vpa.RecommendedContainerResources, resourceName corev1.ResourceName, percentile int) *resource.Quantity {
+	from := getRecommendationQuantity(containerRecommendation.LowerBound, resourceName)
+	to := getRecommendationQuantity(containerRecommendation.Target, resourceName)
+	weight := float64(percentile) / 50
+	if percentile > 50 {
+		from = to
+		to = getRecommendationQuantity(containerRecommendation.UpperBound, resourceName)
+		weight = float64(percentile-50) / 50
+	}
+	if resourceName == corev1.ResourceCPU {
+		value := float64(from.MilliValue()) + (float64(to.MilliValue())-float64(from.MilliValue()))*weight
+		return resource.NewMilliQuantity(int64(math.Ceil(value)), to.Format)
+	}
+	value := float64(from.Value()) + (float64(to.Value())-float64(from.Value()))*weight
+	return resource.NewQuantity(int64(math.Ceil(value)), to.Format)
+}
+
+// getUncappedRecommendation returns the uncapped target, the target when the VPA didn't provide it
+func getUncappedRecommendation(containerRecommendation vpa.RecommendedContainerResources, resourceName corev1.ResourceName) *resource.Quantity {
+	if _, ok := containerRecommendation.UncappedTarget[resourceName]; ok {
+		return getRecommendationQuantity(containerRecommendation.UncappedTarget, resourceName)
+	}
+	return getRecommendationQuantity(containerRecommendation.Target, resourceName)
+}
+
 func GetLimitTargetRecommendations(vpaResource *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) []TargetRecommendation {
 	recommendations := []TargetRecommendation{}
 	if vpaResource.Status.Recommendation != nil {
@@ -49,6 +96,7 @@ func GetLimitTargetRecommendations(vpaResource *vpa.VerticalPodAutoscaler, scfg
 			containerName := containerRecommendation.ContainerName
 			recommendation := TargetRecommendation{
 				ContainerName: containerName,
+				Bounds:        containerRecommendation.DeepCopy(),
 			}
 			switch scfg.GetLimitCpuApplyTarget(containerName) {
 			case config.LimitApplyTargetFrugal:
@@ -0,0 +1,96 @@
+package logical
+
+import (
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+)
+
+func newContainerRecommendation(lower, target, upper string) vpa.RecommendedContainerResources {
+	// the CPU values are in millicores, and the memory ones in Mi
+	resources := func(value string) corev1.ResourceList {
+		return corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(value + "m"), corev1.ResourceMemory: resource.MustParse(value + "Mi")}
+	}
+	return vpa.RecommendedContainerResources{
+		ContainerName: "app",
+		LowerBound:    resources(lower),
+		Target:        resources(target),
+		UpperBound:    resources(upper),
+	}
+}
+
+func TestGetPercentileRecommendation(t *testing.T) {
+	containerRecommendation := newContainerRecommendation("100", "200", "600")
+	tests := []struct {
+		percentile int
+		cpu        string
+		memory     string
+	}{
+		{percentile: 0, cpu: "100m", memory: "100Mi"},
+		{percentile: 25, cpu: "150m", memory: "150Mi"},
+		{percentile: 50, cpu: "200m", memory: "200Mi"},
+		{percentile: 75, cpu: "400m", memory: "400Mi"},
+		{percentile: 90, cpu: "520m", memory: "520Mi"},
+		{percentile: 100, cpu: "600m", memory: "600Mi"},
+	}
+	for _, tt := range tests {
+		if cpu := getPercentileRecommendation(containerRecommendation, corev1.ResourceCPU, tt.percentile); cpu.Cmp(resource.MustParse(tt.cpu)) != 0 {
+			t.Errorf("p%d: expected cpu %s, got %s", tt.percentile, tt.cpu, cpu.String())
+		}
+		if memory := getPercentileRecommendation(containerRecommendation, corev1.ResourceMemory, tt.percentile); memory.Cmp(resource.MustParse(tt.memory)) != 0 {
+			t.Errorf("p%d: expected memory %s, got %s", tt.percentile, tt.memory, memory.String())
+		}
+	}
+
+	// interpolated values are rounded up to the next millicore
+	containerRecommendation = vpa.RecommendedContainerResources{
+		LowerBound: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+		Target:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("101m")},
+		UpperBound: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("101m")},
+	}
+	if cpu := getPercentileRecommendation(containerRecommendation, corev1.ResourceCPU, 10); cpu.Cmp(resource.MustParse("101m")) != 0 {
+		t.Errorf("expected cpu rounded up to 101m, got %s", cpu.String())
+	}
+}
+
+func TestGetRequestTargetRecommendations(t *testing.T) {
+	withUncapped := newContainerRecommendation("100", "200", "600")
+	withUncapped.UncappedTarget = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("800m"), corev1.ResourceMemory: resource.MustParse("800Mi")}
+	tests := []struct {
+		name                    string
+		target                  string
+		containerRecommendation vpa.RecommendedContainerResources
+		cpu                     string
+	}{
+		{name: "frugal", target: "frugal", containerRecommendation: withUncapped, cpu: "100m"},
+		{name: "balanced", target: "balanced", containerRecommendation: withUncapped, cpu: "200m"},
+		{name: "peak", target: "upperBound", containerRecommendation: withUncapped, cpu: "600m"},
+		{name: "percentile", target: "p75", containerRecommendation: withUncapped, cpu: "400m"},
+		{name: "p0", target: "p0", containerRecommendation: withUncapped, cpu: "100m"},
+		{name: "p100", target: "p100", containerRecommendation: withUncapped, cpu: "600m"},
+		{name: "uncapped", target: "uncapped", containerRecommendation: withUncapped, cpu: "800m"},
+		{name: "nil uncapped target", target: "uncappedTarget", containerRecommendation: newContainerRecommendation("100", "200", "600"), cpu: "200m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OBLIK_DEFAULT_REQUEST_APPLY_TARGET", tt.target)
+			vpaResource := &vpa.VerticalPodAutoscaler{
+				Status: vpa.VerticalPodAutoscalerStatus{
+					Recommendation: &vpa.RecommendedPodResources{
+						ContainerRecommendations: []vpa.RecommendedContainerResources{tt.containerRecommendation},
+					},
+				},
+			}
+			recommendations := GetRequestTargetRecommendations(vpaResource, &config.StrategyConfig{LoadCfg: &config.LoadCfg{}}, nil)
+			if len(recommendations) != 1 {
+				t.Fatalf("expected 1 recommendation, got %d", len(recommendations))
+			}
+			if cpu := recommendations[0].Cpu; cpu == nil || cpu.Cmp(resource.MustParse(tt.cpu)) != 0 {
+				t.Errorf("expected cpu %s, got %v", tt.cpu, cpu)
+			}
+		})
+	}
+}
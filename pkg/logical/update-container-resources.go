@@ -7,13 +7,13 @@ import (
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 )
 
-func UpdateContainerResources(containers []corev1.Container, vpaResource *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) *reporting.UpdateResult {
-	requestRecommendations := GetRequestTargetRecommendations(vpaResource, scfg)
+func UpdateContainerResources(containers []corev1.Container, vpaResource *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig, workload *Workload) *reporting.UpdateResult {
+	requestRecommendations := GetRequestTargetRecommendations(vpaResource, scfg, workload)
 	requestRecommendations = SetUnprovidedDefaultRecommendations(containers, requestRecommendations, scfg, vpaResource)
 
 	limitRecommendations := GetLimitTargetRecommendations(vpaResource, scfg)
 	limitRecommendations = SetUnprovidedDefaultRecommendations(containers, limitRecommendations, scfg, vpaResource)
 
-	update := ApplyRecommendationsToContainers(containers, requestRecommendations, limitRecommendations, scfg)
+	update := ApplyRecommendationsToContainers(containers, requestRecommendations, limitRecommendations, scfg, workload)
 	return update
 }
@@ -0,0 +1,44 @@
+package logical
+
+import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Workload holds the state of the workload the recommendations are applied to, beyond its containers
+type Workload struct {
+	// Replicas is the desired replica count, nil for workloads without replicas
+	Replicas *int32
+	// HPAs are the horizontal pod autoscalers targeting the workload
+	HPAs []autoscalingv2.HorizontalPodAutoscaler
+	// Usage is the current usage of each container summed across all the pods, nil when metrics are unavailable
+	Usage map[string]corev1.ResourceList
+	// EphemeralStorage is the highest ephemeral-storage usage of each container among the pods, from the kubelet stats
+	EphemeralStorage map[string]resource.Quantity
+	// PodResources is the pod-level resources of the workload, shared by its containers, nil when they are not managed.
+	// It holds the current value before the recommendations are applied, and the value to set after
+	PodResources *corev1.ResourceRequirements
+	// LimitRanges are the limit ranges of the namespace, only fetched when a CPU limit is removed
+	LimitRanges []corev1.LimitRange
+}
+
+// getCurrentReplicas returns the current replica count of the workload, the one an HPA scaled it to, 0 if unknown
+func (w *Workload) getCurrentReplicas() int32 {
+	if w == nil || w.Replicas == nil {
+		return 0
+	}
+	return *w.Replicas
+}
+
+// getEphemeralStorageRecommendation returns the highest ephemeral-storage usage of the container among the pods, nil if unknown
+func (w *Workload) getEphemeralStorageRecommendation(containerName string) *resource.Quantity {
+	if w == nil || w.EphemeralStorage == nil {
+		return nil
+	}
+	usage, ok := w.EphemeralStorage[containerName]
+	if !ok {
+		return nil
+	}
+	return &usage
+}
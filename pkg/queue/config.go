@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/utils"
+	"k8s.io/klog/v2"
+)
+
+// Config holds the apply queue limits. The global concurrency is the number of workers, at least 1, while a zero
+// limit per namespace or per node pool, or a zero rate limit, means unlimited
+type Config struct {
+	MaxConcurrent             int
+	MaxConcurrentPerNamespace int
+	MaxConcurrentPerNodePool  int
+	NodePoolLabel             string
+	RateLimit                 float64
+	RateBurst                 int
+	RolloutTimeout            time.Duration
+	SlotWaitInterval          time.Duration
+	RetryMaxAttempts          int
+	RetryBaseDelay            time.Duration
+	RetryMaxDelay             time.Duration
+}
+
+func LoadConfig() *Config {
+	return &Config{
+		MaxConcurrent:             getPositiveIntEnv("OBLIK_APPLY_MAX_CONCURRENT", "5"),
+		MaxConcurrentPerNamespace: getIntEnv("OBLIK_APPLY_MAX_CONCURRENT_PER_NAMESPACE", "1"),
+		MaxConcurrentPerNodePool:  getIntEnv("OBLIK_APPLY_MAX_CONCURRENT_PER_NODE_POOL", "0"),
+		NodePoolLabel:             utils.GetEnv("OBLIK_APPLY_NODE_POOL_LABEL", ""),
+		RateLimit:                 getFloatEnv("OBLIK_APPLY_RATE_LIMIT", "0.2"),
+		RateBurst:                 getIntEnv("OBLIK_APPLY_RATE_BURST", "1"),
+		RolloutTimeout:            getDurationEnv("OBLIK_APPLY_ROLLOUT_TIMEOUT", "10m"),
+		SlotWaitInterval:          getDurationEnv("OBLIK_APPLY_SLOT_WAIT_INTERVAL", "10s"),
+		RetryMaxAttempts:          getIntEnv("OBLIK_APPLY_RETRY_MAX_ATTEMPTS", "5"),
+		RetryBaseDelay:            getDurationEnv("OBLIK_APPLY_RETRY_BASE_DELAY", "1m"),
+		RetryMaxDelay:             getDurationEnv("OBLIK_APPLY_RETRY_MAX_DELAY", "1h"),
+	}
+}
+
+func getIntEnv(key, fallback string) int {
+	value := utils.GetEnv(key, fallback)
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		klog.Warningf("Invalid %s: %s, using default %s", key, value, fallback)
+		i, _ = strconv.Atoi(fallback)
+	}
+	return i
+}
+
+func getPositiveIntEnv(key, fallback string) int {
+	i := getIntEnv(key, fallback)
+	if i < 1 {
+		klog.Warningf("Invalid %s: %d, must be at least 1, using default %s", key, i, fallback)
+		i, _ = strconv.Atoi(fallback)
+	}
+	return i
+}
+
+func getFloatEnv(key, fallback string) float64 {
+	value := utils.GetEnv(key, fallback)
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		klog.Warningf("Invalid %s: %s, using default %s", key, value, fallback)
+		f, _ = strconv.ParseFloat(fallback, 64)
+	}
+	return f
+}
+
+func getDurationEnv(key, fallback string) time.Duration {
+	value := utils.GetEnv(key, fallback)
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		klog.Warningf("Invalid %s: %s, using default %s", key, value, fallback)
+		d, _ = time.ParseDuration(fallback)
+	}
+	return d
+}
@@ -0,0 +1,29 @@
+package queue
+
+import "testing"
+
+func TestLoadConfigMaxConcurrent(t *testing.T) {
+	tests := map[string]int{
+		"":        5,
+		"3":       3,
+		"1":       1,
+		"0":       5,
+		"-1":      5,
+		"invalid": 5,
+	}
+	for value, expected := range tests {
+		t.Setenv("OBLIK_APPLY_MAX_CONCURRENT", value)
+		if cfg := LoadConfig(); cfg.MaxConcurrent != expected {
+			t.Errorf("OBLIK_APPLY_MAX_CONCURRENT=%q: expected %d, got %d", value, expected, cfg.MaxConcurrent)
+		}
+	}
+}
+
+func TestLoadConfigUnlimited(t *testing.T) {
+	t.Setenv("OBLIK_APPLY_MAX_CONCURRENT_PER_NAMESPACE", "0")
+	t.Setenv("OBLIK_APPLY_RATE_LIMIT", "0")
+	cfg := LoadConfig()
+	if cfg.MaxConcurrentPerNamespace != 0 || cfg.RateLimit != 0 {
+		t.Errorf("expected unlimited namespace concurrency and rate, got %d and %f", cfg.MaxConcurrentPerNamespace, cfg.RateLimit)
+	}
+}
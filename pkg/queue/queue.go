@@ -0,0 +1,204 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// Job is a scheduled apply, only the latest job enqueued for a key is run
+type Job struct {
+	Key         string
+	Namespace   string
+	NodePool    string
+	Apply       func(ctx context.Context) error
+	WaitRollout func(ctx context.Context) error
+	// GiveUp is called with the last error when the apply failed on all its attempts, a failed run counting once
+	GiveUp func(err error)
+}
+
+// ApplyQueue runs scheduled applies with global, per namespace and per node pool concurrency limits
+// and a token bucket rate limit. A running slot is held until the rollout of the applied workload completes.
+// Failed applies are retried with an exponential backoff, up to a maximum number of attempts.
+type ApplyQueue struct {
+	Config *Config
+
+	queue   workqueue.DelayingInterface
+	limiter *rate.Limiter
+	backoff workqueue.RateLimiter
+
+	mutex            sync.Mutex
+	jobs             map[string]*Job
+	runningNamespace map[string]int
+	runningNodePool  map[string]int
+}
+
+func New(cfg *Config) *ApplyQueue {
+	limit := rate.Inf
+	if cfg.RateLimit > 0 {
+		limit = rate.Limit(cfg.RateLimit)
+	}
+	burst := cfg.RateBurst
+	if burst < 1 {
+		burst = 1
+	}
+	return &ApplyQueue{
+		Config:           cfg,
+		queue:            workqueue.NewDelayingQueueWithConfig(workqueue.DelayingQueueConfig{Name: "oblik-apply"}),
+		limiter:          rate.NewLimiter(limit, burst),
+		backoff:          workqueue.NewItemExponentialFailureRateLimiter(cfg.RetryBaseDelay, cfg.RetryMaxDelay),
+		jobs:             make(map[string]*Job),
+		runningNamespace: make(map[string]int),
+		runningNodePool:  make(map[string]int),
+	}
+}
+
+// Add enqueues the job after the given delay, replacing any pending job for the same key
+// and resetting its retries
+func (q *ApplyQueue) Add(job *Job, delay time.Duration) {
+	q.mutex.Lock()
+	q.jobs[job.Key] = job
+	q.mutex.Unlock()
+	q.backoff.Forget(job.Key)
+
+	if delay > 0 {
+		q.queue.AddAfter(job.Key, delay)
+	} else {
+		q.queue.Add(job.Key)
+	}
+}
+
+// Run starts the workers and blocks until the context is done
+func (q *ApplyQueue) Run(ctx context.Context) {
+	workers := q.Config.MaxConcurrent
+	if workers < 1 {
+		workers = 1
+	}
+	klog.Infof("Starting apply queue with %d workers", workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q.processNext(ctx) {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	q.queue.ShutDown()
+	wg.Wait()
+}
+
+func (q *ApplyQueue) processNext(ctx context.Context) bool {
+	item, shutdown := q.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer q.queue.Done(item)
+
+	key := item.(string)
+
+	q.mutex.Lock()
+	job, exists := q.jobs[key]
+	if !exists {
+		q.mutex.Unlock()
+		return true
+	}
+	if !q.acquire(job) {
+		q.mutex.Unlock()
+		klog.V(2).Infof("Apply of %s is waiting for a free slot in namespace %s", key, job.Namespace)
+		q.queue.AddAfter(key, q.Config.SlotWaitInterval)
+		return true
+	}
+	delete(q.jobs, key)
+	q.mutex.Unlock()
+
+	defer q.release(job)
+
+	if err := q.limiter.Wait(ctx); err != nil {
+		return true
+	}
+
+	if err := job.Apply(ctx); err != nil {
+		q.retry(job, err)
+		return true
+	}
+	q.backoff.Forget(key)
+
+	if job.WaitRollout != nil {
+		waitCtx := ctx
+		if q.Config.RolloutTimeout > 0 {
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeout(ctx, q.Config.RolloutTimeout)
+			defer cancel()
+		}
+		if err := job.WaitRollout(waitCtx); err != nil {
+			klog.Warningf("Rollout of %s not completed: %s", key, err.Error())
+		}
+	}
+
+	return true
+}
+
+func (q *ApplyQueue) retry(job *Job, err error) {
+	key := job.Key
+	attempts := q.backoff.NumRequeues(key) + 1
+	if attempts >= q.Config.RetryMaxAttempts {
+		klog.Errorf("Error applying %s, giving up after %d attempt(s): %s", key, attempts, err.Error())
+		q.backoff.Forget(key)
+		if job.GiveUp != nil {
+			job.GiveUp(err)
+		}
+		return
+	}
+
+	q.mutex.Lock()
+	if _, exists := q.jobs[key]; exists {
+		// a newer job was enqueued meanwhile, it supersedes the retry
+		q.mutex.Unlock()
+		return
+	}
+	q.jobs[key] = job
+	q.mutex.Unlock()
+
+	delay := q.backoff.When(key)
+	klog.Warningf("Error applying %s, retrying in %s (attempt %d/%d): %s", key, delay, attempts, q.Config.RetryMaxAttempts, err.Error())
+	q.queue.AddAfter(key, delay)
+}
+
+// acquire must be called with the mutex held
+func (q *ApplyQueue) acquire(job *Job) bool {
+	if q.Config.MaxConcurrentPerNamespace > 0 && q.runningNamespace[job.Namespace] >= q.Config.MaxConcurrentPerNamespace {
+		return false
+	}
+	if job.NodePool != "" && q.Config.MaxConcurrentPerNodePool > 0 && q.runningNodePool[job.NodePool] >= q.Config.MaxConcurrentPerNodePool {
+		return false
+	}
+	q.runningNamespace[job.Namespace]++
+	if job.NodePool != "" {
+		q.runningNodePool[job.NodePool]++
+	}
+	return true
+}
+
+func (q *ApplyQueue) release(job *Job) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.runningNamespace[job.Namespace]--
+	if q.runningNamespace[job.Namespace] <= 0 {
+		delete(q.runningNamespace, job.Namespace)
+	}
+	if job.NodePool != "" {
+		q.runningNodePool[job.NodePool]--
+		if q.runningNodePool[job.NodePool] <= 0 {
+			delete(q.runningNodePool, job.NodePool)
+		}
+	}
+}
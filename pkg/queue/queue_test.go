@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testConfig() *Config {
+	return &Config{
+		MaxConcurrent:             4,
+		MaxConcurrentPerNamespace: 1,
+		SlotWaitInterval:          10 * time.Millisecond,
+		RetryMaxAttempts:          3,
+		RetryBaseDelay:            time.Millisecond,
+		RetryMaxDelay:             10 * time.Millisecond,
+	}
+}
+
+func TestNamespaceConcurrency(t *testing.T) {
+	q := New(testConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	var wg sync.WaitGroup
+	for _, key := range []string{"default/a", "default/b", "default/c"} {
+		wg.Add(1)
+		q.Add(&Job{
+			Key:       key,
+			Namespace: "default",
+			Apply:     func(ctx context.Context) error { return nil },
+			WaitRollout: func(ctx context.Context) error {
+				defer wg.Done()
+				mutex.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mutex.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				mutex.Lock()
+				running--
+				mutex.Unlock()
+				return nil
+			},
+		}, 0)
+	}
+	wg.Wait()
+	if maxRunning != 1 {
+		t.Errorf("expected a single rollout at a time in the namespace, got %d", maxRunning)
+	}
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	q := New(testConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	var mutex sync.Mutex
+	attempts := 0
+	gaveUp := make(chan error, 1)
+	q.Add(&Job{
+		Key:       "default/app",
+		Namespace: "default",
+		Apply: func(ctx context.Context) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			attempts++
+			return errors.New("conflict")
+		},
+		GiveUp: func(err error) { gaveUp <- err },
+	}, 0)
+
+	select {
+	case err := <-gaveUp:
+		if err.Error() != "conflict" {
+			t.Errorf("expected the last error, got %s", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the queue to give up")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
@@ -0,0 +1,16 @@
+package reporting
+
+import (
+	"fmt"
+
+	"k8s.io/klog/v2"
+)
+
+// ReportDrift tracks a change of the containers resources made outside Oblik, and the policy applied to it
+func ReportDrift(key string, drift string, policy string) {
+	incDrift(key, policy)
+	klog.Warningf("Drift: %s, %s, policy: %s", key, drift, policy)
+	if err := sendMattermostAlert(fmt.Sprintf("🔀 Resources changed outside Oblik on %s (policy: %s)\n\n%s", key, policy, drift)); err != nil {
+		klog.Errorf("Error sending Mattermost alert: %s", err.Error())
+	}
+}
@@ -0,0 +1,39 @@
+package reporting
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/SocialGouv/oblik/pkg/utils"
+	"k8s.io/klog/v2"
+)
+
+func getFailureAlertThreshold() int {
+	value := utils.GetEnv("OBLIK_APPLY_FAILURE_ALERT_THRESHOLD", "3")
+	threshold, err := strconv.Atoi(value)
+	if err != nil {
+		klog.Warningf("Invalid OBLIK_APPLY_FAILURE_ALERT_THRESHOLD: %s, using default 3", value)
+		return 3
+	}
+	return threshold
+}
+
+// ReportFailure tracks a failed apply, alerting once when the consecutive failures reach the threshold
+func ReportFailure(key string, failures int, err error) {
+	incFailures(key)
+	setConsecutiveFailures(key, failures)
+
+	threshold := getFailureAlertThreshold()
+	if threshold <= 0 || failures != threshold {
+		return
+	}
+	klog.Errorf("%d consecutive failures applying recommendations on %s: %s", failures, key, err.Error())
+	message := fmt.Sprintf("🚨 %d consecutive failures applying recommendations on %s\n---\nError: %s", failures, key, err.Error())
+	if err := sendMattermostAlert(message); err != nil {
+		klog.Errorf("Error sending Mattermost alert: %s", err.Error())
+	}
+}
+
+func ReportSucceeded(key string) {
+	setConsecutiveFailures(key, 0)
+}
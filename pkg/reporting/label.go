@@ -17,16 +17,63 @@ func GetUpdateTypeLabel(updateType UpdateType) string {
 		return "CPU limit"
 	case UpdateTypeMemoryLimit:
 		return "Memory limit"
+	case UpdateTypeEphemeralStorageRequest:
+		return "Ephemeral storage request"
+	case UpdateTypeEphemeralStorageLimit:
+		return "Ephemeral storage limit"
+	case UpdateTypeEnv:
+		return "Env"
+	case UpdateTypePodCpuRequest:
+		return "Pod CPU request"
+	case UpdateTypePodMemoryRequest:
+		return "Pod memory request"
+	case UpdateTypePodCpuLimit:
+		return "Pod CPU limit"
+	case UpdateTypePodMemoryLimit:
+		return "Pod memory limit"
 	}
 	return ""
 }
 
+func getChangeLabel(change Change) string {
+	if change.Type == UpdateTypeEnv {
+		return GetUpdateTypeLabel(change.Type) + " " + change.Env
+	}
+	return GetUpdateTypeLabel(change.Type)
+}
+
+// getChangeContainerName returns the container of the change, "(pod)" for the pod-level resources
+func getChangeContainerName(change Change) string {
+	switch change.Type {
+	case UpdateTypePodCpuRequest, UpdateTypePodMemoryRequest, UpdateTypePodCpuLimit, UpdateTypePodMemoryLimit:
+		return "(pod)"
+	}
+	return change.ContainerName
+}
+
+// removedValueText is the new value of the resources unset by the update
+const removedValueText = "unset"
+
+func getChangeValueTexts(change Change) (string, string) {
+	if change.Type == UpdateTypeEnv {
+		return change.OldEnv, change.NewEnv
+	}
+	if change.Removed {
+		return getResourceValueText(change.Type, change.Old), removedValueText
+	}
+	return getResourceValueText(change.Type, change.Old), getResourceValueText(change.Type, change.New)
+}
+
 func getResourceValueText(updateType UpdateType, value resource.Quantity) string {
 	switch updateType {
 	case UpdateTypeMemoryLimit:
 		return utils.FormatMemory(value)
 	case UpdateTypeMemoryRequest:
 		return utils.FormatMemory(value)
+	case UpdateTypePodMemoryRequest, UpdateTypePodMemoryLimit:
+		return utils.FormatMemory(value)
+	case UpdateTypeEphemeralStorageRequest, UpdateTypeEphemeralStorageLimit:
+		return utils.FormatMemory(value)
 	default:
 		return value.String()
 	}
@@ -37,11 +84,33 @@ func ReportUpdated(update *UpdateResult, scfg *config.StrategyConfig) {
 		return
 	}
 	klog.Infof("Updated: %s", scfg.Key)
+	if update.GitOpsConflict != nil {
+		klog.Warningf("GitOps conflict on %s: %s", scfg.Key, update.GitOpsConflict.String())
+	}
+	if update.NewQoSClass != "" {
+		klog.Infof("Changing QoS class of %s from %s to %s", scfg.Key, update.OldQoSClass, update.NewQoSClass)
+	}
 	for _, update := range update.Changes {
-		typeLabel := GetUpdateTypeLabel(update.Type)
-		oldValueText := getResourceValueText(update.Type, update.Old)
-		newValueText := getResourceValueText(update.Type, update.New)
-		klog.Infof("Setting %s to %s (previously %s) for %s container: %s", typeLabel, newValueText, oldValueText, scfg.Key, update.ContainerName)
+		typeLabel := getChangeLabel(update)
+		containerName := getChangeContainerName(update)
+		oldValueText, newValueText := getChangeValueTexts(update)
+		if update.Removed {
+			if update.Reason != "" {
+				klog.Infof("Unsetting %s (previously %s) for %s container: %s (%s)", typeLabel, oldValueText, scfg.Key, containerName, update.Reason)
+				continue
+			}
+			klog.Infof("Unsetting %s (previously %s) for %s container: %s", typeLabel, oldValueText, scfg.Key, containerName)
+			continue
+		}
+		if update.Type != UpdateTypeEnv && update.Old.Cmp(update.New) == 0 {
+			klog.Infof("Keeping %s to %s for %s container: %s (%s)", typeLabel, oldValueText, scfg.Key, containerName, update.Reason)
+			continue
+		}
+		if update.Reason != "" {
+			klog.Infof("Setting %s to %s (previously %s) for %s container: %s (%s)", typeLabel, newValueText, oldValueText, scfg.Key, containerName, update.Reason)
+			continue
+		}
+		klog.Infof("Setting %s to %s (previously %s) for %s container: %s", typeLabel, newValueText, oldValueText, scfg.Key, containerName)
 	}
 	sendUpdatesToMattermost(update)
 }
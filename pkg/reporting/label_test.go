@@ -0,0 +1,56 @@
+package reporting
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGetChangeValueTexts(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  Change
+		wantOld string
+		wantNew string
+	}{
+		{
+			name:    "cpu limit",
+			change:  Change{Type: UpdateTypeCpuLimit, Old: resource.MustParse("1"), New: resource.MustParse("2")},
+			wantOld: "1",
+			wantNew: "2",
+		},
+		{
+			name:    "cpu limit removed",
+			change:  Change{Type: UpdateTypeCpuLimit, Old: resource.MustParse("1"), Removed: true},
+			wantOld: "1",
+			wantNew: "unset",
+		},
+		{
+			name:    "cpu limit added",
+			change:  Change{Type: UpdateTypeCpuLimit, New: resource.MustParse("1")},
+			wantOld: "0",
+			wantNew: "1",
+		},
+		{
+			name:    "pod cpu limit removed",
+			change:  Change{Type: UpdateTypePodCpuLimit, Old: resource.MustParse("2"), Removed: true},
+			wantOld: "2",
+			wantNew: "unset",
+		},
+		{
+			name:    "env",
+			change:  Change{Type: UpdateTypeEnv, Env: "GOMAXPROCS", OldEnv: "1", NewEnv: "2"},
+			wantOld: "1",
+			wantNew: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldText, newText := getChangeValueTexts(tt.change)
+			if oldText != tt.wantOld || newText != tt.wantNew {
+				t.Errorf("expected %s -> %s, got %s -> %s", tt.wantOld, tt.wantNew, oldText, newText)
+			}
+		})
+	}
+}
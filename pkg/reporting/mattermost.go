@@ -22,29 +22,25 @@ func sendUpdatesToMattermost(update *UpdateResult) {
 
 	markdown := []string{}
 
-	var title string
-
-	switch update.Type {
-	case ResultTypeDryRun:
-		title = fmt.Sprintf("👻 Dry Run - Changes on %s", update.Key)
-	case ResultTypeFailed:
-		title = fmt.Sprintf("⚠️ Failure on %s", update.Key)
-	case ResultTypeSuccess:
-		title = fmt.Sprintf("▶️ Changes on %s", update.Key)
-	}
-
 	markdown = append(
 		markdown,
-		title,
-		"\n| Container Name | Change Type | Old Value | New Value |",
-		"|:-----|------|------|------|",
+		getUpdateTitle(update),
+		"\n| Container Name | Change Type | Old Value | New Value | Reason |",
+		"|:-----|------|------|------|------|",
 	)
 
 	for _, update := range update.Changes {
-		typeLabel := GetUpdateTypeLabel(update.Type)
-		oldValueText := getResourceValueText(update.Type, update.Old)
-		newValueText := getResourceValueText(update.Type, update.New)
-		markdown = append(markdown, "|"+update.ContainerName+"|"+typeLabel+"|"+oldValueText+"|"+newValueText+"|")
+		typeLabel := getChangeLabel(update)
+		oldValueText, newValueText := getChangeValueTexts(update)
+		markdown = append(markdown, "|"+getChangeContainerName(update)+"|"+typeLabel+"|"+oldValueText+"|"+newValueText+"|"+update.Reason+"|")
+	}
+
+	if update.NewQoSClass != "" {
+		markdown = append(markdown, fmt.Sprintf("\nQoS class: %s → %s", update.OldQoSClass, update.NewQoSClass))
+	}
+
+	if update.GitOpsConflict != nil {
+		markdown = append(markdown, fmt.Sprintf("\n⚠️ GitOps conflict: %s", update.GitOpsConflict.String()))
 	}
 
 	if update.Type == ResultTypeFailed && update.Error != nil {
@@ -56,6 +52,20 @@ func sendUpdatesToMattermost(update *UpdateResult) {
 	}
 }
 
+func getUpdateTitle(update *UpdateResult) string {
+	switch update.Type {
+	case ResultTypeDryRun:
+		return fmt.Sprintf("👻 Dry Run - Changes on %s", update.Key)
+	case ResultTypeFailed:
+		return fmt.Sprintf("⚠️ Failure on %s", update.Key)
+	case ResultTypeSkipped:
+		return fmt.Sprintf("⏭️ Skipped changes on %s", update.Key)
+	case ResultTypeSuccess:
+		return fmt.Sprintf("▶️ Changes on %s", update.Key)
+	}
+	return ""
+}
+
 func sendMattermostAlert(message string) error {
 	webhookURL := utils.GetEnv("OBLIK_MATTERMOST_WEBHOOK_URL", "")
 
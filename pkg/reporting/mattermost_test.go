@@ -0,0 +1,21 @@
+package reporting
+
+import "testing"
+
+func TestGetUpdateTitle(t *testing.T) {
+	tests := []struct {
+		resultType ResultType
+		want       string
+	}{
+		{ResultTypeSuccess, "▶️ Changes on default/app"},
+		{ResultTypeDryRun, "👻 Dry Run - Changes on default/app"},
+		{ResultTypeFailed, "⚠️ Failure on default/app"},
+		{ResultTypeSkipped, "⏭️ Skipped changes on default/app"},
+	}
+
+	for _, tt := range tests {
+		if got := getUpdateTitle(&UpdateResult{Type: tt.resultType, Key: "default/app"}); got != tt.want {
+			t.Errorf("expected title %q for result type %d, got %q", tt.want, tt.resultType, got)
+		}
+	}
+}
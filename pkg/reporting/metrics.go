@@ -0,0 +1,102 @@
+package reporting
+
+import (
+	"strings"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var nextRunTimestamp = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "oblik_next_run_timestamp_seconds",
+		Help: "Unix timestamp of the next scheduled run applying the recommendations, before the random delay",
+	},
+	[]string{"cluster", "namespace", "name"},
+)
+
+var applyFailuresTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "oblik_apply_failures_total",
+		Help: "Total number of failed applies of the recommendations",
+	},
+	[]string{"cluster", "namespace", "name"},
+)
+
+var applyConsecutiveFailures = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "oblik_apply_consecutive_failures",
+		Help: "Number of applies of the recommendations failed since the last successful one",
+	},
+	[]string{"cluster", "namespace", "name"},
+)
+
+var driftTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "oblik_drift_total",
+		Help: "Total number of changes of the containers resources made outside Oblik",
+	},
+	[]string{"cluster", "namespace", "name", "policy"},
+)
+
+var clusterHealthy = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "oblik_cluster_healthy",
+		Help: "Whether the cache of a remote cluster is synced and its workloads reconciled",
+	},
+	[]string{"cluster"},
+)
+
+func init() {
+	prometheus.MustRegister(nextRunTimestamp)
+	prometheus.MustRegister(applyFailuresTotal)
+	prometheus.MustRegister(applyConsecutiveFailures)
+	prometheus.MustRegister(driftTotal)
+	prometheus.MustRegister(clusterHealthy)
+}
+
+// splitKey returns the cluster, empty for the cluster running Oblik, the namespace and the name of a workload key
+func splitKey(key string) (string, string, string) {
+	cluster, workloadKey := config.SplitClusterKey(key)
+	namespace, name, _ := strings.Cut(workloadKey, "/")
+	return cluster, namespace, name
+}
+
+func SetNextRun(key string, next time.Time) {
+	cluster, namespace, name := splitKey(key)
+	nextRunTimestamp.WithLabelValues(cluster, namespace, name).Set(float64(next.Unix()))
+}
+
+func setConsecutiveFailures(key string, failures int) {
+	cluster, namespace, name := splitKey(key)
+	applyConsecutiveFailures.WithLabelValues(cluster, namespace, name).Set(float64(failures))
+}
+
+func incDrift(key string, policy string) {
+	cluster, namespace, name := splitKey(key)
+	driftTotal.WithLabelValues(cluster, namespace, name, policy).Inc()
+}
+
+func incFailures(key string) {
+	cluster, namespace, name := splitKey(key)
+	applyFailuresTotal.WithLabelValues(cluster, namespace, name).Inc()
+}
+
+// SetClusterHealthy reports whether a remote cluster can be reached
+func SetClusterHealthy(cluster string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	clusterHealthy.WithLabelValues(cluster).Set(value)
+}
+
+// DeleteMetrics removes the metrics of a workload no longer scheduled
+func DeleteMetrics(key string) {
+	cluster, namespace, name := splitKey(key)
+	nextRunTimestamp.DeleteLabelValues(cluster, namespace, name)
+	applyFailuresTotal.DeleteLabelValues(cluster, namespace, name)
+	applyConsecutiveFailures.DeleteLabelValues(cluster, namespace, name)
+	driftTotal.DeletePartialMatch(prometheus.Labels{"cluster": cluster, "namespace": namespace, "name": name})
+}
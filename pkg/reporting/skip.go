@@ -0,0 +1,59 @@
+package reporting
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// skippedAlertInterval is the time after which a skip reason is alerted again, the end of its window being unknown
+const skippedAlertInterval = 24 * time.Hour
+
+var (
+	alertedWindows = map[string]time.Time{}
+	alertedMutex   sync.Mutex
+)
+
+// ReportSkipped logs the skipped workload, and alerts once per skip reason, the other workloads skipped for the
+// same reason being only logged until skippedAlertInterval
+func ReportSkipped(key string, reason string) {
+	klog.Infof("Skipped: %s, reason: %s", key, reason)
+	now := time.Now()
+	if !shouldAlertWindow("skipped:"+reason, now, now.Add(skippedAlertInterval)) {
+		return
+	}
+	if err := sendMattermostAlert(fmt.Sprintf("⏸️ Skipping changes\n\nReason: %s\n\nFirst skipped: %s, the other workloads are only logged", reason, key)); err != nil {
+		klog.Errorf("Error sending Mattermost alert: %s", err.Error())
+	}
+}
+
+// ReportDeferred logs the deferred workload, and alerts once per calendar window, the other workloads deferred until
+// the same time being only logged
+func ReportDeferred(key string, reason string, until time.Time) {
+	untilStr := until.Format(time.RFC3339)
+	klog.Infof("Deferred: %s until %s, reason: %s", key, untilStr, reason)
+	if !shouldAlertWindow("deferred:"+reason+":"+untilStr, time.Now(), until) {
+		return
+	}
+	if err := sendMattermostAlert(fmt.Sprintf("⏳ Deferring changes until %s\n\nReason: %s\n\nFirst deferred: %s, the other workloads are only logged", untilStr, reason, key)); err != nil {
+		klog.Errorf("Error sending Mattermost alert: %s", err.Error())
+	}
+}
+
+// shouldAlertWindow returns whether the window is not alerted yet, recording it as alerted until its expiry
+func shouldAlertWindow(window string, now time.Time, expiry time.Time) bool {
+	alertedMutex.Lock()
+	defer alertedMutex.Unlock()
+	for alerted, alertedUntil := range alertedWindows {
+		if !now.Before(alertedUntil) {
+			delete(alertedWindows, alerted)
+		}
+	}
+	if _, ok := alertedWindows[window]; ok {
+		return false
+	}
+	alertedWindows[window] = expiry
+	return true
+}
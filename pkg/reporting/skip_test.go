@@ -0,0 +1,24 @@
+package reporting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShouldAlertWindow(t *testing.T) {
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	until := now.Add(8 * time.Hour)
+
+	if !shouldAlertWindow("deferred:test", now, until) {
+		t.Fatal("expected the first workload of the window to be alerted")
+	}
+	if shouldAlertWindow("deferred:test", now.Add(time.Hour), until) {
+		t.Error("expected the other workloads of the window to be only logged")
+	}
+	if !shouldAlertWindow("deferred:other", now.Add(time.Hour), until) {
+		t.Error("expected another window to be alerted")
+	}
+	if !shouldAlertWindow("deferred:test", until, until.Add(time.Hour)) {
+		t.Error("expected the window to be alerted again once expired")
+	}
+}
@@ -1,6 +1,12 @@
 package reporting
 
-import "k8s.io/apimachinery/pkg/api/resource"
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
 
 type UpdateType int
 
@@ -9,6 +15,15 @@ const (
 	UpdateTypeMemoryRequest
 	UpdateTypeCpuLimit
 	UpdateTypeMemoryLimit
+	UpdateTypeEphemeralStorageRequest
+	UpdateTypeEphemeralStorageLimit
+	// UpdateTypeEnv is the change of an env var of the container, e.g. the heap size of its runtime
+	UpdateTypeEnv
+	// UpdateTypePodCpuRequest and the following are the changes of the pod-level resources, shared by the containers
+	UpdateTypePodCpuRequest
+	UpdateTypePodMemoryRequest
+	UpdateTypePodCpuLimit
+	UpdateTypePodMemoryLimit
 )
 
 type ResultType int
@@ -17,6 +32,8 @@ const (
 	ResultTypeSuccess ResultType = iota
 	ResultTypeFailed
 	ResultTypeDryRun
+	// ResultTypeSkipped is an update not applied, e.g. to leave the workload to a GitOps tool
+	ResultTypeSkipped
 )
 
 type UpdateResult struct {
@@ -24,6 +41,21 @@ type UpdateResult struct {
 	Type    ResultType
 	Key     string
 	Error   error
+	// OldQoSClass and NewQoSClass are the QoS classes of the pods before and after the update, only set when it changes
+	OldQoSClass corev1.PodQOSClass
+	NewQoSClass corev1.PodQOSClass
+	// GitOpsConflict is set when a GitOps tool also sets the resources of the workload
+	GitOpsConflict *GitOpsConflict
+}
+
+// GitOpsConflict is a GitOps tool managing a workload, with the field managers other than Oblik owning its resources
+type GitOpsConflict struct {
+	Tool     string
+	Managers []string
+}
+
+func (c *GitOpsConflict) String() string {
+	return fmt.Sprintf("resources also set by %s (field managers: %s)", c.Tool, strings.Join(c.Managers, ", "))
 }
 
 type Change struct {
@@ -31,4 +63,12 @@ type Change struct {
 	New           resource.Quantity
 	Type          UpdateType
 	ContainerName string
+	// Reason explains a value held or adjusted by another constraint than the recommendation, e.g. an HPA
+	Reason string
+	// Removed is set when the resource is unset from the container, New being empty
+	Removed bool
+	// Env is the name of the env var of UpdateTypeEnv changes, OldEnv and NewEnv its values
+	Env    string
+	OldEnv string
+	NewEnv string
 }
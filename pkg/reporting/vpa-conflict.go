@@ -0,0 +1,38 @@
+package reporting
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+var (
+	vpaConflicts      = map[string]string{}
+	vpaConflictsMutex sync.Mutex
+)
+
+// ReportVPAConflict reports VPAs created by the users conflicting with Oblik on a workload, once until the conflict changes,
+// as it is checked on each change of the workload
+func ReportVPAConflict(key string, conflict string) {
+	vpaConflictsMutex.Lock()
+	defer vpaConflictsMutex.Unlock()
+	if vpaConflicts[key] == conflict {
+		return
+	}
+	vpaConflicts[key] = conflict
+	klog.Warningf("VPA conflict: %s, %s", key, conflict)
+	if err := sendMattermostAlert(fmt.Sprintf("⚔️ VPA conflict on %s\n\n%s", key, conflict)); err != nil {
+		klog.Errorf("Error sending Mattermost alert: %s", err.Error())
+	}
+}
+
+// ClearVPAConflict forgets the VPA conflict of the workload once resolved
+func ClearVPAConflict(key string) {
+	vpaConflictsMutex.Lock()
+	defer vpaConflictsMutex.Unlock()
+	if _, ok := vpaConflicts[key]; ok {
+		klog.Infof("VPA conflict resolved: %s", key)
+		delete(vpaConflicts, key)
+	}
+}
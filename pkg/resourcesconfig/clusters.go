@@ -0,0 +1,31 @@
+package resourcesconfig
+
+import (
+	oblikv1 "github.com/SocialGouv/oblik/pkg/apis/oblik/v1"
+	"github.com/SocialGouv/oblik/pkg/client"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// TargetsCluster returns whether the ResourcesConfig configures the target of the cluster: the clusters matched by
+// its cluster selector, or the cluster running Oblik without selector
+func TargetsCluster(rc *oblikv1.ResourcesConfig, kubeClients *client.KubeClients) bool {
+	if rc.Spec.ClusterSelector == nil {
+		return kubeClients.IsLocal()
+	}
+	selector, err := metav1.LabelSelectorAsSelector(rc.Spec.ClusterSelector)
+	if err != nil {
+		klog.Errorf("Invalid cluster selector of ResourcesConfig %s/%s: %s", rc.Namespace, rc.Name, err.Error())
+		return false
+	}
+	return selector.Matches(labels.Set(kubeClients.ClusterLabels))
+}
+
+// WithCluster qualifies a status message with the cluster of the target, outside the cluster running Oblik
+func WithCluster(kubeClients *client.KubeClients, message string) string {
+	if kubeClients.IsLocal() {
+		return message
+	}
+	return message + " (cluster " + kubeClients.Cluster + ")"
+}
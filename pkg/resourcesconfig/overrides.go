@@ -0,0 +1,86 @@
+package resourcesconfig
+
+import (
+	"context"
+
+	oblikv1 "github.com/SocialGouv/oblik/pkg/apis/oblik/v1"
+	"github.com/SocialGouv/oblik/pkg/client"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Override is a manual value of a CPU or memory request or limit of a container, the resource being "request-cpu",
+// "request-memory", "limit-cpu" or "limit-memory", and a nil value meaning the resource is not set by Oblik anymore
+type Override struct {
+	ContainerName string
+	Resource      string
+	Value         *string
+}
+
+// AcceptOverrides writes the overrides into the container configs of the ResourcesConfigs targeting the workload, so
+// that their sync keeps them, and returns whether a ResourcesConfig targets the workload
+func AcceptOverrides(ctx context.Context, kubeClients *client.KubeClients, namespace, kind, name string, overrides []Override) (bool, error) {
+	// the ResourcesConfigs are in the cluster running Oblik, whatever the cluster of the workload
+	rcList := &oblikv1.ResourcesConfigList{}
+	err := kubeClients.Local.Reader.List(ctx, rcList, ctrlclient.InNamespace(namespace))
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for _, rc := range rcList.Items {
+		if rc.Spec.TargetRef.Kind != kind || rc.Spec.TargetRef.Name != name || !TargetsCluster(&rc, kubeClients) {
+			continue
+		}
+		found = true
+
+		rcCopy := rc.DeepCopy()
+		if rcCopy.Spec.ContainerConfigs == nil {
+			rcCopy.Spec.ContainerConfigs = map[string]oblikv1.ContainerConfig{}
+		}
+		for _, override := range overrides {
+			containerConfig := rcCopy.Spec.ContainerConfigs[override.ContainerName]
+			setOverride(&containerConfig, override)
+			rcCopy.Spec.ContainerConfigs[override.ContainerName] = containerConfig
+		}
+
+		_, err := kubeClients.Local.ResourcesConfigClientset.OblikV1().Update(ctx, rcCopy.Namespace, rcCopy, metav1.UpdateOptions{})
+		if err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// setOverride sets the overridden value as direct value of the container, clearing the nested one which would take
+// precedence in the sync, or turns the apply mode of the resource off when the value is removed
+func setOverride(containerConfig *oblikv1.ContainerConfig, override Override) {
+	value, applyMode := "", ""
+	if override.Value != nil {
+		value = *override.Value
+	} else {
+		applyMode = "off"
+	}
+	switch override.Resource {
+	case "request-cpu":
+		containerConfig.RequestCpu, containerConfig.RequestCpuApplyMode = value, applyMode
+		if containerConfig.Request != nil {
+			containerConfig.Request.CPU = ""
+		}
+	case "request-memory":
+		containerConfig.RequestMemory, containerConfig.RequestMemoryApplyMode = value, applyMode
+		if containerConfig.Request != nil {
+			containerConfig.Request.Memory = ""
+		}
+	case "limit-cpu":
+		containerConfig.LimitCpu, containerConfig.LimitCpuApplyMode = value, applyMode
+		if containerConfig.Limit != nil {
+			containerConfig.Limit.CPU = ""
+		}
+	case "limit-memory":
+		containerConfig.LimitMemory, containerConfig.LimitMemoryApplyMode = value, applyMode
+		if containerConfig.Limit != nil {
+			containerConfig.Limit.Memory = ""
+		}
+	}
+}
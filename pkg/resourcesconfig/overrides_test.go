@@ -0,0 +1,28 @@
+package resourcesconfig
+
+import (
+	"testing"
+
+	oblikv1 "github.com/SocialGouv/oblik/pkg/apis/oblik/v1"
+)
+
+func TestSetOverride(t *testing.T) {
+	value := "250m"
+	containerConfig := oblikv1.ContainerConfig{
+		LimitCpu: "1",
+		Request:  &oblikv1.ResourceList{CPU: "100m", Memory: "64Mi"},
+	}
+
+	setOverride(&containerConfig, Override{ContainerName: "app", Resource: "request-cpu", Value: &value})
+	if containerConfig.RequestCpu != "250m" || containerConfig.RequestCpuApplyMode != "" {
+		t.Errorf("expected request-cpu override 250m, got %q (apply mode %q)", containerConfig.RequestCpu, containerConfig.RequestCpuApplyMode)
+	}
+	if containerConfig.Request.CPU != "" || containerConfig.Request.Memory != "64Mi" {
+		t.Errorf("expected nested CPU request cleared only, got %+v", containerConfig.Request)
+	}
+
+	setOverride(&containerConfig, Override{ContainerName: "app", Resource: "limit-cpu"})
+	if containerConfig.LimitCpu != "" || containerConfig.LimitCpuApplyMode != "off" {
+		t.Errorf("expected limit-cpu apply mode off, got %q (apply mode %q)", containerConfig.LimitCpu, containerConfig.LimitCpuApplyMode)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/SocialGouv/oblik/pkg/client"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // UpdateStatus updates the status of the ResourcesConfig
@@ -37,6 +38,95 @@ func UpdateStatus(ctx context.Context, kubeClients *client.KubeClients, rc *obli
 	}
 }
 
+// UpdateApplyStatus reports the consecutive apply failures of a workload on the ResourcesConfigs targeting it
+func UpdateApplyStatus(ctx context.Context, kubeClients *client.KubeClients, namespace, kind, name string, failures int, applyErr error) {
+	// the ResourcesConfigs are in the cluster running Oblik, whatever the cluster of the workload
+	rcList := &oblikv1.ResourcesConfigList{}
+	err := kubeClients.Local.Reader.List(ctx, rcList, ctrlclient.InNamespace(namespace))
+	if err != nil {
+		klog.Errorf("Error listing ResourcesConfigs: %s", err.Error())
+		return
+	}
+
+	for _, rc := range rcList.Items {
+		if rc.Spec.TargetRef.Kind != kind || rc.Spec.TargetRef.Name != name || !TargetsCluster(&rc, kubeClients) {
+			continue
+		}
+		if applyErr == nil && rc.Status.ConsecutiveFailures == 0 && hasCondition(&rc, "Applied") {
+			continue
+		}
+
+		rcCopy := rc.DeepCopy()
+		rcCopy.Status.ConsecutiveFailures = int32(failures)
+		if applyErr != nil {
+			rcCopy.Status.LastFailureTime = metav1.NewTime(time.Now())
+			setCondition(rcCopy, "Applied", metav1.ConditionFalse, "ApplyFailed", WithCluster(kubeClients, applyErr.Error()))
+		} else {
+			setCondition(rcCopy, "Applied", metav1.ConditionTrue, "ApplySucceeded", WithCluster(kubeClients, "Successfully applied recommendations to target"))
+		}
+
+		_, err := kubeClients.Local.ResourcesConfigClientset.OblikV1().UpdateStatus(ctx, rcCopy.Namespace, rcCopy, metav1.UpdateOptions{})
+		if err != nil {
+			klog.Errorf("Error updating ResourcesConfig status: %s", err.Error())
+		}
+	}
+}
+
+// UpdateGitOpsStatus reports a conflict with a GitOps tool setting the resources of a workload on the ResourcesConfigs
+// targeting it, the reason being the handling of the conflict, or its absence when the reason is empty
+func UpdateGitOpsStatus(ctx context.Context, kubeClients *client.KubeClients, namespace, kind, name, reason, message string) {
+	// the ResourcesConfigs are in the cluster running Oblik, whatever the cluster of the workload
+	rcList := &oblikv1.ResourcesConfigList{}
+	err := kubeClients.Local.Reader.List(ctx, rcList, ctrlclient.InNamespace(namespace))
+	if err != nil {
+		klog.Errorf("Error listing ResourcesConfigs: %s", err.Error())
+		return
+	}
+
+	for _, rc := range rcList.Items {
+		if rc.Spec.TargetRef.Kind != kind || rc.Spec.TargetRef.Name != name || !TargetsCluster(&rc, kubeClients) {
+			continue
+		}
+		current := getCondition(&rc, "GitOpsConflict")
+		if reason == "" && (current == nil || current.Status != metav1.ConditionTrue) {
+			continue
+		}
+		if reason != "" && current != nil && current.Reason == reason && current.Message == WithCluster(kubeClients, message) {
+			continue
+		}
+
+		rcCopy := rc.DeepCopy()
+		if reason != "" {
+			setCondition(rcCopy, "GitOpsConflict", metav1.ConditionTrue, reason, WithCluster(kubeClients, message))
+		} else {
+			setCondition(rcCopy, "GitOpsConflict", metav1.ConditionFalse, "NoConflict", "No GitOps tool sets the resources of the target")
+		}
+
+		_, err := kubeClients.Local.ResourcesConfigClientset.OblikV1().UpdateStatus(ctx, rcCopy.Namespace, rcCopy, metav1.UpdateOptions{})
+		if err != nil {
+			klog.Errorf("Error updating ResourcesConfig status: %s", err.Error())
+		}
+	}
+}
+
+func getCondition(rc *oblikv1.ResourcesConfig, conditionType string) *metav1.Condition {
+	for i := range rc.Status.Conditions {
+		if rc.Status.Conditions[i].Type == conditionType {
+			return &rc.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func hasCondition(rc *oblikv1.ResourcesConfig, conditionType string) bool {
+	for _, condition := range rc.Status.Conditions {
+		if condition.Type == conditionType {
+			return true
+		}
+	}
+	return false
+}
+
 // setCondition sets a condition on the ResourcesConfig
 func setCondition(rc *oblikv1.ResourcesConfig, conditionType string, status metav1.ConditionStatus, reason, message string) {
 	now := metav1.NewTime(time.Now())
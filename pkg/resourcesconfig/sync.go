@@ -180,9 +180,15 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 	if rc.Spec.Cron != "" {
 		annotations[constants.PREFIX+"cron"] = rc.Spec.Cron
 	}
+	if rc.Spec.CronTimezone != "" {
+		annotations[constants.PREFIX+"cron-timezone"] = rc.Spec.CronTimezone
+	}
 	if rc.Spec.CronAddRandomMax != "" {
 		annotations[constants.PREFIX+"cron-add-random-max"] = rc.Spec.CronAddRandomMax
 	}
+	if rc.Spec.CronCatchUpDeadline != "" {
+		annotations[constants.PREFIX+"cron-catch-up-deadline"] = rc.Spec.CronCatchUpDeadline
+	}
 	if rc.Spec.DryRun {
 		annotations[constants.PREFIX+"dry-run"] = "true"
 	}
@@ -213,6 +219,18 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 	if rc.Spec.LimitMemoryCalculatorValue != "" {
 		annotations[constants.PREFIX+"limit-memory-calculator-value"] = rc.Spec.LimitMemoryCalculatorValue
 	}
+	if rc.Spec.CpuRoundingAlgo != "" {
+		annotations[constants.PREFIX+"cpu-rounding-algo"] = rc.Spec.CpuRoundingAlgo
+	}
+	if rc.Spec.CpuRoundingValue != "" {
+		annotations[constants.PREFIX+"cpu-rounding-value"] = rc.Spec.CpuRoundingValue
+	}
+	if rc.Spec.MemoryRoundingAlgo != "" {
+		annotations[constants.PREFIX+"memory-rounding-algo"] = rc.Spec.MemoryRoundingAlgo
+	}
+	if rc.Spec.MemoryRoundingValue != "" {
+		annotations[constants.PREFIX+"memory-rounding-value"] = rc.Spec.MemoryRoundingValue
+	}
 	if rc.Spec.UnprovidedApplyDefaultRequestCpu != "" {
 		annotations[constants.PREFIX+"unprovided-apply-default-request-cpu"] = rc.Spec.UnprovidedApplyDefaultRequestCpu
 	}
@@ -309,6 +327,60 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 	if rc.Spec.MemoryLimitFromCpuValue != "" {
 		annotations[constants.PREFIX+"memory-limit-from-cpu-value"] = rc.Spec.MemoryLimitFromCpuValue
 	}
+	if rc.Spec.MemoryFromCpuRatio != "" {
+		annotations[constants.PREFIX+"memory-from-cpu-ratio"] = rc.Spec.MemoryFromCpuRatio
+	}
+	if rc.Spec.MemoryFromCpuSource != "" {
+		annotations[constants.PREFIX+"memory-from-cpu-source"] = rc.Spec.MemoryFromCpuSource
+	}
+	if rc.Spec.RuntimeProfile != "" {
+		annotations[constants.PREFIX+"runtime-profile"] = rc.Spec.RuntimeProfile
+	}
+	if rc.Spec.RuntimeHeapRatio != "" {
+		annotations[constants.PREFIX+"runtime-heap-ratio"] = rc.Spec.RuntimeHeapRatio
+	}
+	if rc.Spec.RuntimeCpuRatio != "" {
+		annotations[constants.PREFIX+"runtime-cpu-ratio"] = rc.Spec.RuntimeCpuRatio
+	}
+	if rc.Spec.JVMOptionsEnv != "" {
+		annotations[constants.PREFIX+"jvm-options-env"] = rc.Spec.JVMOptionsEnv
+	}
+	if rc.Spec.JVMHeapFlag != "" {
+		annotations[constants.PREFIX+"jvm-heap-flag"] = rc.Spec.JVMHeapFlag
+	}
+	if rc.Spec.QoSClass != "" {
+		annotations[constants.PREFIX+"qos-class"] = rc.Spec.QoSClass
+	}
+	if rc.Spec.QoSBurstableMargin != "" {
+		annotations[constants.PREFIX+"qos-burstable-margin"] = rc.Spec.QoSBurstableMargin
+	}
+	if rc.Spec.EphemeralStorageApplyMode != "" {
+		annotations[constants.PREFIX+"ephemeral-storage-apply-mode"] = rc.Spec.EphemeralStorageApplyMode
+	}
+	if rc.Spec.RequestEphemeralStorage != "" {
+		annotations[constants.PREFIX+"request-ephemeral-storage"] = rc.Spec.RequestEphemeralStorage
+	}
+	if rc.Spec.LimitEphemeralStorage != "" {
+		annotations[constants.PREFIX+"limit-ephemeral-storage"] = rc.Spec.LimitEphemeralStorage
+	}
+	if rc.Spec.MinRequestEphemeralStorage != "" {
+		annotations[constants.PREFIX+"min-request-ephemeral-storage"] = rc.Spec.MinRequestEphemeralStorage
+	}
+	if rc.Spec.MaxRequestEphemeralStorage != "" {
+		annotations[constants.PREFIX+"max-request-ephemeral-storage"] = rc.Spec.MaxRequestEphemeralStorage
+	}
+	if rc.Spec.MinLimitEphemeralStorage != "" {
+		annotations[constants.PREFIX+"min-limit-ephemeral-storage"] = rc.Spec.MinLimitEphemeralStorage
+	}
+	if rc.Spec.MaxLimitEphemeralStorage != "" {
+		annotations[constants.PREFIX+"max-limit-ephemeral-storage"] = rc.Spec.MaxLimitEphemeralStorage
+	}
+	if rc.Spec.LimitEphemeralStorageRatio != "" {
+		annotations[constants.PREFIX+"limit-ephemeral-storage-ratio"] = rc.Spec.LimitEphemeralStorageRatio
+	}
+	if rc.Spec.EphemeralStorageHeadroom != "" {
+		annotations[constants.PREFIX+"ephemeral-storage-headroom"] = rc.Spec.EphemeralStorageHeadroom
+	}
 	if rc.Spec.RequestApplyTarget != "" {
 		annotations[constants.PREFIX+"request-apply-target"] = rc.Spec.RequestApplyTarget
 	}
@@ -339,6 +411,66 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 	if rc.Spec.LimitMemoryScaleDirection != "" {
 		annotations[constants.PREFIX+"limit-memory-scale-direction"] = rc.Spec.LimitMemoryScaleDirection
 	}
+	if rc.Spec.ReplicasPeakMax != "" {
+		annotations[constants.PREFIX+"replicas-peak-max"] = rc.Spec.ReplicasPeakMax
+	}
+	if rc.Spec.ReplicasFrugalMin != "" {
+		annotations[constants.PREFIX+"replicas-frugal-min"] = rc.Spec.ReplicasFrugalMin
+	}
+	if rc.Spec.MaxTotalCpu != "" {
+		annotations[constants.PREFIX+"max-total-cpu"] = rc.Spec.MaxTotalCpu
+	}
+	if rc.Spec.MaxTotalMemory != "" {
+		annotations[constants.PREFIX+"max-total-memory"] = rc.Spec.MaxTotalMemory
+	}
+	if rc.Spec.HPAMode != "" {
+		annotations[constants.PREFIX+"hpa-mode"] = rc.Spec.HPAMode
+	}
+	if rc.Spec.HPABand != "" {
+		annotations[constants.PREFIX+"hpa-band"] = rc.Spec.HPABand
+	}
+	if rc.Spec.PodResourcesMode != "" {
+		annotations[constants.PREFIX+"pod-resources-mode"] = rc.Spec.PodResourcesMode
+	}
+	if rc.Spec.PodResourcesHeadroom != "" {
+		annotations[constants.PREFIX+"pod-resources-headroom"] = rc.Spec.PodResourcesHeadroom
+	}
+	if rc.Spec.PodResourcesContainerMode != "" {
+		annotations[constants.PREFIX+"pod-resources-container-mode"] = rc.Spec.PodResourcesContainerMode
+	}
+	if rc.Spec.DriftPolicy != "" {
+		annotations[constants.PREFIX+"drift-policy"] = rc.Spec.DriftPolicy
+	}
+	if rc.Spec.GitOpsPolicy != "" {
+		annotations[constants.PREFIX+"gitops-policy"] = rc.Spec.GitOpsPolicy
+	}
+	if rc.Spec.VPAMinAllowedCpu != "" {
+		annotations[constants.PREFIX+"vpa-min-allowed-cpu"] = rc.Spec.VPAMinAllowedCpu
+	}
+	if rc.Spec.VPAMaxAllowedCpu != "" {
+		annotations[constants.PREFIX+"vpa-max-allowed-cpu"] = rc.Spec.VPAMaxAllowedCpu
+	}
+	if rc.Spec.VPAMinAllowedMemory != "" {
+		annotations[constants.PREFIX+"vpa-min-allowed-memory"] = rc.Spec.VPAMinAllowedMemory
+	}
+	if rc.Spec.VPAMaxAllowedMemory != "" {
+		annotations[constants.PREFIX+"vpa-max-allowed-memory"] = rc.Spec.VPAMaxAllowedMemory
+	}
+	if rc.Spec.VPAControlledResources != "" {
+		annotations[constants.PREFIX+"vpa-controlled-resources"] = rc.Spec.VPAControlledResources
+	}
+	if rc.Spec.VPAControlledValues != "" {
+		annotations[constants.PREFIX+"vpa-controlled-values"] = rc.Spec.VPAControlledValues
+	}
+	if rc.Spec.VPAMode != "" {
+		annotations[constants.PREFIX+"vpa-mode"] = rc.Spec.VPAMode
+	}
+	if rc.Spec.VPARecommenders != "" {
+		annotations[constants.PREFIX+"vpa-recommenders"] = rc.Spec.VPARecommenders
+	}
+	if rc.Spec.VPAAdoption != "" {
+		annotations[constants.PREFIX+"vpa-adoption"] = rc.Spec.VPAAdoption
+	}
 
 	// Add direct resource specifications (flat style)
 	if rc.Spec.RequestCpu != "" {
@@ -362,6 +494,9 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 		if rc.Spec.Request.Memory != "" {
 			annotations[constants.PREFIX+"request-memory"] = rc.Spec.Request.Memory
 		}
+		if rc.Spec.Request.EphemeralStorage != "" {
+			annotations[constants.PREFIX+"request-ephemeral-storage"] = rc.Spec.Request.EphemeralStorage
+		}
 	}
 	if rc.Spec.Limit != nil {
 		if rc.Spec.Limit.CPU != "" {
@@ -370,6 +505,9 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 		if rc.Spec.Limit.Memory != "" {
 			annotations[constants.PREFIX+"limit-memory"] = rc.Spec.Limit.Memory
 		}
+		if rc.Spec.Limit.EphemeralStorage != "" {
+			annotations[constants.PREFIX+"limit-ephemeral-storage"] = rc.Spec.Limit.EphemeralStorage
+		}
 	}
 
 	// Handle container-specific configurations
@@ -388,6 +526,18 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 			if containerConfig.LimitMemory != "" {
 				annotations[constants.PREFIX+"limit-memory."+containerName] = containerConfig.LimitMemory
 			}
+			if containerConfig.RequestCpuApplyMode != "" {
+				annotations[constants.PREFIX+"request-cpu-apply-mode."+containerName] = containerConfig.RequestCpuApplyMode
+			}
+			if containerConfig.RequestMemoryApplyMode != "" {
+				annotations[constants.PREFIX+"request-memory-apply-mode."+containerName] = containerConfig.RequestMemoryApplyMode
+			}
+			if containerConfig.LimitCpuApplyMode != "" {
+				annotations[constants.PREFIX+"limit-cpu-apply-mode."+containerName] = containerConfig.LimitCpuApplyMode
+			}
+			if containerConfig.LimitMemoryApplyMode != "" {
+				annotations[constants.PREFIX+"limit-memory-apply-mode."+containerName] = containerConfig.LimitMemoryApplyMode
+			}
 			
 			// Kubernetes-native style resource specifications (nested)
 			if containerConfig.Request != nil {
@@ -397,6 +547,9 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 				if containerConfig.Request.Memory != "" {
 					annotations[constants.PREFIX+"request-memory."+containerName] = containerConfig.Request.Memory
 				}
+				if containerConfig.Request.EphemeralStorage != "" {
+					annotations[constants.PREFIX+"request-ephemeral-storage."+containerName] = containerConfig.Request.EphemeralStorage
+				}
 			}
 			if containerConfig.Limit != nil {
 				if containerConfig.Limit.CPU != "" {
@@ -405,8 +558,21 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 				if containerConfig.Limit.Memory != "" {
 					annotations[constants.PREFIX+"limit-memory."+containerName] = containerConfig.Limit.Memory
 				}
+				if containerConfig.Limit.EphemeralStorage != "" {
+					annotations[constants.PREFIX+"limit-ephemeral-storage."+containerName] = containerConfig.Limit.EphemeralStorage
+				}
 			}
 			
+			if containerConfig.RequestApplyTarget != "" {
+				annotations[constants.PREFIX+"request-apply-target."+containerName] = containerConfig.RequestApplyTarget
+			}
+			if containerConfig.RequestCpuApplyTarget != "" {
+				annotations[constants.PREFIX+"request-cpu-apply-target."+containerName] = containerConfig.RequestCpuApplyTarget
+			}
+			if containerConfig.RequestMemoryApplyTarget != "" {
+				annotations[constants.PREFIX+"request-memory-apply-target."+containerName] = containerConfig.RequestMemoryApplyTarget
+			}
+
 			// Original container-specific configurations
 			if containerConfig.MinLimitCpu != "" {
 				annotations[constants.PREFIX+"min-limit-cpu."+containerName] = containerConfig.MinLimitCpu
@@ -444,6 +610,81 @@ func addAnnotationsFromResourcesConfig(annotations map[string]string, rc *oblikv
 			if containerConfig.MaxAllowedRecommendationMemory != "" {
 				annotations[constants.PREFIX+"max-allowed-recommendation-memory."+containerName] = containerConfig.MaxAllowedRecommendationMemory
 			}
+			if containerConfig.MemoryFromCpuRatio != "" {
+				annotations[constants.PREFIX+"memory-from-cpu-ratio."+containerName] = containerConfig.MemoryFromCpuRatio
+			}
+			if containerConfig.MemoryFromCpuSource != "" {
+				annotations[constants.PREFIX+"memory-from-cpu-source."+containerName] = containerConfig.MemoryFromCpuSource
+			}
+			if containerConfig.RuntimeProfile != "" {
+				annotations[constants.PREFIX+"runtime-profile."+containerName] = containerConfig.RuntimeProfile
+			}
+			if containerConfig.RuntimeHeapRatio != "" {
+				annotations[constants.PREFIX+"runtime-heap-ratio."+containerName] = containerConfig.RuntimeHeapRatio
+			}
+			if containerConfig.RuntimeCpuRatio != "" {
+				annotations[constants.PREFIX+"runtime-cpu-ratio."+containerName] = containerConfig.RuntimeCpuRatio
+			}
+			if containerConfig.JVMOptionsEnv != "" {
+				annotations[constants.PREFIX+"jvm-options-env."+containerName] = containerConfig.JVMOptionsEnv
+			}
+			if containerConfig.JVMHeapFlag != "" {
+				annotations[constants.PREFIX+"jvm-heap-flag."+containerName] = containerConfig.JVMHeapFlag
+			}
+			if containerConfig.QoSClass != "" {
+				annotations[constants.PREFIX+"qos-class."+containerName] = containerConfig.QoSClass
+			}
+			if containerConfig.QoSBurstableMargin != "" {
+				annotations[constants.PREFIX+"qos-burstable-margin."+containerName] = containerConfig.QoSBurstableMargin
+			}
+			if containerConfig.EphemeralStorageApplyMode != "" {
+				annotations[constants.PREFIX+"ephemeral-storage-apply-mode."+containerName] = containerConfig.EphemeralStorageApplyMode
+			}
+			if containerConfig.RequestEphemeralStorage != "" {
+				annotations[constants.PREFIX+"request-ephemeral-storage."+containerName] = containerConfig.RequestEphemeralStorage
+			}
+			if containerConfig.LimitEphemeralStorage != "" {
+				annotations[constants.PREFIX+"limit-ephemeral-storage."+containerName] = containerConfig.LimitEphemeralStorage
+			}
+			if containerConfig.MinRequestEphemeralStorage != "" {
+				annotations[constants.PREFIX+"min-request-ephemeral-storage."+containerName] = containerConfig.MinRequestEphemeralStorage
+			}
+			if containerConfig.MaxRequestEphemeralStorage != "" {
+				annotations[constants.PREFIX+"max-request-ephemeral-storage."+containerName] = containerConfig.MaxRequestEphemeralStorage
+			}
+			if containerConfig.MinLimitEphemeralStorage != "" {
+				annotations[constants.PREFIX+"min-limit-ephemeral-storage."+containerName] = containerConfig.MinLimitEphemeralStorage
+			}
+			if containerConfig.MaxLimitEphemeralStorage != "" {
+				annotations[constants.PREFIX+"max-limit-ephemeral-storage."+containerName] = containerConfig.MaxLimitEphemeralStorage
+			}
+			if containerConfig.LimitEphemeralStorageRatio != "" {
+				annotations[constants.PREFIX+"limit-ephemeral-storage-ratio."+containerName] = containerConfig.LimitEphemeralStorageRatio
+			}
+			if containerConfig.EphemeralStorageHeadroom != "" {
+				annotations[constants.PREFIX+"ephemeral-storage-headroom."+containerName] = containerConfig.EphemeralStorageHeadroom
+			}
+			if containerConfig.VPAMinAllowedCpu != "" {
+				annotations[constants.PREFIX+"vpa-min-allowed-cpu."+containerName] = containerConfig.VPAMinAllowedCpu
+			}
+			if containerConfig.VPAMaxAllowedCpu != "" {
+				annotations[constants.PREFIX+"vpa-max-allowed-cpu."+containerName] = containerConfig.VPAMaxAllowedCpu
+			}
+			if containerConfig.VPAMinAllowedMemory != "" {
+				annotations[constants.PREFIX+"vpa-min-allowed-memory."+containerName] = containerConfig.VPAMinAllowedMemory
+			}
+			if containerConfig.VPAMaxAllowedMemory != "" {
+				annotations[constants.PREFIX+"vpa-max-allowed-memory."+containerName] = containerConfig.VPAMaxAllowedMemory
+			}
+			if containerConfig.VPAControlledResources != "" {
+				annotations[constants.PREFIX+"vpa-controlled-resources."+containerName] = containerConfig.VPAControlledResources
+			}
+			if containerConfig.VPAControlledValues != "" {
+				annotations[constants.PREFIX+"vpa-controlled-values."+containerName] = containerConfig.VPAControlledValues
+			}
+			if containerConfig.VPAMode != "" {
+				annotations[constants.PREFIX+"vpa-mode."+containerName] = containerConfig.VPAMode
+			}
 		}
 	}
 }
@@ -1,17 +1,14 @@
 package server
 
 import (
-	"context"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"strings"
 
 	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/utils"
+	ovpa "github.com/SocialGouv/oblik/pkg/vpa"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	autoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 	"k8s.io/klog/v2"
@@ -90,25 +87,15 @@ func getVPAResource(obj *unstructured.Unstructured, kubeClients *client.KubeClie
 	kind := obj.GetKind()
 	name := obj.GetName()
 
-	// Generate the VPA name using the same convention as in pkg/vpa/operations.go
-	// Using the same prefix as defined in pkg/config/constants.go: const VpaPrefix = "oblik-"
-	vpaName := fmt.Sprintf("oblik-%s-%s", strings.ToLower(kind), name)
-	if len(vpaName) > 63 {
-		hash := sha256.Sum256([]byte(vpaName))
-		truncatedHash := fmt.Sprintf("%x", hash)[:8]
-		vpaName = vpaName[:54] + "-" + truncatedHash
-	}
-
-	klog.V(2).Infof("Looking for VPA with name %s in namespace %s", vpaName, namespace)
-	vpa, err := kubeClients.VpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Get(context.TODO(), vpaName, metav1.GetOptions{})
+	vpa, err := ovpa.FindVPA(kubeClients, namespace, kind, name)
 	if err != nil {
 		if errors.IsNotFound(err) {
-			klog.Infof("No VPA resource found for %s/%s: %v", namespace, vpaName, err)
+			klog.Infof("No VPA resource found for %s/%s/%s: %v", namespace, kind, name, err)
 		} else {
-			klog.Errorf("Error retrieving VPA for %s/%s: %v", namespace, vpaName, err)
+			klog.Errorf("Error retrieving VPA for %s/%s/%s: %v", namespace, kind, name, err)
 		}
 		return nil
 	}
-	klog.V(2).Infof("Found VPA %s in namespace %s", vpaName, namespace)
+	klog.V(2).Infof("Found VPA %s in namespace %s", vpa.Name, namespace)
 	return vpa
 }
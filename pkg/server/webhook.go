@@ -1,15 +1,19 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/SocialGouv/oblik/pkg/calendar"
 	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
 	"github.com/SocialGouv/oblik/pkg/logical"
+	"github.com/SocialGouv/oblik/pkg/target"
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	admissionv1 "k8s.io/api/admission/v1"
 	appsv1 "k8s.io/api/apps/v1"
@@ -100,6 +104,9 @@ func MutateExec(writer http.ResponseWriter, request *http.Request, admissionRevi
 
 	var configurable *config.Configurable
 	var containers []corev1.Container
+	var replicas *int32
+	// the pod-level resources are not part of the typed objects and would be lost by their conversion
+	podResources := target.GetPodResources(obj.Object, obj.GetKind())
 	// Determine the kind of the object and convert it to the respective type
 	switch obj.GetKind() {
 	case "Deployment":
@@ -110,6 +117,7 @@ func MutateExec(writer http.ResponseWriter, request *http.Request, admissionRevi
 		klog.V(2).Infof("Processing Deployment: %s, Replicas=%d", deployment.Name, *deployment.Spec.Replicas)
 		configurable = config.CreateConfigurable(deployment)
 		containers = deployment.Spec.Template.Spec.Containers
+		replicas = deployment.Spec.Replicas
 	case "StatefulSet":
 		statefulSet := &appsv1.StatefulSet{}
 		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, statefulSet); err != nil {
@@ -118,6 +126,7 @@ func MutateExec(writer http.ResponseWriter, request *http.Request, admissionRevi
 		klog.V(2).Infof("Processing StatefulSet: %s, Replicas=%d", statefulSet.Name, *statefulSet.Spec.Replicas)
 		configurable = config.CreateConfigurable(statefulSet)
 		containers = statefulSet.Spec.Template.Spec.Containers
+		replicas = statefulSet.Spec.Replicas
 	case "DaemonSet":
 		daemonSet := &appsv1.DaemonSet{}
 		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, daemonSet); err != nil {
@@ -145,6 +154,8 @@ func MutateExec(writer http.ResponseWriter, request *http.Request, admissionRevi
 		}
 		klog.V(2).Infof("Processing CNPG Cluster: %s", cnpgCluster.Name)
 		configurable = config.CreateConfigurable(cnpgCluster)
+		instances := int32(cnpgCluster.Spec.Instances)
+		replicas = &instances
 		containers = []corev1.Container{
 			{
 				Name:      "postgres",
@@ -156,8 +167,25 @@ func MutateExec(writer http.ResponseWriter, request *http.Request, admissionRevi
 	}
 
 	scfg := config.CreateStrategyConfig(configurable)
-	if !scfg.WebhookEnabled || !scfg.Enabled {
-		klog.V(2).Infof("Skipping mutation: WebhookEnabled=%v, Enabled=%v", scfg.WebhookEnabled, scfg.Enabled)
+	enabled := target.IsWorkloadEnabled(context.TODO(), kubeClients, obj)
+	if !scfg.WebhookEnabled || !enabled {
+		klog.V(2).Infof("Skipping mutation: WebhookEnabled=%v, Enabled=%v", scfg.WebhookEnabled, enabled)
+		allowRequest(writer, admissionReview.Request.UID)
+		return nil
+	}
+
+	cal := calendar.GetNamespaceCalendar(context.TODO(), kubeClients.Reader, admissionReview.Request.Namespace, scfg.GetLocation())
+	if cal.WebhookEnabled {
+		if allowed, reason := cal.Check(time.Now()); !allowed {
+			klog.V(2).Infof("Skipping mutation: %s", reason)
+			allowRequest(writer, admissionReview.Request.UID)
+			return nil
+		}
+	}
+
+	gitOpsConflict := target.DetectGitOpsConflict(obj, scfg)
+	if gitOpsConflict != nil && scfg.GitOpsPolicy == config.GitOpsPolicySkip {
+		klog.V(2).Infof("Skipping mutation: GitOps conflict: %s", gitOpsConflict.String())
 		allowRequest(writer, admissionReview.Request.UID)
 		return nil
 	}
@@ -165,9 +193,15 @@ func MutateExec(writer http.ResponseWriter, request *http.Request, admissionRevi
 	vpaResource := getVPAResource(obj, kubeClients)
 	klog.V(2).Infof("VPA resource found: %v", vpaResource != nil)
 
+	// usage metrics are only fetched for scheduled applies, to keep admission fast
+	workload := target.GetWorkload(kubeClients, admissionRequest.Namespace, obj.GetKind(), obj.GetName(), replicas, nil, scfg)
+	if scfg.IsPodResourcesEnabled() {
+		workload.PodResources = podResources
+	}
+
 	var requestRecommendations, limitRecommendations []logical.TargetRecommendation
 	if vpaResource != nil {
-		requestRecommendations = logical.GetRequestTargetRecommendations(vpaResource, scfg)
+		requestRecommendations = logical.GetRequestTargetRecommendations(vpaResource, scfg, workload)
 		limitRecommendations = logical.GetLimitTargetRecommendations(vpaResource, scfg)
 		klog.V(2).Infof("Got recommendations - Requests: %d, Limits: %d",
 			len(requestRecommendations),
@@ -183,7 +217,7 @@ func MutateExec(writer http.ResponseWriter, request *http.Request, admissionRevi
 		len(requestRecommendations),
 		len(limitRecommendations))
 
-	logical.ApplyRecommendationsToContainers(containers, requestRecommendations, limitRecommendations, scfg)
+	logical.ApplyRecommendationsToContainers(containers, requestRecommendations, limitRecommendations, scfg, workload)
 	klog.V(2).Info("Applied recommendations to containers")
 
 	switch obj.GetKind() {
@@ -229,6 +263,19 @@ func MutateExec(writer http.ResponseWriter, request *http.Request, admissionRevi
 		obj.Object = updated
 	}
 
+	if obj.GetKind() != "Cluster" {
+		target.SetAppliedResources(obj, containers)
+	}
+	if gitOpsConflict != nil && scfg.GitOpsPolicy == config.GitOpsPolicyAnnotate && gitOpsConflict.Tool == "argocd" {
+		target.SetGitOpsIgnoreDifferences(obj, obj.GetAPIVersion(), obj.GetKind())
+	}
+	if scfg.IsPodResourcesEnabled() {
+		podResources = workload.PodResources
+	}
+	if err := target.SetPodResources(obj.Object, obj.GetKind(), podResources); err != nil {
+		return fmt.Errorf("Could not set pod-level resources: %v", err)
+	}
+
 	// Create a JSON patch
 	patch, err := createJSONPatch(admissionRequest.Object.Raw, obj)
 	if err != nil {
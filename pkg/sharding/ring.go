@@ -0,0 +1,77 @@
+package sharding
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// virtualNodes is the number of points of each replica on the ring, spreading the workloads evenly
+const virtualNodes = 100
+
+// Ring is a consistent hashing ring of the replicas, a replica joining or leaving only moves its share of the
+// workloads
+type Ring struct {
+	members []string
+	points  []uint64
+	owners  map[uint64]string
+}
+
+// NewRing builds the ring of the replicas
+func NewRing(members []string) *Ring {
+	ring := &Ring{
+		members: append([]string{}, members...),
+		owners:  make(map[uint64]string, len(members)*virtualNodes),
+	}
+	sort.Strings(ring.members)
+	for _, member := range ring.members {
+		for i := 0; i < virtualNodes; i++ {
+			point := hash(fmt.Sprintf("%s#%d", member, i))
+			ring.owners[point] = member
+			ring.points = append(ring.points, point)
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+// Owner returns the replica owning the key, none when the ring is empty
+func (r *Ring) Owner(key string) string {
+	if r == nil || len(r.points) == 0 {
+		return ""
+	}
+	point := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= point })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// Members returns the sorted replicas of the ring
+func (r *Ring) Members() []string {
+	if r == nil {
+		return nil
+	}
+	return r.members
+}
+
+// Equal returns whether the rings have the same replicas
+func (r *Ring) Equal(other *Ring) bool {
+	members, otherMembers := r.Members(), other.Members()
+	if len(members) != len(otherMembers) {
+		return false
+	}
+	for i := range members {
+		if members[i] != otherMembers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func hash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,107 @@
+package sharding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func getKeys(count int) []string {
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("namespace-%d/workload-%d", i%50, i)
+	}
+	return keys
+}
+
+func TestRingDistribution(t *testing.T) {
+	keys := getKeys(10000)
+	tests := []struct {
+		name    string
+		members []string
+	}{
+		{name: "one replica", members: []string{"oblik-0"}},
+		{name: "two replicas", members: []string{"oblik-0", "oblik-1"}},
+		{name: "three replicas", members: []string{"oblik-0", "oblik-1", "oblik-2"}},
+		{name: "five replicas", members: []string{"oblik-0", "oblik-1", "oblik-2", "oblik-3", "oblik-4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := NewRing(tt.members)
+			counts := map[string]int{}
+			for _, key := range keys {
+				counts[ring.Owner(key)]++
+			}
+			if len(counts) != len(tt.members) {
+				t.Fatalf("expected %d owners, got %v", len(tt.members), counts)
+			}
+			// each replica owns its share of the keys, within a margin of the virtual nodes spread
+			share := len(keys) / len(tt.members)
+			for _, member := range tt.members {
+				if counts[member] < share*6/10 || counts[member] > share*14/10 {
+					t.Errorf("replica %s owns %d keys, expected about %d", member, counts[member], share)
+				}
+			}
+		})
+	}
+}
+
+func TestRingOwnerIsStable(t *testing.T) {
+	// the owners don't depend on the order of the members
+	ring := NewRing([]string{"oblik-2", "oblik-0", "oblik-1"})
+	other := NewRing([]string{"oblik-0", "oblik-1", "oblik-2"})
+	if !ring.Equal(other) {
+		t.Fatalf("expected equal rings, got %v and %v", ring.Members(), other.Members())
+	}
+	for _, key := range getKeys(1000) {
+		if ring.Owner(key) != other.Owner(key) {
+			t.Fatalf("key %s: owner %s and %s", key, ring.Owner(key), other.Owner(key))
+		}
+	}
+}
+
+func TestRingEmpty(t *testing.T) {
+	var nilRing *Ring
+	for _, ring := range []*Ring{nilRing, NewRing(nil)} {
+		if owner := ring.Owner("default/app"); owner != "" {
+			t.Errorf("expected no owner, got %s", owner)
+		}
+	}
+	if !NewRing(nil).Equal(nilRing) {
+		t.Errorf("expected an empty ring equal to a nil ring")
+	}
+}
+
+func TestRingMembersChange(t *testing.T) {
+	keys := getKeys(10000)
+	tests := []struct {
+		name    string
+		before  []string
+		after   []string
+		changed string
+	}{
+		{name: "replica leaving", before: []string{"oblik-0", "oblik-1", "oblik-2"}, after: []string{"oblik-0", "oblik-2"}, changed: "oblik-1"},
+		{name: "last replica leaving", before: []string{"oblik-0", "oblik-1", "oblik-2"}, after: []string{"oblik-0", "oblik-1"}, changed: "oblik-2"},
+		{name: "replica joining", before: []string{"oblik-0", "oblik-1"}, after: []string{"oblik-0", "oblik-1", "oblik-2"}, changed: "oblik-2"},
+		{name: "second replica joining", before: []string{"oblik-0"}, after: []string{"oblik-0", "oblik-1"}, changed: "oblik-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := NewRing(tt.before), NewRing(tt.after)
+			moved := 0
+			for _, key := range keys {
+				previous, current := before.Owner(key), after.Owner(key)
+				if previous == current {
+					continue
+				}
+				// only the keys of the changed replica move, from it when it leaves, to it when it joins
+				if previous != tt.changed && current != tt.changed {
+					t.Fatalf("key %s moved from %s to %s, expected only the keys of %s to move", key, previous, current, tt.changed)
+				}
+				moved++
+			}
+			if moved == 0 {
+				t.Errorf("expected the keys of %s to move", tt.changed)
+			}
+		})
+	}
+}
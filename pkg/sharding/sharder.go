@@ -0,0 +1,245 @@
+package sharding
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/constants"
+	"github.com/SocialGouv/oblik/pkg/utils"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	leasePrefix = "oblik-shard-"
+	shardLabel  = constants.PREFIX + "shard"
+)
+
+// Sharder splits the workloads between the replicas of the operator. Each replica renews its own Lease, the live
+// Leases making the members of a consistent hashing ring, which is rebuilt when replicas come and go.
+// The rings are refreshed every third of the Lease duration with no fencing: during a rebalance, a workload may be
+// owned by two replicas until both refreshed their ring, so the applies re-check the ownership and the Lease.
+// A nil Sharder owns all the workloads, when the sharding is disabled
+type Sharder struct {
+	Identity      string
+	Namespace     string
+	LeaseDuration time.Duration
+	Clientset     kubernetes.Interface
+
+	mutex      sync.RWMutex
+	ring       *Ring
+	lastRenew  time.Time
+	rebalances []func(previous, current *Ring)
+}
+
+// IsEnabled returns whether the workloads are sharded between the replicas
+func IsEnabled() bool {
+	return utils.GetEnv("OBLIK_SHARDING_ENABLED", "false") == "true"
+}
+
+func New(clientset kubernetes.Interface) *Sharder {
+	identity, err := os.Hostname()
+	if err != nil || identity == "" {
+		klog.Fatalf("Unable to get the identity of the replica: %v", err)
+	}
+	leaseDuration := utils.ParseDuration(utils.GetEnv("OBLIK_SHARD_LEASE_DURATION", "15s"), 15*time.Second)
+	return &Sharder{
+		Identity:      identity,
+		Namespace:     utils.GetEnv("NAMESPACE", "default"),
+		LeaseDuration: leaseDuration,
+		Clientset:     clientset,
+	}
+}
+
+// Start renews the Lease of the replica and refreshes the ring until the context is done, then deletes the Lease
+// so that the other replicas take over its workloads without waiting for its expiry
+func (s *Sharder) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		s.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			s.deleteLease()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection runs the Sharder on all the replicas
+func (s *Sharder) NeedLeaderElection() bool {
+	return false
+}
+
+// OnRebalance registers a function called with the previous and the current rings when the members change
+func (s *Sharder) OnRebalance(rebalance func(previous, current *Ring)) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rebalances = append(s.rebalances, rebalance)
+}
+
+// Owns returns whether the replica owns the workload of the key
+func (s *Sharder) Owns(key string) bool {
+	if s == nil {
+		return true
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.OwnedIn(s.ring, key)
+}
+
+// OwnedIn returns whether the replica owns the workload of the key in the ring
+func (s *Sharder) OwnedIn(ring *Ring, key string) bool {
+	if s == nil {
+		return true
+	}
+	return ring.Owner(key) == s.Identity
+}
+
+// HoldsLease returns whether the Lease of the replica is not expired, the other replicas having not taken over its
+// workloads yet
+func (s *Sharder) HoldsLease() bool {
+	if s == nil {
+		return true
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return time.Since(s.lastRenew) < s.LeaseDuration
+}
+
+// OwnsObject returns whether the replica owns the workload, or the workload targeted by the VPA
+func (s *Sharder) OwnsObject(obj metav1.Object) bool {
+	return s.Owns(GetKey(obj))
+}
+
+// Predicate filters the events of the workloads and VPAs owned by other replicas
+func (s *Sharder) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj ctrlclient.Object) bool {
+		return s.OwnsObject(obj)
+	})
+}
+
+// GetKey returns the namespace/name sharding key of a workload, a VPA having the key of its target to be handled
+// by the same replica
+func GetKey(obj metav1.Object) string {
+	if vpaResource, ok := obj.(*vpa.VerticalPodAutoscaler); ok && vpaResource.Spec.TargetRef != nil {
+		return vpaResource.Namespace + "/" + vpaResource.Spec.TargetRef.Name
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+func (s *Sharder) refresh(ctx context.Context) {
+	now := time.Now()
+	if err := s.renewLease(ctx, now); err != nil {
+		klog.Errorf("Error renewing shard lease of %s: %s", s.Identity, err.Error())
+	} else {
+		s.mutex.Lock()
+		s.lastRenew = now
+		s.mutex.Unlock()
+	}
+
+	var members []string
+	if s.HoldsLease() {
+		// the replica left out by the others once its Lease expired stops handling the workloads
+		var err error
+		members, err = s.listMembers(ctx, now)
+		if err != nil {
+			klog.Errorf("Error listing shard leases: %s", err.Error())
+			return
+		}
+	}
+	s.setRing(NewRing(members))
+}
+
+func (s *Sharder) setRing(ring *Ring) {
+	s.mutex.Lock()
+	previous := s.ring
+	if ring.Equal(previous) && previous != nil {
+		s.mutex.Unlock()
+		return
+	}
+	s.ring = ring
+	rebalances := append([]func(previous, current *Ring){}, s.rebalances...)
+	s.mutex.Unlock()
+
+	klog.Infof("Shard members changed: %s", strings.Join(ring.Members(), ", "))
+	for _, rebalance := range rebalances {
+		rebalance(previous, ring)
+	}
+}
+
+func (s *Sharder) renewLease(ctx context.Context, now time.Time) error {
+	leases := s.Clientset.CoordinationV1().Leases(s.Namespace)
+	renewTime := metav1.NewMicroTime(now)
+	leaseDurationSeconds := int32(s.LeaseDuration.Seconds())
+	lease, err := leases.Get(ctx, leasePrefix+s.Identity, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      leasePrefix + s.Identity,
+				Namespace: s.Namespace,
+				Labels:    map[string]string{shardLabel: "true"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &s.Identity,
+				LeaseDurationSeconds: &leaseDurationSeconds,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.HolderIdentity = &s.Identity
+	lease.Spec.LeaseDurationSeconds = &leaseDurationSeconds
+	lease.Spec.RenewTime = &renewTime
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// listMembers returns the replicas whose Lease is not expired
+func (s *Sharder) listMembers(ctx context.Context, now time.Time) ([]string, error) {
+	leaseList, err := s.Clientset.CoordinationV1().Leases(s.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: shardLabel + "=true",
+	})
+	if err != nil {
+		return nil, err
+	}
+	members := []string{}
+	for _, lease := range leaseList.Items {
+		spec := lease.Spec
+		if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+		if expiry.After(now) {
+			members = append(members, *spec.HolderIdentity)
+		}
+	}
+	return members, nil
+}
+
+func (s *Sharder) deleteLease() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := s.Clientset.CoordinationV1().Leases(s.Namespace).Delete(ctx, leasePrefix+s.Identity, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("Error deleting shard lease of %s: %s", s.Identity, err.Error())
+	}
+}
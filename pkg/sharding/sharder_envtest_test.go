@@ -0,0 +1,296 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	testNamespace     = "default"
+	testLeaseDuration = 3 * time.Second
+	testWorkloads     = 30
+)
+
+// reconciliations records the replicas which reconciled each workload
+type reconciliations struct {
+	mutex    sync.Mutex
+	replicas map[string][]string
+}
+
+func (r *reconciliations) record(identity string, key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.replicas[key] = append(r.replicas[key], identity)
+}
+
+func (r *reconciliations) get(key string) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string{}, r.replicas[key]...)
+}
+
+func (r *reconciliations) reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.replicas = map[string][]string{}
+}
+
+type replica struct {
+	sharder *Sharder
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func (r *replica) members() []string {
+	r.sharder.mutex.RLock()
+	defer r.sharder.mutex.RUnlock()
+	return r.sharder.ring.Members()
+}
+
+func (r *replica) stop() {
+	r.cancel()
+	<-r.done
+}
+
+// startReplica starts a manager reconciling the Deployments owned by the replica, as the workload reconcilers do:
+// the events of the other replicas are filtered out, and the Deployments taken over on a rebalance are enqueued
+func startReplica(t *testing.T, cfg *rest.Config, identity string, recorded *reconciliations) *replica {
+	t.Helper()
+	sharder := &Sharder{
+		Identity:      identity,
+		Namespace:     testNamespace,
+		LeaseDuration: testLeaseDuration,
+		Clientset:     kubernetes.NewForConfigOrDie(cfg),
+	}
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Metrics: metricsserver.Options{BindAddress: "0"}})
+	if err != nil {
+		t.Fatalf("Error creating manager of %s: %s", identity, err.Error())
+	}
+	if err := mgr.Add(sharder); err != nil {
+		t.Fatalf("Error adding sharder of %s: %s", identity, err.Error())
+	}
+
+	events := make(chan event.GenericEvent)
+	sharder.OnRebalance(func(previous, current *Ring) {
+		go func() {
+			list := &appsv1.DeploymentList{}
+			if err := mgr.GetAPIReader().List(context.Background(), list, ctrlclient.InNamespace(testNamespace)); err != nil {
+				t.Errorf("Error listing deployments to rebalance: %s", err.Error())
+				return
+			}
+			for i := range list.Items {
+				key := GetKey(&list.Items[i])
+				if !sharder.OwnedIn(previous, key) && sharder.OwnedIn(current, key) {
+					events <- event.GenericEvent{Object: &list.Items[i]}
+				}
+			}
+		}()
+	})
+	err = ctrl.NewControllerManagedBy(mgr).
+		Named("deployment").
+		WatchesRawSource(source.Kind(mgr.GetCache(), ctrlclient.Object(&appsv1.Deployment{}), &handler.EnqueueRequestForObject{}, sharder.Predicate())).
+		WatchesRawSource(source.Channel(events, &handler.EnqueueRequestForObject{})).
+		Complete(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+			if sharder.Owns(req.String()) {
+				recorded.record(identity, req.String())
+			}
+			return reconcile.Result{}, nil
+		}))
+	if err != nil {
+		t.Fatalf("Error creating controller of %s: %s", identity, err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replica{sharder: sharder, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("Error running manager of %s: %s", identity, err.Error())
+		}
+	}()
+	return r
+}
+
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+	err := wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, 30*time.Second, true, func(context.Context) (bool, error) {
+		return condition(), nil
+	})
+	if err != nil {
+		t.Fatalf("Timed out waiting for %s", description)
+	}
+}
+
+func waitForMembers(t *testing.T, replicas []*replica, members int) {
+	t.Helper()
+	waitFor(t, fmt.Sprintf("%d shard members", members), func() bool {
+		for _, r := range replicas {
+			if len(r.members()) != members {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// waitForReconciliations waits for the workloads to be reconciled, and checks that each one was reconciled by its
+// owner only
+func waitForReconciliations(t *testing.T, recorded *reconciliations, ring *Ring, keys []string) {
+	t.Helper()
+	waitFor(t, "the reconciliation of the workloads", func() bool {
+		for _, key := range keys {
+			if len(recorded.get(key)) == 0 {
+				return false
+			}
+		}
+		return true
+	})
+	// leave the time for a duplicated reconciliation to show up
+	time.Sleep(2 * time.Second)
+	for _, key := range keys {
+		replicas := recorded.get(key)
+		if len(replicas) != 1 || replicas[0] != ring.Owner(key) {
+			t.Errorf("%s: expected a reconciliation by %s only, got %v", key, ring.Owner(key), replicas)
+		}
+	}
+}
+
+// splitKeys returns the keys owned by the member in the ring, and the other ones
+func splitKeys(keys []string, ring *Ring, member string) ([]string, []string) {
+	owned, others := []string{}, []string{}
+	for _, key := range keys {
+		if ring.Owner(key) == member {
+			owned = append(owned, key)
+		} else {
+			others = append(others, key)
+		}
+	}
+	return owned, others
+}
+
+func checkNotReconciled(t *testing.T, recorded *reconciliations, keys []string) {
+	t.Helper()
+	for _, key := range keys {
+		if replicas := recorded.get(key); len(replicas) != 0 {
+			t.Errorf("%s: expected no reconciliation, got %v", key, replicas)
+		}
+	}
+}
+
+func createDeployment(t *testing.T, clientset kubernetes.Interface, name string) {
+	t.Helper()
+	labels := map[string]string{"app": name}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx"}}},
+			},
+		},
+	}
+	if _, err := clientset.AppsV1().Deployments(testNamespace).Create(context.Background(), deployment, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Error creating deployment %s: %s", name, err.Error())
+	}
+}
+
+// TestShardingHandover runs several replicas against an API server, checking that each workload is reconciled by a
+// single replica, and handed over when a replica stops or when its Lease expires. It needs the envtest binaries,
+// e.g. KUBEBUILDER_ASSETS="$(setup-envtest use -p path)"
+func TestShardingHandover(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+	testEnv := &envtest.Environment{}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		t.Fatalf("Error starting test environment: %s", err.Error())
+	}
+	defer func() {
+		_ = testEnv.Stop()
+	}()
+	clientset := kubernetes.NewForConfigOrDie(cfg)
+
+	recorded := &reconciliations{replicas: map[string][]string{}}
+	replicas := []*replica{}
+	for _, identity := range []string{"oblik-0", "oblik-1", "oblik-2"} {
+		replicas = append(replicas, startReplica(t, cfg, identity, recorded))
+	}
+	defer func() {
+		for _, r := range replicas {
+			r.stop()
+		}
+	}()
+	waitForMembers(t, replicas, 3)
+
+	keys := []string{}
+	for i := 0; i < testWorkloads; i++ {
+		name := fmt.Sprintf("app-%d", i)
+		createDeployment(t, clientset, name)
+		keys = append(keys, testNamespace+"/"+name)
+	}
+	ring := NewRing([]string{"oblik-0", "oblik-1", "oblik-2"})
+	waitForReconciliations(t, recorded, ring, keys)
+
+	t.Run("handover on deletion", func(t *testing.T) {
+		recorded.reset()
+		stopped := replicas[1]
+		stopped.stop()
+		replicas = append(replicas[:1], replicas[2:]...)
+		waitForMembers(t, replicas, 2)
+
+		// only the workloads of the stopped replica are reconciled again, by their new owner
+		next := NewRing([]string{"oblik-0", "oblik-2"})
+		moved, kept := splitKeys(keys, ring, stopped.sharder.Identity)
+		waitForReconciliations(t, recorded, next, moved)
+		checkNotReconciled(t, recorded, kept)
+		ring = next
+	})
+
+	t.Run("handover on lease expiry", func(t *testing.T) {
+		// a replica which stopped renewing its Lease without deleting it
+		renewTime := metav1.NewMicroTime(time.Now())
+		identity := "oblik-expired"
+		leaseDurationSeconds := int32(testLeaseDuration.Seconds())
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: leasePrefix + identity, Namespace: testNamespace, Labels: map[string]string{shardLabel: "true"}},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &leaseDurationSeconds,
+				RenewTime:            &renewTime,
+			},
+		}
+		if _, err := clientset.CoordinationV1().Leases(testNamespace).Create(context.Background(), lease, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Error creating lease: %s", err.Error())
+		}
+		waitForMembers(t, replicas, 3)
+		recorded.reset()
+		waitForMembers(t, replicas, 2)
+
+		// the workloads of the expired replica go back to the live ones
+		moved, kept := splitKeys(keys, NewRing([]string{"oblik-0", "oblik-2", identity}), identity)
+		waitForReconciliations(t, recorded, ring, moved)
+		checkNotReconciled(t, recorded, kept)
+	})
+}
@@ -0,0 +1,317 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// flushDelay is the time the changes of the states are gathered before being persisted in a single patch
+var flushDelay = 2 * time.Second
+
+// RunState is the persisted scheduling state of a workload
+type RunState struct {
+	// LastSuccess is the time of the last successful scheduled apply
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	// Pending is the time a scheduled apply is planned at, random delay included
+	Pending *time.Time `json:"pending,omitempty"`
+	// ConsecutiveFailures is the number of applies failed since the last successful one
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// LastError is the error of the last failed apply
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Store persists the scheduling state of workloads in ConfigMaps of the operator namespace,
+// so that a new leader can catch up runs missed during a restart or a failover. The states of each remote cluster
+// are kept in their own ConfigMaps, suffixed with the name of the cluster, and the workloads of a cluster are spread
+// over Partitions ConfigMaps, suffixed with their index, not to hit the size limit of a ConfigMap on large clusters.
+// The changes are kept in memory and persisted after flushDelay, gathering the changes of the same ConfigMap in a
+// single patch, without holding the store during the API calls
+type Store struct {
+	Namespace  string
+	Name       string
+	Partitions int
+
+	mutex  sync.Mutex
+	shards map[string]*shard
+}
+
+// shard is the states of the workloads persisted in a ConfigMap
+type shard struct {
+	name string
+
+	mutex     sync.Mutex
+	clientset kubernetes.Interface
+	loaded    bool
+	states    map[string]*RunState
+	// dirty are the keys changed or deleted since the last flush
+	dirty     map[string]bool
+	scheduled bool
+}
+
+func NewStore() *Store {
+	partitions, err := strconv.Atoi(utils.GetEnv("OBLIK_SCHEDULER_STATE_PARTITIONS", "4"))
+	if err != nil || partitions < 1 {
+		klog.Warningf("Invalid OBLIK_SCHEDULER_STATE_PARTITIONS, using default 4")
+		partitions = 4
+	}
+	return &Store{
+		Namespace:  utils.GetEnv("NAMESPACE", "default"),
+		Name:       utils.GetEnv("OBLIK_SCHEDULER_STATE_CONFIGMAP", "oblik-scheduler-state"),
+		Partitions: partitions,
+		shards:     make(map[string]*shard),
+	}
+}
+
+// dataKey converts a workload key to a ConfigMap data key, namespaces can't contain dots, nor the cluster names
+// of the keys qualified in multi-cluster mode underscores
+func dataKey(key string) string {
+	return strings.Replace(strings.Replace(key, ":", "_", 1), "/", ".", 1)
+}
+
+func workloadKey(dataKey string) string {
+	return strings.Replace(strings.Replace(dataKey, "_", ":", 1), ".", "/", 1)
+}
+
+// getConfigMapName returns the ConfigMap of the workload key: the one of its cluster, the cluster running Oblik
+// keeping the unsuffixed name, and of its partition when the states are partitioned
+func (s *Store) getConfigMapName(key string) string {
+	cluster, _ := config.SplitClusterKey(key)
+	name := s.Name
+	if cluster != "" {
+		name = s.Name + "-" + cluster
+	}
+	if s.Partitions <= 1 {
+		return name
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return fmt.Sprintf("%s-%d", name, hash.Sum32()%uint32(s.Partitions))
+}
+
+// getShard returns the shard of the workload key, locked, its ConfigMap being read on first use without holding it
+func (s *Store) getShard(ctx context.Context, clientset kubernetes.Interface, key string) *shard {
+	name := s.getConfigMapName(key)
+	s.mutex.Lock()
+	sh, exists := s.shards[name]
+	if !exists {
+		sh = &shard{name: name, states: make(map[string]*RunState), dirty: make(map[string]bool)}
+		s.shards[name] = sh
+	}
+	s.mutex.Unlock()
+
+	sh.mutex.Lock()
+	if sh.clientset == nil {
+		sh.clientset = clientset
+	}
+	if sh.loaded || sh.clientset == nil {
+		return sh
+	}
+	clientset = sh.clientset
+	sh.mutex.Unlock()
+
+	data, loaded := s.read(ctx, clientset, name)
+	sh.mutex.Lock()
+	if loaded && !sh.loaded {
+		for key, runState := range data {
+			// the changes not flushed yet prevail
+			if !sh.dirty[key] {
+				sh.states[key] = runState
+			}
+		}
+		sh.loaded = true
+		klog.Infof("Loaded scheduler state of %d workload(s) from configmap %s", len(data), name)
+	}
+	return sh
+}
+
+// read returns the states persisted in the ConfigMap, and whether it could be read
+func (s *Store) read(ctx context.Context, clientset kubernetes.Interface, name string) (map[string]*RunState, bool) {
+	data := map[string]*RunState{}
+	configMap, err := clientset.CoreV1().ConfigMaps(s.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return data, true
+		}
+		klog.Errorf("Error loading scheduler state from configmap %s/%s: %s", s.Namespace, name, err.Error())
+		return nil, false
+	}
+	for k, v := range configMap.Data {
+		runState := &RunState{}
+		if err := json.Unmarshal([]byte(v), runState); err != nil {
+			klog.Warningf("Error parsing scheduler state of %s: %s", k, err.Error())
+			continue
+		}
+		data[workloadKey(k)] = runState
+	}
+	return data, true
+}
+
+// Get returns a copy of the state of the workload, loading its ConfigMap on first use
+func (s *Store) Get(ctx context.Context, clientset kubernetes.Interface, key string) RunState {
+	sh := s.getShard(ctx, clientset, key)
+	defer sh.mutex.Unlock()
+
+	if runState, exists := sh.states[key]; exists {
+		return *runState
+	}
+	return RunState{}
+}
+
+func (s *Store) SetPending(ctx context.Context, clientset kubernetes.Interface, key string, pending time.Time) {
+	s.update(ctx, clientset, key, func(runState *RunState) {
+		runState.Pending = &pending
+	})
+}
+
+func (s *Store) SetLastSuccess(ctx context.Context, clientset kubernetes.Interface, key string, lastSuccess time.Time) {
+	s.update(ctx, clientset, key, func(runState *RunState) {
+		runState.LastSuccess = &lastSuccess
+		runState.Pending = nil
+		runState.ConsecutiveFailures = 0
+		runState.LastError = ""
+	})
+}
+
+// RecordFailure increments the consecutive failures of the workload and returns their count
+func (s *Store) RecordFailure(ctx context.Context, clientset kubernetes.Interface, key string, err error) int {
+	failures := 0
+	s.update(ctx, clientset, key, func(runState *RunState) {
+		runState.ConsecutiveFailures++
+		runState.LastError = err.Error()
+		failures = runState.ConsecutiveFailures
+	})
+	return failures
+}
+
+func (s *Store) Delete(ctx context.Context, clientset kubernetes.Interface, key string) {
+	sh := s.getShard(ctx, clientset, key)
+	defer sh.mutex.Unlock()
+
+	if _, exists := sh.states[key]; !exists {
+		return
+	}
+	delete(sh.states, key)
+	s.markDirty(sh, key)
+}
+
+// Reset drops the loaded states, reloaded on next use, when another replica may have updated them. The changes not
+// flushed yet are kept
+func (s *Store) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, sh := range s.shards {
+		sh.mutex.Lock()
+		for key := range sh.states {
+			if !sh.dirty[key] {
+				delete(sh.states, key)
+			}
+		}
+		sh.loaded = false
+		sh.mutex.Unlock()
+	}
+}
+
+func (s *Store) update(ctx context.Context, clientset kubernetes.Interface, key string, mutate func(runState *RunState)) {
+	sh := s.getShard(ctx, clientset, key)
+	defer sh.mutex.Unlock()
+
+	runState, exists := sh.states[key]
+	if !exists {
+		runState = &RunState{}
+		sh.states[key] = runState
+	}
+	mutate(runState)
+	s.markDirty(sh, key)
+}
+
+// markDirty records the change of the key, the shard being locked, and schedules the flush of the shard
+func (s *Store) markDirty(sh *shard, key string) {
+	sh.dirty[key] = true
+	if sh.scheduled {
+		return
+	}
+	sh.scheduled = true
+	time.AfterFunc(flushDelay, func() {
+		s.flush(sh)
+	})
+}
+
+// flush persists the changes of the shard in a single patch, the changes of a failed patch being flushed again
+// with the next ones
+func (s *Store) flush(sh *shard) {
+	sh.mutex.Lock()
+	sh.scheduled = false
+	data := map[string]interface{}{}
+	for key := range sh.dirty {
+		runState, exists := sh.states[key]
+		if !exists {
+			data[dataKey(key)] = nil
+			continue
+		}
+		value, err := json.Marshal(runState)
+		if err != nil {
+			klog.Errorf("Error marshalling scheduler state of %s: %s", key, err.Error())
+			continue
+		}
+		data[dataKey(key)] = string(value)
+	}
+	flushed := sh.dirty
+	sh.dirty = make(map[string]bool)
+	clientset := sh.clientset
+	sh.mutex.Unlock()
+
+	if len(data) == 0 || clientset == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.patch(ctx, clientset, sh.name, data); err != nil {
+		klog.Errorf("Error persisting scheduler state to configmap %s/%s: %s", s.Namespace, sh.name, err.Error())
+		sh.mutex.Lock()
+		for key := range flushed {
+			s.markDirty(sh, key)
+		}
+		sh.mutex.Unlock()
+	}
+}
+
+func (s *Store) patch(ctx context.Context, clientset kubernetes.Interface, name string, data map[string]interface{}) error {
+	patchData, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return err
+	}
+	configMaps := clientset.CoreV1().ConfigMaps(s.Namespace)
+	_, err = configMaps.Patch(ctx, name, types.MergePatchType, patchData, metav1.PatchOptions{})
+	if errors.IsNotFound(err) {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: s.Namespace,
+			},
+			Data: map[string]string{},
+		}
+		for k, v := range data {
+			if str, ok := v.(string); ok {
+				configMap.Data[k] = str
+			}
+		}
+		_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+	}
+	return err
+}
@@ -0,0 +1,220 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestGetConfigMapName(t *testing.T) {
+	store := &Store{Name: "oblik-scheduler-state", Partitions: 1}
+	tests := map[string]string{
+		"default/app":         "oblik-scheduler-state",
+		"prod:default/app":    "oblik-scheduler-state-prod",
+		"prod:other/worker":   "oblik-scheduler-state-prod",
+		"staging:default/app": "oblik-scheduler-state-staging",
+	}
+	for key, name := range tests {
+		if configMapName := store.getConfigMapName(key); configMapName != name {
+			t.Errorf("%s: expected configmap %s, got %s", key, name, configMapName)
+		}
+	}
+}
+
+func TestGetConfigMapNamePartitions(t *testing.T) {
+	store := &Store{Name: "oblik-scheduler-state", Partitions: 4}
+	names := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		key := "prod:default/app-" + string(rune('a'+i%26)) + strings.Repeat("x", i/26)
+		name := store.getConfigMapName(key)
+		if !strings.HasPrefix(name, "oblik-scheduler-state-prod-") {
+			t.Fatalf("%s: expected a partition of the prod configmap, got %s", key, name)
+		}
+		if name != store.getConfigMapName(key) {
+			t.Fatalf("%s: expected a stable partition", key)
+		}
+		names[name] = true
+	}
+	if len(names) != 4 {
+		t.Errorf("expected the workloads to be spread over 4 configmaps, got %v", names)
+	}
+}
+
+func TestDataKey(t *testing.T) {
+	for _, key := range []string{"default/app", "prod:default/app"} {
+		if converted := workloadKey(dataKey(key)); converted != key {
+			t.Errorf("%s: converted back to %s", key, converted)
+		}
+	}
+}
+
+// apiServer records the patches of the ConfigMaps, which are all missing on read
+type apiServer struct {
+	mutex   sync.Mutex
+	patches map[string][]map[string]interface{}
+	failing bool
+	// blocked holds the patches until closed
+	blocked chan struct{}
+}
+
+func (a *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPatch {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+		return
+	}
+	if a.blocked != nil {
+		<-a.blocked
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","code":500}`))
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	patch := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	_ = json.Unmarshal(body, &patch)
+	a.patches[name] = append(a.patches[name], patch.Data)
+	_, _ = w.Write([]byte(`{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"` + name + `"}}`))
+}
+
+func (a *apiServer) get(name string) []map[string]interface{} {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.patches[name]
+}
+
+func (a *apiServer) setFailing(failing bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.failing = failing
+}
+
+func newTestStore(t *testing.T, api *apiServer) (*Store, kubernetes.Interface) {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	previous := flushDelay
+	flushDelay = 50 * time.Millisecond
+	t.Cleanup(func() { flushDelay = previous })
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("Error creating clientset: %s", err.Error())
+	}
+	return &Store{Namespace: "oblik", Name: "oblik-scheduler-state", Partitions: 1, shards: map[string]*shard{}}, clientset
+}
+
+func waitForPatches(t *testing.T, api *apiServer, name string, count int) []map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if patches := api.get(name); len(patches) >= count {
+			return patches
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d patch(es) of %s, got %d", count, name, len(api.get(name)))
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	t.Run("changes gathered in a single patch", func(t *testing.T) {
+		api := &apiServer{patches: map[string][]map[string]interface{}{}}
+		store, clientset := newTestStore(t, api)
+		ctx := context.Background()
+		store.SetPending(ctx, clientset, "default/app", time.Now())
+		store.SetLastSuccess(ctx, clientset, "default/app", time.Now())
+		store.RecordFailure(ctx, clientset, "default/worker", errors.New("conflict"))
+		store.SetPending(ctx, clientset, "default/old", time.Now())
+		store.Delete(ctx, clientset, "default/old")
+
+		patches := waitForPatches(t, api, "oblik-scheduler-state", 1)
+		time.Sleep(200 * time.Millisecond)
+		if patches = api.get("oblik-scheduler-state"); len(patches) != 1 {
+			t.Fatalf("expected a single patch, got %v", patches)
+		}
+		patch := patches[0]
+		if _, exists := patch["default.app"]; !exists {
+			t.Errorf("expected the state of default/app to be persisted, got %v", patch)
+		}
+		if value, _ := patch["default.worker"].(string); !strings.Contains(value, "conflict") {
+			t.Errorf("expected the failure of default/worker to be persisted, got %v", patch)
+		}
+		if value, exists := patch["default.old"]; !exists || value != nil {
+			t.Errorf("expected default/old to be removed, got %v", patch)
+		}
+	})
+
+	t.Run("store not held during the patch", func(t *testing.T) {
+		api := &apiServer{patches: map[string][]map[string]interface{}{}, blocked: make(chan struct{})}
+		store, clientset := newTestStore(t, api)
+		ctx := context.Background()
+		store.SetPending(ctx, clientset, "default/app", time.Now())
+		time.Sleep(200 * time.Millisecond)
+
+		done := make(chan struct{})
+		go func() {
+			store.SetPending(ctx, clientset, "default/worker", time.Now())
+			_ = store.Get(ctx, clientset, "default/app")
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Errorf("expected the store to be usable during a patch")
+		}
+		close(api.blocked)
+		waitForPatches(t, api, "oblik-scheduler-state", 2)
+	})
+
+	t.Run("failed patch retried", func(t *testing.T) {
+		api := &apiServer{patches: map[string][]map[string]interface{}{}, failing: true}
+		store, clientset := newTestStore(t, api)
+		ctx := context.Background()
+		store.SetPending(ctx, clientset, "default/app", time.Now())
+		time.Sleep(200 * time.Millisecond)
+		api.setFailing(false)
+
+		patches := waitForPatches(t, api, "oblik-scheduler-state", 1)
+		if _, exists := patches[0]["default.app"]; !exists {
+			t.Errorf("expected the state of default/app to be persisted again, got %v", patches[0])
+		}
+	})
+}
+
+func TestReset(t *testing.T) {
+	store := &Store{Name: "oblik-scheduler-state", Partitions: 1, shards: map[string]*shard{}}
+	sh := &shard{
+		name:   "oblik-scheduler-state",
+		loaded: true,
+		states: map[string]*RunState{"default/app": {}, "default/worker": {ConsecutiveFailures: 1}},
+		dirty:  map[string]bool{"default/worker": true},
+	}
+	store.shards[sh.name] = sh
+	store.Reset()
+	if sh.loaded {
+		t.Errorf("expected the shard to be reloaded on next use")
+	}
+	if _, exists := sh.states["default/app"]; exists {
+		t.Errorf("expected the persisted state of default/app to be dropped")
+	}
+	if runState, exists := sh.states["default/worker"]; !exists || runState.ConsecutiveFailures != 1 {
+		t.Errorf("expected the state of default/worker not flushed yet to be kept")
+	}
+}
@@ -0,0 +1,31 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PatchAnnotations merges the annotations into the ones of the workload
+func PatchAnnotations(clientset kubernetes.Interface, namespace string, kind string, name string, annotations map[string]string) error {
+	target, ok := workloadTargets[kind]
+	if !ok {
+		return fmt.Errorf("Unsupported kind: %s", kind)
+	}
+	patchData, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return clientset.Discovery().RESTClient().Patch(types.MergePatchType).
+		AbsPath(target.apiPath, "namespaces", namespace, target.resource, name).
+		Body(patchData).
+		Do(context.TODO()).
+		Error()
+}
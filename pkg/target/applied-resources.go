@@ -0,0 +1,63 @@
+package target
+
+import (
+	"encoding/json"
+
+	"github.com/SocialGouv/oblik/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// SetAppliedResources records the CPU and memory resources of the containers in the annotations of the workload,
+// in the same write as the resources so that the watchers never see them apart
+func SetAppliedResources(object metav1.Object, containers []corev1.Container) {
+	applied := map[string]corev1.ResourceRequirements{}
+	for _, container := range containers {
+		applied[container.Name] = getCpuMemoryResources(container.Resources)
+	}
+	value, err := json.Marshal(applied)
+	if err != nil {
+		klog.Errorf("Error recording applied resources: %s", err.Error())
+		return
+	}
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[constants.AppliedResourcesAnnotation] = string(value)
+	object.SetAnnotations(annotations)
+}
+
+// GetAppliedResources returns the containers resources last applied by Oblik, nil when none were recorded
+func GetAppliedResources(annotations map[string]string) map[string]corev1.ResourceRequirements {
+	value, ok := annotations[constants.AppliedResourcesAnnotation]
+	if !ok {
+		return nil
+	}
+	applied := map[string]corev1.ResourceRequirements{}
+	if err := json.Unmarshal([]byte(value), &applied); err != nil {
+		klog.Warningf("Error parsing applied resources: %s", err.Error())
+		return nil
+	}
+	return applied
+}
+
+func getCpuMemoryResources(resources corev1.ResourceRequirements) corev1.ResourceRequirements {
+	filtered := corev1.ResourceRequirements{}
+	for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if request, ok := resources.Requests[resourceName]; ok {
+			if filtered.Requests == nil {
+				filtered.Requests = corev1.ResourceList{}
+			}
+			filtered.Requests[resourceName] = request
+		}
+		if limit, ok := resources.Limits[resourceName]; ok {
+			if filtered.Limits == nil {
+				filtered.Limits = corev1.ResourceList{}
+			}
+			filtered.Limits[resourceName] = limit
+		}
+	}
+	return filtered
+}
@@ -13,25 +13,21 @@ import (
 )
 
 func ApplyVPARecommendations(kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) error {
-	clientset := kubeClients.Clientset
-	dynamicClient := kubeClients.DynamicClient
-	vpaClientset := kubeClients.VpaClientset
-
 	targetRef := vpa.Spec.TargetRef
 	var update *reporting.UpdateResult
 	var err error
 	switch targetRef.Kind {
 	case "Deployment":
-		update, err = UpdateDeployment(clientset, vpa, scfg)
+		update, err = UpdateDeployment(kubeClients, vpa, scfg)
 	case "StatefulSet":
-		update, err = UpdateStatefulSet(clientset, vpa, scfg)
+		update, err = UpdateStatefulSet(kubeClients, vpa, scfg)
 	case "DaemonSet":
-		update, err = UpdateDaemonSet(clientset, vpa, scfg)
+		update, err = UpdateDaemonSet(kubeClients, vpa, scfg)
 	case "CronJob":
-		update, err = UpdateCronJob(clientset, vpa, scfg)
+		update, err = UpdateCronJob(kubeClients, vpa, scfg)
 	case "Cluster":
 		if targetRef.APIVersion == "postgresql.cnpg.io/v1" {
-			update, err = UpdateCluster(dynamicClient, vpa, scfg)
+			update, err = UpdateCluster(kubeClients, vpa, scfg)
 		} else {
 			err := fmt.Errorf("Unsupported Cluster kind from apiVersion: %s", targetRef.APIVersion)
 			klog.Warning(err)
@@ -44,11 +40,16 @@ func ApplyVPARecommendations(kubeClients *client.KubeClients, vpa *vpa.VerticalP
 	}
 	if err != nil {
 		if errors.IsNotFound(err) {
-			ovpa.DeleteVPA(vpaClientset, vpa)
-			return nil
+			return ovpa.DeleteVPA(kubeClients, vpa)
 		}
 		klog.Errorf("Failed to apply updates for %s: %s", scfg.Key, err.Error())
 	}
+	if update != nil && update.Type == reporting.ResultTypeSkipped && update.GitOpsConflict != nil {
+		reporting.ReportSkipped(scfg.Key, "GitOps conflict: "+update.GitOpsConflict.String())
+	}
+	if err == nil {
+		reportGitOpsConflict(kubeClients, vpa, update, scfg)
+	}
 	reporting.ReportUpdated(update, scfg)
 	return err
 }
@@ -6,9 +6,13 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 )
 
-func createPatch(obj interface{}, apiVersion, kind string) ([]byte, error) {
+// createPatch returns the apply patch of the workload, with its pod-level resources when they are managed.
+// The resource version and the status are left out, a resource version read from the cache making the apply fail
+// with a conflict when the workload changed meanwhile
+func createPatch(obj interface{}, podResources *corev1.ResourceRequirements, apiVersion, kind string) ([]byte, error) {
 	var patchedObj interface{}
 	switch t := obj.(type) {
 	case *appsv1.Deployment:
@@ -16,21 +20,29 @@ func createPatch(obj interface{}, apiVersion, kind string) ([]byte, error) {
 		patchedObj.(*appsv1.Deployment).APIVersion = apiVersion
 		patchedObj.(*appsv1.Deployment).Kind = kind
 		patchedObj.(*appsv1.Deployment).ObjectMeta.ManagedFields = nil
+		patchedObj.(*appsv1.Deployment).ObjectMeta.ResourceVersion = ""
+		patchedObj.(*appsv1.Deployment).Status = appsv1.DeploymentStatus{}
 	case *appsv1.StatefulSet:
 		patchedObj = t.DeepCopy()
 		patchedObj.(*appsv1.StatefulSet).APIVersion = apiVersion
 		patchedObj.(*appsv1.StatefulSet).Kind = kind
 		patchedObj.(*appsv1.StatefulSet).ObjectMeta.ManagedFields = nil
+		patchedObj.(*appsv1.StatefulSet).ObjectMeta.ResourceVersion = ""
+		patchedObj.(*appsv1.StatefulSet).Status = appsv1.StatefulSetStatus{}
 	case *appsv1.DaemonSet:
 		patchedObj = t.DeepCopy()
 		patchedObj.(*appsv1.DaemonSet).APIVersion = apiVersion
 		patchedObj.(*appsv1.DaemonSet).Kind = kind
 		patchedObj.(*appsv1.DaemonSet).ObjectMeta.ManagedFields = nil
+		patchedObj.(*appsv1.DaemonSet).ObjectMeta.ResourceVersion = ""
+		patchedObj.(*appsv1.DaemonSet).Status = appsv1.DaemonSetStatus{}
 	case *batchv1.CronJob:
 		patchedObj = t.DeepCopy()
 		patchedObj.(*batchv1.CronJob).APIVersion = apiVersion
 		patchedObj.(*batchv1.CronJob).Kind = kind
 		patchedObj.(*batchv1.CronJob).ObjectMeta.ManagedFields = nil
+		patchedObj.(*batchv1.CronJob).ObjectMeta.ResourceVersion = ""
+		patchedObj.(*batchv1.CronJob).Status = batchv1.CronJobStatus{}
 	default:
 		return nil, fmt.Errorf("unsupported type: %T", t)
 	}
@@ -39,5 +51,5 @@ func createPatch(obj interface{}, apiVersion, kind string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return jsonData, nil
+	return addPodResourcesToPatch(jsonData, kind, podResources)
 }
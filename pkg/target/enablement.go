@@ -0,0 +1,23 @@
+package target
+
+import (
+	"context"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	"github.com/SocialGouv/oblik/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// IsWorkloadEnabled returns whether Oblik manages the workload, from its labels and the labels of its namespace
+func IsWorkloadEnabled(ctx context.Context, kubeClients *client.KubeClients, workload metav1.Object) bool {
+	namespace := &corev1.Namespace{}
+	err := kubeClients.Reader.Get(ctx, types.NamespacedName{Name: workload.GetNamespace()}, namespace)
+	if err != nil {
+		klog.Warningf("Error fetching namespace %s for enablement: %s", workload.GetNamespace(), err.Error())
+		namespace = nil
+	}
+	return config.IsEnabled(workload, namespace)
+}
@@ -0,0 +1,183 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/constants"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	"github.com/SocialGouv/oblik/pkg/resourcesconfig"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/klog/v2"
+)
+
+// DetectGitOpsConflict returns the GitOps tool managing the workload when field managers other than Oblik own
+// its resources, so that the tool would revert the changes of Oblik. It returns nil with the gitops-policy "ignore"
+func DetectGitOpsConflict(object metav1.Object, scfg *config.StrategyConfig) *reporting.GitOpsConflict {
+	if scfg.GitOpsPolicy == config.GitOpsPolicyIgnore {
+		return nil
+	}
+	tool := getGitOpsTool(object)
+	if tool == "" {
+		return nil
+	}
+	managers := []string{}
+	for _, entry := range object.GetManagedFields() {
+		if entry.Manager == FieldManager || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			klog.Warningf("Error parsing managed fields of %s: %s", entry.Manager, err.Error())
+			continue
+		}
+		if ownsResources(fields) && !containsString(managers, entry.Manager) {
+			managers = append(managers, entry.Manager)
+		}
+	}
+	if len(managers) == 0 {
+		return nil
+	}
+	return &reporting.GitOpsConflict{Tool: tool, Managers: managers}
+}
+
+// getGitOpsTool returns "argocd" or "flux" from the tracking metadata of the workload or from its field managers
+func getGitOpsTool(object metav1.Object) string {
+	annotations := object.GetAnnotations()
+	labels := object.GetLabels()
+	if annotations["argocd.argoproj.io/tracking-id"] != "" || annotations["argocd.argoproj.io/instance"] != "" || labels["argocd.argoproj.io/instance"] != "" {
+		return "argocd"
+	}
+	if labels["kustomize.toolkit.fluxcd.io/name"] != "" || labels["helm.toolkit.fluxcd.io/name"] != "" {
+		return "flux"
+	}
+	for _, entry := range object.GetManagedFields() {
+		switch {
+		case strings.HasPrefix(entry.Manager, "argocd"):
+			return "argocd"
+		case entry.Manager == "kustomize-controller" || entry.Manager == "helm-controller":
+			return "flux"
+		}
+	}
+	return ""
+}
+
+// ownsResources tells whether a fields set holds resources of the containers or of the pod, skipping the
+// metadata and the volumes, whose claims have resources too
+func ownsResources(fields map[string]interface{}) bool {
+	for key, value := range fields {
+		child, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch key {
+		case "f:metadata", "f:volumes", "f:volumeClaimTemplates":
+			continue
+		case "f:resources":
+			if len(child) > 0 {
+				return true
+			}
+			continue
+		}
+		if ownsResources(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetGitOpsIgnoreDifferences records in the workload the ignoreDifferences entry of the ArgoCD Application
+// ignoring the resources set by Oblik, from the scheduled updates as well as from the mutating webhook
+func SetGitOpsIgnoreDifferences(object metav1.Object, apiVersion string, kind string) {
+	group := ""
+	if index := strings.Index(apiVersion, "/"); index != -1 {
+		group = apiVersion[:index]
+	}
+	paths := []string{".spec.resources"}
+	if target, ok := workloadTargets[kind]; ok {
+		podResourcesPath := "." + strings.Join(target.fields, ".")
+		podSpecPath := strings.TrimSuffix(podResourcesPath, ".resources")
+		paths = []string{podSpecPath + ".containers[].resources", podResourcesPath}
+	}
+	value, err := json.Marshal(map[string]interface{}{
+		"group":             group,
+		"kind":              kind,
+		"name":              object.GetName(),
+		"namespace":         object.GetNamespace(),
+		"jqPathExpressions": paths,
+	})
+	if err != nil {
+		klog.Errorf("Error recording ignoreDifferences hint: %s", err.Error())
+		return
+	}
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[constants.GitOpsIgnoreDifferencesAnnotation] = string(value)
+	object.SetAnnotations(annotations)
+}
+
+// checkGitOpsConflict applies the gitops-policy to the workload before a scheduled update,
+// returning the detected conflict and whether the update is skipped
+func checkGitOpsConflict(object metav1.Object, apiVersion string, kind string, scfg *config.StrategyConfig) (*reporting.GitOpsConflict, bool) {
+	conflict := DetectGitOpsConflict(object, scfg)
+	if conflict == nil {
+		return nil, false
+	}
+	switch scfg.GitOpsPolicy {
+	case config.GitOpsPolicySkip, config.GitOpsPolicyWebhook:
+		return conflict, true
+	case config.GitOpsPolicyAnnotate:
+		// Flux has no equivalent of ignoreDifferences, the conflict is only reported
+		if conflict.Tool == "argocd" {
+			SetGitOpsIgnoreDifferences(object, apiVersion, kind)
+		}
+	}
+	return conflict, false
+}
+
+// getGitOpsSkippedResult is the result of a scheduled update skipped for a GitOps conflict
+func getGitOpsSkippedResult(conflict *reporting.GitOpsConflict, scfg *config.StrategyConfig) *reporting.UpdateResult {
+	return &reporting.UpdateResult{
+		Key:            scfg.Key,
+		Type:           reporting.ResultTypeSkipped,
+		GitOpsConflict: conflict,
+	}
+}
+
+// reportGitOpsConflict reports the GitOps conflict of the workload, or its absence, on the ResourcesConfigs targeting it
+func reportGitOpsConflict(kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler, update *reporting.UpdateResult, scfg *config.StrategyConfig) {
+	if scfg.GitOpsPolicy == config.GitOpsPolicyIgnore || update == nil {
+		return
+	}
+	reason, message := "", ""
+	if update.GitOpsConflict != nil {
+		message = update.GitOpsConflict.String()
+		switch scfg.GitOpsPolicy {
+		case config.GitOpsPolicySkip:
+			reason = "Skipped"
+		case config.GitOpsPolicyWebhook:
+			reason = "WebhookOnly"
+		case config.GitOpsPolicyAnnotate:
+			reason = "Annotated"
+		default:
+			reason = "Warned"
+		}
+	}
+	targetRef := vpa.Spec.TargetRef
+	resourcesconfig.UpdateGitOpsStatus(context.TODO(), kubeClients, vpa.Namespace, targetRef.Kind, targetRef.Name, reason, message)
+}
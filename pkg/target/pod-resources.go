@@ -0,0 +1,108 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// workloadTarget locates a workload kind in the API, and its pod-level resources, which the typed objects of the
+// current API version don't hold, so they are read and written as unstructured content
+type workloadTarget struct {
+	apiPath  string
+	resource string
+	fields   []string
+}
+
+var workloadTargets = map[string]workloadTarget{
+	"Deployment":  {apiPath: "/apis/apps/v1", resource: "deployments", fields: []string{"spec", "template", "spec", "resources"}},
+	"StatefulSet": {apiPath: "/apis/apps/v1", resource: "statefulsets", fields: []string{"spec", "template", "spec", "resources"}},
+	"DaemonSet":   {apiPath: "/apis/apps/v1", resource: "daemonsets", fields: []string{"spec", "template", "spec", "resources"}},
+	"CronJob":     {apiPath: "/apis/batch/v1", resource: "cronjobs", fields: []string{"spec", "jobTemplate", "spec", "template", "spec", "resources"}},
+}
+
+// GetPodResources returns the pod-level resources of the workload object, empty when it has none,
+// and nil when the kind has no pod template
+func GetPodResources(object map[string]interface{}, kind string) *corev1.ResourceRequirements {
+	target, ok := workloadTargets[kind]
+	if !ok {
+		return nil
+	}
+	podResources := &corev1.ResourceRequirements{}
+	content, found, err := unstructured.NestedMap(object, target.fields...)
+	if err != nil {
+		klog.Warningf("Error reading pod-level resources of %s: %s", kind, err.Error())
+		return podResources
+	}
+	if !found {
+		return podResources
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, podResources); err != nil {
+		klog.Warningf("Error parsing pod-level resources of %s: %s", kind, err.Error())
+		return &corev1.ResourceRequirements{}
+	}
+	return podResources
+}
+
+// SetPodResources sets the pod-level resources of the workload object, removing them when empty
+func SetPodResources(object map[string]interface{}, kind string, podResources *corev1.ResourceRequirements) error {
+	target, ok := workloadTargets[kind]
+	if !ok || podResources == nil {
+		return nil
+	}
+	if len(podResources.Requests) == 0 && len(podResources.Limits) == 0 {
+		unstructured.RemoveNestedField(object, target.fields...)
+		return nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(podResources)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedMap(object, content, target.fields...)
+}
+
+// getWorkload reads the workload into obj from the cache, or when its pod-level resources are managed, from a single
+// live GET of the object, read once as unstructured content for the pod-level resources and converted to obj
+func getWorkload(kubeClients *client.KubeClients, namespace string, kind string, name string, obj ctrlclient.Object, podResourcesEnabled bool) (*corev1.ResourceRequirements, error) {
+	target, ok := workloadTargets[kind]
+	if !podResourcesEnabled || !ok {
+		return nil, kubeClients.Reader.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, obj)
+	}
+	data, err := kubeClients.Clientset.Discovery().RESTClient().Get().
+		AbsPath(target.apiPath, "namespaces", namespace, target.resource, name).
+		DoRaw(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", kind, err.Error())
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, obj); err != nil {
+		return nil, fmt.Errorf("Error converting %s: %s", kind, err.Error())
+	}
+	return GetPodResources(object, kind), nil
+}
+
+// addPodResourcesToPatch sets the pod-level resources in the apply patch, to keep owning them on each apply
+func addPodResourcesToPatch(patchData []byte, kind string, podResources *corev1.ResourceRequirements) ([]byte, error) {
+	if podResources == nil {
+		return patchData, nil
+	}
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(patchData, &object); err != nil {
+		return nil, err
+	}
+	if err := SetPodResources(object, kind, podResources); err != nil {
+		return nil, fmt.Errorf("Error setting pod-level resources: %s", err.Error())
+	}
+	return json.Marshal(object)
+}
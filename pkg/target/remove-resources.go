@@ -0,0 +1,114 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// patchFunc patches the workload with the typed client of its kind
+type patchFunc func(ctx context.Context, patchType types.PatchType, data []byte, opts metav1.PatchOptions) error
+
+// applyPatch applies the patch of the workload, then unsets the resources removed from its containers. The apply only
+// removes the fields no other field manager owns, e.g. a limit set by kubectl or Helm stays, so the removed resources
+// are set to null in a strategic merge patch, a no-op when the apply already removed them
+func applyPatch(ctx context.Context, kind string, patchData []byte, changes []reporting.Change, patch patchFunc) error {
+	force := true
+	err := patch(ctx, types.ApplyPatchType, patchData, metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &force, // Force the apply to take ownership of the fields
+	})
+	if err != nil {
+		return err
+	}
+	removalPatch, err := createRemovalPatch(kind, changes)
+	if err != nil || removalPatch == nil {
+		return err
+	}
+	return patch(ctx, types.StrategicMergePatchType, removalPatch, metav1.PatchOptions{FieldManager: FieldManager})
+}
+
+// createRemovalPatch returns the strategic merge patch setting the resources removed from the containers and from
+// the pod-level resources to null, nil when no resource is removed
+func createRemovalPatch(kind string, changes []reporting.Change) ([]byte, error) {
+	containers := []map[string]interface{}{}
+	byName := map[string]map[string]map[string]interface{}{}
+	podResources := map[string]map[string]interface{}{}
+	for _, change := range changes {
+		if !change.Removed {
+			continue
+		}
+		field, resourceName, ok := getRemovedResource(change.Type)
+		if !ok {
+			continue
+		}
+		if isPodResourcesChange(change.Type) {
+			if podResources[field] == nil {
+				podResources[field] = map[string]interface{}{}
+			}
+			podResources[field][string(resourceName)] = nil
+			continue
+		}
+		resources, exists := byName[change.ContainerName]
+		if !exists {
+			resources = map[string]map[string]interface{}{}
+			byName[change.ContainerName] = resources
+			containers = append(containers, map[string]interface{}{
+				"name":      change.ContainerName,
+				"resources": resources,
+			})
+		}
+		if resources[field] == nil {
+			resources[field] = map[string]interface{}{}
+		}
+		resources[field][string(resourceName)] = nil
+	}
+	if len(containers) == 0 && len(podResources) == 0 {
+		return nil, nil
+	}
+
+	spec := map[string]interface{}{}
+	if len(containers) > 0 {
+		spec["containers"] = containers
+	}
+	if len(podResources) > 0 {
+		spec["resources"] = podResources
+	}
+	podSpec := map[string]interface{}{"spec": spec}
+	workloadSpec := map[string]interface{}{"template": podSpec}
+	if kind == "CronJob" {
+		workloadSpec = map[string]interface{}{"jobTemplate": map[string]interface{}{"spec": workloadSpec}}
+	}
+	return json.Marshal(map[string]interface{}{"spec": workloadSpec})
+}
+
+// getRemovedResource returns the resources field and the resource name of the update type, of the container or of the pod
+func getRemovedResource(updateType reporting.UpdateType) (string, corev1.ResourceName, bool) {
+	switch updateType {
+	case reporting.UpdateTypeCpuRequest, reporting.UpdateTypePodCpuRequest:
+		return "requests", corev1.ResourceCPU, true
+	case reporting.UpdateTypeMemoryRequest, reporting.UpdateTypePodMemoryRequest:
+		return "requests", corev1.ResourceMemory, true
+	case reporting.UpdateTypeEphemeralStorageRequest:
+		return "requests", corev1.ResourceEphemeralStorage, true
+	case reporting.UpdateTypeCpuLimit, reporting.UpdateTypePodCpuLimit:
+		return "limits", corev1.ResourceCPU, true
+	case reporting.UpdateTypeMemoryLimit, reporting.UpdateTypePodMemoryLimit:
+		return "limits", corev1.ResourceMemory, true
+	case reporting.UpdateTypeEphemeralStorageLimit:
+		return "limits", corev1.ResourceEphemeralStorage, true
+	}
+	return "", "", false
+}
+
+func isPodResourcesChange(updateType reporting.UpdateType) bool {
+	switch updateType {
+	case reporting.UpdateTypePodCpuRequest, reporting.UpdateTypePodMemoryRequest, reporting.UpdateTypePodCpuLimit, reporting.UpdateTypePodMemoryLimit:
+		return true
+	}
+	return false
+}
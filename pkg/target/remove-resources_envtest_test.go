@@ -0,0 +1,105 @@
+package target
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/logical"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// TestApplyPatchRemoval removes a CPU limit owned by another field manager with the limit-cpu-apply-mode "remove",
+// which an apply alone keeps. It needs the
+// envtest binaries, e.g. KUBEBUILDER_ASSETS="$(setup-envtest use -p path)"
+func TestApplyPatchRemoval(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+	testEnv := &envtest.Environment{}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		t.Fatalf("Error starting test environment: %s", err.Error())
+	}
+	defer func() {
+		_ = testEnv.Stop()
+	}()
+	clientset := kubernetes.NewForConfigOrDie(cfg)
+	ctx := context.Background()
+	deployments := clientset.AppsV1().Deployments("default")
+
+	// the deployment is created by another field manager, e.g. kubectl, owning the CPU limit
+	labels := map[string]string{"app": "app"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:  "app",
+					Image: "nginx",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+						Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					},
+				}}},
+			},
+		},
+	}
+	deployment, err = deployments.Create(ctx, deployment, metav1.CreateOptions{FieldManager: "kubectl"})
+	if err != nil {
+		t.Fatalf("Error creating deployment: %s", err.Error())
+	}
+
+	// the limit is removed by the limit-cpu-apply-mode "remove" of the containers
+	applyMode := config.ApplyModeRemove
+	scfg := &config.StrategyConfig{LoadCfg: &config.LoadCfg{LimitCPUApplyMode: &applyMode}}
+	update := logical.ApplyRecommendationsToContainers(deployment.Spec.Template.Spec.Containers, nil, nil, scfg, &logical.Workload{})
+	changes := update.Changes
+	if len(changes) != 1 || !changes[0].Removed {
+		t.Fatalf("expected the removal of the cpu limit, got %+v", changes)
+	}
+	patchData, err := createPatch(deployment, nil, "apps/v1", "Deployment")
+	if err != nil {
+		t.Fatalf("Error creating patch: %s", err.Error())
+	}
+	patch := func(ctx context.Context, patchType types.PatchType, data []byte, opts metav1.PatchOptions) error {
+		_, err := deployments.Patch(ctx, "app", patchType, data, opts)
+		return err
+	}
+
+	// the apply alone leaves the limit to its owner
+	if err := applyPatch(ctx, "Deployment", patchData, nil, patch); err != nil {
+		t.Fatalf("Error applying patch: %s", err.Error())
+	}
+	applied, err := deployments.Get(ctx, "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting deployment: %s", err.Error())
+	}
+	if _, ok := applied.Spec.Template.Spec.Containers[0].Resources.Limits[corev1.ResourceCPU]; !ok {
+		t.Fatalf("expected the apply to keep the cpu limit owned by another field manager")
+	}
+
+	if err := applyPatch(ctx, "Deployment", patchData, changes, patch); err != nil {
+		t.Fatalf("Error applying patch: %s", err.Error())
+	}
+	applied, err = deployments.Get(ctx, "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting deployment: %s", err.Error())
+	}
+	resources := applied.Spec.Template.Spec.Containers[0].Resources
+	if limit, ok := resources.Limits[corev1.ResourceCPU]; ok {
+		t.Errorf("expected the cpu limit to be removed, got %s", limit.String())
+	}
+	if request := resources.Requests[corev1.ResourceCPU]; request.Cmp(resource.MustParse("100m")) != 0 {
+		t.Errorf("expected the cpu request to be kept, got %s", request.String())
+	}
+}
@@ -0,0 +1,123 @@
+package target
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+func newResourcesContainers() []corev1.Container {
+	return []corev1.Container{
+		{
+			Name: "app",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("256Mi")},
+			},
+		},
+		{
+			Name: "sidecar",
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+			},
+		},
+	}
+}
+
+func TestCreateRemovalPatch(t *testing.T) {
+	changes := []reporting.Change{
+		{Type: reporting.UpdateTypeCpuLimit, ContainerName: "app", Old: resource.MustParse("1"), Removed: true},
+		{Type: reporting.UpdateTypeMemoryRequest, ContainerName: "app", Old: resource.MustParse("128Mi"), Removed: true},
+		{Type: reporting.UpdateTypeMemoryLimit, ContainerName: "app", Old: resource.MustParse("256Mi"), New: resource.MustParse("512Mi")},
+		{Type: reporting.UpdateTypeCpuLimit, ContainerName: "sidecar", Old: resource.MustParse("500m"), Removed: true},
+	}
+
+	t.Run("deployment", func(t *testing.T) {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = newResourcesContainers()
+		original, _ := json.Marshal(deployment)
+		patch, err := createRemovalPatch("Deployment", changes)
+		if err != nil {
+			t.Fatalf("Error creating removal patch: %s", err.Error())
+		}
+		patched, err := strategicpatch.StrategicMergePatch(original, patch, &appsv1.Deployment{})
+		if err != nil {
+			t.Fatalf("Error applying removal patch: %s", err.Error())
+		}
+		result := &appsv1.Deployment{}
+		if err := json.Unmarshal(patched, result); err != nil {
+			t.Fatalf("Error parsing patched deployment: %s", err.Error())
+		}
+		checkRemovedResources(t, result.Spec.Template.Spec.Containers)
+	})
+
+	t.Run("pod resources", func(t *testing.T) {
+		// the pod-level resources are not in the vendored API version, the patch is checked without applying it
+		patch, err := createRemovalPatch("Deployment", []reporting.Change{
+			{Type: reporting.UpdateTypePodCpuLimit, Old: resource.MustParse("2"), Removed: true},
+			{Type: reporting.UpdateTypePodMemoryLimit, Old: resource.MustParse("1Gi"), New: resource.MustParse("2Gi")},
+		})
+		if err != nil {
+			t.Fatalf("Error creating removal patch: %s", err.Error())
+		}
+		expected := `{"spec":{"template":{"spec":{"resources":{"limits":{"cpu":null}}}}}}`
+		if string(patch) != expected {
+			t.Errorf("expected patch %s, got %s", expected, string(patch))
+		}
+	})
+
+	t.Run("cronjob", func(t *testing.T) {
+		cronjob := &batchv1.CronJob{}
+		cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers = newResourcesContainers()
+		original, _ := json.Marshal(cronjob)
+		patch, err := createRemovalPatch("CronJob", changes)
+		if err != nil {
+			t.Fatalf("Error creating removal patch: %s", err.Error())
+		}
+		patched, err := strategicpatch.StrategicMergePatch(original, patch, &batchv1.CronJob{})
+		if err != nil {
+			t.Fatalf("Error applying removal patch: %s", err.Error())
+		}
+		result := &batchv1.CronJob{}
+		if err := json.Unmarshal(patched, result); err != nil {
+			t.Fatalf("Error parsing patched cronjob: %s", err.Error())
+		}
+		checkRemovedResources(t, result.Spec.JobTemplate.Spec.Template.Spec.Containers)
+	})
+
+	t.Run("no removal", func(t *testing.T) {
+		patch, err := createRemovalPatch("Deployment", changes[2:3])
+		if err != nil || patch != nil {
+			t.Errorf("expected no patch, got %s, %v", patch, err)
+		}
+	})
+}
+
+func checkRemovedResources(t *testing.T, containers []corev1.Container) {
+	t.Helper()
+	if len(containers) != 2 {
+		t.Fatalf("expected the containers to be kept, got %v", containers)
+	}
+	app := containers[0].Resources
+	if _, ok := app.Limits[corev1.ResourceCPU]; ok {
+		t.Errorf("expected the cpu limit of app to be removed")
+	}
+	if _, ok := app.Requests[corev1.ResourceMemory]; ok {
+		t.Errorf("expected the memory request of app to be removed")
+	}
+	if cpu := app.Requests[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("100m")) != 0 {
+		t.Errorf("expected the cpu request of app to be kept, got %s", cpu.String())
+	}
+	if memory := app.Limits[corev1.ResourceMemory]; memory.Cmp(resource.MustParse("256Mi")) != 0 {
+		t.Errorf("expected the memory limit of app to be left to the apply, got %s", memory.String())
+	}
+	if _, ok := containers[1].Resources.Limits[corev1.ResourceCPU]; ok {
+		t.Errorf("expected the cpu limit of sidecar to be removed")
+	}
+}
@@ -0,0 +1,139 @@
+package target
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+)
+
+const rolloutPollInterval = 5 * time.Second
+
+var cnpgClusterGVK = schema.GroupVersionKind{
+	Group:   "postgresql.cnpg.io",
+	Version: "v1",
+	Kind:    "Cluster",
+}
+
+// WaitForRollout blocks until the workload targeted by the VPA has completed its rollout, or the context is done
+func WaitForRollout(ctx context.Context, kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler) error {
+	return wait.PollUntilContextCancel(ctx, rolloutPollInterval, true, func(ctx context.Context) (bool, error) {
+		return isRolledOut(ctx, kubeClients, vpa)
+	})
+}
+
+func isRolledOut(ctx context.Context, kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler) (bool, error) {
+	reader := kubeClients.Reader
+	targetRef := vpa.Spec.TargetRef
+	key := types.NamespacedName{Namespace: vpa.Namespace, Name: targetRef.Name}
+	switch targetRef.Kind {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := reader.Get(ctx, key, deployment); err != nil {
+			return false, err
+		}
+		replicas := getReplicas(deployment.Spec.Replicas)
+		status := deployment.Status
+		return status.ObservedGeneration >= deployment.Generation &&
+			status.UpdatedReplicas == replicas &&
+			status.Replicas == replicas &&
+			status.AvailableReplicas == replicas, nil
+	case "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		if err := reader.Get(ctx, key, statefulSet); err != nil {
+			return false, err
+		}
+		replicas := getReplicas(statefulSet.Spec.Replicas)
+		status := statefulSet.Status
+		if status.ObservedGeneration < statefulSet.Generation || status.ReadyReplicas != replicas {
+			return false, nil
+		}
+		if statefulSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+			return true, nil
+		}
+		return status.UpdatedReplicas == replicas && status.CurrentRevision == status.UpdateRevision, nil
+	case "DaemonSet":
+		daemonSet := &appsv1.DaemonSet{}
+		if err := reader.Get(ctx, key, daemonSet); err != nil {
+			return false, err
+		}
+		status := daemonSet.Status
+		if daemonSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+			return status.ObservedGeneration >= daemonSet.Generation, nil
+		}
+		return status.ObservedGeneration >= daemonSet.Generation &&
+			status.UpdatedNumberScheduled == status.DesiredNumberScheduled &&
+			status.NumberAvailable == status.DesiredNumberScheduled, nil
+	case "Cluster":
+		cluster := &unstructured.Unstructured{}
+		cluster.SetGroupVersionKind(cnpgClusterGVK)
+		if err := reader.Get(ctx, key, cluster); err != nil {
+			return false, err
+		}
+		phase, _, _ := unstructured.NestedString(cluster.Object, "status", "phase")
+		return phase == cnpgv1.PhaseHealthy, nil
+	case "CronJob":
+		// jobs pick up the new template on their next run, there is no rollout to wait for
+		return true, nil
+	default:
+		return false, fmt.Errorf("Unsupported apiVersion/kind: %s/%s", targetRef.APIVersion, targetRef.Kind)
+	}
+}
+
+func getReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// GetNodePool returns the value of the node pool label in the nodeSelector of the workload targeted by the VPA,
+// empty if it has none
+func GetNodePool(ctx context.Context, kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler, nodePoolLabel string) string {
+	if nodePoolLabel == "" {
+		return ""
+	}
+	reader := kubeClients.Reader
+	targetRef := vpa.Spec.TargetRef
+	key := types.NamespacedName{Namespace: vpa.Namespace, Name: targetRef.Name}
+	var podSpec *corev1.PodSpec
+	switch targetRef.Kind {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := reader.Get(ctx, key, deployment); err != nil {
+			return ""
+		}
+		podSpec = &deployment.Spec.Template.Spec
+	case "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		if err := reader.Get(ctx, key, statefulSet); err != nil {
+			return ""
+		}
+		podSpec = &statefulSet.Spec.Template.Spec
+	case "DaemonSet":
+		daemonSet := &appsv1.DaemonSet{}
+		if err := reader.Get(ctx, key, daemonSet); err != nil {
+			return ""
+		}
+		podSpec = &daemonSet.Spec.Template.Spec
+	case "CronJob":
+		cronJob := &batchv1.CronJob{}
+		if err := reader.Get(ctx, key, cronJob); err != nil {
+			return ""
+		}
+		podSpec = &cronJob.Spec.JobTemplate.Spec.Template.Spec
+	default:
+		return ""
+	}
+	return podSpec.NodeSelector[nodePoolLabel]
+}
@@ -0,0 +1,107 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	"k8s.io/klog/v2"
+)
+
+// statsSummaryTTL is the time a stats summary of a node is reused, the runs of a same schedule sharing the summaries
+// of the nodes instead of querying each kubelet once per workload
+const statsSummaryTTL = time.Minute
+
+type statsSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		Containers []struct {
+			Name   string        `json:"name"`
+			Rootfs *fsStatsUsage `json:"rootfs"`
+			Logs   *fsStatsUsage `json:"logs"`
+		} `json:"containers"`
+	} `json:"pods"`
+}
+
+type fsStatsUsage struct {
+	UsedBytes *int64 `json:"usedBytes"`
+}
+
+func (u *fsStatsUsage) usedBytes() int64 {
+	if u == nil || u.UsedBytes == nil {
+		return 0
+	}
+	return *u.UsedBytes
+}
+
+// cachedStatsSummary is the stats summary of a node, nil when it was unavailable so that the failures are not retried
+// before statsSummaryTTL either. done is closed once it is fetched
+type cachedStatsSummary struct {
+	summary   *statsSummary
+	fetchedAt time.Time
+	done      chan struct{}
+}
+
+var (
+	statsSummaries     = map[string]*cachedStatsSummary{}
+	statsSummariesLock sync.Mutex
+)
+
+// getStatsSummary returns the stats summary of the kubelet of the node, cached per cluster and node for
+// statsSummaryTTL, nil when unavailable. A single request is sent per node, the concurrent callers waiting for it
+// without holding the cache of the other nodes
+func getStatsSummary(kubeClients *client.KubeClients, nodeName string) *statsSummary {
+	key := kubeClients.GetKey(nodeName)
+	now := time.Now()
+
+	statsSummariesLock.Lock()
+	for cachedKey, cached := range statsSummaries {
+		if isDone(cached.done) && now.Sub(cached.fetchedAt) >= statsSummaryTTL {
+			delete(statsSummaries, cachedKey)
+		}
+	}
+	cached, ok := statsSummaries[key]
+	if ok {
+		statsSummariesLock.Unlock()
+		<-cached.done
+		return cached.summary
+	}
+	cached = &cachedStatsSummary{done: make(chan struct{})}
+	statsSummaries[key] = cached
+	statsSummariesLock.Unlock()
+
+	cached.summary = fetchStatsSummary(kubeClients, nodeName)
+	cached.fetchedAt = time.Now()
+	close(cached.done)
+	return cached.summary
+}
+
+func isDone(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+func fetchStatsSummary(kubeClients *client.KubeClients, nodeName string) *statsSummary {
+	data, err := kubeClients.Clientset.CoreV1().RESTClient().Get().
+		AbsPath("/api/v1/nodes", nodeName, "proxy/stats/summary").
+		DoRaw(context.TODO())
+	if err != nil {
+		klog.V(2).Infof("Stats summary unavailable for node %s: %s", nodeName, err.Error())
+		return nil
+	}
+	summary := &statsSummary{}
+	if err := json.Unmarshal(data, summary); err != nil {
+		klog.Warningf("Error parsing stats summary of node %s: %s", nodeName, err.Error())
+		return nil
+	}
+	return summary
+}
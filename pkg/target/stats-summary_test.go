@@ -0,0 +1,93 @@
+package target
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func newStatsSummaryClients(t *testing.T, handler http.HandlerFunc) *client.KubeClients {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("Error creating clientset: %s", err.Error())
+	}
+	statsSummariesLock.Lock()
+	statsSummaries = map[string]*cachedStatsSummary{}
+	statsSummariesLock.Unlock()
+	return &client.KubeClients{Clientset: clientset, Cluster: t.Name()}
+}
+
+func TestGetStatsSummary(t *testing.T) {
+	t.Run("single request for concurrent callers", func(t *testing.T) {
+		var requests int32
+		kubeClients := newStatsSummaryClients(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"pods":[{"podRef":{"name":"app","namespace":"default"}}]}`))
+		})
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if summary := getStatsSummary(kubeClients, "node-1"); summary == nil || len(summary.Pods) != 1 {
+					t.Errorf("expected the summary of node-1, got %v", summary)
+				}
+			}()
+		}
+		wg.Wait()
+		if atomic.LoadInt32(&requests) != 1 {
+			t.Errorf("expected a single request, got %d", requests)
+		}
+	})
+
+	t.Run("other nodes not held by a slow node", func(t *testing.T) {
+		release := make(chan struct{})
+		kubeClients := newStatsSummaryClients(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/v1/nodes/slow/proxy/stats/summary" {
+				<-release
+			}
+			_, _ = w.Write([]byte(`{"pods":[]}`))
+		})
+		defer close(release)
+		go getStatsSummary(kubeClients, "slow")
+		time.Sleep(50 * time.Millisecond)
+
+		done := make(chan struct{})
+		go func() {
+			getStatsSummary(kubeClients, "fast")
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Errorf("expected the summary of another node to be fetched during a slow request")
+		}
+	})
+
+	t.Run("errors cached", func(t *testing.T) {
+		var requests int32
+		kubeClients := newStatsSummaryClients(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		for i := 0; i < 3; i++ {
+			if summary := getStatsSummary(kubeClients, "node-1"); summary != nil {
+				t.Errorf("expected no summary, got %v", summary)
+			}
+		}
+		if atomic.LoadInt32(&requests) != 1 {
+			t.Errorf("expected the failure to be cached, got %d requests", requests)
+		}
+	})
+}
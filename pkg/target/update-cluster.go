@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
 	"github.com/SocialGouv/oblik/pkg/logical"
 	"github.com/SocialGouv/oblik/pkg/reporting"
@@ -13,13 +14,13 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
-	"k8s.io/client-go/dynamic"
 )
 
-func UpdateCluster(dynamicClient *dynamic.DynamicClient, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
+func UpdateCluster(kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
 	namespace := vpa.Namespace
 	targetRef := vpa.Spec.TargetRef
 	clusterName := targetRef.Name
@@ -30,7 +31,9 @@ func UpdateCluster(dynamicClient *dynamic.DynamicClient, vpa *vpa.VerticalPodAut
 		Resource: "clusters",
 	}
 
-	clusterResource, err := dynamicClient.Resource(gvr).Namespace(namespace).Get(context.TODO(), clusterName, metav1.GetOptions{})
+	clusterResource := &unstructured.Unstructured{}
+	clusterResource.SetGroupVersionKind(cnpgClusterGVK)
+	err := kubeClients.Reader.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: clusterName}, clusterResource)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return nil, err
@@ -49,13 +52,21 @@ func UpdateCluster(dynamicClient *dynamic.DynamicClient, vpa *vpa.VerticalPodAut
 		return nil, fmt.Errorf("Error unmarshalling cluster: %s", err.Error())
 	}
 
+	conflict, skipped := checkGitOpsConflict(&cluster, "postgresql.cnpg.io/v1", "Cluster", scfg)
+	if skipped {
+		return getGitOpsSkippedResult(conflict, scfg), nil
+	}
+
 	containers := []corev1.Container{
 		corev1.Container{
 			Name:      "postgres",
 			Resources: cluster.Spec.Resources,
 		},
 	}
-	update := logical.UpdateContainerResources(containers, vpa, scfg)
+	instances := int32(cluster.Spec.Instances)
+	workload := &logical.Workload{Replicas: &instances}
+	update := logical.UpdateContainerResources(containers, vpa, scfg, workload)
+	update.GitOpsConflict = conflict
 	cluster.Spec.Resources = containers[0].Resources
 
 	updatedClusterJSON, err := json.Marshal(cluster)
@@ -69,7 +80,7 @@ func UpdateCluster(dynamicClient *dynamic.DynamicClient, vpa *vpa.VerticalPodAut
 	}
 
 	if !scfg.GetDryRun() {
-		_, err = dynamicClient.Resource(gvr).Namespace(namespace).Patch(context.TODO(), clusterName, types.MergePatchType, patchBytes, metav1.PatchOptions{
+		_, err = kubeClients.DynamicClient.Resource(gvr).Namespace(namespace).Patch(context.TODO(), clusterName, types.MergePatchType, patchBytes, metav1.PatchOptions{
 			FieldManager: FieldManager,
 		})
 		if err != nil {
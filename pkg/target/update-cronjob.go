@@ -4,22 +4,25 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
 	"github.com/SocialGouv/oblik/pkg/logical"
 	"github.com/SocialGouv/oblik/pkg/reporting"
+	batchv1 "k8s.io/api/batch/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
-func UpdateCronJob(clientset *kubernetes.Clientset, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
+func UpdateCronJob(kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
+	clientset := kubeClients.Clientset
 	namespace := vpa.Namespace
 	targetRef := vpa.Spec.TargetRef
 	cronjobName := targetRef.Name
 
-	cronjob, err := clientset.BatchV1().CronJobs(namespace).Get(context.TODO(), cronjobName, metav1.GetOptions{})
+	cronjob := &batchv1.CronJob{}
+	podResources, err := getWorkload(kubeClients, namespace, "CronJob", cronjobName, cronjob, scfg.IsPodResourcesEnabled())
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return nil, err
@@ -27,23 +30,32 @@ func UpdateCronJob(clientset *kubernetes.Clientset, vpa *vpa.VerticalPodAutoscal
 		return nil, fmt.Errorf("Error fetching cronjob: %s", err.Error())
 	}
 
-	update := logical.UpdateContainerResources(cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers, vpa, scfg)
+	conflict, skipped := checkGitOpsConflict(cronjob, "batch/v1", "CronJob", scfg)
+	if skipped {
+		return getGitOpsSkippedResult(conflict, scfg), nil
+	}
+
+	workload := GetWorkload(kubeClients, namespace, "CronJob", cronjobName, nil, nil, scfg)
+	workload.PodResources = podResources
+	update := logical.UpdateContainerResources(cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers, vpa, scfg, workload)
+	update.GitOpsConflict = conflict
+
+	SetAppliedResources(cronjob, cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers)
 
-	patchData, err := createPatch(cronjob, "batch/v1", "CronJob")
+	patchData, err := createPatch(cronjob, workload.PodResources, "batch/v1", "CronJob")
 	if err != nil {
 		return nil, fmt.Errorf("Error creating patch: %s", err.Error())
 	}
 
 	if !scfg.GetDryRun() {
-		force := true
-		_, err = clientset.BatchV1().CronJobs(namespace).Patch(context.TODO(), cronjobName, types.ApplyPatchType, patchData, metav1.PatchOptions{
-			FieldManager: FieldManager,
-			Force:        &force, // Force the apply to take ownership of the fields
+		err = applyPatch(context.TODO(), "CronJob", patchData, update.Changes, func(ctx context.Context, patchType types.PatchType, data []byte, opts metav1.PatchOptions) error {
+			_, err := clientset.BatchV1().CronJobs(namespace).Patch(ctx, cronjobName, patchType, data, opts)
+			return err
 		})
 		if err != nil {
 			update.Type = reporting.ResultTypeFailed
 			update.Error = err
-			return nil, fmt.Errorf("Error applying patch to deployment: %s", err.Error())
+			return nil, fmt.Errorf("Error applying patch to cronjob: %s", err.Error())
 		}
 		update.Type = reporting.ResultTypeSuccess
 	} else {
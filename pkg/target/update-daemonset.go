@@ -4,21 +4,24 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
 	"github.com/SocialGouv/oblik/pkg/logical"
 	"github.com/SocialGouv/oblik/pkg/reporting"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
-func UpdateDaemonSet(clientset *kubernetes.Clientset, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
+func UpdateDaemonSet(kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
+	clientset := kubeClients.Clientset
 	namespace := vpa.Namespace
 	targetRef := vpa.Spec.TargetRef
 	daemonsetName := targetRef.Name
-	daemonset, err := clientset.AppsV1().DaemonSets(namespace).Get(context.TODO(), daemonsetName, metav1.GetOptions{})
+	daemonset := &appsv1.DaemonSet{}
+	podResources, err := getWorkload(kubeClients, namespace, "DaemonSet", daemonsetName, daemonset, scfg.IsPodResourcesEnabled())
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return nil, err
@@ -26,18 +29,27 @@ func UpdateDaemonSet(clientset *kubernetes.Clientset, vpa *vpa.VerticalPodAutosc
 		return nil, fmt.Errorf("Error fetching daemonset: %s", err.Error())
 	}
 
-	update := logical.UpdateContainerResources(daemonset.Spec.Template.Spec.Containers, vpa, scfg)
+	conflict, skipped := checkGitOpsConflict(daemonset, "apps/v1", "DaemonSet", scfg)
+	if skipped {
+		return getGitOpsSkippedResult(conflict, scfg), nil
+	}
+
+	workload := GetWorkload(kubeClients, namespace, "DaemonSet", daemonsetName, nil, daemonset.Spec.Selector, scfg)
+	workload.PodResources = podResources
+	update := logical.UpdateContainerResources(daemonset.Spec.Template.Spec.Containers, vpa, scfg, workload)
+	update.GitOpsConflict = conflict
+
+	SetAppliedResources(daemonset, daemonset.Spec.Template.Spec.Containers)
 
-	patchData, err := createPatch(daemonset, "apps/v1", "DaemonSet")
+	patchData, err := createPatch(daemonset, workload.PodResources, "apps/v1", "DaemonSet")
 	if err != nil {
 		return nil, fmt.Errorf("Error creating patch: %s", err.Error())
 	}
 
 	if !scfg.GetDryRun() {
-		force := true
-		_, err = clientset.AppsV1().DaemonSets(namespace).Patch(context.TODO(), daemonsetName, types.ApplyPatchType, patchData, metav1.PatchOptions{
-			FieldManager: FieldManager,
-			Force:        &force, // Force the apply to take ownership of the fields
+		err = applyPatch(context.TODO(), "DaemonSet", patchData, update.Changes, func(ctx context.Context, patchType types.PatchType, data []byte, opts metav1.PatchOptions) error {
+			_, err := clientset.AppsV1().DaemonSets(namespace).Patch(ctx, daemonsetName, patchType, data, opts)
+			return err
 		})
 		if err != nil {
 			update.Type = reporting.ResultTypeFailed
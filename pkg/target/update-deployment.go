@@ -4,21 +4,24 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
 	"github.com/SocialGouv/oblik/pkg/logical"
 	"github.com/SocialGouv/oblik/pkg/reporting"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
-func UpdateDeployment(clientset *kubernetes.Clientset, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
+func UpdateDeployment(kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
+	clientset := kubeClients.Clientset
 	namespace := vpa.Namespace
 	targetRef := vpa.Spec.TargetRef
 	deploymentName := targetRef.Name
-	deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	deployment := &appsv1.Deployment{}
+	podResources, err := getWorkload(kubeClients, namespace, "Deployment", deploymentName, deployment, scfg.IsPodResourcesEnabled())
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return nil, err
@@ -26,18 +29,27 @@ func UpdateDeployment(clientset *kubernetes.Clientset, vpa *vpa.VerticalPodAutos
 		return nil, fmt.Errorf("Error fetching deployment: %s", err.Error())
 	}
 
-	update := logical.UpdateContainerResources(deployment.Spec.Template.Spec.Containers, vpa, scfg)
+	conflict, skipped := checkGitOpsConflict(deployment, "apps/v1", "Deployment", scfg)
+	if skipped {
+		return getGitOpsSkippedResult(conflict, scfg), nil
+	}
+
+	workload := GetWorkload(kubeClients, namespace, "Deployment", deploymentName, deployment.Spec.Replicas, deployment.Spec.Selector, scfg)
+	workload.PodResources = podResources
+	update := logical.UpdateContainerResources(deployment.Spec.Template.Spec.Containers, vpa, scfg, workload)
+	update.GitOpsConflict = conflict
+
+	SetAppliedResources(deployment, deployment.Spec.Template.Spec.Containers)
 
-	patchData, err := createPatch(deployment, "apps/v1", "Deployment")
+	patchData, err := createPatch(deployment, workload.PodResources, "apps/v1", "Deployment")
 	if err != nil {
 		return nil, fmt.Errorf("Error creating patch: %s", err.Error())
 	}
 
 	if !scfg.GetDryRun() {
-		force := true
-		_, err = clientset.AppsV1().Deployments(namespace).Patch(context.TODO(), deploymentName, types.ApplyPatchType, patchData, metav1.PatchOptions{
-			FieldManager: FieldManager,
-			Force:        &force, // Force the apply to take ownership of the fields
+		err = applyPatch(context.TODO(), "Deployment", patchData, update.Changes, func(ctx context.Context, patchType types.PatchType, data []byte, opts metav1.PatchOptions) error {
+			_, err := clientset.AppsV1().Deployments(namespace).Patch(ctx, deploymentName, patchType, data, opts)
+			return err
 		})
 		if err != nil {
 			update.Type = reporting.ResultTypeFailed
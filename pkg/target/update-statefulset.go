@@ -4,22 +4,25 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
 	"github.com/SocialGouv/oblik/pkg/logical"
 	"github.com/SocialGouv/oblik/pkg/reporting"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
-func UpdateStatefulSet(clientset *kubernetes.Clientset, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
+func UpdateStatefulSet(kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) (*reporting.UpdateResult, error) {
+	clientset := kubeClients.Clientset
 	namespace := vpa.Namespace
 	targetRef := vpa.Spec.TargetRef
 	statefulSetName := targetRef.Name
 
-	statefulSet, err := clientset.AppsV1().StatefulSets(namespace).Get(context.TODO(), statefulSetName, metav1.GetOptions{})
+	statefulSet := &appsv1.StatefulSet{}
+	podResources, err := getWorkload(kubeClients, namespace, "StatefulSet", statefulSetName, statefulSet, scfg.IsPodResourcesEnabled())
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return nil, err
@@ -27,18 +30,27 @@ func UpdateStatefulSet(clientset *kubernetes.Clientset, vpa *vpa.VerticalPodAuto
 		return nil, fmt.Errorf("Error fetching stateful set: %s", err.Error())
 	}
 
-	update := logical.UpdateContainerResources(statefulSet.Spec.Template.Spec.Containers, vpa, scfg)
+	conflict, skipped := checkGitOpsConflict(statefulSet, "apps/v1", "StatefulSet", scfg)
+	if skipped {
+		return getGitOpsSkippedResult(conflict, scfg), nil
+	}
+
+	workload := GetWorkload(kubeClients, namespace, "StatefulSet", statefulSetName, statefulSet.Spec.Replicas, statefulSet.Spec.Selector, scfg)
+	workload.PodResources = podResources
+	update := logical.UpdateContainerResources(statefulSet.Spec.Template.Spec.Containers, vpa, scfg, workload)
+	update.GitOpsConflict = conflict
+
+	SetAppliedResources(statefulSet, statefulSet.Spec.Template.Spec.Containers)
 
-	patchData, err := createPatch(statefulSet, "apps/v1", "StatefulSet")
+	patchData, err := createPatch(statefulSet, workload.PodResources, "apps/v1", "StatefulSet")
 	if err != nil {
 		return nil, fmt.Errorf("Error creating patch: %s", err.Error())
 	}
 
 	if !scfg.GetDryRun() {
-		force := true
-		_, err = clientset.AppsV1().StatefulSets(namespace).Patch(context.TODO(), statefulSetName, types.ApplyPatchType, patchData, metav1.PatchOptions{
-			FieldManager: FieldManager,
-			Force:        &force, // Force the apply to take ownership of the fields
+		err = applyPatch(context.TODO(), "StatefulSet", patchData, update.Changes, func(ctx context.Context, patchType types.PatchType, data []byte, opts metav1.PatchOptions) error {
+			_, err := clientset.AppsV1().StatefulSets(namespace).Patch(ctx, statefulSetName, patchType, data, opts)
+			return err
 		})
 		if err != nil {
 			update.Type = reporting.ResultTypeFailed
@@ -0,0 +1,157 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/logical"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetWorkload returns the state of the workload used to size its containers, including the HPAs targeting it
+// and, when a pod selector is given, the current usage of its containers
+func GetWorkload(kubeClients *client.KubeClients, namespace string, kind string, name string, replicas *int32, selector *metav1.LabelSelector, scfg *config.StrategyConfig) *logical.Workload {
+	clientset := kubeClients.Clientset
+	workload := &logical.Workload{
+		Replicas: replicas,
+		HPAs:     getHPAs(kubeClients.Reader, namespace, kind, name),
+	}
+	if selector != nil {
+		workload.Usage = getUsage(clientset, namespace, selector)
+		if scfg.IsEphemeralStorageEnabled() {
+			workload.EphemeralStorage = getEphemeralStorageUsage(kubeClients, namespace, selector)
+		}
+	}
+	if scfg.IsLimitCPURemoveEnabled() {
+		workload.LimitRanges = getLimitRanges(kubeClients.Reader, namespace)
+	}
+	return workload
+}
+
+func getHPAs(reader ctrlclient.Reader, namespace string, kind string, name string) []autoscalingv2.HorizontalPodAutoscaler {
+	hpaList := &autoscalingv2.HorizontalPodAutoscalerList{}
+	err := reader.List(context.TODO(), hpaList, ctrlclient.InNamespace(namespace))
+	if err != nil {
+		klog.Warningf("Error listing HPAs in namespace %s: %s", namespace, err.Error())
+		return nil
+	}
+	var hpas []autoscalingv2.HorizontalPodAutoscaler
+	for _, hpa := range hpaList.Items {
+		if hpa.Spec.ScaleTargetRef.Kind == kind && hpa.Spec.ScaleTargetRef.Name == name {
+			hpas = append(hpas, hpa)
+		}
+	}
+	return hpas
+}
+
+func getLimitRanges(reader ctrlclient.Reader, namespace string) []corev1.LimitRange {
+	limitRangeList := &corev1.LimitRangeList{}
+	err := reader.List(context.TODO(), limitRangeList, ctrlclient.InNamespace(namespace))
+	if err != nil {
+		klog.Warningf("Error listing LimitRanges in namespace %s: %s", namespace, err.Error())
+		return nil
+	}
+	return limitRangeList.Items
+}
+
+type podMetricsList struct {
+	Items []struct {
+		Containers []struct {
+			Name  string              `json:"name"`
+			Usage corev1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// getUsage returns the usage of each container summed across the pods matching the selector, from the metrics API
+func getUsage(clientset kubernetes.Interface, namespace string, selector *metav1.LabelSelector) map[string]corev1.ResourceList {
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		klog.Warningf("Error parsing pod selector: %s", err.Error())
+		return nil
+	}
+	data, err := clientset.Discovery().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", namespace, "pods").
+		Param("labelSelector", labelSelector.String()).
+		DoRaw(context.TODO())
+	if err != nil {
+		klog.V(2).Infof("Metrics unavailable for pods in namespace %s: %s", namespace, err.Error())
+		return nil
+	}
+	metricsList := podMetricsList{}
+	if err := json.Unmarshal(data, &metricsList); err != nil {
+		klog.Warningf("Error parsing pod metrics: %s", err.Error())
+		return nil
+	}
+
+	usage := map[string]corev1.ResourceList{}
+	for _, pod := range metricsList.Items {
+		for _, container := range pod.Containers {
+			total, ok := usage[container.Name]
+			if !ok {
+				total = corev1.ResourceList{}
+				usage[container.Name] = total
+			}
+			for resourceName, quantity := range container.Usage {
+				sum := total[resourceName]
+				sum.Add(quantity)
+				total[resourceName] = sum
+			}
+		}
+	}
+	return usage
+}
+
+// getEphemeralStorageUsage returns the highest ephemeral-storage usage (writable layer and logs) of each container
+// among the pods matching the selector, listed from the shared cache, from the stats summary of the kubelets running them
+func getEphemeralStorageUsage(kubeClients *client.KubeClients, namespace string, selector *metav1.LabelSelector) map[string]resource.Quantity {
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		klog.Warningf("Error parsing pod selector: %s", err.Error())
+		return nil
+	}
+	pods := &corev1.PodList{}
+	err = kubeClients.Reader.List(context.TODO(), pods, ctrlclient.InNamespace(namespace), ctrlclient.MatchingLabelsSelector{Selector: labelSelector})
+	if err != nil {
+		klog.Warningf("Error listing pods in namespace %s: %s", namespace, err.Error())
+		return nil
+	}
+	podsByNode := map[string]map[string]bool{}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		if podsByNode[pod.Spec.NodeName] == nil {
+			podsByNode[pod.Spec.NodeName] = map[string]bool{}
+		}
+		podsByNode[pod.Spec.NodeName][pod.Name] = true
+	}
+
+	usage := map[string]resource.Quantity{}
+	for nodeName, podNames := range podsByNode {
+		summary := getStatsSummary(kubeClients, nodeName)
+		if summary == nil {
+			continue
+		}
+		for _, pod := range summary.Pods {
+			if pod.PodRef.Namespace != namespace || !podNames[pod.PodRef.Name] {
+				continue
+			}
+			for _, container := range pod.Containers {
+				used := container.Rootfs.usedBytes() + container.Logs.usedBytes()
+				if current, ok := usage[container.Name]; !ok || used > current.Value() {
+					usage[container.Name] = *resource.NewQuantity(used, resource.BinarySI)
+				}
+			}
+		}
+	}
+	return usage
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"time"
+
+	cron "github.com/robfig/cron/v3"
+)
+
+const cronStarBit = 1 << 63
+
+// maxDSTShift bounds the clock shift searched around daylight saving time transitions
+const maxDSTShift = 3 * time.Hour
+
+// maxTransitionScanDays bounds the search of a daylight saving time transition between two runs
+const maxTransitionScanDays = 366
+
+// ParseCronSchedule parses a standard cron expression, optionally prefixed with CRON_TZ=<IANA zone>,
+// into a schedule handling daylight saving time transitions the way vixie cron does:
+// runs falling in a skipped hour happen right after the transition, runs in a repeated hour happen once,
+// schedules with a wildcard hour are left unchanged
+func ParseCronSchedule(spec string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, err
+	}
+	specSchedule, ok := schedule.(*cron.SpecSchedule)
+	if !ok {
+		// @every schedules are not bound to wall time
+		return schedule, nil
+	}
+	return &dstSchedule{spec: specSchedule}, nil
+}
+
+type dstSchedule struct {
+	spec *cron.SpecSchedule
+}
+
+func (s *dstSchedule) Next(t time.Time) time.Time {
+	next := s.spec.Next(t)
+	if next.IsZero() || s.spec.Hour&cronStarBit > 0 {
+		return next
+	}
+
+	if transition, ok := s.skippedRun(t, next); ok {
+		return transition
+	}
+
+	for s.isRepeatedRun(next) {
+		next = s.spec.Next(next)
+		if next.IsZero() {
+			break
+		}
+	}
+	return next
+}
+
+// skippedRun returns the first forward transition between t and next whose skipped wall times match the schedule
+func (s *dstSchedule) skippedRun(t, next time.Time) (time.Time, bool) {
+	loc := s.spec.Location
+	from := t
+	for i := 0; i < maxTransitionScanDays && from.Before(next); i++ {
+		to := from.Add(24 * time.Hour)
+		if to.After(next) {
+			to = next
+		}
+		_, fromOffset := from.In(loc).Zone()
+		_, toOffset := to.In(loc).Zone()
+		if toOffset > fromOffset {
+			transition := findTransition(from, to, loc)
+			if transition.After(t) {
+				gap := time.Duration(toOffset-fromOffset) * time.Second
+				wall := transition.In(time.FixedZone("", fromOffset))
+				for shift := time.Duration(0); shift < gap; shift += time.Minute {
+					if s.matches(wall.Add(shift)) {
+						return transition, true
+					}
+				}
+			}
+		}
+		from = to
+	}
+	return time.Time{}, false
+}
+
+// isRepeatedRun tells if the wall time of the run already happened before a backward transition
+func (s *dstSchedule) isRepeatedRun(next time.Time) bool {
+	loc := s.spec.Location
+	_, offset := next.In(loc).Zone()
+	_, earlierOffset := next.Add(-maxDSTShift).In(loc).Zone()
+	if earlierOffset <= offset {
+		return false
+	}
+	earlier := next.Add(-time.Duration(earlierOffset-offset) * time.Second)
+	if _, o := earlier.In(loc).Zone(); o != earlierOffset {
+		return false
+	}
+	return s.spec.Next(earlier.Add(-time.Second)).Equal(earlier)
+}
+
+func (s *dstSchedule) matches(wall time.Time) bool {
+	return 1<<uint(wall.Second())&s.spec.Second > 0 &&
+		1<<uint(wall.Minute())&s.spec.Minute > 0 &&
+		1<<uint(wall.Hour())&s.spec.Hour > 0 &&
+		1<<uint(wall.Month())&s.spec.Month > 0 &&
+		s.dayMatches(wall)
+}
+
+func (s *dstSchedule) dayMatches(wall time.Time) bool {
+	domMatch := 1<<uint(wall.Day())&s.spec.Dom > 0
+	dowMatch := 1<<uint(wall.Weekday())&s.spec.Dow > 0
+	if s.spec.Dom&cronStarBit > 0 || s.spec.Dow&cronStarBit > 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// findTransition returns the first instant in (from, to] having the offset of to
+func findTransition(from, to time.Time, loc *time.Location) time.Time {
+	_, toOffset := to.In(loc).Zone()
+	for to.Sub(from) > time.Second {
+		middle := from.Add(to.Sub(from) / 2).Truncate(time.Second)
+		if !middle.After(from) {
+			break
+		}
+		if _, offset := middle.In(loc).Zone(); offset == toOffset {
+			to = middle
+		} else {
+			from = middle
+		}
+	}
+	return to
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		from string
+		runs []string
+	}{
+		{
+			name: "spring forward runs the skipped hour right after the transition",
+			spec: "CRON_TZ=Europe/Paris 30 2 * * *",
+			from: "2025-03-29T03:00:00+01:00",
+			runs: []string{"2025-03-30T03:00:00+02:00", "2025-03-31T02:30:00+02:00"},
+		},
+		{
+			name: "spring forward leaves the runs outside of the skipped hour",
+			spec: "CRON_TZ=Europe/Paris 30 3 * * *",
+			from: "2025-03-29T04:00:00+01:00",
+			runs: []string{"2025-03-30T03:30:00+02:00", "2025-03-31T03:30:00+02:00"},
+		},
+		{
+			name: "spring forward skipped hour not matching the day",
+			spec: "CRON_TZ=Europe/Paris 30 2 * * 1",
+			from: "2025-03-29T00:00:00+01:00",
+			runs: []string{"2025-03-31T02:30:00+02:00"},
+		},
+		{
+			name: "fall back runs the repeated hour once",
+			spec: "CRON_TZ=Europe/Paris 30 2 * * *",
+			from: "2025-10-26T00:00:00+02:00",
+			runs: []string{"2025-10-26T02:30:00+02:00", "2025-10-27T02:30:00+01:00"},
+		},
+		{
+			name: "fall back leaves the runs outside of the repeated hour",
+			spec: "CRON_TZ=Europe/Paris 0 4 * * *",
+			from: "2025-10-26T00:00:00+02:00",
+			runs: []string{"2025-10-26T04:00:00+01:00", "2025-10-27T04:00:00+01:00"},
+		},
+		{
+			name: "wildcard hour is left unchanged on fall back",
+			spec: "CRON_TZ=Europe/Paris 30 * * * *",
+			from: "2025-10-26T02:00:00+02:00",
+			runs: []string{"2025-10-26T02:30:00+02:00", "2025-10-26T02:30:00+01:00", "2025-10-26T03:30:00+01:00"},
+		},
+		{
+			name: "wildcard hour is left unchanged on spring forward",
+			spec: "CRON_TZ=Europe/Paris 30 * * * *",
+			from: "2025-03-30T01:00:00+01:00",
+			runs: []string{"2025-03-30T01:30:00+01:00", "2025-03-30T03:30:00+02:00"},
+		},
+		{
+			name: "non DST zone",
+			spec: "CRON_TZ=Asia/Tokyo 30 2 * * *",
+			from: "2025-03-29T03:00:00+09:00",
+			runs: []string{"2025-03-30T02:30:00+09:00", "2025-03-31T02:30:00+09:00"},
+		},
+		{
+			name: "UTC",
+			spec: "CRON_TZ=UTC 0 20 * * 1-5",
+			from: "2025-10-24T21:00:00Z",
+			runs: []string{"2025-10-27T20:00:00Z", "2025-10-28T20:00:00Z"},
+		},
+		{
+			name: "every schedule",
+			spec: "@every 1h",
+			from: "2025-10-26T02:00:00+02:00",
+			runs: []string{"2025-10-26T03:00:00+02:00", "2025-10-26T04:00:00+02:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			current := mustParseTime(t, tt.from)
+			for i, run := range tt.runs {
+				current = schedule.Next(current)
+				if expected := mustParseTime(t, run); !current.Equal(expected) {
+					t.Fatalf("run %d: expected %s, got %s", i, expected.Format(time.RFC3339), current.Format(time.RFC3339))
+				}
+			}
+		})
+	}
+}
+
+func TestParseCronScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "0 25 * * *", "CRON_TZ=Nowhere/City 0 2 * * *", "* * *"} {
+		if _, err := ParseCronSchedule(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestFindTransition(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		from, to string
+		expected string
+	}{
+		{name: "spring forward", from: "2025-03-29T12:00:00Z", to: "2025-03-30T12:00:00Z", expected: "2025-03-30T01:00:00Z"},
+		{name: "fall back", from: "2025-10-25T12:00:00Z", to: "2025-10-26T12:00:00Z", expected: "2025-10-26T01:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transition := findTransition(mustParseTime(t, tt.from), mustParseTime(t, tt.to), paris)
+			if expected := mustParseTime(t, tt.expected); !transition.Equal(expected) {
+				t.Errorf("expected %s, got %s", expected.Format(time.RFC3339), transition.UTC().Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("invalid time %s: %s", value, err.Error())
+	}
+	return parsed
+}
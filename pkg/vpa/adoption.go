@@ -0,0 +1,235 @@
+package vpa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/constants"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	"github.com/SocialGouv/oblik/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AdoptedLabel marks a VPA created by the users and adopted by Oblik, its value being the vpa-adoption mode
+const AdoptedLabel = constants.PREFIX + "adopted"
+
+const (
+	adoptedValueAdopt = "adopt"
+	adoptedValueOwn   = "own"
+)
+
+// adoptVPA looks for the VPAs created by the users targeting the workload. With vpa-adoption, the single one in
+// "Off" update mode is adopted instead of the managed VPA, and a VPA applying its recommendations itself is left
+// alone, as well as the workload. It returns whether the workload is handled without the managed VPA
+func adoptVPA(kubeClients *client.KubeClients, obj interface{}) (bool, error) {
+	metadata, namespace, name := utils.GetObjectMetadata(obj)
+	kind := utils.GetKind(obj)
+	scfg := kubeClients.CreateStrategyConfig(obj)
+
+	if scfg.VPAAdoption == config.VPAAdoptionOff {
+		// the VPAs adopted before the adoption was turned off are released, they are in the cache being labelled
+		adopted, err := listTargetingVPAs(kubeClients, namespace, kind, name, false)
+		if err != nil {
+			return false, fmt.Errorf("Error listing VPAs targeting %s/%s: %w", namespace, name, err)
+		}
+		for i := range adopted {
+			if err := releaseVPA(kubeClients, &adopted[i]); err != nil {
+				return false, err
+			}
+		}
+		reporting.ClearVPAConflict(scfg.Key)
+		return false, nil
+	}
+
+	candidates, err := listTargetingVPAs(kubeClients, namespace, kind, name, true)
+	if err != nil {
+		return false, fmt.Errorf("Error listing VPAs targeting %s/%s: %w", namespace, name, err)
+	}
+
+	if len(candidates) == 0 {
+		reporting.ClearVPAConflict(scfg.Key)
+		return false, nil
+	}
+
+	if len(candidates) > 1 {
+		names := make([]string, len(candidates))
+		for i := range candidates {
+			names[i] = candidates[i].Name
+			if err := releaseVPA(kubeClients, &candidates[i]); err != nil {
+				return false, err
+			}
+		}
+		reporting.ReportVPAConflict(scfg.Key, fmt.Sprintf("VPAs %s target the workload, none is adopted", strings.Join(names, ", ")))
+		return false, nil
+	}
+
+	candidate := &candidates[0]
+	if updateMode := getUpdateMode(candidate); updateMode != vpa.UpdateModeOff {
+		reporting.ReportVPAConflict(scfg.Key, fmt.Sprintf("VPA %s applies its recommendations itself with the %s update mode, the workload is left to it", candidate.Name, updateMode))
+		if err := releaseVPA(kubeClients, candidate); err != nil {
+			return false, err
+		}
+		return true, deleteVPAByName(kubeClients, namespace, GenerateVPAName(kind, name))
+	}
+
+	original := candidate.DeepCopy()
+	adoptedValue := adoptedValueAdopt
+	if scfg.VPAAdoption == config.VPAAdoptionOwn {
+		// a VPA controlled by another owner, e.g. a Helm operator, is only adopted, its owner reconciling its spec
+		if controller := getOtherController(candidate, kind, name); controller != nil {
+			reporting.ReportVPAConflict(scfg.Key, fmt.Sprintf("VPA %s is controlled by %s %s, it is adopted without being owned", candidate.Name, controller.Kind, controller.Name))
+		} else {
+			reporting.ClearVPAConflict(scfg.Key)
+			adoptedValue = adoptedValueOwn
+			setOwnerReference(candidate, obj)
+			setOwnedSpec(candidate, obj)
+		}
+	} else {
+		reporting.ClearVPAConflict(scfg.Key)
+	}
+	if adoptedValue == adoptedValueAdopt && candidate.Labels[AdoptedLabel] == adoptedValueOwn {
+		removeOwnerReference(candidate, kind, name)
+	}
+	if candidate.Labels == nil {
+		candidate.Labels = map[string]string{}
+	}
+	candidate.Labels[constants.PREFIX+"enabled"] = "true"
+	candidate.Labels[AdoptedLabel] = adoptedValue
+	candidate.Annotations = mergeOblikAnnotations(candidate.Annotations, utils.GetOblikAnnotations(metadata.GetAnnotations()))
+
+	if !equality.Semantic.DeepEqual(original.ObjectMeta, candidate.ObjectMeta) || !equality.Semantic.DeepEqual(original.Spec, candidate.Spec) {
+		_, err = kubeClients.VpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Update(context.TODO(), candidate, metav1.UpdateOptions{})
+		if err != nil {
+			return false, fmt.Errorf("Error adopting VPA %s for %s/%s: %w", candidate.Name, namespace, name, err)
+		}
+		klog.Infof("Adopted VPA %s for %s/%s (%s)", candidate.Name, namespace, name, adoptedValue)
+	}
+	return true, deleteVPAByName(kubeClients, namespace, GenerateVPAName(kind, name))
+}
+
+// releaseVPA removes the Oblik labels, annotations and owner reference from a VPA adopted by Oblik, leaving its spec
+func releaseVPA(kubeClients *client.KubeClients, vpaResource *vpa.VerticalPodAutoscaler) error {
+	if vpaResource.Labels[AdoptedLabel] == "" {
+		return nil
+	}
+	if targetRef := vpaResource.Spec.TargetRef; targetRef != nil && vpaResource.Labels[AdoptedLabel] == adoptedValueOwn {
+		removeOwnerReference(vpaResource, targetRef.Kind, targetRef.Name)
+	}
+	delete(vpaResource.Labels, AdoptedLabel)
+	delete(vpaResource.Labels, constants.PREFIX+"enabled")
+	vpaResource.Annotations = mergeOblikAnnotations(vpaResource.Annotations, nil)
+	_, err := kubeClients.VpaClientset.AutoscalingV1().VerticalPodAutoscalers(vpaResource.Namespace).Update(context.TODO(), vpaResource, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("Error releasing VPA %s/%s: %w", vpaResource.Namespace, vpaResource.Name, err)
+	}
+	klog.Infof("Released VPA %s/%s", vpaResource.Namespace, vpaResource.Name)
+	return nil
+}
+
+// releaseAdoptedVPAs releases the VPAs adopted for a deleted workload, deleting the owned ones
+func releaseAdoptedVPAs(kubeClients *client.KubeClients, namespace, kind, name string) error {
+	adopted, err := listTargetingVPAs(kubeClients, namespace, kind, name, false)
+	if err != nil {
+		return fmt.Errorf("Error listing VPAs targeting %s/%s: %w", namespace, name, err)
+	}
+	for i := range adopted {
+		switch adopted[i].Labels[AdoptedLabel] {
+		case adoptedValueOwn:
+			err = deleteVPAByName(kubeClients, namespace, adopted[i].Name)
+		case adoptedValueAdopt:
+			err = releaseVPA(kubeClients, &adopted[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listTargetingVPAs lists the VPAs targeting the workload, except the managed one. Only the VPAs labelled by Oblik,
+// among which the adopted ones, are in the shared cache: with all, the VPAs of the users are also listed, from the
+// cache of all the VPAs indexed by their target
+func listTargetingVPAs(kubeClients *client.KubeClients, namespace, kind, name string, all bool) ([]vpa.VerticalPodAutoscaler, error) {
+	vpaList := &vpa.VerticalPodAutoscalerList{}
+	if all {
+		items, err := getTargetingVPAs(kubeClients, namespace, kind, name)
+		if err != nil {
+			return nil, err
+		}
+		vpaList.Items = items
+	} else if err := kubeClients.Reader.List(context.TODO(), vpaList, ctrlclient.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	managedName := GenerateVPAName(kind, name)
+	vpas := []vpa.VerticalPodAutoscaler{}
+	for _, vpaResource := range vpaList.Items {
+		targetRef := vpaResource.Spec.TargetRef
+		if vpaResource.Name == managedName || targetRef == nil || targetRef.Kind != kind || targetRef.Name != name {
+			continue
+		}
+		if !all && vpaResource.Labels[AdoptedLabel] == "" {
+			continue
+		}
+		vpas = append(vpas, vpaResource)
+	}
+	return vpas, nil
+}
+
+// FindVPA returns the VPA Oblik uses for the workload, the managed one or else the adopted one
+func FindVPA(kubeClients *client.KubeClients, namespace, kind, name string) (*vpa.VerticalPodAutoscaler, error) {
+	vpaResource := &vpa.VerticalPodAutoscaler{}
+	err := kubeClients.Reader.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: GenerateVPAName(kind, name)}, vpaResource)
+	if err == nil || !errors.IsNotFound(err) {
+		return vpaResource, err
+	}
+	adopted, listErr := listTargetingVPAs(kubeClients, namespace, kind, name, false)
+	if listErr != nil {
+		return nil, listErr
+	}
+	if len(adopted) > 0 {
+		return &adopted[0], nil
+	}
+	return nil, err
+}
+
+// removeOwnerReference removes the reference to the workload set on an owned VPA
+func removeOwnerReference(vpaResource *vpa.VerticalPodAutoscaler, kind, name string) {
+	ownerReferences := []metav1.OwnerReference{}
+	for _, ownerReference := range vpaResource.OwnerReferences {
+		if ownerReference.Kind != kind || ownerReference.Name != name {
+			ownerReferences = append(ownerReferences, ownerReference)
+		}
+	}
+	vpaResource.OwnerReferences = ownerReferences
+}
+
+// getUpdateMode returns the update mode of the VPA, "Auto" by default
+func getUpdateMode(vpaResource *vpa.VerticalPodAutoscaler) vpa.UpdateMode {
+	if vpaResource.Spec.UpdatePolicy == nil || vpaResource.Spec.UpdatePolicy.UpdateMode == nil {
+		return vpa.UpdateModeAuto
+	}
+	return *vpaResource.Spec.UpdatePolicy.UpdateMode
+}
+
+// mergeOblikAnnotations replaces the Oblik annotations, keeping the others set by the users
+func mergeOblikAnnotations(annotations map[string]string, oblikAnnotations map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range annotations {
+		if !strings.HasPrefix(key, constants.PREFIX) {
+			merged[key] = value
+		}
+	}
+	for key, value := range oblikAnnotations {
+		merged[key] = value
+	}
+	return merged
+}
@@ -6,16 +6,16 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
 	"github.com/SocialGouv/oblik/pkg/constants"
 	"github.com/SocialGouv/oblik/pkg/utils"
 	autoscaling "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
-	vpaclientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 )
 
@@ -29,28 +29,41 @@ func GenerateVPAName(kind, name string) string {
 	return vpaName
 }
 
-func AddVPA(clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, vpaClientset *vpaclientset.Clientset, obj interface{}) {
+// ReconcileVPA creates or updates the VPA of an enabled workload, unless a VPA created by the users is adopted instead
+func ReconcileVPA(kubeClients *client.KubeClients, obj interface{}) error {
 	metadata, namespace, name := utils.GetObjectMetadata(obj)
 	if metadata == nil {
-		klog.Errorf("Error getting metadata for object")
-		return
+		return fmt.Errorf("Error getting metadata for object")
+	}
+	if metadata.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	adopted, err := adoptVPA(kubeClients, obj)
+	if err != nil || adopted {
+		return err
 	}
 
 	kind := utils.GetKind(obj)
 	vpaName := GenerateVPAName(kind, name)
 
-	// Check if VPA already exists
-	_, err := vpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Get(context.TODO(), vpaName, metav1.GetOptions{})
-	if err == nil {
-		// VPA exists, update it
-		UpdateVPA(clientset, dynamicClient, vpaClientset, obj)
-		return
-	} else if !strings.Contains(err.Error(), "not found") {
-		klog.Errorf("Error checking VPA existence for %s/%s: %v", namespace, name, err)
-		return
+	vpa := &vpa.VerticalPodAutoscaler{}
+	err = kubeClients.Reader.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: vpaName}, vpa)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return createManagedVPA(kubeClients, obj)
+		}
+		return fmt.Errorf("Error getting VPA for %s/%s: %w", namespace, name, err)
 	}
 
-	// VPA doesn't exist, create it
+	return updateManagedVPA(kubeClients, vpa, obj)
+}
+
+func createManagedVPA(kubeClients *client.KubeClients, obj interface{}) error {
+	metadata, namespace, name := utils.GetObjectMetadata(obj)
+	kind := utils.GetKind(obj)
+	vpaName := GenerateVPAName(kind, name)
+
 	annotations := utils.GetOblikAnnotations(metadata.GetAnnotations())
 	updateMode := vpa.UpdateModeOff
 	vpa := &vpa.VerticalPodAutoscaler{
@@ -61,6 +74,7 @@ func AddVPA(clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClien
 			Labels: map[string]string{
 				constants.PREFIX + "enabled": "true",
 			},
+			OwnerReferences: []metav1.OwnerReference{getOwnerReference(obj)},
 		},
 		Spec: vpa.VerticalPodAutoscalerSpec{
 			TargetRef: &autoscaling.CrossVersionObjectReference{
@@ -73,67 +87,111 @@ func AddVPA(clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClien
 			},
 		},
 	}
+	setManagedSpec(vpa, obj)
 
-	_, err = vpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Create(context.TODO(), vpa, metav1.CreateOptions{})
+	_, err := kubeClients.VpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Create(context.TODO(), vpa, metav1.CreateOptions{})
 	if err != nil {
 		if strings.Contains(err.Error(), "unable to create new content in namespace") && strings.Contains(err.Error(), "because it is being terminated") {
 			klog.Infof("Skipping VPA creation for %s/%s in namespace %s: namespace is being terminated", kind, name, namespace)
-			return
+			return nil
 		}
-		klog.Errorf("Error creating VPA for %s/%s: %v", namespace, name, err)
-	} else {
-		klog.Infof("Created VPA %s for %s/%s", vpaName, namespace, name)
+		return fmt.Errorf("Error creating VPA for %s/%s: %w", namespace, name, err)
 	}
+	klog.Infof("Created VPA %s for %s/%s", vpaName, namespace, name)
+	return nil
 }
 
-func UpdateVPA(clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, vpaClientset *vpaclientset.Clientset, obj interface{}) {
+func updateManagedVPA(kubeClients *client.KubeClients, vpa *vpa.VerticalPodAutoscaler, obj interface{}) error {
 	metadata, namespace, name := utils.GetObjectMetadata(obj)
-	if metadata == nil {
-		klog.Errorf("Error getting metadata for object")
-		return
-	}
 
-	annotations := utils.GetOblikAnnotations(metadata.GetAnnotations())
-	kind := utils.GetKind(obj)
-	vpaName := GenerateVPAName(kind, name)
+	original := vpa.DeepCopy()
+	vpa.ObjectMeta.Annotations = utils.GetOblikAnnotations(metadata.GetAnnotations())
+	if vpa.ObjectMeta.Labels == nil {
+		vpa.ObjectMeta.Labels = map[string]string{}
+	}
+	vpa.ObjectMeta.Labels[constants.PREFIX+"enabled"] = "true"
+	if controller := setOwnerReference(vpa, obj); controller != nil {
+		klog.Warningf("VPA %s/%s is controlled by %s %s, not owned by %s/%s", namespace, vpa.Name, controller.Kind, controller.Name, namespace, name)
+	}
+	setManagedSpec(vpa, obj)
+	// the workload is reconciled on each of its changes, most of them leaving its VPA unchanged
+	if equality.Semantic.DeepEqual(original.ObjectMeta, vpa.ObjectMeta) && equality.Semantic.DeepEqual(original.Spec, vpa.Spec) {
+		return nil
+	}
 
-	vpa, err := vpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Get(context.TODO(), vpaName, metav1.GetOptions{})
+	_, err := kubeClients.VpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Update(context.TODO(), vpa, metav1.UpdateOptions{})
 	if err != nil {
-		if errors.IsNotFound(err) {
-			return
-		}
-		klog.Errorf("Error getting VPA for %s/%s: %v", namespace, name, err)
-		return
+		return fmt.Errorf("Error updating VPA for %s/%s: %w", namespace, name, err)
 	}
+	klog.Infof("Updated VPA %s for %s/%s", vpa.Name, namespace, name)
+	return nil
+}
 
-	vpa.ObjectMeta.Annotations = annotations
-	vpa.ObjectMeta.Labels[constants.PREFIX+"enabled"] = "true"
+// getOwnerReference returns the reference of the workload owning its VPA, so that the VPA is garbage collected with
+// the workload and that its changes are reconciled
+func getOwnerReference(obj interface{}) metav1.OwnerReference {
+	metadata, _, name := utils.GetObjectMetadata(obj)
+	controller := true
+	return metav1.OwnerReference{
+		APIVersion: utils.GetAPIVersion(obj),
+		Kind:       utils.GetKind(obj),
+		Name:       name,
+		UID:        metadata.GetUID(),
+		Controller: &controller,
+	}
+}
 
-	_, err = vpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Update(context.TODO(), vpa, metav1.UpdateOptions{})
-	if err != nil {
-		klog.Errorf("Error updating VPA for %s/%s: %v", namespace, name, err)
-	} else {
-		klog.Infof("Updated VPA %s for %s/%s", vpaName, namespace, name)
+// setOwnerReference adds the owner reference to the VPAs created before they were owned by their workload. An object
+// having a single controller, the reference is not added to a VPA already controlled by another owner, which is returned
+func setOwnerReference(vpa *vpa.VerticalPodAutoscaler, obj interface{}) *metav1.OwnerReference {
+	ownerReference := getOwnerReference(obj)
+	if controller := getOtherController(vpa, ownerReference.Kind, ownerReference.Name); controller != nil {
+		return controller
 	}
+	for i, existing := range vpa.OwnerReferences {
+		if existing.Kind == ownerReference.Kind && existing.Name == ownerReference.Name {
+			vpa.OwnerReferences[i] = ownerReference
+			return nil
+		}
+	}
+	vpa.OwnerReferences = append(vpa.OwnerReferences, ownerReference)
+	return nil
 }
 
-func DeleteVPA(vpaClientset *vpaclientset.Clientset, obj interface{}) {
-	metadata, namespace, name := utils.GetObjectMetadata(obj)
-	if metadata == nil {
-		klog.Errorf("Error getting metadata for object")
-		return
+// getOtherController returns the controller owner reference of the VPA when it's not the workload
+func getOtherController(vpa *vpa.VerticalPodAutoscaler, kind, name string) *metav1.OwnerReference {
+	for i, existing := range vpa.OwnerReferences {
+		if existing.Controller != nil && *existing.Controller && (existing.Kind != kind || existing.Name != name) {
+			return &vpa.OwnerReferences[i]
+		}
 	}
+	return nil
+}
 
-	kind := utils.GetKind(obj)
-	vpaName := GenerateVPAName(kind, name)
+// DeleteVPA deletes a VPA managed by Oblik, an adopted one being only released
+func DeleteVPA(kubeClients *client.KubeClients, vpaResource *vpa.VerticalPodAutoscaler) error {
+	if vpaResource.Labels[AdoptedLabel] == adoptedValueAdopt {
+		return releaseVPA(kubeClients, vpaResource)
+	}
+	return deleteVPAByName(kubeClients, vpaResource.Namespace, vpaResource.Name)
+}
+
+// DeleteWorkloadVPA deletes the VPA of a workload deleted or no longer enabled, and releases the VPAs it adopted
+func DeleteWorkloadVPA(kubeClients *client.KubeClients, namespace, kind, name string) error {
+	if err := deleteVPAByName(kubeClients, namespace, GenerateVPAName(kind, name)); err != nil {
+		return err
+	}
+	return releaseAdoptedVPAs(kubeClients, namespace, kind, name)
+}
 
-	err := vpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Delete(context.TODO(), vpaName, metav1.DeleteOptions{})
+func deleteVPAByName(kubeClients *client.KubeClients, namespace string, vpaName string) error {
+	err := kubeClients.VpaClientset.AutoscalingV1().VerticalPodAutoscalers(namespace).Delete(context.TODO(), vpaName, metav1.DeleteOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
-			return
+			return nil
 		}
-		klog.Errorf("Error deleting VPA for %s/%s: %v", namespace, name, err)
-	} else {
-		klog.Infof("Deleted VPA %s for %s/%s", vpaName, namespace, name)
+		return fmt.Errorf("Error deleting VPA %s/%s: %w", namespace, vpaName, err)
 	}
+	klog.Infof("Deleted VPA %s/%s", namespace, vpaName)
+	return nil
 }
@@ -0,0 +1,91 @@
+package vpa
+
+import (
+	"sort"
+
+	"github.com/SocialGouv/oblik/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+)
+
+// setManagedSpec sets the resource policy and the recommenders of the managed VPA from the vpa-* settings of the workload
+func setManagedSpec(vpaResource *vpa.VerticalPodAutoscaler, obj interface{}) {
+	scfg := config.CreateStrategyConfig(config.CreateConfigurable(obj))
+	vpaResource.Spec.ResourcePolicy = getResourcePolicy(scfg)
+	vpaResource.Spec.Recommenders = getRecommenders(scfg)
+}
+
+// setOwnedSpec sets the resource policy and the recommenders of an owned VPA created by the users, only when the vpa-*
+// settings of the workload define them, keeping the ones set by the users otherwise
+func setOwnedSpec(vpaResource *vpa.VerticalPodAutoscaler, obj interface{}) {
+	scfg := config.CreateStrategyConfig(config.CreateConfigurable(obj))
+	if resourcePolicy := getResourcePolicy(scfg); resourcePolicy != nil {
+		vpaResource.Spec.ResourcePolicy = resourcePolicy
+	}
+	if recommenders := getRecommenders(scfg); recommenders != nil {
+		vpaResource.Spec.Recommenders = recommenders
+	}
+}
+
+// getResourcePolicy returns the resource policy of the VPA: the workload settings apply to all the containers, and a
+// container with its own settings gets its own policy, merged with the workload ones as the VPA doesn't merge them
+func getResourcePolicy(scfg *config.StrategyConfig) *vpa.PodResourcePolicy {
+	policies := []vpa.ContainerResourcePolicy{}
+	if scfg.LoadCfg.HasVPAResourcePolicy() {
+		policies = append(policies, getContainerResourcePolicy(vpa.DefaultContainerResourcePolicy, scfg))
+	}
+	containerNames := make([]string, 0, len(scfg.Containers))
+	for containerName, containerConfig := range scfg.Containers {
+		if containerConfig.HasVPAResourcePolicy() {
+			containerNames = append(containerNames, containerName)
+		}
+	}
+	sort.Strings(containerNames)
+	for _, containerName := range containerNames {
+		policies = append(policies, getContainerResourcePolicy(containerName, scfg))
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+	return &vpa.PodResourcePolicy{ContainerPolicies: policies}
+}
+
+func getContainerResourcePolicy(containerName string, scfg *config.StrategyConfig) vpa.ContainerResourcePolicy {
+	policy := vpa.ContainerResourcePolicy{
+		ContainerName:    containerName,
+		Mode:             scfg.GetVPAMode(containerName),
+		MinAllowed:       getResourceList(scfg.GetVPAMinAllowedCpu(containerName), scfg.GetVPAMinAllowedMemory(containerName)),
+		MaxAllowed:       getResourceList(scfg.GetVPAMaxAllowedCpu(containerName), scfg.GetVPAMaxAllowedMemory(containerName)),
+		ControlledValues: scfg.GetVPAControlledValues(containerName),
+	}
+	if resourceNames := scfg.GetVPAControlledResources(containerName); resourceNames != nil {
+		policy.ControlledResources = &resourceNames
+	}
+	return policy
+}
+
+func getResourceList(cpu *resource.Quantity, memory *resource.Quantity) corev1.ResourceList {
+	if cpu == nil && memory == nil {
+		return nil
+	}
+	resourceList := corev1.ResourceList{}
+	if cpu != nil {
+		resourceList[corev1.ResourceCPU] = *cpu
+	}
+	if memory != nil {
+		resourceList[corev1.ResourceMemory] = *memory
+	}
+	return resourceList
+}
+
+func getRecommenders(scfg *config.StrategyConfig) []*vpa.VerticalPodAutoscalerRecommenderSelector {
+	if len(scfg.VPARecommenders) == 0 {
+		return nil
+	}
+	recommenders := make([]*vpa.VerticalPodAutoscalerRecommenderSelector, len(scfg.VPARecommenders))
+	for i, name := range scfg.VPARecommenders {
+		recommenders[i] = &vpa.VerticalPodAutoscalerRecommenderSelector{Name: name}
+	}
+	return recommenders
+}
@@ -0,0 +1,84 @@
+package vpa
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+)
+
+func newDeployment(annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: "uid", Annotations: annotations},
+	}
+}
+
+func newUserVPA() *vpa.VerticalPodAutoscaler {
+	return &vpa.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: vpa.VerticalPodAutoscalerSpec{
+			ResourcePolicy: &vpa.PodResourcePolicy{ContainerPolicies: []vpa.ContainerResourcePolicy{{
+				ContainerName: "app",
+				MaxAllowed:    corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			}}},
+			Recommenders: []*vpa.VerticalPodAutoscalerRecommenderSelector{{Name: "custom"}},
+		},
+	}
+}
+
+func TestSetOwnedSpec(t *testing.T) {
+	t.Run("users spec kept without vpa settings", func(t *testing.T) {
+		vpaResource := newUserVPA()
+		setOwnedSpec(vpaResource, newDeployment(nil))
+		if vpaResource.Spec.ResourcePolicy == nil || vpaResource.Spec.ResourcePolicy.ContainerPolicies[0].ContainerName != "app" {
+			t.Errorf("expected the resource policy of the users to be kept, got %+v", vpaResource.Spec.ResourcePolicy)
+		}
+		if len(vpaResource.Spec.Recommenders) != 1 || vpaResource.Spec.Recommenders[0].Name != "custom" {
+			t.Errorf("expected the recommenders of the users to be kept, got %+v", vpaResource.Spec.Recommenders)
+		}
+	})
+
+	t.Run("resource policy set from the vpa settings", func(t *testing.T) {
+		vpaResource := newUserVPA()
+		setOwnedSpec(vpaResource, newDeployment(map[string]string{"oblik.socialgouv.io/vpa-min-allowed-cpu": "100m"}))
+		policies := vpaResource.Spec.ResourcePolicy.ContainerPolicies
+		if len(policies) != 1 || policies[0].ContainerName != vpa.DefaultContainerResourcePolicy {
+			t.Fatalf("expected the resource policy of the workload settings, got %+v", policies)
+		}
+		if minCpu := policies[0].MinAllowed[corev1.ResourceCPU]; minCpu.Cmp(resource.MustParse("100m")) != 0 {
+			t.Errorf("expected min allowed cpu 100m, got %s", minCpu.String())
+		}
+		if len(vpaResource.Spec.Recommenders) != 1 || vpaResource.Spec.Recommenders[0].Name != "custom" {
+			t.Errorf("expected the recommenders of the users to be kept, got %+v", vpaResource.Spec.Recommenders)
+		}
+	})
+}
+
+func TestSetOwnerReference(t *testing.T) {
+	t.Run("owner reference added", func(t *testing.T) {
+		vpaResource := newUserVPA()
+		if controller := setOwnerReference(vpaResource, newDeployment(nil)); controller != nil {
+			t.Fatalf("expected no other controller, got %+v", controller)
+		}
+		if len(vpaResource.OwnerReferences) != 1 || vpaResource.OwnerReferences[0].Name != "app" || vpaResource.OwnerReferences[0].UID != "uid" {
+			t.Errorf("expected the workload owner reference, got %+v", vpaResource.OwnerReferences)
+		}
+	})
+
+	t.Run("other controller kept", func(t *testing.T) {
+		vpaResource := newUserVPA()
+		controller := true
+		vpaResource.OwnerReferences = []metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: "App", Name: "app", Controller: &controller}}
+		other := setOwnerReference(vpaResource, newDeployment(nil))
+		if other == nil || other.Kind != "App" {
+			t.Fatalf("expected the other controller to be returned, got %+v", other)
+		}
+		if len(vpaResource.OwnerReferences) != 1 || vpaResource.OwnerReferences[0].Kind != "App" {
+			t.Errorf("expected the owner references to be left unchanged, got %+v", vpaResource.OwnerReferences)
+		}
+	})
+}
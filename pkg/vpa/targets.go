@@ -0,0 +1,101 @@
+package vpa
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// targetIndex indexes the VPAs by the namespace, kind and name of their target
+const targetIndex = "target"
+
+var (
+	// targetInformers cache all the VPAs of each cluster, the ones created by the users included, which the shared
+	// cache restricted to the VPAs labelled by Oblik doesn't hold
+	targetInformers      = map[*client.KubeClients]toolscache.SharedIndexInformer{}
+	targetInformersMutex sync.Mutex
+	targetSyncTimeout    = 30 * time.Second
+)
+
+// getTargetInformer returns the informer of the VPAs of the cluster, started on first use and lasting as long as
+// the process, once synced
+func getTargetInformer(kubeClients *client.KubeClients) (toolscache.SharedIndexInformer, error) {
+	targetInformersMutex.Lock()
+	informer, ok := targetInformers[kubeClients]
+	if !ok {
+		informer = newTargetInformer(kubeClients)
+		targetInformers[kubeClients] = informer
+		go informer.Run(wait.NeverStop)
+	}
+	targetInformersMutex.Unlock()
+
+	if !informer.HasSynced() {
+		ctx, cancel := context.WithTimeout(context.Background(), targetSyncTimeout)
+		defer cancel()
+		if !toolscache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+			return nil, fmt.Errorf("timed out waiting for the VPAs cache to sync")
+		}
+	}
+	return informer, nil
+}
+
+func newTargetInformer(kubeClients *client.KubeClients) toolscache.SharedIndexInformer {
+	vpas := kubeClients.VpaClientset.AutoscalingV1().VerticalPodAutoscalers(metav1.NamespaceAll)
+	listWatch := &toolscache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return vpas.List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return vpas.Watch(context.TODO(), options)
+		},
+	}
+	informer := toolscache.NewSharedIndexInformer(listWatch, &vpa.VerticalPodAutoscaler{}, 0, toolscache.Indexers{targetIndex: indexTarget})
+	// the managed fields are never read, the status is kept as the adopted VPAs are updated from the cached objects
+	_ = informer.SetTransform(func(obj interface{}) (interface{}, error) {
+		if accessor, ok := obj.(metav1.Object); ok {
+			accessor.SetManagedFields(nil)
+		}
+		return obj, nil
+	})
+	return informer
+}
+
+func indexTarget(obj interface{}) ([]string, error) {
+	vpaResource, ok := obj.(*vpa.VerticalPodAutoscaler)
+	if !ok || vpaResource.Spec.TargetRef == nil {
+		return nil, nil
+	}
+	return []string{getTargetKey(vpaResource.Namespace, vpaResource.Spec.TargetRef.Kind, vpaResource.Spec.TargetRef.Name)}, nil
+}
+
+func getTargetKey(namespace, kind, name string) string {
+	return namespace + "/" + kind + "/" + name
+}
+
+// getTargetingVPAs returns copies of the cached VPAs targeting the workload
+func getTargetingVPAs(kubeClients *client.KubeClients, namespace, kind, name string) ([]vpa.VerticalPodAutoscaler, error) {
+	informer, err := getTargetInformer(kubeClients)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := informer.GetIndexer().ByIndex(targetIndex, getTargetKey(namespace, kind, name))
+	if err != nil {
+		return nil, err
+	}
+	vpas := []vpa.VerticalPodAutoscaler{}
+	for _, obj := range objects {
+		if vpaResource, ok := obj.(*vpa.VerticalPodAutoscaler); ok {
+			vpas = append(vpas, *vpaResource.DeepCopy())
+		}
+	}
+	return vpas, nil
+}
@@ -0,0 +1,79 @@
+package vpa
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	autoscaling "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	vpaclientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
+	"k8s.io/client-go/rest"
+)
+
+func newTargetingVPA(namespace, name, kind, target string) vpa.VerticalPodAutoscaler {
+	return vpa.VerticalPodAutoscaler{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "autoscaling.k8s.io/v1", Kind: "VerticalPodAutoscaler"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, ResourceVersion: "1"},
+		Spec:       vpa.VerticalPodAutoscalerSpec{TargetRef: &autoscaling.CrossVersionObjectReference{Kind: kind, Name: target}},
+	}
+}
+
+func TestGetTargetingVPAs(t *testing.T) {
+	var lists atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		lists.Add(1)
+		list := vpa.VerticalPodAutoscalerList{
+			TypeMeta: metav1.TypeMeta{APIVersion: "autoscaling.k8s.io/v1", Kind: "VerticalPodAutoscalerList"},
+			ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+			Items: []vpa.VerticalPodAutoscaler{
+				newTargetingVPA("default", "app", "Deployment", "app"),
+				newTargetingVPA("default", "other", "Deployment", "other"),
+				newTargetingVPA("other", "app", "Deployment", "app"),
+				newTargetingVPA("default", "app-sts", "StatefulSet", "app"),
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	// the informer keeps watching as long as the process, its connection is closed with the server
+	defer func() {
+		server.CloseClientConnections()
+		server.Close()
+	}()
+	vpaClientset, err := vpaclientset.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("Error creating VPA clientset: %s", err.Error())
+	}
+	kubeClients := &client.KubeClients{VpaClientset: vpaClientset}
+
+	for i := 0; i < 2; i++ {
+		vpas, err := getTargetingVPAs(kubeClients, "default", "Deployment", "app")
+		if err != nil {
+			t.Fatalf("Error getting targeting VPAs: %s", err.Error())
+		}
+		if len(vpas) != 1 || vpas[0].Namespace != "default" || vpas[0].Name != "app" {
+			t.Fatalf("expected the VPA default/app, got %+v", vpas)
+		}
+		// the returned VPAs are copies, updated without changing the cache
+		vpas[0].Labels = map[string]string{AdoptedLabel: adoptedValueAdopt}
+	}
+	if count := lists.Load(); count != 1 {
+		t.Errorf("expected the VPAs to be listed once, got %d lists", count)
+	}
+	vpas, _ := getTargetingVPAs(kubeClients, "default", "Deployment", "app")
+	if vpas[0].Labels[AdoptedLabel] != "" {
+		t.Errorf("expected the cached VPA to be left unchanged, got labels %v", vpas[0].Labels)
+	}
+}
@@ -0,0 +1,281 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	"github.com/SocialGouv/oblik/pkg/config"
+	"github.com/SocialGouv/oblik/pkg/constants"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	"github.com/SocialGouv/oblik/pkg/resourcesconfig"
+	"github.com/SocialGouv/oblik/pkg/target"
+	"github.com/SocialGouv/oblik/pkg/utils"
+	ovpa "github.com/SocialGouv/oblik/pkg/vpa"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var (
+	eventRecorder     record.EventRecorder
+	eventRecorderOnce sync.Once
+)
+
+func getEventRecorder(clientset kubernetes.Interface) record.EventRecorder {
+	eventRecorderOnce.Do(func() {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+		eventRecorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "oblik"})
+	})
+	return eventRecorder
+}
+
+// resourceDrift is a CPU or memory request or limit of a container changed from the value last applied by Oblik
+type resourceDrift struct {
+	containerName string
+	annotation    string
+	applied       *string
+	current       *string
+}
+
+func (d resourceDrift) String() string {
+	applied, current := "unset", "unset"
+	if d.applied != nil {
+		applied = *d.applied
+	}
+	if d.current != nil {
+		current = *d.current
+	}
+	return fmt.Sprintf("%s of container %s from %s to %s", d.annotation, d.containerName, applied, current)
+}
+
+func getPodTemplateContainers(obj interface{}) []corev1.Container {
+	switch v := obj.(type) {
+	case *appsv1.Deployment:
+		return v.Spec.Template.Spec.Containers
+	case *appsv1.StatefulSet:
+		return v.Spec.Template.Spec.Containers
+	case *appsv1.DaemonSet:
+		return v.Spec.Template.Spec.Containers
+	case *batchv1.CronJob:
+		return v.Spec.JobTemplate.Spec.Template.Spec.Containers
+	}
+	return nil
+}
+
+// DriftReconciler checks the drift of the resources of the enabled workloads of a kind, enqueued by the updates
+// changing their resources, the reaction to the drift being retried on failure
+type DriftReconciler struct {
+	KubeClients *client.KubeClients
+	Kind        string
+	WorkloadType
+}
+
+func (r *DriftReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	if !Sharder.Owns(req.String()) {
+		return reconcile.Result{}, nil
+	}
+	obj := r.NewObject()
+	if err := r.KubeClients.Reader.Get(ctx, req.NamespacedName, obj); err != nil {
+		return reconcile.Result{}, ctrlclient.IgnoreNotFound(err)
+	}
+	if !target.IsWorkloadEnabled(ctx, r.KubeClients, obj) {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{}, checkDrift(r.KubeClients, obj)
+}
+
+// resourcesChanged only keeps the updates changing the resources of the containers, not to report a same drift again
+var resourcesChanged = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return hasResourcesChanged(getPodTemplateContainers(e.ObjectOld), getPodTemplateContainers(e.ObjectNew))
+	},
+}
+
+var (
+	// reportedDrifts are the drifts reported for each workload, not to report them again when their reaction is retried
+	reportedDrifts      = map[string]string{}
+	reportedDriftsMutex sync.Mutex
+)
+
+// checkDrift detects the changes of the containers resources made outside Oblik, comparing them to the values
+// Oblik last applied, and reacts according to the drift policy of the workload
+func checkDrift(kubeClients *client.KubeClients, obj interface{}) error {
+	containers := getPodTemplateContainers(obj)
+	metadata, ok := obj.(metav1.Object)
+	if containers == nil || !ok {
+		return nil
+	}
+	applied := target.GetAppliedResources(metadata.GetAnnotations())
+	if applied == nil {
+		return nil
+	}
+
+	scfg := kubeClients.CreateStrategyConfig(obj)
+	drifts := getResourceDrifts(applied, containers)
+	if len(drifts) == 0 {
+		reportedDriftsMutex.Lock()
+		delete(reportedDrifts, scfg.Key)
+		reportedDriftsMutex.Unlock()
+		return nil
+	}
+
+	descriptions := make([]string, len(drifts))
+	for i, drift := range drifts {
+		descriptions[i] = drift.String()
+	}
+	message := "Resources changed outside Oblik: " + strings.Join(descriptions, ", ")
+
+	policy := "ignore"
+	switch scfg.DriftPolicy {
+	case config.DriftPolicyReapply:
+		policy = "reapply"
+	case config.DriftPolicyAccept:
+		policy = "accept"
+	}
+	reportedDriftsMutex.Lock()
+	reported := reportedDrifts[scfg.Key] == message
+	reportedDrifts[scfg.Key] = message
+	reportedDriftsMutex.Unlock()
+	if !reported {
+		reporting.ReportDrift(scfg.Key, message, policy)
+		if runtimeObj, ok := obj.(runtime.Object); ok {
+			getEventRecorder(kubeClients.Clientset).Event(runtimeObj, corev1.EventTypeWarning, "ResourcesDrift", message)
+		}
+	}
+
+	kind := utils.GetKind(obj)
+	switch scfg.DriftPolicy {
+	case config.DriftPolicyReapply:
+		return reapplyDrift(kubeClients, kind, metadata.GetNamespace(), metadata.GetName())
+	case config.DriftPolicyAccept:
+		return acceptDrift(kubeClients, kind, metadata, containers, drifts)
+	}
+	return nil
+}
+
+func hasResourcesChanged(oldContainers []corev1.Container, newContainers []corev1.Container) bool {
+	if len(oldContainers) != len(newContainers) {
+		return true
+	}
+	for i := range newContainers {
+		if !equalResourceLists(oldContainers[i].Resources.Requests, newContainers[i].Resources.Requests) ||
+			!equalResourceLists(oldContainers[i].Resources.Limits, newContainers[i].Resources.Limits) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalResourceLists(a corev1.ResourceList, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for resourceName, quantity := range a {
+		other, ok := b[resourceName]
+		if !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func getResourceDrifts(applied map[string]corev1.ResourceRequirements, containers []corev1.Container) []resourceDrift {
+	drifts := []resourceDrift{}
+	for _, container := range containers {
+		appliedResources, ok := applied[container.Name]
+		if !ok {
+			continue
+		}
+		for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			drifts = appendResourceDrift(drifts, container.Name, "request-"+string(resourceName), appliedResources.Requests, container.Resources.Requests, resourceName)
+			drifts = appendResourceDrift(drifts, container.Name, "limit-"+string(resourceName), appliedResources.Limits, container.Resources.Limits, resourceName)
+		}
+	}
+	sort.SliceStable(drifts, func(i, j int) bool {
+		return drifts[i].containerName < drifts[j].containerName
+	})
+	return drifts
+}
+
+func appendResourceDrift(drifts []resourceDrift, containerName string, annotation string, appliedList corev1.ResourceList, currentList corev1.ResourceList, resourceName corev1.ResourceName) []resourceDrift {
+	appliedValue, hasApplied := appliedList[resourceName]
+	currentValue, hasCurrent := currentList[resourceName]
+	if hasApplied == hasCurrent && appliedValue.Cmp(currentValue) == 0 {
+		return drifts
+	}
+	drift := resourceDrift{containerName: containerName, annotation: annotation}
+	if hasApplied {
+		value := appliedValue.String()
+		drift.applied = &value
+	}
+	if hasCurrent {
+		value := currentValue.String()
+		drift.current = &value
+	}
+	return append(drifts, drift)
+}
+
+// reapplyDrift enqueues an immediate apply of the recommendations to the workload
+func reapplyDrift(kubeClients *client.KubeClients, kind string, namespace string, name string) error {
+	vpaResource, err := ovpa.FindVPA(kubeClients, namespace, kind, name)
+	if err != nil {
+		return fmt.Errorf("Error getting VPA of %s/%s to reapply drifted resources: %w", namespace, name, err)
+	}
+	scfg := kubeClients.CreateStrategyConfig(vpaResource)
+	klog.Infof("Reapplying recommendations to %s after drift", scfg.Key)
+	enqueueVPA(kubeClients, vpaResource, scfg, 0)
+	return nil
+}
+
+// acceptDrift keeps the drifted values as a manual override: changed values are set as direct values of the container,
+// and removed ones turn the apply mode of the container off, so that the next applies keep them. The overrides are
+// written into the ResourcesConfig targeting the workload if any, its sync replacing the annotations of the workload
+func acceptDrift(kubeClients *client.KubeClients, kind string, metadata metav1.Object, containers []corev1.Container, drifts []resourceDrift) error {
+	overrides := make([]resourcesconfig.Override, len(drifts))
+	for i, drift := range drifts {
+		overrides[i] = resourcesconfig.Override{ContainerName: drift.containerName, Resource: drift.annotation, Value: drift.current}
+	}
+	inResourcesConfig, err := resourcesconfig.AcceptOverrides(context.Background(), kubeClients, metadata.GetNamespace(), kind, metadata.GetName(), overrides)
+	if err != nil {
+		return fmt.Errorf("Error accepting drifted resources of %s/%s in ResourcesConfig: %w", metadata.GetNamespace(), metadata.GetName(), err)
+	}
+
+	annotations := map[string]string{}
+	if !inResourcesConfig {
+		for _, drift := range drifts {
+			if drift.current != nil {
+				annotations[constants.PREFIX+drift.annotation+"."+drift.containerName] = *drift.current
+			} else {
+				annotations[constants.PREFIX+drift.annotation+"-apply-mode."+drift.containerName] = "off"
+			}
+		}
+	}
+	recorded := &metav1.ObjectMeta{}
+	target.SetAppliedResources(recorded, containers)
+	annotations[constants.AppliedResourcesAnnotation] = recorded.Annotations[constants.AppliedResourcesAnnotation]
+
+	if err := target.PatchAnnotations(kubeClients.Clientset, metadata.GetNamespace(), kind, metadata.GetName(), annotations); err != nil {
+		return fmt.Errorf("Error accepting drifted resources of %s/%s: %w", metadata.GetNamespace(), metadata.GetName(), err)
+	}
+	klog.Infof("Accepted drifted resources of %s/%s as manual override", metadata.GetNamespace(), metadata.GetName())
+	return nil
+}
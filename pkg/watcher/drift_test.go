@@ -0,0 +1,57 @@
+package watcher
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func newDriftDeployment(cpuRequest string, image string) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{}
+	deployment.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name:  "app",
+		Image: image,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpuRequest)},
+		},
+	}}
+	return deployment
+}
+
+func TestResourcesChanged(t *testing.T) {
+	old := newDriftDeployment("100m", "app:1")
+	if !resourcesChanged.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: newDriftDeployment("200m", "app:1")}) {
+		t.Error("expected an update of the resources to be checked")
+	}
+	if resourcesChanged.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: newDriftDeployment("0.1", "app:2")}) {
+		t.Error("expected an update leaving the resources unchanged to be ignored")
+	}
+	if resourcesChanged.Create(event.CreateEvent{Object: old}) {
+		t.Error("expected the creations to be ignored")
+	}
+}
+
+func TestGetResourceDrifts(t *testing.T) {
+	applied := map[string]corev1.ResourceRequirements{
+		"app": {
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+		},
+	}
+	containers := newDriftDeployment("200m", "app:1").Spec.Template.Spec.Containers
+	containers = append(containers, corev1.Container{Name: "sidecar"})
+
+	drifts := getResourceDrifts(applied, containers)
+	if len(drifts) != 2 {
+		t.Fatalf("expected 2 drifts, got %v", drifts)
+	}
+	if got := drifts[0].String(); got != "request-cpu of container app from 100m to 200m" {
+		t.Errorf("unexpected drift %q", got)
+	}
+	if got := drifts[1].String(); got != "limit-cpu of container app from 1 to unset" {
+		t.Errorf("unexpected drift %q", got)
+	}
+}
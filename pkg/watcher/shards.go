@@ -0,0 +1,53 @@
+package watcher
+
+import (
+	"context"
+
+	"github.com/SocialGouv/oblik/pkg/client"
+	"github.com/SocialGouv/oblik/pkg/sharding"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// getControllerOptions runs the controllers of the workloads and VPAs on all the replicas with sharding, and only on
+// the leader otherwise
+func getControllerOptions() controller.Options {
+	needLeaderElection := Sharder == nil
+	return controller.Options{
+		NeedLeaderElection: &needLeaderElection,
+	}
+}
+
+// getRebalanceSource returns a source enqueuing the objects of a list taken over by the replica when the shards are
+// rebalanced, the controllers having skipped their events until then
+func getRebalanceSource(kubeClients *client.KubeClients, newList func() ctrlclient.ObjectList) source.Source {
+	events := make(chan event.GenericEvent)
+	Sharder.OnRebalance(func(previous, current *sharding.Ring) {
+		// the controller may not be started yet, the events are sent without blocking the rebalance
+		go func() {
+			list := newList()
+			if err := kubeClients.Reader.List(context.TODO(), list); err != nil {
+				klog.Errorf("Error listing objects to rebalance: %s", err.Error())
+				return
+			}
+			_ = meta.EachListItem(list, func(item runtime.Object) error {
+				obj, ok := item.(ctrlclient.Object)
+				if !ok {
+					return nil
+				}
+				key := sharding.GetKey(obj)
+				if !Sharder.OwnedIn(previous, key) && Sharder.OwnedIn(current, key) {
+					events <- event.GenericEvent{Object: obj}
+				}
+				return nil
+			})
+		}()
+	})
+	return source.Channel(events, &handler.EnqueueRequestForObject{})
+}
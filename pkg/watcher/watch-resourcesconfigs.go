@@ -2,102 +2,136 @@ package watcher
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	oblikv1 "github.com/SocialGouv/oblik/pkg/apis/oblik/v1"
 	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/resourcesconfig"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
-	"k8s.io/client-go/tools/cache"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-func WatchResourcesConfigs(ctx context.Context, kubeClients *client.KubeClients) {
-	resourcesConfigClientset := kubeClients.ResourcesConfigClientset
+// resourcesConfigRetryInterval is the interval to sync again a ResourcesConfig whose target doesn't exist yet
+const resourcesConfigRetryInterval = time.Minute
 
-	// Create a custom ListWatch for ResourcesConfigs
-	watchlist := &cache.ListWatch{
-		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			options.FieldSelector = fields.Everything().String()
-			return resourcesConfigClientset.OblikV1().List(ctx, metav1.NamespaceAll, options)
-		},
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			options.FieldSelector = fields.Everything().String()
-			return resourcesConfigClientset.OblikV1().Watch(ctx, metav1.NamespaceAll, options)
-		},
-	}
-
-	_, controller := cache.NewInformer(
-		watchlist,
-		&oblikv1.ResourcesConfig{},
-		time.Second*0,
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				handleResourcesConfig(ctx, kubeClients, obj)
-			},
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				handleResourcesConfig(ctx, kubeClients, newObj)
-			},
-			DeleteFunc: func(obj interface{}) {
-				handleResourcesConfigDelete(ctx, kubeClients, obj)
-			},
-		},
-	)
+// ResourcesConfigReconciler syncs the annotations of the ResourcesConfigs to their target, in each cluster they
+// select in multi-cluster mode
+type ResourcesConfigReconciler struct {
+	KubeClients *client.KubeClients
+	// Clusters are the clients of all the clusters managed by Oblik, the cluster running it included
+	Clusters []*client.KubeClients
 
-	klog.Info("Starting ResourcesConfigs watcher...")
-	controller.Run(ctx.Done())
+	// synced are the ResourcesConfigs last synced, to remove their annotations once deleted
+	synced      map[types.NamespacedName]*oblikv1.ResourcesConfig
+	syncedMutex sync.Mutex
 }
 
-func handleResourcesConfig(ctx context.Context, kubeClients *client.KubeClients, obj interface{}) {
-	rc, ok := obj.(*oblikv1.ResourcesConfig)
-	if !ok {
-		klog.Error("Could not cast to ResourcesConfig object")
-		return
+func (r *ResourcesConfigReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	rc := &oblikv1.ResourcesConfig{}
+	err := r.KubeClients.Reader.Get(ctx, req.NamespacedName, rc)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.syncedMutex.Lock()
+			deleted := r.synced[req.NamespacedName]
+			delete(r.synced, req.NamespacedName)
+			r.syncedMutex.Unlock()
+			if deleted != nil {
+				klog.Infof("Handling ResourcesConfig deletion: %s/%s", deleted.Namespace, deleted.Name)
+				for _, cluster := range r.Clusters {
+					if resourcesconfig.TargetsCluster(deleted, cluster) {
+						handleResourcesConfigDelete(ctx, cluster, deleted)
+					}
+				}
+			}
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	r.syncedMutex.Lock()
+	previous := r.synced[req.NamespacedName]
+	r.synced[req.NamespacedName] = rc
+	r.syncedMutex.Unlock()
+	// the clusters no longer selected are handled as for a deletion
+	if previous != nil {
+		for _, cluster := range r.Clusters {
+			if resourcesconfig.TargetsCluster(previous, cluster) && !resourcesconfig.TargetsCluster(rc, cluster) {
+				handleResourcesConfigDelete(ctx, cluster, previous)
+			}
+		}
 	}
+	return r.handleResourcesConfig(ctx, rc)
+}
+
+// SetupResourcesConfigReconciler registers the reconciler of the ResourcesConfigs, which are in the cluster running
+// Oblik whatever the clusters of their targets
+func SetupResourcesConfigReconciler(mgr ctrl.Manager, kubeClients *client.KubeClients, clusters []*client.KubeClients) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// the status updates of the reconciler don't change the generation
+		For(&oblikv1.ResourcesConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(&ResourcesConfigReconciler{
+			KubeClients: kubeClients,
+			Clusters:    clusters,
+			synced:      map[types.NamespacedName]*oblikv1.ResourcesConfig{},
+		})
+}
 
+func (r *ResourcesConfigReconciler) handleResourcesConfig(ctx context.Context, rc *oblikv1.ResourcesConfig) (reconcile.Result, error) {
 	klog.Infof("Handling ResourcesConfig: %s/%s", rc.Namespace, rc.Name)
 
-	err := resourcesconfig.SyncAnnotations(ctx, kubeClients, rc)
-	if err != nil {
+	// the status reports the first failure, an error prevailing over a missing target, the others being logged
+	var syncErr error
+	var syncErrCluster *client.KubeClients
+	for _, cluster := range r.Clusters {
+		if !resourcesconfig.TargetsCluster(rc, cluster) {
+			continue
+		}
+		err := resourcesconfig.SyncAnnotations(ctx, cluster, rc)
+		if err == nil {
+			continue
+		}
 		if resourcesconfig.IsResourceNotFoundError(err) {
 			// Log as warning instead of error when resource is not found
-			klog.Warningf("Warning syncing annotations: %s", err.Error())
-			// Update status with warning
-			resourcesconfig.UpdateStatus(ctx, kubeClients, rc, false, err.Error())
-			return
+			klog.Warningf("Warning syncing annotations: %s", resourcesconfig.WithCluster(cluster, err.Error()))
+		} else {
+			klog.Errorf("Error syncing annotations: %s", resourcesconfig.WithCluster(cluster, err.Error()))
+		}
+		if syncErr == nil || resourcesconfig.IsResourceNotFoundError(syncErr) && !resourcesconfig.IsResourceNotFoundError(err) {
+			syncErr = err
+			syncErrCluster = cluster
 		}
-		klog.Errorf("Error syncing annotations: %s", err.Error())
-		// Update status with error
-		resourcesconfig.UpdateStatus(ctx, kubeClients, rc, false, err.Error())
-		return
 	}
 
-	// Update status with success
-	resourcesconfig.UpdateStatus(ctx, kubeClients, rc, true, "")
-}
-
-func handleResourcesConfigDelete(ctx context.Context, kubeClients *client.KubeClients, obj interface{}) {
-	rc, ok := obj.(*oblikv1.ResourcesConfig)
-	if !ok {
-		klog.Error("Could not cast to ResourcesConfig object")
-		return
+	if syncErr != nil {
+		resourcesconfig.UpdateStatus(ctx, r.KubeClients, rc, false, resourcesconfig.WithCluster(syncErrCluster, syncErr.Error()))
+		if resourcesconfig.IsResourceNotFoundError(syncErr) {
+			return reconcile.Result{RequeueAfter: resourcesConfigRetryInterval}, nil
+		}
+		return reconcile.Result{}, syncErr
 	}
 
-	klog.Infof("Handling ResourcesConfig deletion: %s/%s", rc.Namespace, rc.Name)
+	// Update status with success
+	resourcesconfig.UpdateStatus(ctx, r.KubeClients, rc, true, "")
+	return reconcile.Result{}, nil
+}
 
+func handleResourcesConfigDelete(ctx context.Context, kubeClients *client.KubeClients, rc *oblikv1.ResourcesConfig) {
 	// If annotation mode is "replace", remove all oblik annotations from the target
 	if rc.Spec.AnnotationMode != "merge" {
 		err := resourcesconfig.RemoveAnnotations(ctx, kubeClients, rc)
 		if err != nil {
 			if resourcesconfig.IsResourceNotFoundError(err) {
 				// Log as warning instead of error when resource is not found
-				klog.Warningf("Warning removing annotations: %s", err.Error())
+				klog.Warningf("Warning removing annotations: %s", resourcesconfig.WithCluster(kubeClients, err.Error()))
 				return
 			}
-			klog.Errorf("Error removing annotations: %s", err.Error())
+			klog.Errorf("Error removing annotations: %s", resourcesconfig.WithCluster(kubeClients, err.Error()))
 			return
 		}
 	}
@@ -6,112 +6,291 @@ import (
 	"sync"
 	"time"
 
+	"github.com/SocialGouv/oblik/pkg/calendar"
 	"github.com/SocialGouv/oblik/pkg/client"
 	"github.com/SocialGouv/oblik/pkg/config"
-	"github.com/SocialGouv/oblik/pkg/constants"
+	"github.com/SocialGouv/oblik/pkg/queue"
+	"github.com/SocialGouv/oblik/pkg/reporting"
+	"github.com/SocialGouv/oblik/pkg/resourcesconfig"
+	"github.com/SocialGouv/oblik/pkg/sharding"
+	"github.com/SocialGouv/oblik/pkg/state"
 	"github.com/SocialGouv/oblik/pkg/target"
+	"github.com/SocialGouv/oblik/pkg/utils"
 	cron "github.com/robfig/cron/v3"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
-	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var (
 	CronScheduler = cron.New()
 	cronJobs      = make(map[string]cron.EntryID)
-	cronMutex     sync.Mutex
+	// vpaKeys are the workload keys of the scheduled VPAs, by cluster:namespace/name of the VPA
+	vpaKeys    = make(map[string]string)
+	cronMutex  sync.Mutex
+	ApplyQueue = queue.New(queue.LoadConfig())
+	RunStates  = state.NewStore()
+	// Sharder splits the workloads between the replicas, nil when the sharding is disabled
+	Sharder *sharding.Sharder
 )
 
-func WatchVPAs(ctx context.Context, kubeClients *client.KubeClients) {
-	vpaClientset := kubeClients.VpaClientset
+// VPAReconciler schedules the application of the recommendations of the VPAs labelled by Oblik in a cluster
+type VPAReconciler struct {
+	KubeClients *client.KubeClients
+}
 
-	labelSelector := labels.SelectorFromSet(labels.Set{constants.PREFIX + "enabled": "true"})
-	watchlist := cache.NewFilteredListWatchFromClient(
-		vpaClientset.AutoscalingV1().RESTClient(),
-		"verticalpodautoscalers",
-		metav1.NamespaceAll,
-		func(options *metav1.ListOptions) {
-			options.LabelSelector = labelSelector.String()
-		},
-	)
-
-	_, controller := cache.NewInformer(
-		watchlist,
-		&vpa.VerticalPodAutoscaler{},
-		time.Second*0,
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				handleVPA(kubeClients, obj)
-			},
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				handleVPA(kubeClients, newObj)
-			},
-			DeleteFunc: func(obj interface{}) {
-				klog.Info("VPA deleted")
-				vpa, ok := obj.(*vpa.VerticalPodAutoscaler)
-				if !ok {
-					klog.Error("Could not cast to VPA object")
-					return
-				}
-
-				configurable := config.CreateConfigurable(vpa)
-				key := config.GetKey(configurable)
-
-				if entryID, exists := cronJobs[key]; exists {
-					CronScheduler.Remove(entryID)
-				}
-			},
-		},
-	)
+func (r *VPAReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	vpaResource := &vpa.VerticalPodAutoscaler{}
+	err := r.KubeClients.Reader.Get(ctx, req.NamespacedName, vpaResource)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			unscheduleVPA(r.KubeClients, getVPARef(r.KubeClients, req.NamespacedName))
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !Sharder.OwnsObject(vpaResource) {
+		handOffVPA(getVPARef(r.KubeClients, req.NamespacedName))
+		return reconcile.Result{}, nil
+	}
+
+	klog.Infof("Handling VPA: %s/%s", vpaResource.Namespace, vpaResource.Name)
 
-	klog.Info("Starting VPA watcher...")
-	controller.Run(ctx.Done())
+	// the scheduled runs are reconciled with each change of the recommendations, status included
+	scheduleVPA(r.KubeClients, vpaResource)
+	return reconcile.Result{}, nil
 }
 
-func handleVPA(kubeClients *client.KubeClients, obj interface{}) {
-	vpa, ok := obj.(*vpa.VerticalPodAutoscaler)
-	if !ok {
-		klog.Error("Could not cast to VPA object")
+// SetupVPAReconciler registers the reconciler of the VPAs of a cluster. With sharding, it runs on all the replicas,
+// each one scheduling the VPAs of its own workloads
+func SetupVPAReconciler(mgr ctrl.Manager, cl cluster.Cluster, kubeClients *client.KubeClients) error {
+	Sharder.OnRebalance(func(previous, current *sharding.Ring) {
+		cronMutex.Lock()
+		handedOff := []string{}
+		for vpaRef, key := range vpaKeys {
+			keyCluster, workloadKey := config.SplitClusterKey(key)
+			if keyCluster == kubeClients.Cluster && !Sharder.OwnedIn(current, workloadKey) {
+				handedOff = append(handedOff, vpaRef)
+			}
+		}
+		cronMutex.Unlock()
+		for _, vpaRef := range handedOff {
+			handOffVPA(vpaRef)
+		}
+		// the run states of the workloads taken over were updated by their previous replica
+		RunStates.Reset()
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(getControllerName("verticalpodautoscaler", kubeClients)).
+		WatchesRawSource(source.Kind(cl.GetCache(), ctrlclient.Object(&vpa.VerticalPodAutoscaler{}), &handler.EnqueueRequestForObject{}, Sharder.Predicate())).
+		WatchesRawSource(getRebalanceSource(kubeClients, func() ctrlclient.ObjectList { return &vpa.VerticalPodAutoscalerList{} })).
+		WithOptions(getControllerOptions()).
+		Complete(&VPAReconciler{
+			KubeClients: kubeClients,
+		})
+}
+
+// getVPARef returns the cluster:namespace/name reference of a VPA of the cluster
+func getVPARef(kubeClients *client.KubeClients, vpaName types.NamespacedName) string {
+	return kubeClients.GetKey(vpaName.String())
+}
+
+// handOffVPA removes the scheduled runs of a VPA moved to another replica, keeping its run state for the catch-up
+// of the other replica
+func handOffVPA(vpaRef string) {
+	cronMutex.Lock()
+	key, exists := vpaKeys[vpaRef]
+	if !exists {
+		cronMutex.Unlock()
 		return
 	}
+	klog.Infof("VPA moved to another shard: %s", vpaRef)
+	if entryID, exists := cronJobs[key]; exists {
+		CronScheduler.Remove(entryID)
+		delete(cronJobs, key)
+	}
+	delete(vpaKeys, vpaRef)
+	cronMutex.Unlock()
+	reporting.DeleteMetrics(key)
+}
 
-	klog.Infof("Handling VPA: %s/%s", vpa.Namespace, vpa.Name)
-
-	scheduleVPA(kubeClients, vpa)
+// unscheduleVPA removes the scheduled runs of a deleted VPA
+func unscheduleVPA(kubeClients *client.KubeClients, vpaRef string) {
+	cronMutex.Lock()
+	key, exists := vpaKeys[vpaRef]
+	if !exists {
+		cronMutex.Unlock()
+		return
+	}
+	klog.Infof("VPA deleted: %s", vpaRef)
+	if entryID, exists := cronJobs[key]; exists {
+		CronScheduler.Remove(entryID)
+		delete(cronJobs, key)
+	}
+	delete(vpaKeys, vpaRef)
+	cronMutex.Unlock()
+	reporting.DeleteMetrics(key)
+	RunStates.Delete(context.TODO(), kubeClients.Local.Clientset, key)
 }
 
 func scheduleVPA(kubeClients *client.KubeClients, vpaResource *vpa.VerticalPodAutoscaler) {
 	cronMutex.Lock()
 	defer cronMutex.Unlock()
 
-	configurable := config.CreateConfigurable(vpaResource)
-	scfg := config.CreateStrategyConfig(configurable)
+	scfg := kubeClients.CreateStrategyConfig(vpaResource)
 
 	key := scfg.Key
+	vpaKeys[getVPARef(kubeClients, types.NamespacedName{Namespace: vpaResource.Namespace, Name: vpaResource.Name})] = key
 
-	klog.Infof("Scheduling VPA recommendations for %s with cron: %s", key, scfg.CronExpr)
+	cronSpec := scfg.GetCronSpec()
+	klog.Infof("Scheduling VPA recommendations for %s with cron: %s", key, cronSpec)
 
-	if entryID, exists := cronJobs[key]; exists {
+	entryID, scheduled := cronJobs[key]
+	if scheduled {
 		CronScheduler.Remove(entryID)
 	}
 
-	entryID, err := CronScheduler.AddFunc(scfg.CronExpr, func() {
+	schedule, err := utils.ParseCronSchedule(cronSpec)
+	if err != nil {
+		klog.Errorf("Error scheduling cron job: %s", err.Error())
+		return
+	}
+
+	if !scheduled {
+		catchUpVPA(kubeClients, vpaResource, scfg, schedule)
+	}
+
+	entryID = CronScheduler.Schedule(schedule, cron.FuncJob(func() {
+		reporting.SetNextRun(key, schedule.Next(time.Now()))
+		var randomDelay time.Duration
 		nsecondsDelay := scfg.CronMaxRandomDelay.Nanoseconds()
 		if nsecondsDelay != 0 {
-			randomDelay := time.Duration(rand.Int63n(nsecondsDelay))
-			time.Sleep(randomDelay)
+			randomDelay = time.Duration(rand.Int63n(nsecondsDelay))
 		}
-		klog.Infof("Applying VPA recommendations for %s with cron: %s", key, scfg.CronExpr)
-		err := target.ApplyVPARecommendations(kubeClients, vpaResource, scfg)
-		if err != nil {
-			klog.Errorf("Error applying VPA recommendations: %s", err.Error())
+		enqueueVPA(kubeClients, vpaResource, scfg, randomDelay)
+	}))
+	cronJobs[key] = entryID
+
+	next := schedule.Next(time.Now())
+	reporting.SetNextRun(key, next)
+	klog.Infof("Next run for %s at %s", key, next.Format(time.RFC3339))
+}
+
+// catchUpVPA enqueues the run pending or missed while no operator instance was leading,
+// when it is not later than the catch-up deadline, keeping the random delay already drawn
+func catchUpVPA(kubeClients *client.KubeClients, vpaResource *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig, schedule cron.Schedule) {
+	key := scfg.Key
+	runState := RunStates.Get(context.TODO(), kubeClients.Local.Clientset, key)
+	now := time.Now()
+
+	if runState.Pending != nil {
+		pending := *runState.Pending
+		if pending.After(now) {
+			klog.Infof("Resuming pending run for %s at %s", key, pending.Format(time.RFC3339))
+			enqueueVPA(kubeClients, vpaResource, scfg, pending.Sub(now))
+			return
 		}
-	})
-	if err != nil {
-		klog.Errorf("Error scheduling cron job: %s", err.Error())
+		if scfg.CronCatchUpDeadline > 0 && now.Sub(pending) <= scfg.CronCatchUpDeadline {
+			klog.Infof("Catching up missed run for %s planned at %s", key, pending.Format(time.RFC3339))
+			enqueueVPA(kubeClients, vpaResource, scfg, 0)
+			return
+		}
+	}
+
+	if runState.LastSuccess == nil || scfg.CronCatchUpDeadline <= 0 {
 		return
 	}
-	cronJobs[key] = entryID
+	from := *runState.LastSuccess
+	if deadline := now.Add(-scfg.CronCatchUpDeadline); from.Before(deadline) {
+		from = deadline
+	}
+	if missed := schedule.Next(from); !missed.IsZero() && !missed.After(now) {
+		klog.Infof("Catching up missed run for %s scheduled at %s", key, missed.Format(time.RFC3339))
+		enqueueVPA(kubeClients, vpaResource, scfg, 0)
+	}
+}
+
+func enqueueVPA(kubeClients *client.KubeClients, vpaResource *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig, delay time.Duration) {
+	RunStates.SetPending(context.TODO(), kubeClients.Local.Clientset, scfg.Key, time.Now().Add(delay))
+	// the namespaces and node pools of the clusters are limited apart
+	nodePool := target.GetNodePool(context.TODO(), kubeClients, vpaResource, ApplyQueue.Config.NodePoolLabel)
+	if nodePool != "" {
+		nodePool = kubeClients.GetKey(nodePool)
+	}
+	job := &queue.Job{
+		Key:       scfg.Key,
+		Namespace: kubeClients.GetKey(vpaResource.Namespace),
+		NodePool:  nodePool,
+		Apply: func(ctx context.Context) error {
+			return runScheduledVPA(kubeClients, vpaResource, scfg)
+		},
+		GiveUp: func(err error) {
+			recordFailedRun(kubeClients, vpaResource, scfg, err)
+		},
+	}
+	if !scfg.GetDryRun() {
+		job.WaitRollout = func(ctx context.Context) error {
+			return target.WaitForRollout(ctx, kubeClients, vpaResource)
+		}
+	}
+	ApplyQueue.Add(job, delay)
+}
+
+func runScheduledVPA(kubeClients *client.KubeClients, vpaResource *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig) error {
+	key := scfg.Key
+
+	// the run queued before the VPA moved to another replica is resumed by it from the pending run state
+	if !Sharder.OwnsObject(vpaResource) {
+		klog.Infof("Skipping run for %s, moved to another shard", key)
+		return nil
+	}
+
+	cal := calendar.GetNamespaceCalendar(context.TODO(), kubeClients.Reader, vpaResource.Namespace, scfg.GetLocation())
+	now := time.Now()
+	if allowed, reason := cal.Check(now); !allowed {
+		if cal.Action == calendar.FreezeActionDefer {
+			if next, ok := cal.NextAllowed(now); ok {
+				reporting.ReportDeferred(key, reason, next)
+				enqueueVPA(kubeClients, vpaResource, scfg, next.Sub(now))
+				return nil
+			}
+		}
+		reporting.ReportSkipped(key, reason)
+		return nil
+	}
+
+	// the ownership is checked again at apply time, the ring may have changed since the run was queued, and an expired
+	// Lease lets another replica take over the workload before this replica refreshes its ring
+	if !Sharder.OwnsObject(vpaResource) || !Sharder.HoldsLease() {
+		klog.Infof("Skipping run for %s, moved to another shard", key)
+		return nil
+	}
+
+	klog.Infof("Applying VPA recommendations for %s with cron: %s", key, scfg.GetCronSpec())
+	targetRef := vpaResource.Spec.TargetRef
+	err := target.ApplyVPARecommendations(kubeClients, vpaResource, scfg)
+	if err != nil {
+		// the failure of the run is recorded once the queue gives up retrying it
+		klog.Errorf("Error applying VPA recommendations: %s", err.Error())
+		return err
+	}
+	RunStates.SetLastSuccess(context.TODO(), kubeClients.Local.Clientset, key, time.Now())
+	reporting.ReportSucceeded(key)
+	resourcesconfig.UpdateApplyStatus(context.TODO(), kubeClients, vpaResource.Namespace, targetRef.Kind, targetRef.Name, 0, nil)
+	return nil
+}
+
+// recordFailedRun records and reports a run which failed on all its attempts
+func recordFailedRun(kubeClients *client.KubeClients, vpaResource *vpa.VerticalPodAutoscaler, scfg *config.StrategyConfig, err error) {
+	targetRef := vpaResource.Spec.TargetRef
+	failures := RunStates.RecordFailure(context.TODO(), kubeClients.Local.Clientset, scfg.Key, err)
+	reporting.ReportFailure(scfg.Key, failures, err)
+	resourcesconfig.UpdateApplyStatus(context.TODO(), kubeClients, vpaResource.Namespace, targetRef.Kind, targetRef.Name, failures, err)
 }
@@ -2,100 +2,235 @@ package watcher
 
 import (
 	"context"
+	"reflect"
 	"strings"
-	"time"
 
 	"github.com/SocialGouv/oblik/pkg/client"
+	"github.com/SocialGouv/oblik/pkg/config"
 	"github.com/SocialGouv/oblik/pkg/constants"
+	"github.com/SocialGouv/oblik/pkg/target"
 	ovpa "github.com/SocialGouv/oblik/pkg/vpa"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/watch"
-	vpaclientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/types"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
-func WatchWorkloads(ctx context.Context, kubeClients *client.KubeClients) {
-	clientset := kubeClients.Clientset
-	dynamicClient := kubeClients.DynamicClient
-	vpaClientset := kubeClients.VpaClientset
-	config := kubeClients.RestConfig
-
-	labelSelector := labels.SelectorFromSet(labels.Set{constants.PREFIX + "enabled": "true"})
+var (
+	enabledSelector = labels.SelectorFromSet(labels.Set{constants.PREFIX + "enabled": "true"})
+	// with the namespace enablement, the workloads can be enabled by the label of their namespace, only the ones opted
+	// out are left out of the cache
+	notDisabledSelector, _ = labels.Parse(constants.PREFIX + "enabled!=false")
+)
 
-	deploymentWatcher := createWatcher(ctx, clientset, dynamicClient, vpaClientset,
-		cache.NewFilteredListWatchFromClient(clientset.AppsV1().RESTClient(), "deployments", corev1.NamespaceAll, func(options *metav1.ListOptions) {
-			options.LabelSelector = labelSelector.String()
-		}),
-		&appsv1.Deployment{})
+// WorkloadType is a workload kind supported by Oblik, with constructors of its objects and lists
+type WorkloadType struct {
+	NewObject func() ctrlclient.Object
+	NewList   func() ctrlclient.ObjectList
+}
 
-	statefulSetWatcher := createWatcher(ctx, clientset, dynamicClient, vpaClientset,
-		cache.NewFilteredListWatchFromClient(clientset.AppsV1().RESTClient(), "statefulsets", corev1.NamespaceAll, func(options *metav1.ListOptions) {
-			options.LabelSelector = labelSelector.String()
-		}),
-		&appsv1.StatefulSet{})
+// WorkloadReconciler reconciles the VPA of the enabled workloads of a kind
+type WorkloadReconciler struct {
+	KubeClients *client.KubeClients
+	Kind        string
+	WorkloadType
+}
 
-	cronJobWatcher := createWatcher(ctx, clientset, dynamicClient, vpaClientset,
-		cache.NewFilteredListWatchFromClient(clientset.BatchV1().RESTClient(), "cronjobs", corev1.NamespaceAll, func(options *metav1.ListOptions) {
-			options.LabelSelector = labelSelector.String()
-		}),
-		&batchv1.CronJob{})
+func (r *WorkloadReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	if !Sharder.Owns(req.String()) {
+		return reconcile.Result{}, nil
+	}
+	obj := r.NewObject()
+	err := r.KubeClients.Reader.Get(ctx, req.NamespacedName, obj)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// the workload is deleted, or opted out and then out of the cache
+			return reconcile.Result{}, ovpa.DeleteWorkloadVPA(r.KubeClients, req.Namespace, r.Kind, req.Name)
+		}
+		return reconcile.Result{}, err
+	}
+	if !target.IsWorkloadEnabled(ctx, r.KubeClients, obj) {
+		return reconcile.Result{}, ovpa.DeleteWorkloadVPA(r.KubeClients, req.Namespace, r.Kind, req.Name)
+	}
+	return reconcile.Result{}, ovpa.ReconcileVPA(r.KubeClients, obj)
+}
 
-	daemonSetWatcher := createWatcher(ctx, clientset, dynamicClient, vpaClientset,
-		cache.NewFilteredListWatchFromClient(clientset.AppsV1().RESTClient(), "daemonsets", corev1.NamespaceAll, func(options *metav1.ListOptions) {
-			options.LabelSelector = labelSelector.String()
-		}),
-		&appsv1.DaemonSet{})
+// mapNamespace reconciles the workloads of a namespace whose labels changed, which may enable or disable them
+func (r *WorkloadReconciler) mapNamespace(ctx context.Context, namespace ctrlclient.Object) []reconcile.Request {
+	list := r.NewList()
+	if err := r.KubeClients.Reader.List(ctx, list, ctrlclient.InNamespace(namespace.GetName())); err != nil {
+		klog.Errorf("Error listing %s in namespace %s: %s", r.Kind, namespace.GetName(), err.Error())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	_ = meta.EachListItem(list, func(obj runtime.Object) error {
+		if workload, ok := obj.(metav1.Object); ok && Sharder.OwnsObject(workload) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: workload.GetNamespace(), Name: workload.GetName()}})
+		}
+		return nil
+	})
+	return requests
+}
 
-	klog.Info("Starting Workloads watchers...")
-	go deploymentWatcher.Run(ctx.Done())
-	go statefulSetWatcher.Run(ctx.Done())
-	go cronJobWatcher.Run(ctx.Done())
-	go daemonSetWatcher.Run(ctx.Done())
+// GetWorkloadTypes returns the workload kinds supported by Oblik.
+// The CNPG clusters are read as unstructured objects, only when their CRD is installed
+func GetWorkloadTypes(ctx context.Context, kubeClients *client.KubeClients) map[string]WorkloadType {
+	workloadTypes := map[string]WorkloadType{
+		"Deployment": {
+			NewObject: func() ctrlclient.Object { return &appsv1.Deployment{} },
+			NewList:   func() ctrlclient.ObjectList { return &appsv1.DeploymentList{} },
+		},
+		"StatefulSet": {
+			NewObject: func() ctrlclient.Object { return &appsv1.StatefulSet{} },
+			NewList:   func() ctrlclient.ObjectList { return &appsv1.StatefulSetList{} },
+		},
+		"DaemonSet": {
+			NewObject: func() ctrlclient.Object { return &appsv1.DaemonSet{} },
+			NewList:   func() ctrlclient.ObjectList { return &appsv1.DaemonSetList{} },
+		},
+		"CronJob": {
+			NewObject: func() ctrlclient.Object { return &batchv1.CronJob{} },
+			NewList:   func() ctrlclient.ObjectList { return &batchv1.CronJobList{} },
+		},
+	}
 
-	// Check if CNPG CRD exists before creating the watcher
-	cnpgCRDExists, err := checkCRDExists(ctx, config, "clusters.postgresql.cnpg.io")
+	cnpgCRDExists, err := checkCRDExists(ctx, kubeClients.RestConfig, "clusters.postgresql.cnpg.io")
 	if err != nil {
 		klog.Errorf("Error checking CNPG CRD: %v", err)
 	} else if cnpgCRDExists {
-		cnpgClusterWatcher := createWatcher(ctx, clientset, dynamicClient, vpaClientset,
-			&cache.ListWatch{
-				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-					options.LabelSelector = labelSelector.String()
-					return dynamicClient.Resource(schema.GroupVersionResource{
-						Group:    "postgresql.cnpg.io",
-						Version:  "v1",
-						Resource: "clusters",
-					}).Namespace(corev1.NamespaceAll).List(ctx, options)
-				},
-				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-					options.LabelSelector = labelSelector.String()
-					return dynamicClient.Resource(schema.GroupVersionResource{
-						Group:    "postgresql.cnpg.io",
-						Version:  "v1",
-						Resource: "clusters",
-					}).Namespace(corev1.NamespaceAll).Watch(ctx, options)
-				},
+		workloadTypes["Cluster"] = WorkloadType{
+			NewObject: func() ctrlclient.Object {
+				cluster := &unstructured.Unstructured{}
+				cluster.SetGroupVersionKind(schema.GroupVersionKind{Group: "postgresql.cnpg.io", Version: "v1", Kind: "Cluster"})
+				return cluster
 			},
-			&unstructured.Unstructured{})
-		go cnpgClusterWatcher.Run(ctx.Done())
-		klog.Info("CNPG Cluster watcher started")
+			NewList: func() ctrlclient.ObjectList {
+				clusters := &unstructured.UnstructuredList{}
+				clusters.SetGroupVersionKind(schema.GroupVersionKind{Group: "postgresql.cnpg.io", Version: "v1", Kind: "ClusterList"})
+				return clusters
+			},
+		}
 	} else {
 		klog.Info("CNPG CRD not found, skipping CNPG Cluster watcher")
 	}
+	return workloadTypes
+}
 
-	<-ctx.Done()
+// GetCacheByObject restricts the shared cache to the enabled workloads and the VPAs managed by Oblik. With the
+// namespace enablement, the cache holds all the workloads not opted out, whatever their namespace. The pods, only
+// cached once the ephemeral-storage usage is read, are trimmed down to the fields it needs
+func GetCacheByObject(workloadTypes map[string]WorkloadType) map[ctrlclient.Object]cache.ByObject {
+	byObject := map[ctrlclient.Object]cache.ByObject{
+		&vpa.VerticalPodAutoscaler{}: {Label: enabledSelector},
+		&corev1.Pod{}:                {Transform: trimPod},
+	}
+	workloadSelector := enabledSelector
+	if config.IsNamespaceEnablementEnabled() {
+		workloadSelector = notDisabledSelector
+	}
+	for _, workloadType := range workloadTypes {
+		byObject[workloadType.NewObject()] = cache.ByObject{Label: workloadSelector}
+	}
+	return byObject
+}
+
+// trimPod keeps the name, the labels and the node of the pods in the cache
+func trimPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+			Labels:          pod.Labels,
+		},
+		Spec: corev1.PodSpec{NodeName: pod.Spec.NodeName},
+	}, nil
+}
+
+// SetupWorkloadReconcilers registers a reconciler for each workload kind of a cluster, watching its cache. The VPAs
+// are owned by their workload, so that a change or a deletion of a VPA reconciles it again, and the changes of the
+// namespace labels reconcile all the workloads of the namespace. A drift reconciler of the kind checks the updates
+// changing the resources of the workloads. With sharding, each replica only reconciles its own workloads
+func SetupWorkloadReconcilers(mgr ctrl.Manager, cl cluster.Cluster, kubeClients *client.KubeClients, workloadTypes map[string]WorkloadType) error {
+	// status updates, e.g. during rollouts or from the VPA recommender, don't change the VPA
+	changed := predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{})
+	// the workloads are already reconciled on startup, only the label updates of the namespaces matter
+	namespaceLabelChanged := predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+		},
+	}
+	owned := Sharder.Predicate()
+	for kind, workloadType := range workloadTypes {
+		reconciler := &WorkloadReconciler{
+			KubeClients:  kubeClients,
+			Kind:         kind,
+			WorkloadType: workloadType,
+		}
+		ownerHandler := handler.EnqueueRequestForOwner(cl.GetScheme(), cl.GetRESTMapper(), workloadType.NewObject(), handler.OnlyControllerOwner())
+		err := ctrl.NewControllerManagedBy(mgr).
+			Named(getControllerName(strings.ToLower(kind), kubeClients)).
+			WatchesRawSource(source.Kind(cl.GetCache(), workloadType.NewObject(), &handler.EnqueueRequestForObject{}, owned, changed)).
+			WatchesRawSource(source.Kind(cl.GetCache(), ctrlclient.Object(&vpa.VerticalPodAutoscaler{}), ownerHandler, owned, changed)).
+			WatchesRawSource(source.Kind(cl.GetCache(), ctrlclient.Object(&corev1.Namespace{}), handler.EnqueueRequestsFromMapFunc(reconciler.mapNamespace), namespaceLabelChanged)).
+			WatchesRawSource(getRebalanceSource(kubeClients, workloadType.NewList)).
+			WithOptions(getControllerOptions()).
+			Complete(reconciler)
+		if err != nil {
+			return err
+		}
+		err = ctrl.NewControllerManagedBy(mgr).
+			Named(getControllerName(strings.ToLower(kind)+"-drift", kubeClients)).
+			WatchesRawSource(source.Kind(cl.GetCache(), workloadType.NewObject(), &handler.EnqueueRequestForObject{}, owned, resourcesChanged)).
+			WithOptions(getControllerOptions()).
+			Complete(&DriftReconciler{
+				KubeClients:  kubeClients,
+				Kind:         kind,
+				WorkloadType: workloadType,
+			})
+		if err != nil {
+			return err
+		}
+		klog.Infof("%s reconciler registered", getControllerName(kind, kubeClients))
+	}
+	return nil
+}
+
+// getControllerName qualifies the name of a controller with its cluster, the controllers of all the clusters
+// running in the same manager
+func getControllerName(name string, kubeClients *client.KubeClients) string {
+	if kubeClients.IsLocal() {
+		return name
+	}
+	return name + "-" + kubeClients.Cluster
 }
 
 func checkCRDExists(ctx context.Context, config *rest.Config, crdName string) (bool, error) {
@@ -113,23 +248,3 @@ func checkCRDExists(ctx context.Context, config *rest.Config, crdName string) (b
 	}
 	return true, nil
 }
-
-func createWatcher(ctx context.Context, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, vpaClientset *vpaclientset.Clientset, lw cache.ListerWatcher, objType runtime.Object) cache.Controller {
-	_, controller := cache.NewInformer(
-		lw,
-		objType,
-		time.Second*0,
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				ovpa.AddVPA(clientset, dynamicClient, vpaClientset, obj)
-			},
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				ovpa.UpdateVPA(clientset, dynamicClient, vpaClientset, newObj)
-			},
-			DeleteFunc: func(obj interface{}) {
-				ovpa.DeleteVPA(vpaClientset, obj)
-			},
-		},
-	)
-	return controller
-}